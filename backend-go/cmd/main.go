@@ -5,6 +5,7 @@ import (
 
 	"github.com/gonext-tech/invoicing-system/backend/database"
 	"github.com/gonext-tech/invoicing-system/backend/routes"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -15,6 +16,8 @@ func main() {
 		fmt.Println("Error loading .env file")
 	}
 	e := echo.New()
+	// Client addresses come from the connection unless TRUSTED_PROXIES is set
+	e.IPExtractor = routes.ClientIPExtractor()
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{
 			"http://localhost:3000",
@@ -55,6 +58,9 @@ func main() {
 	}))
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
+	if err := services.LoadJWTKeys(); err != nil {
+		e.Logger.Fatal(err)
+	}
 	db, err := database.DBInit()
 	if err != nil {
 		e.Logger.Fatal(err)
@@ -66,6 +72,7 @@ func main() {
 	}
 
 	routes.SetupRoutes(e, db)
+	services.NewWebhookDispatcher(db).Start()
 	// cron.StartCron(db)
 	e.Logger.Fatal(e.Start(":9001"))
 }
@@ -0,0 +1,395 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type actorContextKey struct{}
+
+// Actor is who a request's changes are attributed to in the audit log
+type Actor struct {
+	UserID    uint
+	APIKeyID  *uint
+	IPAddress string
+}
+
+// WithActor returns a context whose GORM statements are audited as made by actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor
+func ActorFromContext(ctx context.Context) (Actor, bool) {
+	if ctx == nil {
+		return Actor{}, false
+	}
+	actor, ok := ctx.Value(actorContextKey{}).(Actor)
+	return actor, ok
+}
+
+// auditedModels are the business tables whose every change is written to audit_logs
+var auditedModels = []interface{}{
+	&models.SalesInvoice{},
+	&models.SalesInvoiceItem{},
+	&models.PurchaseInvoice{},
+	&models.PurchaseInvoiceItem{},
+	&models.CreditNote{},
+	&models.CreditNoteItem{},
+	&models.Payment{},
+	&models.PaymentAllocation{},
+	&models.Stock{},
+	&models.Product{},
+	&models.ProductUnit{},
+	&models.Customer{},
+	&models.Vendor{},
+	&models.User{},
+	&models.Role{},
+	&models.ApprovalRule{},
+	&models.PriceList{},
+	&models.PriceListItem{},
+	&models.Promotion{},
+	&models.PromotionItem{},
+}
+
+var (
+	// auditSkipped columns change on every write and say nothing
+	auditSkipped = map[string]bool{"created_at": true, "updated_at": true}
+	// auditRedacted columns are logged as changed, without their values
+	auditRedacted = map[string]bool{"password": true}
+)
+
+const (
+	auditRedactedValue = "[redacted]"
+	// auditRowLimit caps the rows one update or delete statement is audited for
+	auditRowLimit  = 1000
+	auditBeforeKey = "audit:before"
+)
+
+// RegisterAuditCallbacks writes an audit log entry for every create, update and delete
+// on an audited table, attributed to the actor of the statement's context (see
+// WithActor). Updates and deletes read the affected rows before and after, so the entry
+// holds a column level diff. The entries are written in the statement's transaction and
+// a failure to write them fails the statement. Register after RegisterTenantCallbacks so
+// the rows read are limited to the company. Raw SQL is not audited; use RecordChange.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	tables := map[string]bool{}
+	for _, model := range auditedModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		tables[stmt.Schema.Table] = true
+	}
+	audit := &auditCallbacks{tables: tables}
+
+	if err := db.Callback().Update().Before("gorm:update").After("tenant:update").
+		Register("audit:before_update", audit.captureBefore); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("audit:update", audit.recordUpdate); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").After("tenant:delete").
+		Register("audit:before_delete", audit.captureBefore); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register("audit:delete", audit.recordDelete); err != nil {
+		return err
+	}
+	return db.Callback().Create().After("gorm:create").Register("audit:create", audit.recordCreate)
+}
+
+// RecordChange writes an audit entry for a change GORM's callbacks can't see, such as
+// one made with raw SQL
+func RecordChange(db *gorm.DB, entity string, entityID uint, action string, changes map[string]models.AuditChange) error {
+	entry := newAuditLog(db, entity, entityID, action, changes)
+	if companyID, ok := CompanyFromContext(db.Statement.Context); ok {
+		entry.CompanyID = companyID
+	}
+	return db.Session(&gorm.Session{NewDB: true}).Create(&entry).Error
+}
+
+type auditCallbacks struct {
+	tables map[string]bool
+}
+
+func (a *auditCallbacks) audited(db *gorm.DB) bool {
+	stmt := db.Statement
+	return db.Error == nil && stmt.Schema != nil && stmt.Schema.PrioritizedPrimaryField != nil &&
+		a.tables[stmt.Table] && !stmt.DryRun
+}
+
+// session runs the audit's own statements in the same transaction and company
+func (a *auditCallbacks) session(db *gorm.DB) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true})
+}
+
+// captureBefore reads the rows an update or delete is about to change
+func (a *auditCallbacks) captureBefore(db *gorm.DB) {
+	if !a.audited(db) || !hasConditions(db) {
+		return
+	}
+	stmt := db.Statement
+	query := a.session(db).Model(reflect.New(stmt.Schema.ModelType).Interface())
+	if where, ok := stmt.Clauses["WHERE"]; ok {
+		query = query.Clauses(where.Expression)
+	}
+	if ids := primaryKeys(stmt, stmt.ReflectValue); len(ids) > 0 {
+		query = query.Where(clause.IN{Column: clause.Column{Table: stmt.Table, Name: stmt.Schema.PrioritizedPrimaryField.DBName}, Values: ids})
+	}
+
+	rows := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	if err := query.Limit(auditRowLimit).Find(rows.Interface()).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(auditBeforeKey, rows.Elem())
+}
+
+func (a *auditCallbacks) before(db *gorm.DB) (reflect.Value, bool) {
+	value, ok := db.InstanceGet(auditBeforeKey)
+	if !ok || db.Error != nil || db.RowsAffected == 0 {
+		return reflect.Value{}, false
+	}
+	rows := value.(reflect.Value)
+	return rows, rows.Len() > 0
+}
+
+func (a *auditCallbacks) recordUpdate(db *gorm.DB) {
+	before, ok := a.before(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+
+	after := reflect.New(reflect.SliceOf(stmt.Schema.ModelType))
+	if err := a.session(db).Model(reflect.New(stmt.Schema.ModelType).Interface()).
+		Where(clause.IN{Column: clause.Column{Table: stmt.Table, Name: primaryKey.DBName}, Values: primaryKeys(stmt, before)}).
+		Find(after.Interface()).Error; err != nil {
+		db.AddError(err)
+		return
+	}
+	afterByID := map[uint]reflect.Value{}
+	for i := 0; i < after.Elem().Len(); i++ {
+		row := after.Elem().Index(i)
+		afterByID[entityID(stmt, row)] = row
+	}
+
+	var entries []models.AuditLog
+	for i := 0; i < before.Len(); i++ {
+		old := before.Index(i)
+		id := entityID(stmt, old)
+		current, ok := afterByID[id]
+		if !ok {
+			continue
+		}
+		if changes := diffRows(stmt, old, current); len(changes) > 0 {
+			entries = append(entries, a.entry(db, old, id, "update", changes))
+		}
+	}
+	a.write(db, entries)
+}
+
+func (a *auditCallbacks) recordDelete(db *gorm.DB) {
+	before, ok := a.before(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	entries := make([]models.AuditLog, 0, before.Len())
+	for i := 0; i < before.Len(); i++ {
+		row := before.Index(i)
+		entries = append(entries, a.entry(db, row, entityID(stmt, row), "delete", diffRows(stmt, row, reflect.Value{})))
+	}
+	a.write(db, entries)
+}
+
+func (a *auditCallbacks) recordCreate(db *gorm.DB) {
+	if !a.audited(db) {
+		return
+	}
+	stmt := db.Statement
+	var entries []models.AuditLog
+	add := func(row reflect.Value) {
+		if row.Kind() == reflect.Struct {
+			entries = append(entries, a.entry(db, row, entityID(stmt, row), "create", diffRows(stmt, reflect.Value{}, row)))
+		}
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			add(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		add(stmt.ReflectValue)
+	case reflect.Map:
+		// Created from a map: log the given columns
+		if values, ok := stmt.Dest.(map[string]interface{}); ok {
+			changes := map[string]models.AuditChange{}
+			for column, value := range values {
+				if auditRedacted[column] {
+					value = auditRedactedValue
+				}
+				changes[column] = models.AuditChange{New: value}
+			}
+			entry := a.entry(db, reflect.Value{}, toUint(values[stmt.Schema.PrioritizedPrimaryField.DBName]), "create", changes)
+			entries = append(entries, entry)
+		}
+	}
+	a.write(db, entries)
+}
+
+// entry builds a log entry for row, in the row's company
+func (a *auditCallbacks) entry(db *gorm.DB, row reflect.Value, id uint, action string, changes map[string]models.AuditChange) models.AuditLog {
+	entry := newAuditLog(db, db.Statement.Table, id, action, changes)
+	if companyID, ok := CompanyFromContext(db.Statement.Context); ok {
+		entry.CompanyID = companyID
+	}
+	if row.IsValid() {
+		if field := db.Statement.Schema.LookUpField("CompanyID"); field != nil {
+			if value, isZero := field.ValueOf(db.Statement.Context, row); !isZero {
+				entry.CompanyID = toUint(value)
+			}
+		}
+	}
+	return entry
+}
+
+func (a *auditCallbacks) write(db *gorm.DB, entries []models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := a.session(db).Create(&entries).Error; err != nil {
+		db.AddError(err)
+	}
+}
+
+func newAuditLog(db *gorm.DB, entity string, entityID uint, action string, changes map[string]models.AuditChange) models.AuditLog {
+	entry := models.AuditLog{
+		Entity:    entity,
+		EntityID:  entityID,
+		Action:    action,
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	}
+	if actor, ok := ActorFromContext(db.Statement.Context); ok {
+		if actor.UserID != 0 {
+			userID := actor.UserID
+			entry.UserID = &userID
+		}
+		entry.APIKeyID = actor.APIKeyID
+		entry.IPAddress = actor.IPAddress
+	}
+	return entry
+}
+
+// diffRows compares two versions of a row column by column. A missing old row is a
+// create and a missing new row a delete; then every non-empty column is listed.
+func diffRows(stmt *gorm.Statement, old, current reflect.Value) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for _, column := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[column]
+		if field == nil || auditSkipped[column] {
+			continue
+		}
+		oldValue, oldZero := fieldValue(stmt, field, old)
+		newValue, newZero := fieldValue(stmt, field, current)
+		if old.IsValid() && current.IsValid() {
+			if equalValues(oldValue, newValue) {
+				continue
+			}
+		} else if oldZero && newZero {
+			continue
+		}
+
+		if auditRedacted[column] {
+			changes[column] = models.AuditChange{Old: redact(old), New: redact(current)}
+			continue
+		}
+		changes[column] = models.AuditChange{Old: oldValue, New: newValue}
+	}
+	return changes
+}
+
+// fieldValue returns the column's value with pointers dereferenced; nil when empty
+func fieldValue(stmt *gorm.Statement, field *schema.Field, row reflect.Value) (interface{}, bool) {
+	if !row.IsValid() {
+		return nil, true
+	}
+	value, isZero := field.ValueOf(stmt.Context, row)
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, true
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, true
+	}
+	return rv.Interface(), isZero
+}
+
+func equalValues(a, b interface{}) bool {
+	if at, ok := a.(time.Time); ok {
+		bt, ok := b.(time.Time)
+		return ok && at.Equal(bt)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func redact(row reflect.Value) interface{} {
+	if !row.IsValid() {
+		return nil
+	}
+	return auditRedactedValue
+}
+
+func primaryKeys(stmt *gorm.Statement, rows reflect.Value) []interface{} {
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+	var ids []interface{}
+	collect := func(row reflect.Value) {
+		if row.Kind() != reflect.Struct {
+			return
+		}
+		if id, isZero := primaryKey.ValueOf(stmt.Context, row); !isZero {
+			ids = append(ids, id)
+		}
+	}
+	switch rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			collect(reflect.Indirect(rows.Index(i)))
+		}
+	case reflect.Struct:
+		collect(rows)
+	}
+	return ids
+}
+
+func entityID(stmt *gorm.Statement, row reflect.Value) uint {
+	id, _ := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, row)
+	return toUint(id)
+}
+
+func toUint(value interface{}) uint {
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uint(rv.Uint())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() > 0 {
+			return uint(rv.Int())
+		}
+	}
+	return 0
+}
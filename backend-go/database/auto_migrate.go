@@ -1,6 +1,7 @@
 package database
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
@@ -11,13 +12,31 @@ import (
 func AutoMigrate(db *gorm.DB) error {
 	log.Println("Starting automatic database migration...")
 
+	// Document numbers, SKUs and ledger codes are now unique per company
+	dropGlobalUniqueIndexes(db)
+
 	// List of all models to migrate
 	err := db.AutoMigrate(
+		// Companies (tenants)
+		&models.Company{},
+
 		// User and Auth
 		&models.User{},
+		&models.UserSession{},
+		&models.LoginThrottle{},
+		&models.PasswordResetToken{},
+		&models.UserTwoFactor{},
+		&models.UserBackupCode{},
+		&models.Role{},
+		&models.Permission{},
+		&models.UserRole{},
+		&models.UserLocation{},
+		&models.APIKey{},
+		&models.AuditLog{},
 
 		// Products
 		&models.Product{},
+		&models.ProductUnit{},
 		&models.Category{},
 		&models.ProductType{},
 		&models.ProductBrand{},
@@ -44,6 +63,31 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.Payment{},
 		&models.PaymentAllocation{},
 
+		// Cash boxes, bank accounts and reconciliation
+		&models.MoneyAccount{},
+		&models.MoneyAccountTransaction{},
+		&models.MoneyTransfer{},
+		&models.BankStatementImport{},
+		&models.BankStatementLine{},
+
+		// Van end-of-day settlements
+		&models.VanSettlement{},
+		&models.VanSettlementMethodTotal{},
+		&models.VanSettlementDenomination{},
+		&models.DriverCashVariance{},
+
+		// General ledger
+		&models.LedgerAccount{},
+		&models.LedgerAccountMapping{},
+		&models.JournalEntry{},
+		&models.JournalLine{},
+		&models.AccountingPeriod{},
+
+		// Expenses
+		&models.ExpenseCategory{},
+		&models.Expense{},
+		&models.ExpenseAttachment{},
+
 		// Credit Notes
 		&models.CreditNote{},
 		&models.CreditNoteItem{},
@@ -51,6 +95,30 @@ func AutoMigrate(db *gorm.DB) error {
 		// Transfers
 		&models.Transfer{},
 		&models.TransferItem{},
+
+		// Approval workflows
+		&models.ApprovalRule{},
+		&models.ApprovalRequest{},
+		&models.ApprovalDecision{},
+
+		// Notifications
+		&models.Notification{},
+		&models.NotificationPreference{},
+
+		// Outbound webhooks
+		&models.WebhookSubscription{},
+		&models.OutboxEvent{},
+		&models.WebhookDelivery{},
+
+		// Offline sync
+		&models.SyncRecord{},
+		&models.SyncLog{},
+
+		// Pricing
+		&models.PriceList{},
+		&models.PriceListItem{},
+		&models.Promotion{},
+		&models.PromotionItem{},
 	)
 
 	if err != nil {
@@ -58,6 +126,11 @@ func AutoMigrate(db *gorm.DB) error {
 		return err
 	}
 
+	if err := backfillCompanies(db); err != nil {
+		log.Printf("Error assigning records to companies: %v", err)
+		return err
+	}
+
 	// Fix floating-point precision issues in existing invoices
 	log.Println("Fixing floating-point precision issues in invoices...")
 
@@ -141,6 +214,82 @@ func AutoMigrate(db *gorm.DB) error {
 	return nil
 }
 
+// dropGlobalUniqueIndexes removes the single-column unique indexes that were replaced by
+// (company_id, column) ones. The names depend on the GORM version that created them.
+func dropGlobalUniqueIndexes(db *gorm.DB) {
+	indexes := []struct {
+		model  interface{}
+		table  string
+		column string
+	}{
+		{&models.Product{}, "products", "sku"},
+		{&models.SalesInvoice{}, "sales_invoices", "invoice_number"},
+		{&models.PurchaseInvoice{}, "purchase_invoices", "invoice_number"},
+		{&models.CreditNote{}, "credit_notes", "credit_note_number"},
+		{&models.LedgerAccount{}, "ledger_accounts", "code"},
+		{&models.LedgerAccountMapping{}, "ledger_account_mappings", "key"},
+		{&models.JournalEntry{}, "journal_entries", "entry_number"},
+	}
+
+	migrator := db.Migrator()
+	for _, index := range indexes {
+		if !migrator.HasTable(index.model) {
+			continue
+		}
+		for _, name := range []string{
+			index.column,
+			fmt.Sprintf("idx_%s_%s", index.table, index.column),
+			fmt.Sprintf("uni_%s_%s", index.table, index.column),
+		} {
+			if !migrator.HasIndex(index.model, name) {
+				continue
+			}
+			if err := migrator.DropIndex(index.model, name); err != nil {
+				log.Printf("Warning: Could not drop index %s on %s: %v", name, index.table, err)
+			} else {
+				log.Printf("Dropped global unique index %s on %s", name, index.table)
+			}
+		}
+	}
+}
+
+// backfillCompanies creates the companies users already refer to and moves records from
+// before multi-tenancy (company_id 0) into the oldest company
+func backfillCompanies(db *gorm.DB) error {
+	var companyIDs []uint
+	if err := db.Model(&models.User{}).Where("company_id <> 0").Distinct().Pluck("company_id", &companyIDs).Error; err != nil {
+		return err
+	}
+	for _, companyID := range companyIDs {
+		company := models.Company{ID: companyID, Name: fmt.Sprintf("Company %d", companyID), IsActive: true}
+		if err := db.Where("id = ?", companyID).FirstOrCreate(&company).Error; err != nil {
+			return err
+		}
+	}
+
+	var defaultCompany models.Company
+	if err := db.Order("id ASC").Limit(1).Find(&defaultCompany).Error; err != nil {
+		return err
+	}
+	if defaultCompany.ID == 0 {
+		defaultCompany = models.Company{Name: "Default Company", IsActive: true}
+		if err := db.Create(&defaultCompany).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, model := range tenantModels {
+		result := db.Model(model).Where("company_id = 0").UpdateColumn("company_id", defaultCompany.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("Assigned %d %T records to company %d", result.RowsAffected, model, defaultCompany.ID)
+		}
+	}
+	return nil
+}
+
 // MigrateWithData runs migrations and seeds initial data if needed
 func MigrateWithData(db *gorm.DB) error {
 	// Run auto migration first
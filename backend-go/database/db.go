@@ -19,8 +19,15 @@ func DBInit() (d *gorm.DB, err error) {
 	if database == nil {
 		log.Panic("Can't connect to Postgres!")
 	}
+	if err := RegisterTenantCallbacks(database); err != nil {
+		return nil, err
+	}
+	if err := RegisterAuditCallbacks(database); err != nil {
+		return nil, err
+	}
 	database.AutoMigrate(
 		// Core models
+		models.Company{},
 		models.User{},
 		models.Category{},
 		models.ProductType{},
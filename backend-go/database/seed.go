@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"log"
 	"time"
 
@@ -9,15 +10,25 @@ import (
 	"gorm.io/gorm"
 )
 
+// seedCompanyID is the company all seed data belongs to
+const seedCompanyID = 1001
+
 // SeedDatabase seeds the database with initial data
 func SeedDatabase(db *gorm.DB) error {
 	log.Println("Starting database seeding...")
 
 	// Seed in order of dependencies
+	if err := seedCompany(db); err != nil {
+		return err
+	}
+
 	if err := seedUsers(db); err != nil {
 		return err
 	}
 
+	// Everything else belongs to the seed company
+	db = db.WithContext(WithCompany(context.Background(), seedCompanyID))
+
 	if err := seedCategories(db); err != nil {
 		return err
 	}
@@ -66,6 +77,13 @@ func SeedDatabase(db *gorm.DB) error {
 	return nil
 }
 
+func seedCompany(db *gorm.DB) error {
+	log.Println("Seeding company...")
+
+	company := models.Company{ID: seedCompanyID, Name: "Diyaa Trading", IsActive: true}
+	return db.Where("id = ?", company.ID).FirstOrCreate(&company).Error
+}
+
 func seedUsers(db *gorm.DB) error {
 	log.Println("Seeding users...")
 
@@ -85,7 +103,7 @@ func seedUsers(db *gorm.DB) error {
 	users := []models.User{
 		{
 			Email:     "admin@diyaa.com",
-			CompanyID: 1001,
+			CompanyID: seedCompanyID,
 			FirstName: "Admin",
 			LastName:  "User",
 			Phone:     "+1234567890",
@@ -97,7 +115,7 @@ func seedUsers(db *gorm.DB) error {
 		},
 		{
 			Email:     "manager@diyaa.com",
-			CompanyID: 1002,
+			CompanyID: seedCompanyID,
 			FirstName: "Manager",
 			LastName:  "User",
 			Phone:     "+1234567891",
@@ -109,7 +127,7 @@ func seedUsers(db *gorm.DB) error {
 		},
 		{
 			Email:     "user@diyaa.com",
-			CompanyID: 1003,
+			CompanyID: seedCompanyID,
 			FirstName: "Regular",
 			LastName:  "User",
 			Phone:     "+1234567892",
@@ -0,0 +1,281 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOtherCompany is returned when a save would overwrite a record of another company
+var ErrOtherCompany = errors.New("record belongs to another company")
+
+type companyContextKey struct{}
+
+// WithCompany returns a context that limits every GORM statement run with it to one company
+func WithCompany(ctx context.Context, companyID uint) context.Context {
+	return context.WithValue(ctx, companyContextKey{}, companyID)
+}
+
+// CompanyFromContext returns the company set by WithCompany
+func CompanyFromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	companyID, ok := ctx.Value(companyContextKey{}).(uint)
+	return companyID, ok && companyID != 0
+}
+
+// tenantModels are the tables that belong to a company. Each has a company_id column.
+var tenantModels = []interface{}{
+	&models.User{},
+	&models.Role{},
+	&models.Supplier{},
+	&models.Product{},
+	&models.ProductUnit{},
+	&models.Category{},
+	&models.ProductType{},
+	&models.ProductBrand{},
+	&models.Location{},
+	&models.Van{},
+	&models.Employee{},
+	&models.Customer{},
+	&models.Vendor{},
+	&models.Stock{},
+	&models.StockMovement{},
+	&models.SalesInvoice{},
+	&models.SalesInvoiceItem{},
+	&models.PurchaseInvoice{},
+	&models.PurchaseInvoiceItem{},
+	&models.Payment{},
+	&models.PaymentAllocation{},
+	&models.MoneyAccount{},
+	&models.MoneyAccountTransaction{},
+	&models.MoneyTransfer{},
+	&models.BankStatementImport{},
+	&models.BankStatementLine{},
+	&models.VanSettlement{},
+	&models.VanSettlementMethodTotal{},
+	&models.VanSettlementDenomination{},
+	&models.DriverCashVariance{},
+	&models.LedgerAccount{},
+	&models.LedgerAccountMapping{},
+	&models.JournalEntry{},
+	&models.JournalLine{},
+	&models.AccountingPeriod{},
+	&models.ExpenseCategory{},
+	&models.Expense{},
+	&models.ExpenseAttachment{},
+	&models.CreditNote{},
+	&models.CreditNoteItem{},
+	&models.Transfer{},
+	&models.TransferItem{},
+	&models.APIKey{},
+	&models.AuditLog{},
+	&models.ApprovalRule{},
+	&models.ApprovalRequest{},
+	&models.ApprovalDecision{},
+	&models.Notification{},
+	&models.NotificationPreference{},
+	&models.WebhookSubscription{},
+	&models.OutboxEvent{},
+	&models.WebhookDelivery{},
+	&models.SyncRecord{},
+	&models.SyncLog{},
+	&models.PriceList{},
+	&models.PriceListItem{},
+	&models.Promotion{},
+	&models.PromotionItem{},
+}
+
+// RegisterTenantCallbacks makes GORM apply the company of the statement's context
+// (see WithCompany) to every query, update and delete on a tenant table and stamp it on
+// every created record. Statements without a company in their context, such as
+// migrations, seeding and login, are left alone. Raw SQL is never rewritten and has to
+// filter on company_id itself.
+func RegisterTenantCallbacks(db *gorm.DB) error {
+	tables := map[string]bool{}
+	for _, model := range tenantModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		tables[stmt.Schema.Table] = true
+	}
+	tenant := &tenantCallbacks{tables: tables}
+
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant:query", tenant.filter); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tenant:row", tenant.filter); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:update", tenant.update); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("tenant:delete", tenant.delete); err != nil {
+		return err
+	}
+	return db.Callback().Create().Before("gorm:create").Register("tenant:create", tenant.create)
+}
+
+type tenantCallbacks struct {
+	tables map[string]bool
+}
+
+// company returns the statement's company when it runs against a tenant table
+func (t *tenantCallbacks) company(db *gorm.DB) (uint, bool) {
+	if db.Error != nil || !t.tables[db.Statement.Table] {
+		return 0, false
+	}
+	return CompanyFromContext(db.Statement.Context)
+}
+
+func (t *tenantCallbacks) addCondition(db *gorm.DB, companyID uint) {
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: "company_id"}, Value: companyID},
+	}})
+}
+
+func (t *tenantCallbacks) filter(db *gorm.DB) {
+	if companyID, ok := t.company(db); ok {
+		t.addCondition(db, companyID)
+	}
+}
+
+func (t *tenantCallbacks) update(db *gorm.DB) {
+	companyID, ok := t.company(db)
+	if !ok {
+		return
+	}
+	// Save writes every column; keep the record in the company instead of resetting it to 0
+	t.stamp(db, companyID, false)
+	if hasConditions(db) {
+		t.addCondition(db, companyID)
+	}
+}
+
+func (t *tenantCallbacks) delete(db *gorm.DB) {
+	if companyID, ok := t.company(db); ok && hasConditions(db) {
+		t.addCondition(db, companyID)
+	}
+}
+
+func (t *tenantCallbacks) create(db *gorm.DB) {
+	companyID, ok := t.company(db)
+	if !ok {
+		return
+	}
+	// Save falls back to an upsert when its update matched nothing, which must not take
+	// over a row with the same ID in another company
+	if _, upsert := db.Statement.Clauses["ON CONFLICT"]; upsert {
+		if err := t.checkOwnership(db, companyID); err != nil {
+			db.AddError(err)
+			return
+		}
+	}
+	t.stamp(db, companyID, true)
+}
+
+func (t *tenantCallbacks) checkOwnership(db *gorm.DB, companyID uint) error {
+	stmt := db.Statement
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return nil
+	}
+	primaryKey := stmt.Schema.PrioritizedPrimaryField
+
+	ids := []interface{}{}
+	collect := func(record reflect.Value) {
+		if record.Kind() != reflect.Struct {
+			return
+		}
+		if id, isZero := primaryKey.ValueOf(stmt.Context, record); !isZero {
+			ids = append(ids, id)
+		}
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			collect(reflect.Indirect(stmt.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		collect(stmt.ReflectValue)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := db.Session(&gorm.Session{NewDB: true}).WithContext(context.Background()).
+		Table(stmt.Table).
+		Where(clause.IN{Column: clause.Column{Name: primaryKey.DBName}, Values: ids}).
+		Where("company_id <> ?", companyID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrOtherCompany
+	}
+	return nil
+}
+
+// stamp sets CompanyID on the statement's records, and on map values when creating
+func (t *tenantCallbacks) stamp(db *gorm.DB, companyID uint, creating bool) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return
+	}
+	field := stmt.Schema.LookUpField("CompanyID")
+	if field == nil {
+		return
+	}
+
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < stmt.ReflectValue.Len(); i++ {
+			if record := reflect.Indirect(stmt.ReflectValue.Index(i)); record.Kind() == reflect.Struct {
+				_ = field.Set(stmt.Context, record, companyID)
+			}
+		}
+	case reflect.Struct:
+		if stmt.ReflectValue.CanAddr() {
+			_ = field.Set(stmt.Context, stmt.ReflectValue, companyID)
+		}
+	}
+
+	if !creating {
+		return
+	}
+	switch values := stmt.Dest.(type) {
+	case map[string]interface{}:
+		values[field.DBName] = companyID
+	case []map[string]interface{}:
+		for _, value := range values {
+			value[field.DBName] = companyID
+		}
+	}
+}
+
+// hasConditions reports whether an update or delete is already limited to some rows.
+// Adding the company condition to an unconditioned statement would get it past GORM's
+// protection against accidental table-wide updates and deletes.
+func hasConditions(db *gorm.DB) bool {
+	stmt := db.Statement
+	if _, ok := stmt.Clauses["WHERE"]; ok || db.AllowGlobalUpdate {
+		return true
+	}
+	if stmt.Schema == nil || stmt.Schema.PrioritizedPrimaryField == nil {
+		return false
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		return stmt.ReflectValue.Len() > 0
+	case reflect.Struct:
+		_, isZero := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, stmt.ReflectValue)
+		return !isZero
+	}
+	return false
+}
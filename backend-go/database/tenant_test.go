@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	companyA uint = 7
+	companyB uint = 8
+)
+
+// newDryRunDB returns a database that builds SQL without a server, so the tests can
+// inspect exactly what each statement would send
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "test:test@tcp(127.0.0.1:3306)/test?parseTime=true",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("open dry run database: %v", err)
+	}
+	if err := RegisterTenantCallbacks(db); err != nil {
+		t.Fatalf("register tenant callbacks: %v", err)
+	}
+	return db
+}
+
+func companyDB(db *gorm.DB, companyID uint) *gorm.DB {
+	return db.WithContext(WithCompany(context.Background(), companyID))
+}
+
+// assertScoped checks a statement is limited to the company through a bound parameter
+func assertScoped(t *testing.T, tx *gorm.DB, table string, companyID uint) {
+	t.Helper()
+	if tx.Error != nil {
+		t.Fatalf("unexpected error: %v", tx.Error)
+	}
+	sql := tx.Statement.SQL.String()
+	condition := "`" + table + "`.`company_id` = ?"
+	if !strings.Contains(sql, condition) {
+		t.Fatalf("expected %q in %s", condition, sql)
+	}
+	if !hasVar(tx, companyID) {
+		t.Fatalf("expected company %d in %v", companyID, tx.Statement.Vars)
+	}
+	for _, other := range []uint{companyA, companyB} {
+		if other != companyID && hasVar(tx, other) {
+			t.Fatalf("statement for company %d references company %d: %v", companyID, other, tx.Statement.Vars)
+		}
+	}
+}
+
+func hasVar(tx *gorm.DB, value uint) bool {
+	for _, v := range tx.Statement.Vars {
+		if id, ok := v.(uint); ok && id == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestQueriesOnlyReadTheCompanysRecords(t *testing.T) {
+	db := newDryRunDB(t)
+
+	var products []models.Product
+	assertScoped(t, companyDB(db, companyA).Where("is_active = ?", true).Find(&products), "products", companyA)
+
+	var count int64
+	assertScoped(t, companyDB(db, companyB).Model(&models.SalesInvoice{}).Count(&count), "sales_invoices", companyB)
+
+	var stock []map[string]interface{}
+	assertScoped(t, companyDB(db, companyA).Table("stocks").Select("stocks.*").
+		Joins("LEFT JOIN products ON stocks.product_id = products.id").Find(&stock), "stocks", companyA)
+}
+
+func TestRecordOfAnotherCompanyCannotBeFetchedByID(t *testing.T) {
+	db := newDryRunDB(t)
+
+	// Record 42 may well exist, but only as company B's; company A's lookup can't match it
+	var customer models.Customer
+	tx := companyDB(db, companyA).First(&customer, 42)
+	assertScoped(t, tx, "customers", companyA)
+	if !strings.Contains(tx.Statement.SQL.String(), "`customers`.`id` = ?") {
+		t.Fatalf("expected the id condition as well: %s", tx.Statement.SQL.String())
+	}
+}
+
+func TestCreateAssignsTheCallersCompany(t *testing.T) {
+	db := newDryRunDB(t)
+
+	// A client supplied company is overridden so records can't be planted in another tenant
+	product := models.Product{SKU: "SKU-1", NameEn: "Water", CompanyID: companyB}
+	if err := companyDB(db, companyA).Create(&product).Error; err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if product.CompanyID != companyA {
+		t.Fatalf("expected company %d, got %d", companyA, product.CompanyID)
+	}
+
+	items := []models.SalesInvoiceItem{{ProductID: 1}, {ProductID: 2, CompanyID: companyA}}
+	if err := companyDB(db, companyB).Create(&items).Error; err != nil {
+		t.Fatalf("create items: %v", err)
+	}
+	for _, item := range items {
+		if item.CompanyID != companyB {
+			t.Fatalf("expected company %d on every item, got %d", companyB, item.CompanyID)
+		}
+	}
+
+	tx := companyDB(db, companyA).Model(&models.Customer{}).Create(map[string]interface{}{"name": "Cash"})
+	if tx.Error != nil {
+		t.Fatalf("create from map: %v", tx.Error)
+	}
+	if !strings.Contains(tx.Statement.SQL.String(), "`company_id`") || !hasVar(tx, companyA) {
+		t.Fatalf("expected company_id in map insert: %s %v", tx.Statement.SQL.String(), tx.Statement.Vars)
+	}
+}
+
+func TestUpdatesAndDeletesOnlyTouchTheCompanysRecords(t *testing.T) {
+	db := newDryRunDB(t)
+
+	tx := companyDB(db, companyA).Model(&models.Customer{ID: 5}).Update("name", "Renamed")
+	assertScoped(t, tx, "customers", companyA)
+
+	tx = companyDB(db, companyA).Model(&models.Stock{}).Where("product_id = ?", 3).
+		Updates(map[string]interface{}{"quantity": 10})
+	assertScoped(t, tx, "stocks", companyA)
+
+	tx = companyDB(db, companyB).Delete(&models.Van{ID: 9})
+	assertScoped(t, tx, "vans", companyB)
+
+	tx = companyDB(db, companyB).Where("location_id = ?", 2).Delete(&models.Stock{})
+	assertScoped(t, tx, "stocks", companyB)
+}
+
+func TestSaveKeepsTheRecordInTheCompany(t *testing.T) {
+	db := newDryRunDB(t)
+
+	// A record rebuilt from a request has no company; saving it must not move it to 0 or
+	// write into another company
+	location := models.Location{ID: 4, Name: "Main warehouse"}
+	tx := companyDB(db, companyA).Save(&location)
+	assertScoped(t, tx, "locations", companyA)
+	if location.CompanyID != companyA {
+		t.Fatalf("expected company %d, got %d", companyA, location.CompanyID)
+	}
+	if !strings.Contains(tx.Statement.SQL.String(), "`company_id`=?") {
+		t.Fatalf("expected company_id to be written: %s", tx.Statement.SQL.String())
+	}
+}
+
+func TestUnconditionedUpdatesAreStillRejected(t *testing.T) {
+	db := newDryRunDB(t)
+
+	err := companyDB(db, companyA).Model(&models.Product{}).Update("is_active", false).Error
+	if !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("expected ErrMissingWhereClause, got %v", err)
+	}
+	err = companyDB(db, companyA).Delete(&models.Product{}).Error
+	if !errors.Is(err, gorm.ErrMissingWhereClause) {
+		t.Fatalf("expected ErrMissingWhereClause, got %v", err)
+	}
+}
+
+func TestStatementsWithoutCompanyAreUntouched(t *testing.T) {
+	db := newDryRunDB(t)
+
+	// Login, migrations and seeding run without a company
+	var user models.User
+	tx := db.Where("email = ?", "admin@example.com").First(&user)
+	if strings.Contains(tx.Statement.SQL.String(), "company_id") {
+		t.Fatalf("unexpected company condition: %s", tx.Statement.SQL.String())
+	}
+
+	// Shared tables have no company
+	var permissions []models.Permission
+	tx = companyDB(db, companyA).Find(&permissions)
+	if strings.Contains(tx.Statement.SQL.String(), "company_id") {
+		t.Fatalf("unexpected company condition on permissions: %s", tx.Statement.SQL.String())
+	}
+}
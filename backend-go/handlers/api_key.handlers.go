@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type APIKeyService interface {
+	GetAll() ([]models.APIKey, error)
+	GetID(id uint) (models.APIKey, error)
+	Create(userID uint, input services.APIKeyInput) (models.APIKey, string, error)
+	Update(id uint, input services.APIKeyInput) (models.APIKey, error)
+	Revoke(id uint) error
+}
+
+type APIKeyHandler struct {
+	APIKeyServices APIKeyService
+	Access         PermissionChecker
+}
+
+func NewAPIKeyHandler(ks APIKeyService, access PermissionChecker) *APIKeyHandler {
+	return &APIKeyHandler{
+		APIKeyServices: ks,
+		Access:         access,
+	}
+}
+
+func (kh *APIKeyHandler) GetAllHandler(c echo.Context) error {
+	keys, err := kh.APIKeyServices.GetAll()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, keys, "data")
+}
+
+func (kh *APIKeyHandler) GetIDHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid api key id"))
+	}
+	key, err := kh.APIKeyServices.GetID(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, key, "data")
+}
+
+// CreateHandler issues a key acting for the current user. The response carries the key
+// in "key"; it can't be retrieved again.
+func (kh *APIKeyHandler) CreateHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var input services.APIKeyInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := kh.checkGrant(c, user, input); err != nil {
+		return ResponseError(c, err)
+	}
+
+	key, secret, err := kh.APIKeyServices.Create(user.ID, input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", map[string]interface{}{
+		"api_key": key,
+		"key":     secret,
+	})
+}
+
+func (kh *APIKeyHandler) UpdateHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid api key id"))
+	}
+	var input services.APIKeyInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := kh.checkGrant(c, user, input); err != nil {
+		return ResponseError(c, err)
+	}
+
+	key, err := kh.APIKeyServices.Update(uint(id), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", key)
+}
+
+func (kh *APIKeyHandler) RevokeHandler(c echo.Context) error {
+	if _, ok := GetAPIKey(c); ok {
+		return ResponseError(c, errors.New("API keys can't manage API keys"))
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid api key id"))
+	}
+	if err := kh.APIKeyServices.Revoke(uint(id)); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "revoked", nil)
+}
+
+// checkGrant stops anyone handing a key more than they have themselves: permissions they
+// don't hold, or locations outside their scope. Keys can't mint other keys.
+func (kh *APIKeyHandler) checkGrant(c echo.Context, user models.User, input services.APIKeyInput) error {
+	if _, ok := GetAPIKey(c); ok {
+		return errors.New("API keys can't manage API keys")
+	}
+
+	if !services.IsAdminRole(user.Role) {
+		for _, name := range input.Permissions {
+			resource, action, _ := strings.Cut(strings.TrimSpace(name), ":")
+			allowed, err := kh.Access.HasPermission(user.ID, resource, action)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				return fmt.Errorf("you can't grant %s, you don't have it", name)
+			}
+		}
+	}
+
+	scope := GetAccessScope(c)
+	if scope.Restricted {
+		if input.LocationID == nil {
+			return errors.New("location_id is required, the key must be limited to one of your locations")
+		}
+		if err := scope.Check(*input.LocationID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
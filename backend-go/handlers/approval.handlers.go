@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+type ApprovalService interface {
+	GetRules() ([]models.ApprovalRule, error)
+	GetRule(id uint) (models.ApprovalRule, error)
+	CreateRule(input services.ApprovalRuleInput) (models.ApprovalRule, error)
+	UpdateRule(id uint, input services.ApprovalRuleInput) (models.ApprovalRule, error)
+	DeleteRule(id uint) error
+	Match(facts services.ApprovalFacts) (*models.ApprovalRule, error)
+	CanDecide(user models.User, approverRoleID *uint) (bool, error)
+	Submit(rule models.ApprovalRule, facts services.ApprovalFacts, call services.ApprovalSubmission, requester models.User) (models.ApprovalRequest, error)
+	GetAll(user models.User, status string, limit, page int) (services.PaginationResponse, error)
+	GetID(user models.User, id uint) (models.ApprovalRequest, error)
+	Approve(user models.User, id uint, comment string) (models.ApprovalRequest, error)
+	Reject(user models.User, id uint, comment string) (models.ApprovalRequest, error)
+	Cancel(user models.User, id uint, comment string) (models.ApprovalRequest, error)
+	RecordResult(user models.User, id uint, statusCode int, body string) (models.ApprovalRequest, error)
+}
+
+type ApprovalHandler struct {
+	ApprovalServices ApprovalService
+	// Router runs approved requests; it is the Echo instance the API is served from
+	Router http.Handler
+}
+
+func NewApprovalHandler(as ApprovalService, router http.Handler) *ApprovalHandler {
+	return &ApprovalHandler{
+		ApprovalServices: as,
+		Router:           router,
+	}
+}
+
+type approvalDecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+func (ah *ApprovalHandler) GetRulesHandler(c echo.Context) error {
+	rules, err := ah.ApprovalServices.GetRules()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, rules, "data")
+}
+
+func (ah *ApprovalHandler) GetRuleHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid approval rule id"))
+	}
+	rule, err := ah.ApprovalServices.GetRule(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, rule, "data")
+}
+
+func (ah *ApprovalHandler) CreateRuleHandler(c echo.Context) error {
+	var input services.ApprovalRuleInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	rule, err := ah.ApprovalServices.CreateRule(input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", rule)
+}
+
+func (ah *ApprovalHandler) UpdateRuleHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid approval rule id"))
+	}
+	var input services.ApprovalRuleInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	rule, err := ah.ApprovalServices.UpdateRule(uint(id), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", rule)
+}
+
+func (ah *ApprovalHandler) DeleteRuleHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid approval rule id"))
+	}
+	if err := ah.ApprovalServices.DeleteRule(uint(id)); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "deleted", nil)
+}
+
+// GetAllHandler lists the requests the user made or may decide, optionally by status
+func (ah *ApprovalHandler) GetAllHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	response, err := ah.ApprovalServices.GetAll(user, c.QueryParam("status"), limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (ah *ApprovalHandler) GetIDHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid approval request id"))
+	}
+	request, err := ah.ApprovalServices.GetID(user, uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, request, "data")
+}
+
+// ApproveHandler approves a pending request and runs it straight away as its requester.
+// The outcome is stored on the request: executed, or failed with the error response.
+func (ah *ApprovalHandler) ApproveHandler(c echo.Context) error {
+	user, input, id, err := ah.decisionInput(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	request, err := ah.ApprovalServices.Approve(user, id, input.Comment)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	statusCode, body := ah.execute(request)
+	request, err = ah.ApprovalServices.RecordResult(user, id, statusCode, body)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "approved", request)
+}
+
+func (ah *ApprovalHandler) RejectHandler(c echo.Context) error {
+	user, input, id, err := ah.decisionInput(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	request, err := ah.ApprovalServices.Reject(user, id, input.Comment)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "rejected", request)
+}
+
+func (ah *ApprovalHandler) CancelHandler(c echo.Context) error {
+	user, input, id, err := ah.decisionInput(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	request, err := ah.ApprovalServices.Cancel(user, id, input.Comment)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "cancelled", request)
+}
+
+// decisionInput reads who decides which request. API keys can't decide.
+func (ah *ApprovalHandler) decisionInput(c echo.Context) (models.User, approvalDecisionRequest, uint, error) {
+	var input approvalDecisionRequest
+	if _, ok := GetAPIKey(c); ok {
+		return models.User{}, input, 0, errors.New("API keys can't decide approval requests")
+	}
+	user, err := GetUserContext(c)
+	if err != nil {
+		return models.User{}, input, 0, err
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return models.User{}, input, 0, errors.New("invalid approval request id")
+	}
+	if err := c.Bind(&input); err != nil {
+		return models.User{}, input, 0, err
+	}
+	return user, input, uint(id), nil
+}
+
+// execute replays the approved request through the router, authenticated as the
+// requester and the API key they used, if any (see JWTMiddleware), so it passes the same permission, scope and validation
+// checks it would have passed when it was made
+func (ah *ApprovalHandler) execute(request models.ApprovalRequest) (int, string) {
+	ctx := context.WithValue(context.Background(), approvalGrantKey{}, approvalGrant{
+		RequestID: request.ID,
+		UserID:    request.RequestedBy,
+		APIKeyID:  request.APIKeyID,
+	})
+	req, err := http.NewRequestWithContext(ctx, request.Method, request.Path, strings.NewReader(request.Body))
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if request.RequestedIP != "" {
+		req.RemoteAddr = net.JoinHostPort(request.RequestedIP, "0")
+	}
+
+	recorder := httptest.NewRecorder()
+	ah.Router.ServeHTTP(recorder, req)
+	return recorder.Code, recorder.Body.String()
+}
+
+// approvalGrant marks a replayed request. It only exists in the context of requests
+// built by execute, so clients can't forge one.
+type approvalGrant struct {
+	RequestID uint
+	UserID    uint
+	APIKeyID  *uint // the key the request was made with, if any
+}
+
+type approvalGrantKey struct{}
+
+func getApprovalGrant(c echo.Context) (approvalGrant, bool) {
+	grant, ok := c.Request().Context().Value(approvalGrantKey{}).(approvalGrant)
+	return grant, ok
+}
+
+// ApprovalExtractor describes a request for the approval rules. It returns false when
+// the request isn't one the rules apply to.
+type ApprovalExtractor func(c echo.Context, db *gorm.DB, body []byte) (services.ApprovalFacts, bool, error)
+
+// ApprovalGate holds back requests that match an approval rule
+type ApprovalGate struct {
+	// Service returns the approval service for the request's company database
+	Service func(db *gorm.DB) ApprovalService
+}
+
+func NewApprovalGate(service func(db *gorm.DB) ApprovalService) *ApprovalGate {
+	return &ApprovalGate{
+		Service: service,
+	}
+}
+
+// Require must run after TenantMiddleware. When a rule matches, the request is stored
+// and answered with 202 and the pending approval request instead of being handled.
+// Users who could approve it themselves are let through, as are approved requests
+// being run.
+func (g *ApprovalGate) Require(extract ApprovalExtractor) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := getApprovalGrant(c); ok {
+				return next(c)
+			}
+			db, err := TenantDB(c)
+			if err != nil {
+				return ResponseError(c, err)
+			}
+			user, err := GetUserContext(c)
+			if err != nil {
+				return ResponseError(c, err)
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return ResponseError(c, err)
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			facts, ok, err := extract(c, db, body)
+			if err != nil {
+				return ResponseError(c, err)
+			}
+			if !ok {
+				return next(c)
+			}
+
+			service := g.Service(db)
+			rule, err := service.Match(facts)
+			if err != nil {
+				return ResponseError(c, err)
+			}
+			if rule == nil {
+				return next(c)
+			}
+			// An API key acts for its creator but never approves on their behalf
+			if _, isKey := GetAPIKey(c); !isKey {
+				canDecide, err := service.CanDecide(user, rule.ApproverRoleID)
+				if err != nil {
+					return ResponseError(c, err)
+				}
+				if canDecide {
+					return next(c)
+				}
+			}
+
+			submission := services.ApprovalSubmission{
+				Method:    c.Request().Method,
+				Path:      c.Request().URL.RequestURI(),
+				Body:      string(body),
+				IPAddress: c.RealIP(),
+			}
+			if key, isKey := GetAPIKey(c); isKey {
+				submission.APIKeyID = &key.ID
+			}
+			request, err := service.Submit(*rule, facts, submission, user)
+			if err != nil {
+				return ResponseError(c, err)
+			}
+			return c.JSON(http.StatusAccepted, map[string]interface{}{
+				"ok":                true,
+				"approval_required": true,
+				"message":           "This operation needs approval (" + rule.Name + ")",
+				"data":              request,
+			})
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// The extractors below describe the operations listed in services.ApprovalOperations
+// for ApprovalGate.Require. Bodies that don't decode are left to the handler to reject.
+
+func decodeApprovalBody(body []byte, v interface{}) bool {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false
+	}
+	return json.Unmarshal(body, v) == nil
+}
+
+// SalesDiscountApproval covers new sales invoices and added or changed invoice lines.
+// The amount is the highest line discount in percent.
+func SalesDiscountApproval(c echo.Context, db *gorm.DB, body []byte) (services.ApprovalFacts, bool, error) {
+	var req struct {
+		LocationID      uint    `json:"location_id"`
+		DiscountPercent float64 `json:"discount_percent"`
+		Items           []struct {
+			DiscountPercent float64 `json:"discount_percent"`
+		} `json:"items"`
+	}
+	if !decodeApprovalBody(body, &req) {
+		return services.ApprovalFacts{}, false, nil
+	}
+	highest := req.DiscountPercent
+	for _, item := range req.Items {
+		highest = math.Max(highest, item.DiscountPercent)
+	}
+	if highest <= 0 {
+		return services.ApprovalFacts{}, false, nil
+	}
+
+	locationID := req.LocationID
+	summary := fmt.Sprintf("give a %.2f%% discount on a new sales invoice", highest)
+	if id := c.Param("id"); id != "" {
+		invoice, err := services.NewSalesInvoiceService(models.SalesInvoice{}, db).GetID(GetAccessScope(c), id)
+		if err != nil {
+			return services.ApprovalFacts{}, false, err
+		}
+		locationID = invoice.LocationID
+		summary = fmt.Sprintf("give a %.2f%% discount on sales invoice %s", highest, invoice.InvoiceNumber)
+	}
+	return services.ApprovalFacts{
+		Operation:  services.ApprovalSalesDiscount,
+		Amount:     highest,
+		LocationID: &locationID,
+		Summary:    summary,
+	}, true, nil
+}
+
+// InvoiceDeleteApproval covers deleting sales and purchase invoices. The amount is the
+// invoice total.
+func InvoiceDeleteApproval(c echo.Context, db *gorm.DB, body []byte) (services.ApprovalFacts, bool, error) {
+	id := c.Param("id")
+	var facts services.ApprovalFacts
+	if c.QueryParam("invoice_type") == "purchase" {
+		invoice, err := services.NewPurchaseInvoiceService(models.PurchaseInvoice{}, db).GetID(GetAccessScope(c), id)
+		if err != nil {
+			return services.ApprovalFacts{}, false, err
+		}
+		facts = services.ApprovalFacts{
+			Amount:     invoice.TotalAmount,
+			LocationID: &invoice.LocationID,
+			Summary:    fmt.Sprintf("delete purchase invoice %s (total %.2f)", invoice.InvoiceNumber, invoice.TotalAmount),
+		}
+	} else {
+		invoice, err := services.NewSalesInvoiceService(models.SalesInvoice{}, db).GetID(GetAccessScope(c), id)
+		if err != nil {
+			return services.ApprovalFacts{}, false, err
+		}
+		facts = services.ApprovalFacts{
+			Amount:     invoice.TotalAmount,
+			LocationID: &invoice.LocationID,
+			Summary:    fmt.Sprintf("delete sales invoice %s (total %.2f)", invoice.InvoiceNumber, invoice.TotalAmount),
+		}
+	}
+	facts.Operation = services.ApprovalInvoiceDelete
+	return facts, true, nil
+}
+
+// StockAdjustApproval covers setting a stock level by hand. The amount is the number of
+// units added or removed; stock that can't be read counts as zero.
+func StockAdjustApproval(c echo.Context, db *gorm.DB, body []byte) (services.ApprovalFacts, bool, error) {
+	var req struct {
+		ProductID  uint    `json:"product_id"`
+		LocationID uint    `json:"location_id"`
+		Quantity   float64 `json:"quantity"`
+	}
+	if !decodeApprovalBody(body, &req) {
+		return services.ApprovalFacts{}, false, nil
+	}
+
+	stockService := services.NewStockService(models.Stock{}, db)
+	locationType, locationID := stockService.GetLocationTypeAndID(req.LocationID)
+	var current float64
+	if stock, err := stockService.GetProductStock(req.ProductID, locationType, locationID); err == nil {
+		current = stock.Quantity
+	}
+
+	return services.ApprovalFacts{
+		Operation:  services.ApprovalStockAdjust,
+		Amount:     math.Abs(req.Quantity - current),
+		LocationID: &req.LocationID,
+		Summary: fmt.Sprintf("adjust stock of product #%d at location #%d from %.2f to %.2f",
+			req.ProductID, req.LocationID, current, req.Quantity),
+	}, true, nil
+}
+
+// TransferApproval covers stock transfers. The amount is the number of units moved and
+// the location the one they leave.
+func TransferApproval(c echo.Context, db *gorm.DB, body []byte) (services.ApprovalFacts, bool, error) {
+	var req struct {
+		FromLocationID uint `json:"from_location_id"`
+		ToLocationID   uint `json:"to_location_id"`
+		Items          []struct {
+			Quantity float64 `json:"quantity"`
+		} `json:"items"`
+	}
+	if !decodeApprovalBody(body, &req) {
+		return services.ApprovalFacts{}, false, nil
+	}
+	var units float64
+	for _, item := range req.Items {
+		units += item.Quantity
+	}
+
+	return services.ApprovalFacts{
+		Operation:  services.ApprovalTransferCreate,
+		Amount:     units,
+		LocationID: &req.FromLocationID,
+		Summary: fmt.Sprintf("transfer %.2f units from location #%d to location #%d",
+			units, req.FromLocationID, req.ToLocationID),
+	}, true, nil
+}
+
+// CreditNoteApproval covers approving a credit note, which takes its items out of
+// stock. The amount is the credit note total.
+func CreditNoteApproval(c echo.Context, db *gorm.DB, body []byte) (services.ApprovalFacts, bool, error) {
+	creditNote, err := services.NewCreditNoteService(db).GetByID(c.Param("id"))
+	if err != nil {
+		return services.ApprovalFacts{}, false, err
+	}
+	if creditNote.Status != "draft" {
+		return services.ApprovalFacts{}, false, errors.New("only draft credit notes can be approved")
+	}
+	return services.ApprovalFacts{
+		Operation:  services.ApprovalCreditNoteApprove,
+		Amount:     creditNote.TotalAmount,
+		LocationID: &creditNote.LocationID,
+		Summary:    fmt.Sprintf("approve credit note %s (total %.2f)", creditNote.CreditNoteNumber, creditNote.TotalAmount),
+	}, true, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type AuditLogService interface {
+	GetALL(limit, page int, filters map[string]string) (services.PaginationResponse, error)
+}
+
+type AuditLogHandler struct {
+	AuditLogServices AuditLogService
+}
+
+func NewAuditLogHandler(as AuditLogService) *AuditLogHandler {
+	return &AuditLogHandler{
+		AuditLogServices: as,
+	}
+}
+
+// GetAllHandler searches the audit log by entity (table name, e.g. sales_invoices),
+// entity_id, user_id, action and from_date / to_date
+func (ah *AuditLogHandler) GetAllHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	filters := map[string]string{}
+	for _, key := range []string{"entity", "entity_id", "user_id", "action", "from_date", "to_date"} {
+		filters[key] = c.QueryParam(key)
+	}
+
+	response, err := ah.AuditLogServices.GetALL(limit, page, filters)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
@@ -1,30 +1,83 @@
 package handlers
 
 import (
+	"errors"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	accessTokenCookie  = "jwt"
+	refreshTokenCookie = "refresh_token"
+	// The refresh cookie is only sent to the auth endpoints
+	refreshCookiePath = "/api/auth"
+	sessionIDKey      = "session_id"
+	apiKeyHeader      = "X-API-Key"
+	apiKeyContextKey  = "api_key"
+)
+
+// twoFactorEnrolmentRoutes are all a session may use while the user still has to set up
+// two-factor authentication
+var twoFactorEnrolmentRoutes = map[string]bool{
+	"GET /api/me":               true,
+	"POST /api/auth/logout":     true,
+	"GET /api/auth/2fa":         true,
+	"POST /api/auth/2fa/setup":  true,
+	"POST /api/auth/2fa/enable": true,
+}
+
 type AuthService interface {
 	Register(email, password string) error
 	CheckEmail(username string) (models.User, error)
 	CheckPassword(user models.User, password string) error
 	CheckDeactive(user models.User) error
-	Login(username, password string) (*http.Cookie, string, error)
-	Logout() *http.Cookie
-	GetUser(tokenString string) (*models.User, error)
+	Login(user models.User, userAgent, ipAddress string, twoFactorPending bool) (services.TokenPair, error)
+	Refresh(refreshToken, userAgent, ipAddress string) (services.TokenPair, models.User, error)
+	TwoFactorChallenge(user models.User) (string, time.Time, error)
+	CheckChallenge(tokenString string) (models.User, error)
+	Authenticate(tokenString string) (models.User, models.UserSession, error)
+	AuthenticateAPIKey(secret, ipAddress string) (models.User, models.APIKey, error)
+	GetAPIKey(id uint) (models.APIKey, error)
+	GetUserByID(id uint) (models.User, error)
+	GetSessions(userID, currentSessionID uint) ([]models.UserSession, error)
+	RevokeSession(userID, sessionID uint, reason string) error
+	RevokeAllSessions(userID uint, reason string) error
+	GenerateCookie(name, value, path string, expires time.Time) *http.Cookie
+}
+
+// LoginThrottle tracks failed logins per account and per client and locks them out
+type LoginThrottle interface {
+	Check(email, ipAddress string) error
+	RecordFailure(email, ipAddress string) error
+	ClearAccount(email string) error
+}
+
+// TwoFactorVerifier is the part of two-factor authentication the login needs
+type TwoFactorVerifier interface {
+	IsEnabled(userID uint) (bool, error)
+	IsRequired(user models.User) (bool, error)
+	Verify(userID uint, code string) error
 }
+
 type AuthHandler struct {
 	AuthServices AuthService
+	Throttle     LoginThrottle
+	TwoFactor    TwoFactorVerifier
 }
 
-func NewAuthHandler(us AuthService) *AuthHandler {
+func NewAuthHandler(us AuthService, throttle LoginThrottle, twoFactor TwoFactorVerifier) *AuthHandler {
 	return &AuthHandler{
 		AuthServices: us,
+		Throttle:     throttle,
+		TwoFactor:    twoFactor,
 	}
 }
 
@@ -54,30 +107,100 @@ func (ah *AuthHandler) LoginHandler(c echo.Context) error {
 	if err := c.Bind(&formData); err != nil {
 		return err
 	}
+	ip := c.RealIP()
+	if err := ah.Throttle.Check(formData.Email, ip); err != nil {
+		return lockedOut(c, err)
+	}
+
 	user, err := ah.AuthServices.CheckEmail(formData.Email)
+	if err == nil {
+		err = ah.AuthServices.CheckPassword(user, formData.Password)
+	}
 	if err != nil {
+		if recordErr := ah.Throttle.RecordFailure(formData.Email, ip); recordErr != nil {
+			log.Printf("login: could not record failed attempt: %v", recordErr)
+		}
 		return ResponseError(c, err)
 	}
 
-	err = ah.AuthServices.CheckPassword(user, formData.Password)
+	err = ah.AuthServices.CheckDeactive(user)
 	if err != nil {
 		return ResponseError(c, err)
 	}
 
-	err = ah.AuthServices.CheckDeactive(user)
+	// With two-factor enabled the password only earns a challenge token; the session
+	// starts in VerifyTwoFactorHandler
+	enabled, err := ah.TwoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if enabled {
+		challenge, expiresAt, err := ah.AuthServices.TwoFactorChallenge(user)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		return ResponseOK(c, map[string]interface{}{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+			"expires_at":          expiresAt,
+		}, "data")
+	}
+
+	required, err := ah.TwoFactor.IsRequired(user)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ah.startSession(c, user, required)
+}
+
+// VerifyTwoFactorHandler completes a login with the challenge token and a code from the
+// authenticator app or a backup code
+func (ah *AuthHandler) VerifyTwoFactorHandler(c echo.Context) error {
+	var formData struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	user, err := ah.AuthServices.CheckChallenge(formData.ChallengeToken)
 	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+
+	// Wrong codes count against the account like wrong passwords
+	ip := c.RealIP()
+	if err := ah.Throttle.Check(user.Email, ip); err != nil {
+		return lockedOut(c, err)
+	}
+	if err := ah.TwoFactor.Verify(user.ID, formData.Code); err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			if recordErr := ah.Throttle.RecordFailure(user.Email, ip); recordErr != nil {
+				log.Printf("login: could not record failed attempt: %v", recordErr)
+			}
+		}
 		return ResponseError(c, err)
 	}
+	return ah.startSession(c, user, false)
+}
+
+// startSession finishes a login: the account's failed attempts are forgotten and the
+// tokens are handed out
+func (ah *AuthHandler) startSession(c echo.Context, user models.User, twoFactorPending bool) error {
+	if err := ah.Throttle.ClearAccount(user.Email); err != nil {
+		log.Printf("login: could not clear failed attempts: %v", err)
+	}
 
-	cookie, tokenString, err := ah.AuthServices.Login(formData.Email, formData.Password)
+	tokens, err := ah.AuthServices.Login(user, c.Request().UserAgent(), c.RealIP(), twoFactorPending)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
-	c.SetCookie(cookie)
+	ah.setTokenCookies(c, tokens)
 
 	type LoginData struct {
-		User  models.User `json:"user"`
-		Token string      `json:"token"`
+		User models.User `json:"user"`
+		services.TokenPair
+		TwoFactorSetupRequired bool `json:"two_factor_setup_required"`
 	}
 	type LoginResponse struct {
 		OK      bool      `json:"ok"`
@@ -87,78 +210,224 @@ func (ah *AuthHandler) LoginHandler(c echo.Context) error {
 	response := LoginResponse{
 		OK: true,
 		Data: LoginData{
-			User:  user,
-			Token: tokenString,
+			User:                   user,
+			TokenPair:              tokens,
+			TwoFactorSetupRequired: twoFactorPending,
 		},
 		Message: "Login successful",
 	}
 	return c.JSON(http.StatusOK, response)
 }
 
-func (ah *AuthHandler) GetUserHandler(c echo.Context) error {
-	// Retrieve token string from the cookie
-	cookie, err := c.Cookie("jwt")
-	log.Printf("Setting cookie: %v", cookie)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "missing or invalid token cookie")
+// RefreshHandler rotates the refresh token (body "refresh_token" or the refresh cookie)
+// and returns a new access token
+func (ah *AuthHandler) RefreshHandler(c echo.Context) error {
+	var formData struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	if formData.RefreshToken == "" {
+		if cookie, err := c.Cookie(refreshTokenCookie); err == nil {
+			formData.RefreshToken = cookie.Value
+		}
 	}
-	tokenString := cookie.Value
 
-	// Call the GetUser method to retrieve user details
-	user, err := ah.AuthServices.GetUser(tokenString)
+	tokens, user, err := ah.AuthServices.Refresh(formData.RefreshToken, c.Request().UserAgent(), c.RealIP())
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
+	ah.setTokenCookies(c, tokens)
+
+	return ResponseOK(c, map[string]interface{}{
+		"user":               user,
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.ExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		"session_id":         tokens.SessionID,
+	}, "data")
+}
 
+func (ah *AuthHandler) GetUserHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
 	return ResponseOK(c, user, "data")
 }
 
-// Logout handler
+// LogoutHandler ends the current session
 func (ah *AuthHandler) LogoutHandler(c echo.Context) error {
-	// Call the Logout method to get the logout cookie
-	cookie := ah.AuthServices.Logout()
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := ah.AuthServices.RevokeSession(user.ID, GetSessionID(c), "logout"); err != nil {
+		return ResponseError(c, err)
+	}
+	ah.clearTokenCookies(c)
+	return ResponseOK(c, nil, "")
+}
 
-	// Set the cookie in the response to clear the existing JWT token cookie
-	c.SetCookie(cookie)
+// LogoutAllHandler ends every session of the current user, on all devices
+func (ah *AuthHandler) LogoutAllHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := ah.AuthServices.RevokeAllSessions(user.ID, "logout_all"); err != nil {
+		return ResponseError(c, err)
+	}
+	ah.clearTokenCookies(c)
 	return ResponseOK(c, nil, "")
 }
 
+func (ah *AuthHandler) SessionsHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	sessions, err := ah.AuthServices.GetSessions(user.ID, GetSessionID(c))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, sessions, "data")
+}
+
+// RevokeSessionHandler signs the current user out of one of their other devices
+func (ah *AuthHandler) RevokeSessionHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid session id"))
+	}
+	if err := ah.AuthServices.RevokeSession(user.ID, uint(sessionID), "revoked"); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Session revoked successfully", nil)
+}
+
+// lockedOut answers 429 with Retry-After while the account or client is locked out
+func lockedOut(c echo.Context, err error) error {
+	var locked *services.LockedError
+	if !errors.As(err, &locked) {
+		return ResponseError(c, err)
+	}
+	seconds := int(math.Ceil(locked.RetryAfter().Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	return c.JSON(http.StatusTooManyRequests, map[string]interface{}{
+		"ok":          false,
+		"message":     locked.Error(),
+		"retry_after": seconds,
+	})
+}
+
+func (ah *AuthHandler) setTokenCookies(c echo.Context, tokens services.TokenPair) {
+	c.SetCookie(ah.AuthServices.GenerateCookie(accessTokenCookie, tokens.AccessToken, "/", tokens.ExpiresAt))
+	c.SetCookie(ah.AuthServices.GenerateCookie(refreshTokenCookie, tokens.RefreshToken, refreshCookiePath, tokens.RefreshExpiresAt))
+}
+
+func (ah *AuthHandler) clearTokenCookies(c echo.Context) {
+	c.SetCookie(ah.AuthServices.GenerateCookie(accessTokenCookie, "", "/", time.Time{}))
+	c.SetCookie(ah.AuthServices.GenerateCookie(refreshTokenCookie, "", refreshCookiePath, time.Time{}))
+}
+
+// JWTMiddleware accepts a Bearer token or the jwt cookie. Besides the signature and
+// expiry, the token's session must not have been revoked, and a session waiting for
+// two-factor enrolment only reaches the enrolment routes. Integrations send an API key
+// instead, in the X-API-Key header or as the Bearer token.
 func (ah *AuthHandler) JWTMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var tokenString string
+		if grant, ok := getApprovalGrant(c); ok {
+			return ah.authenticateApproval(c, next, grant)
+		}
+		if apiKey := c.Request().Header.Get(apiKeyHeader); apiKey != "" {
+			return ah.authenticateAPIKey(c, next, apiKey)
+		}
 
 		// Try to get token from Authorization header first (Bearer token)
 		authHeader := c.Request().Header.Get("Authorization")
 		if authHeader != "" && len(authHeader) > 7 && authHeader[:7] == "Bearer " {
 			tokenString = authHeader[7:]
+			if services.IsAPIKey(tokenString) {
+				return ah.authenticateAPIKey(c, next, tokenString)
+			}
 		} else {
 			// Fallback to cookie
-			cookie, err := c.Cookie("jwt")
+			cookie, err := c.Cookie(accessTokenCookie)
 			if err != nil {
 				return echo.NewHTTPError(http.StatusUnauthorized, "missing or invalid token")
 			}
 			tokenString = cookie.Value
 		}
 
-		// Parse and validate JWT token
-		token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte("secret"), nil // Replace "secret" with your secret key
-		})
+		user, session, err := ah.AuthServices.Authenticate(tokenString)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 		}
-
-		claims, ok := token.Claims.(*models.Claims)
-		if !ok || !token.Valid {
-			return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
+		if session.TwoFactorPending && !twoFactorEnrolmentRoutes[c.Request().Method+" "+c.Path()] {
+			return echo.NewHTTPError(http.StatusForbidden, "set up two-factor authentication to continue")
 		}
 
-		user, err := ah.AuthServices.CheckEmail(claims.Email)
-		if err != nil {
-			log.Println(err.Error())
-		}
 		// Set user details in context
 		c.Set("user", user)
+		c.Set(sessionIDKey, session.ID)
 		return next(c)
 	}
 }
+
+// authenticateAPIKey lets a request through as the key's user. The account routes under
+// /api/auth belong to people and are closed to keys.
+func (ah *AuthHandler) authenticateAPIKey(c echo.Context, next echo.HandlerFunc, secret string) error {
+	if strings.HasPrefix(c.Path(), refreshCookiePath+"/") {
+		return echo.NewHTTPError(http.StatusForbidden, "API keys can't be used for account routes")
+	}
+	user, key, err := ah.AuthServices.AuthenticateAPIKey(secret, c.RealIP())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	c.Set("user", user)
+	c.Set(apiKeyContextKey, key)
+	return next(c)
+}
+
+// authenticateApproval lets an approved request run as the user who made it (see
+// ApprovalHandler.execute), provided they are still active. A request made with an API
+// key runs with that key again, limited to its permissions and locations, and fails
+// once the key was revoked or expired.
+func (ah *AuthHandler) authenticateApproval(c echo.Context, next echo.HandlerFunc, grant approvalGrant) error {
+	user, err := ah.AuthServices.GetUserByID(grant.UserID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "requester not found")
+	}
+	if err := ah.AuthServices.CheckDeactive(user); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+	}
+	c.Set("user", user)
+	if grant.APIKeyID != nil {
+		key, err := ah.AuthServices.GetAPIKey(*grant.APIKeyID)
+		if err != nil || key.UserID != user.ID {
+			return echo.NewHTTPError(http.StatusUnauthorized, "the API key of the request is no longer valid")
+		}
+		c.Set(apiKeyContextKey, key)
+	}
+	return next(c)
+}
+
+// GetAPIKey returns the API key the current request was made with, if any
+func GetAPIKey(c echo.Context) (models.APIKey, bool) {
+	key, ok := c.Get(apiKeyContextKey).(models.APIKey)
+	return key, ok
+}
+
+// GetSessionID returns the session of the current request's access token
+func GetSessionID(c echo.Context) uint {
+	sessionID, _ := c.Get(sessionIDKey).(uint)
+	return sessionID
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/labstack/echo/v4"
+)
+
+// maxStatementSize limits uploaded statement files to 5 MB
+const maxStatementSize = 5 << 20
+
+type BankReconciliationService interface {
+	ImportStatement(accountID uint, fileName string, data []byte, createdBy uint) (models.BankStatementImport, error)
+	GetImports(accountID string) ([]models.BankStatementImport, error)
+	GetImport(id string) (models.BankStatementImport, error)
+	AutoMatch(importID uint) (int, error)
+	Match(lineID, transactionID uint) (models.BankStatementLine, error)
+	Unmatch(lineID uint) (models.BankStatementLine, error)
+	Ignore(lineID uint) (models.BankStatementLine, error)
+	Confirm(importID uint) (models.BankStatementImport, error)
+}
+
+type BankReconciliationHandler struct {
+	BankReconciliationServices BankReconciliationService
+}
+
+func NewBankReconciliationHandler(brs BankReconciliationService) *BankReconciliationHandler {
+	return &BankReconciliationHandler{
+		BankReconciliationServices: brs,
+	}
+}
+
+// ImportHandler uploads a CSV or OFX statement (multipart field "file") for an account
+func (bh *BankReconciliationHandler) ImportHandler(c echo.Context) error {
+	accountID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return ResponseError(c, errors.New("statement file is required"))
+	}
+	if file.Size > maxStatementSize {
+		return ResponseError(c, errors.New("statement file is too large"))
+	}
+	src, err := file.Open()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	response, err := bh.BankReconciliationServices.ImportStatement(accountID, file.Filename, data, user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Statement imported successfully", response)
+}
+
+func (bh *BankReconciliationHandler) GetAllHandler(c echo.Context) error {
+	imports, err := bh.BankReconciliationServices.GetImports(c.QueryParam("account_id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, imports, "data")
+}
+
+func (bh *BankReconciliationHandler) GetIDHandler(c echo.Context) error {
+	response, err := bh.BankReconciliationServices.GetImport(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, response, "data")
+}
+
+// AutoMatchHandler re-runs automatic matching for the unmatched lines of a statement
+func (bh *BankReconciliationHandler) AutoMatchHandler(c echo.Context) error {
+	importID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	matched, err := bh.BankReconciliationServices.AutoMatch(importID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	response, err := bh.BankReconciliationServices.GetImport(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Matched "+strconv.Itoa(matched)+" lines", response)
+}
+
+func (bh *BankReconciliationHandler) MatchHandler(c echo.Context) error {
+	lineID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var req struct {
+		TransactionID any `json:"transaction_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+	transactionID := convertToInt(req.TransactionID)
+	if transactionID <= 0 {
+		return ResponseError(c, errors.New("transaction_id is required"))
+	}
+
+	response, err := bh.BankReconciliationServices.Match(lineID, uint(transactionID))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Statement line matched", response)
+}
+
+func (bh *BankReconciliationHandler) UnmatchHandler(c echo.Context) error {
+	lineID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	response, err := bh.BankReconciliationServices.Unmatch(lineID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Statement line unmatched", response)
+}
+
+func (bh *BankReconciliationHandler) IgnoreHandler(c echo.Context) error {
+	lineID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	response, err := bh.BankReconciliationServices.Ignore(lineID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Statement line ignored", response)
+}
+
+// ConfirmHandler marks all matched lines and their ledger transactions as reconciled
+func (bh *BankReconciliationHandler) ConfirmHandler(c echo.Context) error {
+	importID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	response, err := bh.BankReconciliationServices.Confirm(importID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Reconciliation confirmed", response)
+}
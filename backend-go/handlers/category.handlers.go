@@ -11,6 +11,7 @@ import (
 
 type CategoryService interface {
 	GetALL(limit, page int, orderBy, sortBy, searchTerm string) (services.PaginationResponse, error)
+	GetTree() ([]models.Category, error)
 	GetID(id string) (models.Category, error)
 	Create(category models.Category) (models.Category, error)
 	Update(category models.Category) (models.Category, error)
@@ -52,6 +53,15 @@ func (ch *CategoryHandler) GetAllHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, response)
 }
 
+// GetTreeHandler returns every category nested under its parent
+func (ch *CategoryHandler) GetTreeHandler(c echo.Context) error {
+	tree, err := ch.CategoryServices.GetTree()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, tree, "data")
+}
+
 func (ch *CategoryHandler) GetIDHandler(c echo.Context) error {
 	id := c.Param("id")
 	response, err := ch.CategoryServices.GetID(id)
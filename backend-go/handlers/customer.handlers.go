@@ -71,6 +71,8 @@ func (ch *CustomerHandler) CreateHandler(c echo.Context) error {
 		TaxNumber   string `json:"tax_number"`
 		CreditLimit any    `json:"credit_limit"` // Accept string or number
 		IsActive    bool   `json:"is_active"`
+		PriceListID *uint  `json:"price_list_id"`
+		Group       string `json:"customer_group"`
 	}
 	
 	if err := c.Bind(&dto); err != nil {
@@ -84,8 +86,9 @@ func (ch *CustomerHandler) CreateHandler(c echo.Context) error {
 	
 	// Create customer with converted fields
 	customer := models.Customer{
-		Name:     dto.Name,
-		IsActive: dto.IsActive,
+		Name:        dto.Name,
+		IsActive:    dto.IsActive,
+		PriceListID: dto.PriceListID,
 	}
 	
 	if dto.Phone != "" {
@@ -100,6 +103,9 @@ func (ch *CustomerHandler) CreateHandler(c echo.Context) error {
 	if dto.TaxNumber != "" {
 		customer.TaxNumber = &dto.TaxNumber
 	}
+	if dto.Group != "" {
+		customer.CustomerGroup = &dto.Group
+	}
 	
 	// Convert credit_limit
 	customer.CreditLimit = convertToFloat64(dto.CreditLimit)
@@ -127,6 +133,8 @@ func (ch *CustomerHandler) UpdateHandler(c echo.Context) error {
 		TaxNumber   string `json:"tax_number"`
 		CreditLimit any    `json:"credit_limit"` // Accept string or number
 		IsActive    bool   `json:"is_active"`
+		PriceListID *uint  `json:"price_list_id"`
+		Group       string `json:"customer_group"`
 	}
 	
 	if err = c.Bind(&dto); err != nil {
@@ -136,6 +144,7 @@ func (ch *CustomerHandler) UpdateHandler(c echo.Context) error {
 	// Update customer fields
 	customer.Name = dto.Name
 	customer.IsActive = dto.IsActive
+	customer.PriceListID = dto.PriceListID
 	
 	if dto.Phone != "" {
 		customer.Phone = &dto.Phone
@@ -161,6 +170,12 @@ func (ch *CustomerHandler) UpdateHandler(c echo.Context) error {
 		customer.TaxNumber = nil
 	}
 	
+	if dto.Group != "" {
+		customer.CustomerGroup = &dto.Group
+	} else {
+		customer.CustomerGroup = nil
+	}
+	
 	// Convert credit_limit
 	customer.CreditLimit = convertToFloat64(dto.CreditLimit)
 
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+// liveHeartbeat keeps proxies from closing an idle stream
+const liveHeartbeat = 25 * time.Second
+
+// EventHandler streams live changes to dashboards as server-sent events
+type EventHandler struct {
+	Access PermissionChecker
+}
+
+func NewEventHandler(access PermissionChecker) *EventHandler {
+	return &EventHandler{
+		Access: access,
+	}
+}
+
+// StreamHandler serves GET /api/events as text/event-stream. Each message is named after
+// the event type (stock.changed, sale.created, payment.created, transfer.created) and
+// carries the event as JSON. Only events the user may view, at locations in their scope,
+// are sent. The stream ends when the access token would expire; EventSource reconnects
+// on its own with the current token cookie, which also picks up permission changes.
+func (eh *EventHandler) StreamHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	allowed := make(map[string]bool, len(services.LiveEventResources))
+	for event, resource := range services.LiveEventResources {
+		if allowed[event], err = hasPermission(c, eh.Access, user, resource, "view"); err != nil {
+			return ResponseError(c, err)
+		}
+	}
+	scope := GetAccessScope(c)
+
+	events, unsubscribe := services.LiveEvents().Subscribe(user.CompanyID)
+	defer unsubscribe()
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set(echo.HeaderCacheControl, "no-cache")
+	response.Header().Set(echo.HeaderConnection, "keep-alive")
+	// Tell nginx not to buffer the stream
+	response.Header().Set("X-Accel-Buffering", "no")
+	response.WriteHeader(http.StatusOK)
+	fmt.Fprint(response, "retry: 3000\n\n")
+	response.Flush()
+
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	expiry := time.NewTimer(services.AccessTokenTTL())
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-expiry.C:
+			return nil
+		case <-heartbeat.C:
+			fmt.Fprint(response, ": ping\n\n")
+			response.Flush()
+		case event := <-events:
+			if !allowed[event.Type] || !inLiveScope(scope, event.LocationIDs) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+			response.Flush()
+		}
+	}
+}
+
+// inLiveScope reports whether any of the event's locations belongs to the scope
+func inLiveScope(scope services.AccessScope, locationIDs []uint) bool {
+	if !scope.Restricted {
+		return true
+	}
+	for _, id := range locationIDs {
+		if scope.Allows(id) {
+			return true
+		}
+	}
+	return false
+}
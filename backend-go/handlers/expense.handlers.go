@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/gonext-tech/invoicing-system/backend/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const maxAttachmentSize = 10 << 20 // 10 MB
+
+type ExpenseService interface {
+	GetCategories() ([]models.ExpenseCategory, error)
+	GetCategory(id string) (models.ExpenseCategory, error)
+	CreateCategory(category models.ExpenseCategory) (models.ExpenseCategory, error)
+	UpdateCategory(category models.ExpenseCategory) (models.ExpenseCategory, error)
+	DeleteCategory(category models.ExpenseCategory) error
+	GetALL(limit, page int, filters map[string]string) (services.PaginationResponse, error)
+	GetID(id string) (models.Expense, error)
+	Create(expense models.Expense) (models.Expense, error)
+	Update(expense models.Expense, updatedBy uint) (models.Expense, error)
+	Delete(expense models.Expense, deletedBy uint) error
+	AddAttachment(attachment models.ExpenseAttachment) (models.ExpenseAttachment, error)
+	GetAttachment(expenseID, attachmentID string) (models.ExpenseAttachment, error)
+	DeleteAttachment(attachment models.ExpenseAttachment) error
+}
+
+type ExpenseHandler struct {
+	ExpenseServices ExpenseService
+}
+
+func NewExpenseHandler(es ExpenseService) *ExpenseHandler {
+	return &ExpenseHandler{
+		ExpenseServices: es,
+	}
+}
+
+type expenseCategoryDTO struct {
+	Name            string  `json:"name"`
+	Description     *string `json:"description"`
+	LedgerAccountID any     `json:"ledger_account_id"`
+	IsActive        any     `json:"is_active"`
+}
+
+type expenseDTO struct {
+	CategoryID      any     `json:"category_id"`
+	Amount          any     `json:"amount"`
+	ExpenseDate     string  `json:"expense_date"`
+	Description     string  `json:"description"`
+	PaymentMethod   string  `json:"payment_method"`
+	MoneyAccountID  any     `json:"money_account_id"`
+	ReferenceNumber *string `json:"reference_number"`
+	VanID           any     `json:"van_id"`
+	LocationID      any     `json:"location_id"`
+	EmployeeID      any     `json:"employee_id"`
+	Notes           *string `json:"notes"`
+}
+
+func (eh *ExpenseHandler) GetCategoriesHandler(c echo.Context) error {
+	categories, err := eh.ExpenseServices.GetCategories()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, categories, "data")
+}
+
+func (eh *ExpenseHandler) CreateCategoryHandler(c echo.Context) error {
+	var dto expenseCategoryDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	category := models.ExpenseCategory{
+		Name:            dto.Name,
+		Description:     dto.Description,
+		LedgerAccountID: convertToUintPtr(dto.LedgerAccountID),
+		IsActive:        true,
+	}
+	if dto.IsActive != nil {
+		category.IsActive = convertToBool(dto.IsActive)
+	}
+
+	response, err := eh.ExpenseServices.CreateCategory(category)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Expense category created successfully", response)
+}
+
+func (eh *ExpenseHandler) UpdateCategoryHandler(c echo.Context) error {
+	category, err := eh.ExpenseServices.GetCategory(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	var dto expenseCategoryDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	if dto.Name != "" {
+		category.Name = dto.Name
+	}
+	if dto.Description != nil {
+		category.Description = dto.Description
+	}
+	category.LedgerAccountID = convertToUintPtr(dto.LedgerAccountID)
+	if dto.IsActive != nil {
+		category.IsActive = convertToBool(dto.IsActive)
+	}
+	category.LedgerAccount = nil
+
+	response, err := eh.ExpenseServices.UpdateCategory(category)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Expense category updated successfully", response)
+}
+
+func (eh *ExpenseHandler) DeleteCategoryHandler(c echo.Context) error {
+	category, err := eh.ExpenseServices.GetCategory(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := eh.ExpenseServices.DeleteCategory(category); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Expense category deleted successfully", nil)
+}
+
+func (eh *ExpenseHandler) GetAllHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	filters := map[string]string{}
+	for _, key := range []string{"category_id", "van_id", "location_id", "employee_id", "money_account_id", "from_date", "to_date", "search"} {
+		filters[key] = c.QueryParam(key)
+	}
+
+	response, err := eh.ExpenseServices.GetALL(limit, page, filters)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (eh *ExpenseHandler) GetIDHandler(c echo.Context) error {
+	expense, err := eh.ExpenseServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, expense, "data")
+}
+
+func (eh *ExpenseHandler) CreateHandler(c echo.Context) error {
+	var dto expenseDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	expense := models.Expense{CreatedBy: user.ID}
+	if err := applyExpenseDTO(&expense, dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	response, err := eh.ExpenseServices.Create(expense)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Expense created successfully", response)
+}
+
+func (eh *ExpenseHandler) UpdateHandler(c echo.Context) error {
+	expense, err := eh.ExpenseServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	var dto expenseDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := applyExpenseDTO(&expense, dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	expense.Category, expense.MoneyAccount, expense.Van, expense.Location, expense.Employee = nil, nil, nil, nil, nil
+	expense.Attachments, expense.CreatedByUser = nil, nil
+
+	response, err := eh.ExpenseServices.Update(expense, user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Expense updated successfully", response)
+}
+
+func (eh *ExpenseHandler) Delete(c echo.Context) error {
+	expense, err := eh.ExpenseServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	if err := eh.ExpenseServices.Delete(expense, user.ID); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Expense deleted successfully", nil)
+}
+
+// UploadAttachmentHandler stores a receipt (multipart field "file") against an expense
+func (eh *ExpenseHandler) UploadAttachmentHandler(c echo.Context) error {
+	expense, err := eh.ExpenseServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return ResponseError(c, errors.New("attachment file is required"))
+	}
+	if file.Size > maxAttachmentSize {
+		return ResponseError(c, errors.New("attachment file is too large"))
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	path, err := utils.SaveUpload(file, "expenses")
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	response, err := eh.ExpenseServices.AddAttachment(models.ExpenseAttachment{
+		ExpenseID:   expense.ID,
+		FileName:    file.Filename,
+		FilePath:    path,
+		ContentType: file.Header.Get("Content-Type"),
+		Size:        file.Size,
+		UploadedBy:  user.ID,
+	})
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Attachment uploaded successfully", response)
+}
+
+func (eh *ExpenseHandler) DownloadAttachmentHandler(c echo.Context) error {
+	attachment, err := eh.ExpenseServices.GetAttachment(c.Param("id"), c.Param("attachment_id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return c.Attachment(attachment.FilePath, attachment.FileName)
+}
+
+func (eh *ExpenseHandler) DeleteAttachmentHandler(c echo.Context) error {
+	attachment, err := eh.ExpenseServices.GetAttachment(c.Param("id"), c.Param("attachment_id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := eh.ExpenseServices.DeleteAttachment(attachment); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Attachment deleted successfully", nil)
+}
+
+func applyExpenseDTO(expense *models.Expense, dto expenseDTO) error {
+	if dto.CategoryID != nil {
+		expense.CategoryID = uint(convertToInt(dto.CategoryID))
+	}
+	if dto.Amount != nil {
+		expense.Amount = convertToFloat64(dto.Amount)
+	}
+	if dto.ExpenseDate != "" {
+		date, err := ParseDate(dto.ExpenseDate)
+		if err != nil {
+			return err
+		}
+		expense.ExpenseDate = date
+	}
+	if dto.Description != "" {
+		expense.Description = dto.Description
+	}
+	if dto.PaymentMethod != "" {
+		expense.PaymentMethod = dto.PaymentMethod
+	}
+	expense.MoneyAccountID = convertToUintPtr(dto.MoneyAccountID)
+	if dto.ReferenceNumber != nil {
+		expense.ReferenceNumber = dto.ReferenceNumber
+	}
+	expense.VanID = convertToUintPtr(dto.VanID)
+	expense.LocationID = convertToUintPtr(dto.LocationID)
+	expense.EmployeeID = convertToUintPtr(dto.EmployeeID)
+	if dto.Notes != nil {
+		expense.Notes = dto.Notes
+	}
+	return nil
+}
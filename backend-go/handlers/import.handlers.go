@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+// maxImportSize limits uploaded import files to 10 MB
+const maxImportSize = 10 << 20
+
+type ImportService interface {
+	Import(kind, fileName string, data []byte, scope services.AccessScope, dryRun bool, createdBy uint) (services.ImportResult, error)
+}
+
+// ImportHandler takes CSV and XLSX files (multipart field "file") of records to create.
+// Files are only checked unless dry_run=false; the rows' errors are in the result.
+type ImportHandler struct {
+	ImportServices ImportService
+}
+
+func NewImportHandler(is ImportService) *ImportHandler {
+	return &ImportHandler{
+		ImportServices: is,
+	}
+}
+
+func (ih *ImportHandler) ProductsHandler(c echo.Context) error {
+	return ih.importFile(c, services.ImportProducts)
+}
+
+func (ih *ImportHandler) CustomersHandler(c echo.Context) error {
+	return ih.importFile(c, services.ImportCustomers)
+}
+
+func (ih *ImportHandler) VendorsHandler(c echo.Context) error {
+	return ih.importFile(c, services.ImportVendors)
+}
+
+// StockHandler adds opening stock balances at the user's locations
+func (ih *ImportHandler) StockHandler(c echo.Context) error {
+	return ih.importFile(c, services.ImportStock)
+}
+
+func (ih *ImportHandler) importFile(c echo.Context, kind string) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return ResponseError(c, errors.New("import file is required"))
+	}
+	if file.Size > maxImportSize {
+		return ResponseError(c, errors.New("import file is too large"))
+	}
+	src, err := file.Open()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	dryRun := c.QueryParam("dry_run") != "false" && c.QueryParam("dry_run") != "0"
+	result, err := ih.ImportServices.Import(kind, file.Filename, data, GetAccessScope(c), dryRun, user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if result.Applied {
+		return ResponseSuccess(c, "imported", result)
+	}
+	return ResponseOK(c, result, "data")
+}
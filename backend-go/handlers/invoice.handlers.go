@@ -8,30 +8,49 @@ import (
 	"time"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type SalesInvoiceService interface {
-	GetALL(filters map[string]string, limit, offset int) ([]models.SalesInvoice, int64, error)
-	GetID(id string) (models.SalesInvoice, error)
-	GetCount() (int64, error)
+	GetALL(scope services.AccessScope, filters map[string]string, limit, offset int) ([]models.SalesInvoice, int64, error)
+	GetID(scope services.AccessScope, id string) (models.SalesInvoice, error)
+	GetCount(scope services.AccessScope) (int64, error)
 	Create(invoice models.SalesInvoice) (models.SalesInvoice, error)
+	CreateSale(invoice models.SalesInvoice, payment *models.Payment) (models.SalesInvoice, error)
 	Update(invoice models.SalesInvoice) (models.SalesInvoice, error)
-	UpdateItem(itemID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error
-	AddItem(invoiceID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error
+	UpdateItem(itemID uint, productID uint, unit services.LineUnit, unitPrice, discountPercent float64, pricing services.LinePrice) error
+	AddItem(invoiceID uint, productID uint, unit services.LineUnit, unitPrice, discountPercent float64, pricing services.LinePrice) error
 	RecalculateTotals(invoiceID uint) error
 	Delete(id string) error
 }
 
+// PriceResolver resolves the price a product sells at, see services.PriceListService
+type PriceResolver interface {
+	Resolve(customerID *uint, productID uint, quantity float64) (services.ResolvedPrice, error)
+}
+
+// PromotionEngine applies promotions to the lines of a new sales invoice, see
+// services.PromotionService
+type PromotionEngine interface {
+	Apply(customerID *uint, locationID uint, items []models.SalesInvoiceItem) error
+}
+
+// UnitConverter reads line quantities entered in a product's packs, see
+// services.ProductUnitService
+type UnitConverter interface {
+	Convert(productID uint, unit string, quantity float64) (services.LineUnit, error)
+}
+
 type PurchaseInvoiceService interface {
-	GetALL(filters map[string]string, limit, offset int) ([]models.PurchaseInvoice, int64, error)
-	GetID(id string) (models.PurchaseInvoice, error)
-	GetCount() (int64, error)
+	GetALL(scope services.AccessScope, filters map[string]string, limit, offset int) ([]models.PurchaseInvoice, int64, error)
+	GetID(scope services.AccessScope, id string) (models.PurchaseInvoice, error)
+	GetCount(scope services.AccessScope) (int64, error)
 	Create(invoice models.PurchaseInvoice) (models.PurchaseInvoice, error)
 	Update(invoice models.PurchaseInvoice) (models.PurchaseInvoice, error)
-	UpdateItem(itemID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error
-	AddItem(invoiceID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error
+	UpdateItem(itemID uint, productID uint, unit services.LineUnit, unitPrice, discountPercent float64) error
+	AddItem(invoiceID uint, productID uint, unit services.LineUnit, unitPrice, discountPercent float64) error
 	RecalculateTotals(invoiceID uint) error
 	Delete(id string) error
 }
@@ -41,20 +60,60 @@ type InvoiceHandler struct {
 	PurchaseInvoiceServices PurchaseInvoiceService
 	StockServices           StockService
 	PaymentServices         PaymentService
+	Prices                  PriceResolver
+	Promotions              PromotionEngine
+	Units                   UnitConverter
+	Access                  PermissionChecker
 }
 
-func NewInvoiceHandler(sis SalesInvoiceService, pis PurchaseInvoiceService, ss StockService, ps PaymentService) *InvoiceHandler {
+func NewInvoiceHandler(sis SalesInvoiceService, pis PurchaseInvoiceService, ss StockService, ps PaymentService, prices PriceResolver, promotions PromotionEngine, units UnitConverter, access PermissionChecker) *InvoiceHandler {
 	return &InvoiceHandler{
 		SalesInvoiceServices:    sis,
 		PurchaseInvoiceServices: pis,
 		StockServices:           ss,
 		PaymentServices:         ps,
+		Prices:                  prices,
+		Promotions:              promotions,
+		Units:                   units,
+		Access:                  access,
+	}
+}
+
+// priceSalesLine resolves the price of a sales line for the customer. A line without a
+// unit price sells at the resolved one. Selling under it needs prices:sell_below_list,
+// and under cost prices:sell_below_cost; lines sold so are flagged. unitPrice is per
+// unit of the line; the price returned is per base unit.
+func (ih *InvoiceHandler) priceSalesLine(c echo.Context, user models.User, customerID *uint, productID uint, unit services.LineUnit, unitPrice *float64, discountPercent float64) (float64, services.LinePrice, error) {
+	resolved, err := ih.Prices.Resolve(customerID, productID, unit.BaseQuantity())
+	if err != nil {
+		return 0, services.LinePrice{}, err
+	}
+	resolved = resolved.InUnit(unit)
+	price := resolved.UnitPrice
+	if unitPrice != nil {
+		price = *unitPrice / unit.Factor
+	}
+
+	flag := resolved.Flag(price, discountPercent)
+	if flag != "" {
+		allowed, err := hasPermission(c, ih.Access, user, "prices", "sell_"+flag)
+		if err != nil {
+			return 0, services.LinePrice{}, err
+		}
+		if !allowed {
+			if flag == services.PriceBelowCost {
+				return 0, services.LinePrice{}, fmt.Errorf("product ID %d can't be sold below its cost", productID)
+			}
+			return 0, services.LinePrice{}, fmt.Errorf("product ID %d can't be sold at %.2f a %s, below its price of %.2f",
+				productID, price*unit.Factor, unit.Name, resolved.UnitPrice*unit.Factor)
+		}
 	}
+	return price, services.LinePrice{ListPrice: resolved.UnitPrice, Flag: flag}, nil
 }
 
 func (ih *InvoiceHandler) StatsHandler(c echo.Context) error {
-	salesCount, _ := ih.SalesInvoiceServices.GetCount()
-	purchaseCount, _ := ih.PurchaseInvoiceServices.GetCount()
+	salesCount, _ := ih.SalesInvoiceServices.GetCount(GetAccessScope(c))
+	purchaseCount, _ := ih.PurchaseInvoiceServices.GetCount(GetAccessScope(c))
 
 	stats := map[string]interface{}{
 		"sales_count":    salesCount,
@@ -88,7 +147,7 @@ func (ih *InvoiceHandler) GetAllHandler(c echo.Context) error {
 	}
 
 	if invoiceType == "purchase" {
-		invoices, total, err := ih.PurchaseInvoiceServices.GetALL(filters, limit, offset)
+		invoices, total, err := ih.PurchaseInvoiceServices.GetALL(GetAccessScope(c), filters, limit, offset)
 		if err != nil {
 			return ResponseError(c, err)
 		}
@@ -110,7 +169,7 @@ func (ih *InvoiceHandler) GetAllHandler(c echo.Context) error {
 	}
 
 	// Sales invoices
-	invoices, total, err := ih.SalesInvoiceServices.GetALL(filters, limit, offset)
+	invoices, total, err := ih.SalesInvoiceServices.GetALL(GetAccessScope(c), filters, limit, offset)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -139,14 +198,14 @@ func (ih *InvoiceHandler) GetIDHandler(c echo.Context) error {
 	}
 
 	if invoiceType == "purchase" {
-		invoice, err := ih.PurchaseInvoiceServices.GetID(id)
+		invoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), id)
 		if err != nil {
 			return ResponseError(c, err)
 		}
 		return ResponseOK(c, invoice, "data")
 	}
 
-	invoice, err := ih.SalesInvoiceServices.GetID(id)
+	invoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), id)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -172,7 +231,7 @@ func (ih *InvoiceHandler) UpdateHandler(c echo.Context) error {
 		}
 
 		// Get existing invoice
-		invoice, err := ih.PurchaseInvoiceServices.GetID(id)
+		invoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), id)
 		if err != nil {
 			return ResponseError(c, err)
 		}
@@ -214,10 +273,13 @@ func (ih *InvoiceHandler) UpdateHandler(c echo.Context) error {
 	}
 
 	// Get existing invoice
-	invoice, err := ih.SalesInvoiceServices.GetID(id)
+	invoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), id)
 	if err != nil {
 		return ResponseError(c, err)
 	}
+	if err := services.CheckSalesInvoiceEditable(invoice); err != nil {
+		return ResponseError(c, err)
+	}
 
 	// Update only provided fields
 	if req.Notes != nil {
@@ -242,6 +304,7 @@ func (ih *InvoiceHandler) CreatePurchaseHandler(c echo.Context) error {
 		Notes         *string `json:"notes"`
 		Items         []struct {
 			ProductID       uint    `json:"product_id"`
+			Unit            string  `json:"unit"` // a pack of the product, its base unit when left out
 			Quantity        float64 `json:"quantity"`
 			UnitPrice       float64 `json:"unit_price"`
 			DiscountPercent float64 `json:"discount_percent"`
@@ -251,6 +314,9 @@ func (ih *InvoiceHandler) CreatePurchaseHandler(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return ResponseError(c, err)
 	}
+	if err := GetAccessScope(c).Check(req.LocationID); err != nil {
+		return ResponseError(c, err)
+	}
 
 	// Parse invoice date
 	var invoiceDate time.Time
@@ -283,16 +349,22 @@ func (ih *InvoiceHandler) CreatePurchaseHandler(c echo.Context) error {
 	var totalAmount float64
 	var items []models.PurchaseInvoiceItem
 	for _, item := range req.Items {
+		unit, err := ih.Units.Convert(item.ProductID, item.Unit, item.Quantity)
+		if err != nil {
+			return ResponseError(c, err)
+		}
 		subtotal := float64(item.Quantity) * item.UnitPrice
 		discountAmount := subtotal * item.DiscountPercent / 100
 		total := subtotal - discountAmount
 		totalAmount += total
 		items = append(items, models.PurchaseInvoiceItem{
 			ProductID:       item.ProductID,
-			Quantity:        item.Quantity,
-			UnitPrice:       item.UnitPrice,
+			Quantity:        unit.BaseQuantity(),
+			UnitPrice:       item.UnitPrice / unit.Factor,
 			DiscountPercent: item.DiscountPercent,
 			Total:           total,
+			Unit:            unit.Name,
+			UnitQuantity:    item.Quantity,
 		})
 	}
 
@@ -326,7 +398,7 @@ func (ih *InvoiceHandler) CreatePurchaseHandler(c echo.Context) error {
 	locationType, locationID := ih.StockServices.GetLocationTypeAndID(req.LocationID)
 
 	// Update stock (add to location)
-	for _, item := range req.Items {
+	for _, item := range items {
 		if err := ih.StockServices.UpdateStock(item.ProductID, locationType, locationID, item.Quantity); err != nil {
 			log.Printf("[PURCHASE INVOICE] Error adding stock: %v", err)
 			return ResponseError(c, err)
@@ -354,6 +426,8 @@ func (ih *InvoiceHandler) CreatePurchaseHandler(c echo.Context) error {
 	if req.PaidAmount > 0 && req.PaymentMethod != nil {
 		payment := models.Payment{
 			InvoiceID:     createdInvoice.ID,
+			InvoiceType:   "purchase",
+			VendorID:      req.VendorID,
 			Amount:        req.PaidAmount,
 			PaymentMethod: *req.PaymentMethod,
 			CreatedBy:     user.ID,
@@ -379,10 +453,11 @@ func (ih *InvoiceHandler) CreateSalesHandler(c echo.Context) error {
 		PaidAmount    float64 `json:"paid_amount"`
 		Notes         *string `json:"notes"`
 		Items         []struct {
-			ProductID       uint    `json:"product_id"`
-			Quantity        float64 `json:"quantity"`
-			UnitPrice       float64 `json:"unit_price"`
-			DiscountPercent float64 `json:"discount_percent"`
+			ProductID       uint     `json:"product_id"`
+			Unit            string   `json:"unit"` // a pack of the product, its base unit when left out
+			Quantity        float64  `json:"quantity"`
+			UnitPrice       *float64 `json:"unit_price"` // left out to sell at the resolved price
+			DiscountPercent float64  `json:"discount_percent"`
 		} `json:"items"`
 	}
 
@@ -393,28 +468,49 @@ func (ih *InvoiceHandler) CreateSalesHandler(c echo.Context) error {
 	if len(req.Items) == 0 {
 		return ResponseError(c, errors.New("invoice must have at least one item"))
 	}
+	if err := GetAccessScope(c).Check(req.LocationID); err != nil {
+		return ResponseError(c, err)
+	}
 
 	user, err := GetUserContext(c)
 	if err != nil {
 		return ResponseError(c, err)
 	}
 
-	// Calculate total
-	var totalAmount float64
 	var items []models.SalesInvoiceItem
 	for _, item := range req.Items {
-		subtotal := float64(item.Quantity) * item.UnitPrice
+		unit, err := ih.Units.Convert(item.ProductID, item.Unit, item.Quantity)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		unitPrice, pricing, err := ih.priceSalesLine(c, user, req.CustomerID, item.ProductID, unit, item.UnitPrice, item.DiscountPercent)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		subtotal := unit.BaseQuantity() * unitPrice
 		discountAmount := subtotal * item.DiscountPercent / 100
 		total := subtotal - discountAmount
-		totalAmount += total
 		items = append(items, models.SalesInvoiceItem{
 			ProductID:       item.ProductID,
-			Quantity:        item.Quantity,
-			UnitPrice:       item.UnitPrice,
+			Quantity:        unit.BaseQuantity(),
+			UnitPrice:       unitPrice,
 			DiscountPercent: item.DiscountPercent,
 			Total:           total,
+			ListPrice:       pricing.ListPrice,
+			PriceFlag:       pricing.Flag,
+			Unit:            unit.Name,
+			UnitQuantity:    item.Quantity,
 		})
 	}
+	if err := ih.Promotions.Apply(req.CustomerID, req.LocationID, items); err != nil {
+		return ResponseError(c, err)
+	}
+
+	// Calculate total
+	var totalAmount float64
+	for _, item := range items {
+		totalAmount += item.Total
+	}
 
 	// Determine payment status
 	paymentStatus := "unpaid"
@@ -436,70 +532,22 @@ func (ih *InvoiceHandler) CreateSalesHandler(c echo.Context) error {
 		Items:         items,
 	}
 
-	createdInvoice, err := ih.SalesInvoiceServices.Create(invoice)
-	if err != nil {
-		return ResponseError(c, err)
-	}
-
-	// Get correct location type and ID
-	locationType, locationID := ih.StockServices.GetLocationTypeAndID(req.LocationID)
-
-	// Validate stock availability before creating sales invoice
-	for _, item := range req.Items {
-		currentStock, err := ih.StockServices.GetProductStock(item.ProductID, locationType, locationID)
-		if err != nil {
-			// Handle case where no stock record exists
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return ResponseError(c, fmt.Errorf("insufficient stock for product ID %d: no stock available", item.ProductID))
-			}
-			return ResponseError(c, err)
-		}
-
-		if currentStock.Quantity < item.Quantity {
-			return ResponseError(c, fmt.Errorf("insufficient stock for product ID %d: available %.2f, required %.2f", item.ProductID, currentStock.Quantity, item.Quantity))
-		}
-	}
-
-	// Update stock (reduce from location)
-	for _, item := range req.Items {
-		if err := ih.StockServices.UpdateStock(item.ProductID, locationType, locationID, -item.Quantity); err != nil {
-			log.Printf("[SALES INVOICE] Error reducing stock: %v", err)
-			return ResponseError(c, err)
-		}
-
-		// Create stock movement record
-		notes := fmt.Sprintf("Sales Invoice #%d", createdInvoice.ID)
-		if err := ih.StockServices.CreateMovement(
-			item.ProductID,
-			"sale",
-			item.Quantity,
-			locationType,
-			locationID,
-			"", // No destination for sales
-			0,  // No destination ID for sales
-			notes,
-			user.ID,
-		); err != nil {
-			log.Printf("[SALES INVOICE] Error creating movement record: %v", err)
-			// Don't fail the invoice if movement recording fails
-		}
-	}
-
 	// Create payment record if there's a paid amount
+	var payment *models.Payment
 	if req.PaidAmount > 0 && req.PaymentMethod != nil {
-		payment := models.Payment{
-			InvoiceID:     createdInvoice.ID,
+		payment = &models.Payment{
+			InvoiceType:   "sales",
+			CustomerID:    req.CustomerID,
 			Amount:        req.PaidAmount,
 			PaymentMethod: *req.PaymentMethod,
 			CreatedBy:     user.ID,
 		}
+	}
 
-		if _, err := ih.PaymentServices.Create(payment); err != nil {
-			log.Printf("[SALES INVOICE] Error creating payment record: %v", err)
-			// Don't fail the invoice if payment recording fails
-		} else {
-			log.Printf("[SALES INVOICE] Payment record created for invoice #%d, amount: %.2f", createdInvoice.ID, req.PaidAmount)
-		}
+	// The invoice, stock, movements and payment are saved together or not at all
+	createdInvoice, err := ih.SalesInvoiceServices.CreateSale(invoice, payment)
+	if err != nil {
+		return ResponseError(c, err)
 	}
 
 	log.Printf("[SALES INVOICE] Invoice #%d created successfully with %d items", createdInvoice.ID, len(req.Items))
@@ -512,10 +560,11 @@ func (ih *InvoiceHandler) UpdateSalesInvoiceItem(c echo.Context) error {
 	itemID := c.Param("item_id")
 
 	var req struct {
-		ProductID       uint    `json:"product_id"`
-		Quantity        float64 `json:"quantity"`
-		UnitPrice       float64 `json:"unit_price"`
-		DiscountPercent float64 `json:"discount_percent"`
+		ProductID       uint     `json:"product_id"`
+		Unit            string   `json:"unit"` // a pack of the product, its base unit when left out
+		Quantity        float64  `json:"quantity"`
+		UnitPrice       *float64 `json:"unit_price"` // left out to sell at the resolved price
+		DiscountPercent float64  `json:"discount_percent"`
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -527,11 +576,21 @@ func (ih *InvoiceHandler) UpdateSalesInvoiceItem(c echo.Context) error {
 		return ResponseError(c, err)
 	}
 
+	// From here on Quantity is in the base unit
+	unit, err := ih.Units.Convert(req.ProductID, req.Unit, req.Quantity)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	req.Quantity = unit.BaseQuantity()
+
 	// Get the invoice and item
-	invoice, err := ih.SalesInvoiceServices.GetID(id)
+	invoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), id)
 	if err != nil {
 		return ResponseError(c, err)
 	}
+	if err := services.CheckSalesInvoiceEditable(invoice); err != nil {
+		return ResponseError(c, err)
+	}
 
 	var itemToUpdate *models.SalesInvoiceItem
 	for i := range invoice.Items {
@@ -551,8 +610,13 @@ func (ih *InvoiceHandler) UpdateSalesInvoiceItem(c echo.Context) error {
 	quantityDiff := req.Quantity - oldQuantity
 	productChanged := req.ProductID != oldProductID
 
+	unitPrice, pricing, err := ih.priceSalesLine(c, user, invoice.CustomerID, req.ProductID, unit, req.UnitPrice, req.DiscountPercent)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
 	// Update the item using the service method
-	if err := ih.SalesInvoiceServices.UpdateItem(itemToUpdate.ID, req.ProductID, req.Quantity, req.UnitPrice, req.DiscountPercent); err != nil {
+	if err := ih.SalesInvoiceServices.UpdateItem(itemToUpdate.ID, req.ProductID, unit, unitPrice, req.DiscountPercent, pricing); err != nil {
 		return ResponseError(c, err)
 	}
 
@@ -562,7 +626,7 @@ func (ih *InvoiceHandler) UpdateSalesInvoiceItem(c echo.Context) error {
 	}
 
 	// Get updated invoice
-	updatedInvoice, err := ih.SalesInvoiceServices.GetID(fmt.Sprintf("%d", invoice.ID))
+	updatedInvoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), fmt.Sprintf("%d", invoice.ID))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -620,7 +684,7 @@ func (ih *InvoiceHandler) UpdateSalesInvoiceItem(c echo.Context) error {
 		}
 
 		// Create stock movement record
-		notes := fmt.Sprintf("Updated sales invoice #%s item", invoice.ID)
+		notes := fmt.Sprintf("Updated sales invoice #%d item", invoice.ID)
 		var movementQuantity float64
 		if productChanged {
 			movementQuantity = req.Quantity
@@ -653,6 +717,7 @@ func (ih *InvoiceHandler) UpdatePurchaseInvoiceItem(c echo.Context) error {
 
 	var req struct {
 		ProductID       uint    `json:"product_id"`
+		Unit            string  `json:"unit"` // a pack of the product, its base unit when left out
 		Quantity        float64 `json:"quantity"`
 		UnitPrice       float64 `json:"unit_price"`
 		DiscountPercent float64 `json:"discount_percent"`
@@ -667,8 +732,15 @@ func (ih *InvoiceHandler) UpdatePurchaseInvoiceItem(c echo.Context) error {
 		return ResponseError(c, err)
 	}
 
+	// From here on Quantity is in the base unit
+	unit, err := ih.Units.Convert(req.ProductID, req.Unit, req.Quantity)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	req.Quantity = unit.BaseQuantity()
+
 	// Get the invoice and item
-	invoice, err := ih.PurchaseInvoiceServices.GetID(id)
+	invoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), id)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -692,7 +764,7 @@ func (ih *InvoiceHandler) UpdatePurchaseInvoiceItem(c echo.Context) error {
 	productChanged := req.ProductID != oldProductID
 
 	// Update the item using the service method
-	if err := ih.PurchaseInvoiceServices.UpdateItem(itemToUpdate.ID, req.ProductID, req.Quantity, req.UnitPrice, req.DiscountPercent); err != nil {
+	if err := ih.PurchaseInvoiceServices.UpdateItem(itemToUpdate.ID, req.ProductID, unit, req.UnitPrice/unit.Factor, req.DiscountPercent); err != nil {
 		return ResponseError(c, err)
 	}
 
@@ -702,7 +774,7 @@ func (ih *InvoiceHandler) UpdatePurchaseInvoiceItem(c echo.Context) error {
 	}
 
 	// Get updated invoice
-	updatedInvoice, err := ih.PurchaseInvoiceServices.GetID(fmt.Sprintf("%d", invoice.ID))
+	updatedInvoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), fmt.Sprintf("%d", invoice.ID))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -729,7 +801,7 @@ func (ih *InvoiceHandler) UpdatePurchaseInvoiceItem(c echo.Context) error {
 		}
 
 		// Create stock movement record
-		notes := fmt.Sprintf("Updated purchase invoice #%s item", invoice.ID)
+		notes := fmt.Sprintf("Updated purchase invoice #%d item", invoice.ID)
 		var movementQuantity float64
 		if productChanged {
 			movementQuantity = req.Quantity
@@ -760,10 +832,11 @@ func (ih *InvoiceHandler) AddSalesInvoiceItem(c echo.Context) error {
 	id := c.Param("id")
 
 	var req struct {
-		ProductID       uint    `json:"product_id"`
-		Quantity        float64 `json:"quantity"`
-		UnitPrice       float64 `json:"unit_price"`
-		DiscountPercent float64 `json:"discount_percent"`
+		ProductID       uint     `json:"product_id"`
+		Unit            string   `json:"unit"` // a pack of the product, its base unit when left out
+		Quantity        float64  `json:"quantity"`
+		UnitPrice       *float64 `json:"unit_price"` // left out to sell at the resolved price
+		DiscountPercent float64  `json:"discount_percent"`
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -775,14 +848,29 @@ func (ih *InvoiceHandler) AddSalesInvoiceItem(c echo.Context) error {
 		return ResponseError(c, err)
 	}
 
+	// From here on Quantity is in the base unit
+	unit, err := ih.Units.Convert(req.ProductID, req.Unit, req.Quantity)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	req.Quantity = unit.BaseQuantity()
+
 	// Get the invoice
-	invoice, err := ih.SalesInvoiceServices.GetID(id)
+	invoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), id)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := services.CheckSalesInvoiceEditable(invoice); err != nil {
+		return ResponseError(c, err)
+	}
+
+	unitPrice, pricing, err := ih.priceSalesLine(c, user, invoice.CustomerID, req.ProductID, unit, req.UnitPrice, req.DiscountPercent)
 	if err != nil {
 		return ResponseError(c, err)
 	}
 
 	// Add the new item
-	if err := ih.SalesInvoiceServices.AddItem(invoice.ID, req.ProductID, req.Quantity, req.UnitPrice, req.DiscountPercent); err != nil {
+	if err := ih.SalesInvoiceServices.AddItem(invoice.ID, req.ProductID, unit, unitPrice, req.DiscountPercent, pricing); err != nil {
 		return ResponseError(c, err)
 	}
 
@@ -792,7 +880,7 @@ func (ih *InvoiceHandler) AddSalesInvoiceItem(c echo.Context) error {
 	}
 
 	// Get updated invoice
-	updatedInvoice, err := ih.SalesInvoiceServices.GetID(fmt.Sprintf("%d", invoice.ID))
+	updatedInvoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), fmt.Sprintf("%d", invoice.ID))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -804,7 +892,7 @@ func (ih *InvoiceHandler) AddSalesInvoiceItem(c echo.Context) error {
 	}
 
 	// Create stock movement record
-	notes := fmt.Sprintf("Added item to sales invoice #%s", invoice.ID)
+	notes := fmt.Sprintf("Added item to sales invoice #%d", invoice.ID)
 	if err := ih.StockServices.CreateMovement(
 		req.ProductID,
 		"sale",
@@ -829,6 +917,7 @@ func (ih *InvoiceHandler) AddPurchaseInvoiceItem(c echo.Context) error {
 
 	var req struct {
 		ProductID       uint    `json:"product_id"`
+		Unit            string  `json:"unit"` // a pack of the product, its base unit when left out
 		Quantity        float64 `json:"quantity"`
 		UnitPrice       float64 `json:"unit_price"`
 		DiscountPercent float64 `json:"discount_percent"`
@@ -843,14 +932,21 @@ func (ih *InvoiceHandler) AddPurchaseInvoiceItem(c echo.Context) error {
 		return ResponseError(c, err)
 	}
 
+	// From here on Quantity is in the base unit
+	unit, err := ih.Units.Convert(req.ProductID, req.Unit, req.Quantity)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	req.Quantity = unit.BaseQuantity()
+
 	// Get the invoice
-	invoice, err := ih.PurchaseInvoiceServices.GetID(id)
+	invoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), id)
 	if err != nil {
 		return ResponseError(c, err)
 	}
 
 	// Add the new item
-	if err := ih.PurchaseInvoiceServices.AddItem(invoice.ID, req.ProductID, req.Quantity, req.UnitPrice, req.DiscountPercent); err != nil {
+	if err := ih.PurchaseInvoiceServices.AddItem(invoice.ID, req.ProductID, unit, req.UnitPrice/unit.Factor, req.DiscountPercent); err != nil {
 		return ResponseError(c, err)
 	}
 
@@ -860,7 +956,7 @@ func (ih *InvoiceHandler) AddPurchaseInvoiceItem(c echo.Context) error {
 	}
 
 	// Get updated invoice
-	updatedInvoice, err := ih.PurchaseInvoiceServices.GetID(fmt.Sprintf("%d", invoice.ID))
+	updatedInvoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), fmt.Sprintf("%d", invoice.ID))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -872,7 +968,7 @@ func (ih *InvoiceHandler) AddPurchaseInvoiceItem(c echo.Context) error {
 	}
 
 	// Create stock movement record
-	notes := fmt.Sprintf("Added item to purchase invoice #%s", invoice.ID)
+	notes := fmt.Sprintf("Added item to purchase invoice #%d", invoice.ID)
 	if err := ih.StockServices.CreateMovement(
 		req.ProductID,
 		"purchase",
@@ -920,7 +1016,7 @@ func (ih *InvoiceHandler) DeleteInvoiceHandler(c echo.Context) error {
 	var items []interface{}
 
 	if invoiceType == "purchase" {
-		invoice, err := ih.PurchaseInvoiceServices.GetID(id)
+		invoice, err := ih.PurchaseInvoiceServices.GetID(GetAccessScope(c), id)
 		if err != nil {
 			tx.Rollback()
 			return ResponseError(c, err)
@@ -931,11 +1027,15 @@ func (ih *InvoiceHandler) DeleteInvoiceHandler(c echo.Context) error {
 			items = append(items, item)
 		}
 	} else {
-		invoice, err := ih.SalesInvoiceServices.GetID(id)
+		invoice, err := ih.SalesInvoiceServices.GetID(GetAccessScope(c), id)
 		if err != nil {
 			tx.Rollback()
 			return ResponseError(c, err)
 		}
+		if err := services.CheckSalesInvoiceEditable(invoice); err != nil {
+			tx.Rollback()
+			return ResponseError(c, err)
+		}
 		locationID = invoice.LocationID
 		invoiceTypeStr = "sales"
 		for _, item := range invoice.Items {
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type LedgerService interface {
+	GetAccounts(accountType string) ([]models.LedgerAccount, error)
+	GetAccount(id string) (models.LedgerAccount, error)
+	CreateAccount(account models.LedgerAccount) (models.LedgerAccount, error)
+	UpdateAccount(account models.LedgerAccount) (models.LedgerAccount, error)
+	DeleteAccount(account models.LedgerAccount) error
+	GetMappings() ([]models.LedgerAccountMapping, error)
+	UpdateMapping(key string, accountID uint) (models.LedgerAccountMapping, error)
+	GetEntries(limit, page int, sourceType, accountID, fromDate, toDate string) (services.PaginationResponse, error)
+	GetEntry(id string) (models.JournalEntry, error)
+	CreateManualEntry(entry models.JournalEntry) (models.JournalEntry, error)
+	DeleteManualEntry(entry models.JournalEntry) error
+	TrialBalance(fromDate, toDate time.Time) (models.TrialBalance, error)
+	ProfitAndLoss(fromDate, toDate time.Time) (models.ProfitAndLoss, error)
+	BalanceSheet(asOf time.Time) (models.BalanceSheet, error)
+	GetPeriods() ([]models.AccountingPeriod, error)
+	CreatePeriod(period models.AccountingPeriod) (models.AccountingPeriod, error)
+	ClosePeriod(id string, closedBy uint) (models.AccountingPeriod, error)
+	ReopenPeriod(id string) (models.AccountingPeriod, error)
+}
+
+type LedgerHandler struct {
+	LedgerServices LedgerService
+}
+
+func NewLedgerHandler(ls LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		LedgerServices: ls,
+	}
+}
+
+type ledgerAccountDTO struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	ParentID any    `json:"parent_id"`
+	IsActive any    `json:"is_active"`
+}
+
+func (lh *LedgerHandler) GetAccountsHandler(c echo.Context) error {
+	accounts, err := lh.LedgerServices.GetAccounts(c.QueryParam("type"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, accounts, "data")
+}
+
+func (lh *LedgerHandler) GetAccountHandler(c echo.Context) error {
+	account, err := lh.LedgerServices.GetAccount(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, account, "data")
+}
+
+func (lh *LedgerHandler) CreateAccountHandler(c echo.Context) error {
+	var dto ledgerAccountDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	account := models.LedgerAccount{
+		Code:     dto.Code,
+		Name:     dto.Name,
+		Type:     dto.Type,
+		ParentID: convertToUintPtr(dto.ParentID),
+		IsActive: true,
+	}
+	if dto.IsActive != nil {
+		account.IsActive = convertToBool(dto.IsActive)
+	}
+
+	response, err := lh.LedgerServices.CreateAccount(account)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Account created successfully", response)
+}
+
+func (lh *LedgerHandler) UpdateAccountHandler(c echo.Context) error {
+	account, err := lh.LedgerServices.GetAccount(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	var dto ledgerAccountDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	if dto.Code != "" {
+		account.Code = dto.Code
+	}
+	if dto.Name != "" {
+		account.Name = dto.Name
+	}
+	if dto.Type != "" {
+		account.Type = dto.Type
+	}
+	account.ParentID = convertToUintPtr(dto.ParentID)
+	if dto.IsActive != nil {
+		account.IsActive = convertToBool(dto.IsActive)
+	}
+	account.Parent = nil
+
+	response, err := lh.LedgerServices.UpdateAccount(account)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Account updated successfully", response)
+}
+
+func (lh *LedgerHandler) DeleteAccountHandler(c echo.Context) error {
+	account, err := lh.LedgerServices.GetAccount(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := lh.LedgerServices.DeleteAccount(account); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Account deleted successfully", nil)
+}
+
+func (lh *LedgerHandler) GetMappingsHandler(c echo.Context) error {
+	mappings, err := lh.LedgerServices.GetMappings()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, mappings, "data")
+}
+
+// UpdateMappingHandler points an automatic posting role (e.g. sales_revenue) at another account
+func (lh *LedgerHandler) UpdateMappingHandler(c echo.Context) error {
+	var req struct {
+		AccountID any `json:"account_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+	accountID := convertToInt(req.AccountID)
+	if accountID <= 0 {
+		return ResponseError(c, errors.New("account_id is required"))
+	}
+
+	response, err := lh.LedgerServices.UpdateMapping(c.Param("key"), uint(accountID))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Mapping updated successfully", response)
+}
+
+func (lh *LedgerHandler) GetEntriesHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	response, err := lh.LedgerServices.GetEntries(
+		limit,
+		page,
+		c.QueryParam("source_type"),
+		c.QueryParam("account_id"),
+		c.QueryParam("from_date"),
+		c.QueryParam("to_date"),
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (lh *LedgerHandler) GetEntryHandler(c echo.Context) error {
+	entry, err := lh.LedgerServices.GetEntry(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, entry, "data")
+}
+
+// CreateEntryHandler records a manual journal entry; debits and credits must balance
+func (lh *LedgerHandler) CreateEntryHandler(c echo.Context) error {
+	var req struct {
+		EntryDate   string `json:"entry_date"`
+		Description string `json:"description"`
+		Lines       []struct {
+			AccountID   any    `json:"account_id"`
+			Debit       any    `json:"debit"`
+			Credit      any    `json:"credit"`
+			Description string `json:"description"`
+		} `json:"lines"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	entry := models.JournalEntry{
+		Description: req.Description,
+		CreatedBy:   user.ID,
+	}
+	if req.EntryDate != "" {
+		if entry.EntryDate, err = ParseDate(req.EntryDate); err != nil {
+			return ResponseError(c, err)
+		}
+	}
+	for _, line := range req.Lines {
+		entry.Lines = append(entry.Lines, models.JournalLine{
+			AccountID:   uint(convertToInt(line.AccountID)),
+			Debit:       convertToFloat64(line.Debit),
+			Credit:      convertToFloat64(line.Credit),
+			Description: line.Description,
+		})
+	}
+
+	response, err := lh.LedgerServices.CreateManualEntry(entry)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Journal entry created successfully", response)
+}
+
+func (lh *LedgerHandler) DeleteEntryHandler(c echo.Context) error {
+	entry, err := lh.LedgerServices.GetEntry(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := lh.LedgerServices.DeleteManualEntry(entry); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Journal entry deleted successfully", nil)
+}
+
+// TrialBalanceHandler query params: from_date, to_date (YYYY-MM-DD)
+func (lh *LedgerHandler) TrialBalanceHandler(c echo.Context) error {
+	fromDate, toDate, err := statementDateRange(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	report, err := lh.LedgerServices.TrialBalance(fromDate, toDate)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, report, "data")
+}
+
+// ProfitAndLossHandler query params: from_date, to_date (YYYY-MM-DD)
+func (lh *LedgerHandler) ProfitAndLossHandler(c echo.Context) error {
+	fromDate, toDate, err := statementDateRange(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	report, err := lh.LedgerServices.ProfitAndLoss(fromDate, toDate)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, report, "data")
+}
+
+// BalanceSheetHandler query params: as_of (YYYY-MM-DD, defaults to today)
+func (lh *LedgerHandler) BalanceSheetHandler(c echo.Context) error {
+	asOf := time.Now()
+	if value := c.QueryParam("as_of"); value != "" {
+		parsed, err := ParseDate(value)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		asOf = parsed
+	}
+	report, err := lh.LedgerServices.BalanceSheet(asOf)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, report, "data")
+}
+
+func (lh *LedgerHandler) GetPeriodsHandler(c echo.Context) error {
+	periods, err := lh.LedgerServices.GetPeriods()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, periods, "data")
+}
+
+func (lh *LedgerHandler) CreatePeriodHandler(c echo.Context) error {
+	var req struct {
+		Name      string `json:"name"`
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+
+	startDate, err := ParseDate(req.StartDate)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	endDate, err := ParseDate(req.EndDate)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	response, err := lh.LedgerServices.CreatePeriod(models.AccountingPeriod{
+		Name:      req.Name,
+		StartDate: startDate,
+		EndDate:   endDate,
+	})
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Accounting period created successfully", response)
+}
+
+func (lh *LedgerHandler) ClosePeriodHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	response, err := lh.LedgerServices.ClosePeriod(c.Param("id"), user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Accounting period closed", response)
+}
+
+func (lh *LedgerHandler) ReopenPeriodHandler(c echo.Context) error {
+	response, err := lh.LedgerServices.ReopenPeriod(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Accounting period reopened", response)
+}
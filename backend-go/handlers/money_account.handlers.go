@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/labstack/echo/v4"
+)
+
+type MoneyAccountService interface {
+	GetALL(accountType, locationID string) ([]models.MoneyAccount, error)
+	GetID(id string) (models.MoneyAccount, error)
+	Create(account models.MoneyAccount, createdBy uint) (models.MoneyAccount, error)
+	Update(account models.MoneyAccount) (models.MoneyAccount, error)
+	Delete(account models.MoneyAccount) error
+	GetTransactions(accountID, fromDate, toDate, reconciled string, limit int) ([]models.MoneyAccountTransaction, error)
+	Transfer(transfer models.MoneyTransfer) (models.MoneyTransfer, error)
+	GetTransfers(accountID string) ([]models.MoneyTransfer, error)
+	Withdraw(accountID uint, amount float64, date time.Time, description string, referenceNumber *string, createdBy uint) (models.MoneyAccountTransaction, error)
+}
+
+type MoneyAccountHandler struct {
+	MoneyAccountServices MoneyAccountService
+}
+
+func NewMoneyAccountHandler(mas MoneyAccountService) *MoneyAccountHandler {
+	return &MoneyAccountHandler{
+		MoneyAccountServices: mas,
+	}
+}
+
+type moneyAccountDTO struct {
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	LocationID     any     `json:"location_id"`
+	VanID          any     `json:"van_id"`
+	BankName       *string `json:"bank_name"`
+	AccountNumber  *string `json:"account_number"`
+	IBAN           *string `json:"iban"`
+	Currency       string  `json:"currency"`
+	OpeningBalance any     `json:"opening_balance"`
+	IsDefault      any     `json:"is_default"`
+	IsActive       any     `json:"is_active"`
+}
+
+func (mh *MoneyAccountHandler) GetAllHandler(c echo.Context) error {
+	accounts, err := mh.MoneyAccountServices.GetALL(c.QueryParam("type"), c.QueryParam("location_id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, accounts, "data")
+}
+
+func (mh *MoneyAccountHandler) GetIDHandler(c echo.Context) error {
+	account, err := mh.MoneyAccountServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, account, "data")
+}
+
+func (mh *MoneyAccountHandler) CreateHandler(c echo.Context) error {
+	var dto moneyAccountDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	account := models.MoneyAccount{
+		Name:           dto.Name,
+		Type:           dto.Type,
+		LocationID:     convertToUintPtr(dto.LocationID),
+		VanID:          convertToUintPtr(dto.VanID),
+		BankName:       dto.BankName,
+		AccountNumber:  dto.AccountNumber,
+		IBAN:           dto.IBAN,
+		Currency:       dto.Currency,
+		OpeningBalance: convertToFloat64(dto.OpeningBalance),
+		IsDefault:      convertToBool(dto.IsDefault),
+		IsActive:       true,
+	}
+	if dto.IsActive != nil {
+		account.IsActive = convertToBool(dto.IsActive)
+	}
+	if account.Currency == "" {
+		account.Currency = "USD"
+	}
+
+	response, err := mh.MoneyAccountServices.Create(account, user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Account created successfully", response)
+}
+
+func (mh *MoneyAccountHandler) UpdateHandler(c echo.Context) error {
+	account, err := mh.MoneyAccountServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	var dto moneyAccountDTO
+	if err := c.Bind(&dto); err != nil {
+		return ResponseError(c, err)
+	}
+
+	if dto.Name != "" {
+		account.Name = dto.Name
+	}
+	if dto.LocationID != nil {
+		account.LocationID = convertToUintPtr(dto.LocationID)
+	}
+	if dto.VanID != nil {
+		account.VanID = convertToUintPtr(dto.VanID)
+	}
+	account.BankName = dto.BankName
+	account.AccountNumber = dto.AccountNumber
+	account.IBAN = dto.IBAN
+	if dto.Currency != "" {
+		account.Currency = dto.Currency
+	}
+	if dto.IsDefault != nil {
+		account.IsDefault = convertToBool(dto.IsDefault)
+	}
+	if dto.IsActive != nil {
+		account.IsActive = convertToBool(dto.IsActive)
+	}
+	account.Location = nil
+	account.Van = nil
+
+	response, err := mh.MoneyAccountServices.Update(account)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Account updated successfully", response)
+}
+
+func (mh *MoneyAccountHandler) Delete(c echo.Context) error {
+	account, err := mh.MoneyAccountServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := mh.MoneyAccountServices.Delete(account); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Account deleted successfully", nil)
+}
+
+// TransactionsHandler returns the ledger of an account.
+// Query params: from_date, to_date (YYYY-MM-DD), reconciled (true/false), limit
+func (mh *MoneyAccountHandler) TransactionsHandler(c echo.Context) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	transactions, err := mh.MoneyAccountServices.GetTransactions(
+		c.Param("id"),
+		c.QueryParam("from_date"),
+		c.QueryParam("to_date"),
+		c.QueryParam("reconciled"),
+		limit,
+	)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, transactions, "data")
+}
+
+// WithdrawHandler takes money out of an account for an expense
+func (mh *MoneyAccountHandler) WithdrawHandler(c echo.Context) error {
+	accountID, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	var req struct {
+		Amount          any     `json:"amount"`
+		Date            string  `json:"date"`
+		Description     string  `json:"description"`
+		ReferenceNumber *string `json:"reference_number"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+	if req.Description == "" {
+		return ResponseError(c, errors.New("description is required"))
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	date := time.Now()
+	if req.Date != "" {
+		if date, err = ParseDate(req.Date); err != nil {
+			return ResponseError(c, err)
+		}
+	}
+
+	transaction, err := mh.MoneyAccountServices.Withdraw(accountID, convertToFloat64(req.Amount), date, req.Description, req.ReferenceNumber, user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Withdrawal recorded successfully", transaction)
+}
+
+func (mh *MoneyAccountHandler) GetTransfersHandler(c echo.Context) error {
+	transfers, err := mh.MoneyAccountServices.GetTransfers(c.QueryParam("account_id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, transfers, "data")
+}
+
+// TransferHandler moves money between two accounts (e.g. van cash box to bank)
+func (mh *MoneyAccountHandler) TransferHandler(c echo.Context) error {
+	var req struct {
+		FromAccountID   any     `json:"from_account_id"`
+		ToAccountID     any     `json:"to_account_id"`
+		Amount          any     `json:"amount"`
+		TransferDate    string  `json:"transfer_date"`
+		ReferenceNumber *string `json:"reference_number"`
+		Notes           *string `json:"notes"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	transfer := models.MoneyTransfer{
+		FromAccountID:   uint(convertToInt(req.FromAccountID)),
+		ToAccountID:     uint(convertToInt(req.ToAccountID)),
+		Amount:          convertToFloat64(req.Amount),
+		ReferenceNumber: req.ReferenceNumber,
+		Notes:           req.Notes,
+		CreatedBy:       user.ID,
+	}
+	if req.TransferDate != "" {
+		if transfer.TransferDate, err = ParseDate(req.TransferDate); err != nil {
+			return ResponseError(c, err)
+		}
+	}
+
+	response, err := mh.MoneyAccountServices.Transfer(transfer)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Transfer completed successfully", response)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type NotificationService interface {
+	GetAll(userID uint, unreadOnly bool, limit, page int) (services.PaginationResponse, error)
+	UnreadCount(userID uint) (int64, error)
+	MarkRead(userID, id uint) (models.Notification, error)
+	MarkAllRead(userID uint) (int64, error)
+	GetPreferences(userID uint) ([]services.NotificationSetting, error)
+	UpdatePreferences(userID uint, settings []services.NotificationSetting) ([]services.NotificationSetting, error)
+}
+
+// NotificationHandler serves the current user's inbox and notification preferences
+type NotificationHandler struct {
+	NotificationServices NotificationService
+}
+
+func NewNotificationHandler(ns NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		NotificationServices: ns,
+	}
+}
+
+// GetAllHandler lists the inbox, newest first; unread=true leaves out read notifications
+func (nh *NotificationHandler) GetAllHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+	unreadOnly, _ := strconv.ParseBool(c.QueryParam("unread"))
+
+	response, err := nh.NotificationServices.GetAll(user.ID, unreadOnly, limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (nh *NotificationHandler) UnreadCountHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	count, err := nh.NotificationServices.UnreadCount(user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, count, "count")
+}
+
+func (nh *NotificationHandler) MarkReadHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid notification id"))
+	}
+	notification, err := nh.NotificationServices.MarkRead(user.ID, uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", notification)
+}
+
+func (nh *NotificationHandler) MarkAllReadHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	count, err := nh.NotificationServices.MarkAllRead(user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, count, "count")
+}
+
+// GetPreferencesHandler lists every event and channel with whether it is on for the user
+func (nh *NotificationHandler) GetPreferencesHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	settings, err := nh.NotificationServices.GetPreferences(user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, settings, "data")
+}
+
+// UpdatePreferencesHandler takes a list of {event, channel, enabled, target}; the
+// webhook channel needs its URL as target. A new webhook signing secret is in the
+// response once, as secret.
+func (nh *NotificationHandler) UpdatePreferencesHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var settings []services.NotificationSetting
+	if err := c.Bind(&settings); err != nil {
+		return ResponseError(c, err)
+	}
+	settings, err = nh.NotificationServices.UpdatePreferences(user.ID, settings)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", settings)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+type PasswordResetService interface {
+	RequestReset(email, ipAddress string) error
+	ResetPassword(token, password string) error
+}
+
+type PasswordResetHandler struct {
+	PasswordResetServices PasswordResetService
+}
+
+func NewPasswordResetHandler(ps PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		PasswordResetServices: ps,
+	}
+}
+
+// ForgotPasswordHandler sends a reset link. It answers the same whether or not the email
+// belongs to an account.
+func (ph *PasswordResetHandler) ForgotPasswordHandler(c echo.Context) error {
+	var formData struct {
+		Email string `json:"email"`
+	}
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := ph.PasswordResetServices.RequestReset(formData.Email, c.RealIP()); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, "If the email belongs to an account, a reset link has been sent", "message")
+}
+
+// ResetPasswordHandler sets a new password with the token from the reset link
+func (ph *PasswordResetHandler) ResetPasswordHandler(c echo.Context) error {
+	var formData struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := ph.PasswordResetServices.ResetPassword(formData.Token, formData.Password); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, "Password has been reset, please log in again", "message")
+}
@@ -5,11 +5,12 @@ import (
 	"strconv"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
 )
 
 type PaymentService interface {
-	GetALL(invoiceID string, limit int) ([]models.Payment, error)
+	GetALL(scope services.AccessScope, invoiceID string, limit int) ([]models.Payment, error)
 	Create(payment models.Payment) (models.Payment, error)
 	DeleteByInvoiceID(invoiceID uint) error
 }
@@ -32,7 +33,7 @@ func (ph *PaymentHandler) GetAllHandler(c echo.Context) error {
 	invoiceID := c.QueryParam("invoice_id")
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 
-	payments, err := ph.PaymentServices.GetALL(invoiceID, limit)
+	payments, err := ph.PaymentServices.GetALL(GetAccessScope(c), invoiceID, limit)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -47,6 +48,7 @@ func (ph *PaymentHandler) CreateHandler(c echo.Context) error {
 		PaymentMethod   string  `json:"payment_method"`
 		ReferenceNumber *string `json:"reference_number"`
 		Notes           *string `json:"notes"`
+		AccountID       *uint   `json:"account_id"`
 	}
 
 	if err := c.Bind(&req); err != nil {
@@ -68,7 +70,7 @@ func (ph *PaymentHandler) CreateHandler(c echo.Context) error {
 	var vendorID *uint
 
 	if req.InvoiceType == "purchase" {
-		invoice, err := ph.PurchaseInvoiceServices.GetID(strconv.Itoa(int(req.InvoiceID)))
+		invoice, err := ph.PurchaseInvoiceServices.GetID(GetAccessScope(c), strconv.Itoa(int(req.InvoiceID)))
 		if err != nil {
 			return ResponseError(c, errors.New("invoice not found"))
 		}
@@ -76,10 +78,13 @@ func (ph *PaymentHandler) CreateHandler(c echo.Context) error {
 		paidAmount = invoice.PaidAmount
 		vendorID = invoice.VendorID
 	} else {
-		invoice, err := ph.SalesInvoiceServices.GetID(strconv.Itoa(int(req.InvoiceID)))
+		invoice, err := ph.SalesInvoiceServices.GetID(GetAccessScope(c), strconv.Itoa(int(req.InvoiceID)))
 		if err != nil {
 			return ResponseError(c, errors.New("invoice not found"))
 		}
+		if err := services.CheckSalesInvoiceEditable(invoice); err != nil {
+			return ResponseError(c, err)
+		}
 		totalAmount = invoice.TotalAmount
 		paidAmount = invoice.PaidAmount
 		if invoice.CustomerID != nil {
@@ -103,6 +108,7 @@ func (ph *PaymentHandler) CreateHandler(c echo.Context) error {
 		PaymentMethod:   req.PaymentMethod,
 		ReferenceNumber: req.ReferenceNumber,
 		Notes:           req.Notes,
+		AccountID:       req.AccountID,
 		AllocationType:  "single",
 		CreatedBy:       user.ID,
 	}
@@ -117,7 +123,7 @@ func (ph *PaymentHandler) CreateHandler(c echo.Context) error {
 
 	if req.InvoiceType == "purchase" {
 		// Update purchase invoice
-		invoice, _ := ph.PurchaseInvoiceServices.GetID(strconv.Itoa(int(req.InvoiceID)))
+		invoice, _ := ph.PurchaseInvoiceServices.GetID(GetAccessScope(c), strconv.Itoa(int(req.InvoiceID)))
 
 		// Use tolerance for floating-point comparison (0.01 = 1 cent)
 		if newPaidAmount >= totalAmount-0.01 {
@@ -134,7 +140,7 @@ func (ph *PaymentHandler) CreateHandler(c echo.Context) error {
 		ph.PurchaseInvoiceServices.Update(invoice)
 	} else {
 		// Update sales invoice
-		invoice, _ := ph.SalesInvoiceServices.GetID(strconv.Itoa(int(req.InvoiceID)))
+		invoice, _ := ph.SalesInvoiceServices.GetID(GetAccessScope(c), strconv.Itoa(int(req.InvoiceID)))
 
 		// Use tolerance for floating-point comparison (0.01 = 1 cent)
 		if newPaidAmount >= totalAmount-0.01 {
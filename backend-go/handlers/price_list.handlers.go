@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type PriceListService interface {
+	GetAll() ([]models.PriceList, error)
+	GetID(id uint) (models.PriceList, error)
+	Create(input services.PriceListInput) (models.PriceList, error)
+	Update(id uint, input services.PriceListInput) (models.PriceList, error)
+	Delete(id uint) error
+	Resolve(customerID *uint, productID uint, quantity float64) (services.ResolvedPrice, error)
+}
+
+type PriceListHandler struct {
+	PriceListServices PriceListService
+}
+
+func NewPriceListHandler(ps PriceListService) *PriceListHandler {
+	return &PriceListHandler{
+		PriceListServices: ps,
+	}
+}
+
+func (ph *PriceListHandler) GetAllHandler(c echo.Context) error {
+	lists, err := ph.PriceListServices.GetAll()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, lists, "data")
+}
+
+func (ph *PriceListHandler) GetIDHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid price list id"))
+	}
+	list, err := ph.PriceListServices.GetID(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, list, "data")
+}
+
+func (ph *PriceListHandler) CreateHandler(c echo.Context) error {
+	var input services.PriceListInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	list, err := ph.PriceListServices.Create(input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", list)
+}
+
+// UpdateHandler replaces the list, items included
+func (ph *PriceListHandler) UpdateHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid price list id"))
+	}
+	var input services.PriceListInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	list, err := ph.PriceListServices.Update(uint(id), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", list)
+}
+
+func (ph *PriceListHandler) DeleteHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid price list id"))
+	}
+	if err := ph.PriceListServices.Delete(uint(id)); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "deleted", nil)
+}
+
+// ResolveHandler returns the price a product sells at, for the optional customer_id and
+// quantity (1 when left out), so the invoice form can show it before saving
+func (ph *PriceListHandler) ResolveHandler(c echo.Context) error {
+	productID, err := strconv.ParseUint(c.QueryParam("product_id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid product id"))
+	}
+	quantity := 1.0
+	if value := c.QueryParam("quantity"); value != "" {
+		if quantity, err = strconv.ParseFloat(value, 64); err != nil || quantity <= 0 {
+			return ResponseError(c, errors.New("invalid quantity"))
+		}
+	}
+	var customerID *uint
+	if value := c.QueryParam("customer_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return ResponseError(c, errors.New("invalid customer id"))
+		}
+		customer := uint(id)
+		customerID = &customer
+	}
+
+	price, err := ph.PriceListServices.Resolve(customerID, uint(productID), quantity)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, price, "data")
+}
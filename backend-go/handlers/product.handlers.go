@@ -10,7 +10,7 @@ import (
 )
 
 type ProductService interface {
-	GetALL(limit, page int, orderBy, sortBy, searchTerm string) (services.PaginationResponse, error)
+	GetALL(limit, page int, orderBy, sortBy, searchTerm string, brandID, categoryID uint, groupByParent bool) (services.PaginationResponse, error)
 	GetID(id string) (models.Product, error)
 	// GetEmail(email string) (models.User, error)
 	Create(product models.Product) (models.Product, error)
@@ -46,7 +46,12 @@ func (ph *ProductHandler) GetAllHandler(c echo.Context) error {
 		sortBy = "name_en"
 	}
 	searchTerm := c.QueryParam("searchTerm")
-	response, err := ph.ProductServices.GetALL(limit, page, orderBy, sortBy, searchTerm)
+	brandID, _ := strconv.ParseUint(c.QueryParam("brand_id"), 10, 64)
+	// category_id includes the products of its subcategories
+	categoryID, _ := strconv.ParseUint(c.QueryParam("category_id"), 10, 64)
+	// group_by=parent lists variants inside their parent product
+	groupByParent := c.QueryParam("group_by") == "parent"
+	response, err := ph.ProductServices.GetALL(limit, page, orderBy, sortBy, searchTerm, uint(brandID), uint(categoryID), groupByParent)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
@@ -72,10 +77,12 @@ func (ph *ProductHandler) CreateHandler(c echo.Context) error {
 		Description   *string `json:"description"`
 		CategoryID    any     `json:"category_id"`     // Accept string or number
 		TypeID        any     `json:"type_id"`         // Accept string or number
+		BrandID       any     `json:"brand_id"`        // Accept string or number
 		UnitPrice     any     `json:"unit_price"`      // Accept string or number
 		CostPrice     any     `json:"cost_price"`      // Accept string or number
-		Unit          string  `json:"unit"`
-		MinStockLevel any     `json:"min_stock_level"` // Accept string or number
+		TaxRate       any     `json:"tax_rate"`        // Accept string or number, empty takes the category's
+		Unit          string  `json:"unit"`            // empty takes the category's
+		MinStockLevel any     `json:"min_stock_level"` // Accept string or number, empty takes the category's
 		IsActive      any     `json:"is_active"`       // Accept bool, number, or string
 	}
 	
@@ -98,6 +105,11 @@ func (ph *ProductHandler) CreateHandler(c echo.Context) error {
 	client.CostPrice = convertToFloat64(dto.CostPrice)
 	client.MinStockLevel = convertToInt(dto.MinStockLevel)
 	client.IsActive = convertToBool(dto.IsActive)
+	client.TaxRate = convertToFloat64Ptr(dto.TaxRate)
+	// What's left empty follows the category
+	client.InheritsTaxRate = client.TaxRate == nil
+	client.InheritsUnit = dto.Unit == ""
+	client.InheritsMinStockLevel = dto.MinStockLevel == nil || dto.MinStockLevel == ""
 
 	// Convert CategoryID (handle string or number)
 	if dto.CategoryID != nil {
@@ -113,6 +125,9 @@ func (ph *ProductHandler) CreateHandler(c echo.Context) error {
 		}
 	}
 
+	// Convert BrandID (handle string or number)
+	client.BrandID = convertToUintPtr(dto.BrandID)
+
 	response, err := ph.ProductServices.Create(client)
 	if err != nil {
 		return ResponseError(c, err)
@@ -136,10 +151,12 @@ func (ph *ProductHandler) UpdateHandler(c echo.Context) error {
 		Description   *string `json:"description"`
 		CategoryID    any     `json:"category_id"`     // Accept string or number
 		TypeID        any     `json:"type_id"`         // Accept string or number
+		BrandID       any     `json:"brand_id"`        // Accept string or number
 		UnitPrice     any     `json:"unit_price"`      // Accept string or number
 		CostPrice     any     `json:"cost_price"`      // Accept string or number
-		Unit          string  `json:"unit"`
-		MinStockLevel any     `json:"min_stock_level"` // Accept string or number
+		TaxRate       any     `json:"tax_rate"`        // Accept string or number, empty takes the category's
+		Unit          string  `json:"unit"`            // empty takes the category's
+		MinStockLevel any     `json:"min_stock_level"` // Accept string or number, empty takes the category's
 		IsActive      any     `json:"is_active"`       // Accept bool, number, or string
 	}
 
@@ -160,6 +177,11 @@ func (ph *ProductHandler) UpdateHandler(c echo.Context) error {
 	client.CostPrice = convertToFloat64(dto.CostPrice)
 	client.MinStockLevel = convertToInt(dto.MinStockLevel)
 	client.IsActive = convertToBool(dto.IsActive)
+	client.TaxRate = convertToFloat64Ptr(dto.TaxRate)
+	// What's left empty follows the category
+	client.InheritsTaxRate = client.TaxRate == nil
+	client.InheritsUnit = dto.Unit == ""
+	client.InheritsMinStockLevel = dto.MinStockLevel == nil || dto.MinStockLevel == ""
 
 	// Convert CategoryID (handle string or number, including null)
 	client.CategoryID = convertToUintPtr(dto.CategoryID)
@@ -167,6 +189,9 @@ func (ph *ProductHandler) UpdateHandler(c echo.Context) error {
 	// Convert TypeID (handle string or number, including null)
 	client.TypeID = convertToUintPtr(dto.TypeID)
 
+	// Convert BrandID (handle string or number, including null)
+	client.BrandID = convertToUintPtr(dto.BrandID)
+
 	response, err := ph.ProductServices.Update(client)
 	if err != nil {
 		return ResponseError(c, err)
@@ -217,6 +242,15 @@ func convertToFloat64(value any) float64 {
 	return 0
 }
 
+// convertToFloat64Ptr converts like convertToFloat64, but returns nil for nil or ""
+func convertToFloat64Ptr(value any) *float64 {
+	if value == nil || value == "" {
+		return nil
+	}
+	num := convertToFloat64(value)
+	return &num
+}
+
 // convertToInt converts various types to int
 // Handles: string, float64, int, or nil (returns 0)
 func convertToInt(value any) int {
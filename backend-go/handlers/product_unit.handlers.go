@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type ProductUnitService interface {
+	GetByProduct(productID uint) ([]models.ProductUnit, error)
+	Replace(productID uint, inputs []services.ProductUnitInput) ([]models.ProductUnit, error)
+}
+
+// ProductUnitHandler manages the packs a product is bought, sold and moved in
+type ProductUnitHandler struct {
+	ProductUnitServices ProductUnitService
+}
+
+func NewProductUnitHandler(ps ProductUnitService) *ProductUnitHandler {
+	return &ProductUnitHandler{
+		ProductUnitServices: ps,
+	}
+}
+
+func (ph *ProductUnitHandler) GetAllHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid product id"))
+	}
+	units, err := ph.ProductUnitServices.GetByProduct(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, units, "data")
+}
+
+// ReplaceHandler sets all the packs of the product, e.g.
+// [{"name": "carton", "factor": 24, "unit_price": 20}]
+func (ph *ProductUnitHandler) ReplaceHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid product id"))
+	}
+	var inputs []services.ProductUnitInput
+	if err := c.Bind(&inputs); err != nil {
+		return ResponseError(c, err)
+	}
+	units, err := ph.ProductUnitServices.Replace(uint(id), inputs)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", units)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type ProductVariantService interface {
+	GetByParent(parentID uint) ([]models.Product, error)
+	Create(parentID uint, input services.VariantInput) (models.Product, error)
+	Update(parentID, id uint, input services.VariantInput) (models.Product, error)
+}
+
+// ProductVariantHandler manages the variants of a parent product. Variants are products,
+// so they're deleted and stocked like any other.
+type ProductVariantHandler struct {
+	ProductVariantServices ProductVariantService
+}
+
+func NewProductVariantHandler(ps ProductVariantService) *ProductVariantHandler {
+	return &ProductVariantHandler{
+		ProductVariantServices: ps,
+	}
+}
+
+func (ph *ProductVariantHandler) GetAllHandler(c echo.Context) error {
+	parentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid product id"))
+	}
+	variants, err := ph.ProductVariantServices.GetByParent(uint(parentID))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, variants, "data")
+}
+
+func (ph *ProductVariantHandler) CreateHandler(c echo.Context) error {
+	parentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid product id"))
+	}
+	var input services.VariantInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	variant, err := ph.ProductVariantServices.Create(uint(parentID), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", variant)
+}
+
+func (ph *ProductVariantHandler) UpdateHandler(c echo.Context) error {
+	parentID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid product id"))
+	}
+	id, err := strconv.ParseUint(c.Param("variant_id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid variant id"))
+	}
+	var input services.VariantInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	variant, err := ph.ProductVariantServices.Update(uint(parentID), uint(id), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", variant)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type PromotionService interface {
+	GetAll(running bool) ([]models.Promotion, error)
+	GetID(id uint) (models.Promotion, error)
+	Create(input services.PromotionInput) (models.Promotion, error)
+	Update(id uint, input services.PromotionInput) (models.Promotion, error)
+	Delete(id uint) error
+	Apply(customerID *uint, locationID uint, items []models.SalesInvoiceItem) error
+}
+
+type PromotionHandler struct {
+	PromotionServices PromotionService
+}
+
+func NewPromotionHandler(ps PromotionService) *PromotionHandler {
+	return &PromotionHandler{
+		PromotionServices: ps,
+	}
+}
+
+// GetAllHandler lists the promotions, only the running ones with running=true
+func (ph *PromotionHandler) GetAllHandler(c echo.Context) error {
+	running, _ := strconv.ParseBool(c.QueryParam("running"))
+	promotions, err := ph.PromotionServices.GetAll(running)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, promotions, "data")
+}
+
+func (ph *PromotionHandler) GetIDHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid promotion id"))
+	}
+	promotion, err := ph.PromotionServices.GetID(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, promotion, "data")
+}
+
+func (ph *PromotionHandler) CreateHandler(c echo.Context) error {
+	var input services.PromotionInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	promotion, err := ph.PromotionServices.Create(input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", promotion)
+}
+
+func (ph *PromotionHandler) UpdateHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid promotion id"))
+	}
+	var input services.PromotionInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	promotion, err := ph.PromotionServices.Update(uint(id), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "updated", promotion)
+}
+
+func (ph *PromotionHandler) DeleteHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid promotion id"))
+	}
+	if err := ph.PromotionServices.Delete(uint(id)); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "deleted", nil)
+}
+
+// EvaluateHandler runs the promotions on a basket without saving anything, so the
+// invoice form can show them before the sale. Lines must carry their unit price.
+func (ph *PromotionHandler) EvaluateHandler(c echo.Context) error {
+	var req struct {
+		CustomerID *uint `json:"customer_id"`
+		LocationID uint  `json:"location_id"`
+		Items      []struct {
+			ProductID       uint    `json:"product_id"`
+			Quantity        float64 `json:"quantity"`
+			UnitPrice       float64 `json:"unit_price"`
+			DiscountPercent float64 `json:"discount_percent"`
+		} `json:"items"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := GetAccessScope(c).Check(req.LocationID); err != nil {
+		return ResponseError(c, err)
+	}
+
+	items := make([]models.SalesInvoiceItem, len(req.Items))
+	for i, item := range req.Items {
+		subtotal := item.Quantity * item.UnitPrice
+		items[i] = models.SalesInvoiceItem{
+			ProductID:       item.ProductID,
+			Quantity:        item.Quantity,
+			UnitPrice:       item.UnitPrice,
+			DiscountPercent: item.DiscountPercent,
+			Total:           subtotal - subtotal*item.DiscountPercent/100,
+		}
+	}
+	if err := ph.PromotionServices.Apply(req.CustomerID, req.LocationID, items); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, items, "data")
+}
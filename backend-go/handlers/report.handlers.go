@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"sort"
+	"strconv"
 	"time"
 
+	"github.com/gonext-tech/invoicing-system/backend/database"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
@@ -17,8 +22,16 @@ func NewReportHandler(db *gorm.DB) *ReportHandler {
 	}
 }
 
+// companyID is the company the reports run for. The raw queries below aren't scoped by
+// the tenant callbacks, so each one filters on it explicitly.
+func (rh *ReportHandler) companyID() uint {
+	companyID, _ := database.CompanyFromContext(rh.db.Statement.Context)
+	return companyID
+}
+
 func (rh *ReportHandler) SalesReportHandler(c echo.Context) error {
 	vanID := c.QueryParam("van_id")
+	brandID := c.QueryParam("brand_id")
 	fromDate := c.QueryParam("from_date")
 	toDate := c.QueryParam("to_date")
 
@@ -44,16 +57,27 @@ func (rh *ReportHandler) SalesReportHandler(c echo.Context) error {
 		LEFT JOIN customers c ON i.customer_id = c.id
 		LEFT JOIN vans v ON i.van_id = v.id
 		LEFT JOIN users u ON i.created_by = u.id
-		WHERE DATE(i.created_at) BETWEEN ? AND ?
+		WHERE i.company_id = ?
+		AND DATE(i.created_at) BETWEEN ? AND ?
 	`
 
-	args := []interface{}{fromDate, toDate}
+	args := []interface{}{rh.companyID(), fromDate, toDate}
 
 	if vanID != "" {
 		query += " AND i.van_id = ?"
 		args = append(args, vanID)
 	}
 
+	// Invoices with at least one product of the brand
+	if brandID != "" {
+		query += ` AND EXISTS (
+			SELECT 1 FROM sales_invoice_items ii
+			JOIN products p ON ii.product_id = p.id
+			WHERE ii.invoice_id = i.id AND p.brand_id = ?
+		)`
+		args = append(args, brandID)
+	}
+
 	query += " ORDER BY i.created_at DESC"
 
 	var sales []map[string]interface{}
@@ -89,6 +113,7 @@ func (rh *ReportHandler) SalesReportHandler(c echo.Context) error {
 
 func (rh *ReportHandler) StockMovementsReportHandler(c echo.Context) error {
 	productID := c.QueryParam("product_id")
+	brandID := c.QueryParam("brand_id")
 	fromDate := c.QueryParam("from_date")
 	toDate := c.QueryParam("to_date")
 
@@ -109,16 +134,22 @@ func (rh *ReportHandler) StockMovementsReportHandler(c echo.Context) error {
 		FROM stock_movements sm
 		JOIN products p ON sm.product_id = p.id
 		LEFT JOIN users u ON sm.created_by = u.id
-		WHERE DATE(sm.created_at) BETWEEN ? AND ?
+		WHERE sm.company_id = ?
+		AND DATE(sm.created_at) BETWEEN ? AND ?
 	`
 
-	args := []interface{}{fromDate, toDate}
+	args := []interface{}{rh.companyID(), fromDate, toDate}
 
 	if productID != "" {
 		query += " AND sm.product_id = ?"
 		args = append(args, productID)
 	}
 
+	if brandID != "" {
+		query += " AND p.brand_id = ?"
+		args = append(args, brandID)
+	}
+
 	query += " ORDER BY sm.created_at DESC LIMIT 500"
 
 	var movements []map[string]interface{}
@@ -145,12 +176,13 @@ func (rh *ReportHandler) ReceivablesReportHandler(c echo.Context) error {
 		FROM sales_invoices i
 		LEFT JOIN customers c ON i.customer_id = c.id
 		LEFT JOIN vans v ON i.van_id = v.id
-		WHERE i.payment_status IN ('unpaid', 'partial')
+		WHERE i.company_id = ?
+		AND i.payment_status IN ('unpaid', 'partial')
 		ORDER BY i.created_at DESC
 	`
 
 	var receivables []map[string]interface{}
-	if err := rh.db.Raw(query).Scan(&receivables).Error; err != nil {
+	if err := rh.db.Raw(query, rh.companyID()).Scan(&receivables).Error; err != nil {
 		return ResponseError(c, err)
 	}
 
@@ -175,7 +207,11 @@ func (rh *ReportHandler) ReceivablesReportHandler(c echo.Context) error {
 	return ResponseOK(c, result, "data")
 }
 
+// ProductPerformanceReportHandler ranks the products by units sold, optionally of one
+// brand_id or category_id (subcategories included)
 func (rh *ReportHandler) ProductPerformanceReportHandler(c echo.Context) error {
+	brandID := c.QueryParam("brand_id")
+	categoryID, _ := strconv.ParseUint(c.QueryParam("category_id"), 10, 64)
 	fromDate := c.QueryParam("from_date")
 	toDate := c.QueryParam("to_date")
 
@@ -193,6 +229,7 @@ func (rh *ReportHandler) ProductPerformanceReportHandler(c echo.Context) error {
 			p.name_en,
 			p.name_ar,
 			c.name_en as category_name,
+			b.name_en as brand_name,
 			SUM(ii.quantity) as total_sold,
 			SUM(ii.total) as total_revenue,
 			COUNT(DISTINCT i.id) as invoice_count
@@ -201,19 +238,156 @@ func (rh *ReportHandler) ProductPerformanceReportHandler(c echo.Context) error {
 		LEFT JOIN sales_invoices i ON ii.invoice_id = i.id
 			AND DATE(i.created_at) BETWEEN ? AND ?
 		LEFT JOIN categories c ON p.category_id = c.id
-		GROUP BY p.id
-		ORDER BY total_sold DESC
-		LIMIT 50
+		LEFT JOIN product_brands b ON p.brand_id = b.id
+		WHERE p.company_id = ?
 	`
 
+	args := []interface{}{fromDate, toDate, rh.companyID()}
+
+	if brandID != "" {
+		query += " AND p.brand_id = ?"
+		args = append(args, brandID)
+	}
+
+	if categoryID != 0 {
+		categories, err := services.CategoryWithDescendants(rh.db, uint(categoryID))
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		query += " AND p.category_id IN (?)"
+		args = append(args, categories)
+	}
+
+	query += " GROUP BY p.id ORDER BY total_sold DESC LIMIT 50"
+
 	var products []map[string]interface{}
-	if err := rh.db.Raw(query, fromDate, toDate).Scan(&products).Error; err != nil {
+	if err := rh.db.Raw(query, args...).Scan(&products).Error; err != nil {
 		return ResponseError(c, err)
 	}
 
 	return ResponseOK(c, products, "data")
 }
 
+// BrandPerformanceReportHandler shows the units, revenue and margin of each brand, with
+// the units and revenue of every van that sold it. Products without a brand are grouped
+// under brand_id 0.
+func (rh *ReportHandler) BrandPerformanceReportHandler(c echo.Context) error {
+	fromDate := c.QueryParam("from_date")
+	toDate := c.QueryParam("to_date")
+
+	if fromDate == "" {
+		fromDate = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	if toDate == "" {
+		toDate = time.Now().Format("2006-01-02")
+	}
+
+	type brandVan struct {
+		BrandID uint    `json:"-"`
+		VanID   uint    `json:"van_id"`
+		VanName string  `json:"van_name"`
+		Units   float64 `json:"units"`
+		Revenue float64 `json:"revenue"`
+	}
+
+	type brandPerformance struct {
+		BrandID       uint       `json:"brand_id"`
+		BrandName     string     `json:"brand_name"`
+		ProductCount  int64      `json:"product_count"`
+		InvoiceCount  int64      `json:"invoice_count"`
+		Units         float64    `json:"units"`
+		Revenue       float64    `json:"revenue"`
+		Cost          float64    `json:"cost"`
+		Margin        float64    `json:"margin"`
+		MarginPercent float64    `json:"margin_percent"`
+		Vans          []brandVan `json:"vans"`
+	}
+
+	args := []interface{}{rh.companyID(), fromDate, toDate}
+
+	var brands []brandPerformance
+	if err := rh.db.Raw(`
+		SELECT
+			COALESCE(p.brand_id, 0) as brand_id,
+			COALESCE(b.name_en, 'Unbranded') as brand_name,
+			COUNT(DISTINCT p.id) as product_count,
+			COUNT(DISTINCT i.id) as invoice_count,
+			SUM(ii.quantity) as units,
+			SUM(ii.total) as revenue,
+			SUM(ii.quantity * p.cost_price) as cost
+		FROM sales_invoice_items ii
+		JOIN sales_invoices i ON ii.invoice_id = i.id
+		JOIN products p ON ii.product_id = p.id
+		LEFT JOIN product_brands b ON p.brand_id = b.id
+		WHERE i.company_id = ?
+		AND i.deleted_at IS NULL
+		AND DATE(i.created_at) BETWEEN ? AND ?
+		GROUP BY COALESCE(p.brand_id, 0), COALESCE(b.name_en, 'Unbranded')
+		ORDER BY revenue DESC
+	`, args...).Scan(&brands).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	var vans []brandVan
+	if err := rh.db.Raw(`
+		SELECT
+			COALESCE(p.brand_id, 0) as brand_id,
+			v.id as van_id,
+			v.name as van_name,
+			SUM(ii.quantity) as units,
+			SUM(ii.total) as revenue
+		FROM sales_invoice_items ii
+		JOIN sales_invoices i ON ii.invoice_id = i.id
+		JOIN products p ON ii.product_id = p.id
+		JOIN locations l ON i.location_id = l.id
+		JOIN vans v ON l.van_id = v.id
+		WHERE i.company_id = ?
+		AND i.deleted_at IS NULL
+		AND DATE(i.created_at) BETWEEN ? AND ?
+		GROUP BY COALESCE(p.brand_id, 0), v.id, v.name
+		ORDER BY units DESC
+	`, args...).Scan(&vans).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	byBrand := make(map[uint][]brandVan)
+	for _, van := range vans {
+		byBrand[van.BrandID] = append(byBrand[van.BrandID], van)
+	}
+
+	var totalUnits, totalRevenue, totalCost float64
+	for i := range brands {
+		brand := &brands[i]
+		brand.Margin = brand.Revenue - brand.Cost
+		if brand.Revenue != 0 {
+			brand.MarginPercent = brand.Margin / brand.Revenue * 100
+		}
+		brand.Vans = byBrand[brand.BrandID]
+		if brand.Vans == nil {
+			brand.Vans = []brandVan{}
+		}
+		totalUnits += brand.Units
+		totalRevenue += brand.Revenue
+		totalCost += brand.Cost
+	}
+
+	summary := map[string]interface{}{
+		"total_units":   totalUnits,
+		"total_revenue": totalRevenue,
+		"total_cost":    totalCost,
+		"total_margin":  totalRevenue - totalCost,
+		"date_from":     fromDate,
+		"date_to":       toDate,
+	}
+
+	result := map[string]interface{}{
+		"brands":  brands,
+		"summary": summary,
+	}
+
+	return ResponseOK(c, result, "data")
+}
+
 func (rh *ReportHandler) LocationSalesReportHandler(c echo.Context) error {
 	fromDate := c.QueryParam("from_date")
 	toDate := c.QueryParam("to_date")
@@ -238,13 +412,14 @@ func (rh *ReportHandler) LocationSalesReportHandler(c echo.Context) error {
 		LEFT JOIN sales_invoices i ON l.id = i.location_id
 			AND DATE(i.created_at) BETWEEN ? AND ?
 		LEFT JOIN sales_invoice_items ii ON i.id = ii.invoice_id
-		WHERE l.is_active = true
+		WHERE l.company_id = ?
+		AND l.is_active = true
 		GROUP BY l.id, l.name
 		ORDER BY total_sales DESC
 	`
 
 	var locationSales []map[string]interface{}
-	if err := rh.db.Raw(query, fromDate, toDate).Scan(&locationSales).Error; err != nil {
+	if err := rh.db.Raw(query, fromDate, toDate, rh.companyID()).Scan(&locationSales).Error; err != nil {
 		return ResponseError(c, err)
 	}
 
@@ -287,6 +462,7 @@ func (rh *ReportHandler) LocationSalesReportHandler(c echo.Context) error {
 
 func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 	dashboard := make(map[string]interface{})
+	companyID := rh.companyID()
 
 	// Total products
 	var totalProducts int64
@@ -299,7 +475,8 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 		SELECT SUM(s.quantity * p.cost_price) as value
 		FROM stocks s
 		JOIN products p ON s.product_id = p.id
-	`).Scan(&inventoryValue)
+		WHERE s.company_id = ?
+	`, companyID).Scan(&inventoryValue)
 	dashboard["inventory_value"] = inventoryValue
 
 	// Today's sales
@@ -310,8 +487,9 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 	rh.db.Raw(`
 		SELECT COUNT(*) as count, SUM(total_amount) as total
 		FROM sales_invoices
-		WHERE DATE(created_at) = CURDATE()
-	`).Scan(&todaySales)
+		WHERE company_id = ?
+		AND DATE(created_at) = CURDATE()
+	`, companyID).Scan(&todaySales)
 	dashboard["today_sales_count"] = todaySales.Count
 	dashboard["today_sales_total"] = todaySales.Total
 
@@ -320,8 +498,9 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 	rh.db.Raw(`
 		SELECT COALESCE(SUM(total_amount - paid_amount), 0) as total
 		FROM sales_invoices
-		WHERE payment_status IN ('unpaid', 'partial')
-	`).Scan(&pendingPayments)
+		WHERE company_id = ?
+		AND payment_status IN ('unpaid', 'partial')
+	`, companyID).Scan(&pendingPayments)
 	dashboard["pending_payments"] = pendingPayments
 
 	// Payables
@@ -329,8 +508,9 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 	rh.db.Raw(`
 		SELECT COALESCE(SUM(total_amount - paid_amount), 0) as total
 		FROM purchase_invoices
-		WHERE payment_status IN ('unpaid', 'partial')
-	`).Scan(&payables)
+		WHERE company_id = ?
+		AND payment_status IN ('unpaid', 'partial')
+	`, companyID).Scan(&payables)
 	dashboard["payables"] = payables
 
 	// Low stock products
@@ -339,8 +519,9 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 		SELECT COUNT(DISTINCT s.product_id) as count
 		FROM stocks s
 		JOIN products p ON s.product_id = p.id
-		WHERE s.quantity <= COALESCE(p.min_stock_level, 10)
-	`).Scan(&lowStockCount)
+		WHERE s.company_id = ?
+		AND s.quantity <= COALESCE(p.min_stock_level, 10)
+	`, companyID).Scan(&lowStockCount)
 	dashboard["low_stock_count"] = lowStockCount
 
 	// Active locations
@@ -362,7 +543,8 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 			COUNT(CASE WHEN status = 'approved' THEN 1 END) as approved_count,
 			COALESCE(SUM(total_amount), 0) as total_amount
 		FROM credit_notes
-	`).Scan(&creditNotes)
+		WHERE company_id = ?
+	`, companyID).Scan(&creditNotes)
 	dashboard["credit_notes_total"] = creditNotes.TotalCount
 	dashboard["credit_notes_pending"] = creditNotes.PendingCount
 	dashboard["credit_notes_approved"] = creditNotes.ApprovedCount
@@ -379,9 +561,10 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 			COUNT(DISTINCT ii.product_id) as top_products
 		FROM sales_invoice_items ii
 		JOIN sales_invoices i ON ii.invoice_id = i.id
-		WHERE MONTH(i.created_at) = MONTH(CURDATE())
+		WHERE i.company_id = ?
+		AND MONTH(i.created_at) = MONTH(CURDATE())
 		AND YEAR(i.created_at) = YEAR(CURDATE())
-	`).Scan(&productRevenue)
+	`, companyID).Scan(&productRevenue)
 	dashboard["product_revenue"] = productRevenue.TotalRevenue
 	dashboard["top_products_count"] = productRevenue.TopProducts
 
@@ -390,11 +573,324 @@ func (rh *ReportHandler) DashboardReportHandler(c echo.Context) error {
 	rh.db.Raw(`
 		SELECT DATE(created_at) as date, SUM(total_amount) as total
 		FROM sales_invoices
-		WHERE created_at >= DATE_SUB(CURDATE(), INTERVAL 7 DAY)
+		WHERE company_id = ?
+		AND created_at >= DATE_SUB(CURDATE(), INTERVAL 7 DAY)
 		GROUP BY DATE(created_at)
 		ORDER BY date ASC
-	`).Scan(&salesChart)
+	`, companyID).Scan(&salesChart)
 	dashboard["sales_chart"] = salesChart
 
 	return ResponseOK(c, dashboard, "data")
 }
+
+// ExpenseReportHandler totals expenses per month, grouped by group_by (category, van, location or employee)
+func (rh *ReportHandler) ExpenseReportHandler(c echo.Context) error {
+	groupBy := c.QueryParam("group_by")
+	fromDate := c.QueryParam("from_date")
+	toDate := c.QueryParam("to_date")
+
+	if fromDate == "" {
+		fromDate = time.Now().AddDate(0, -12, 0).Format("2006-01-02")
+	}
+	if toDate == "" {
+		toDate = time.Now().Format("2006-01-02")
+	}
+
+	var groupColumns, groupJoin string
+	switch groupBy {
+	case "van":
+		groupColumns = "COALESCE(e.van_id, l.van_id) as group_id, v.name as group_name"
+		groupJoin = "LEFT JOIN locations l ON e.location_id = l.id LEFT JOIN vans v ON v.id = COALESCE(e.van_id, l.van_id)"
+	case "location":
+		groupColumns = "e.location_id as group_id, l.name as group_name"
+		groupJoin = "LEFT JOIN locations l ON e.location_id = l.id"
+	case "employee":
+		groupColumns = "e.employee_id as group_id, emp.full_name as group_name"
+		groupJoin = "LEFT JOIN employees emp ON e.employee_id = emp.id"
+	case "", "category":
+		groupBy = "category"
+		groupColumns = "e.category_id as group_id, ec.name as group_name"
+		groupJoin = "LEFT JOIN expense_categories ec ON e.category_id = ec.id"
+	default:
+		return ResponseError(c, errors.New("group_by must be category, van, location or employee"))
+	}
+
+	query := `
+		SELECT
+			DATE_FORMAT(e.expense_date, '%Y-%m') as month,
+			` + groupColumns + `,
+			COUNT(*) as expense_count,
+			SUM(e.amount) as total_amount
+		FROM expenses e
+		` + groupJoin + `
+		WHERE e.company_id = ?
+		AND e.deleted_at IS NULL
+		AND e.expense_date BETWEEN ? AND ?
+	`
+
+	args := []interface{}{rh.companyID(), fromDate, toDate}
+
+	for _, column := range []string{"category_id", "van_id", "location_id", "employee_id"} {
+		if value := c.QueryParam(column); value != "" {
+			query += " AND e." + column + " = ?"
+			args = append(args, value)
+		}
+	}
+
+	query += " GROUP BY month, group_id, group_name ORDER BY month ASC, total_amount DESC"
+
+	var expenses []map[string]interface{}
+	if err := rh.db.Raw(query, args...).Scan(&expenses).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	// Calculate summary
+	var totalAmount float64
+	var totalCount int64
+	for _, expense := range expenses {
+		if amt, ok := expense["total_amount"].(float64); ok {
+			totalAmount += amt
+		}
+		if count, ok := expense["expense_count"].(int64); ok {
+			totalCount += count
+		}
+	}
+
+	summary := map[string]interface{}{
+		"group_by":      groupBy,
+		"total_amount":  totalAmount,
+		"expense_count": totalCount,
+		"date_from":     fromDate,
+		"date_to":       toDate,
+	}
+
+	result := map[string]interface{}{
+		"expenses": expenses,
+		"summary":  summary,
+	}
+
+	return ResponseOK(c, result, "data")
+}
+
+// VanProfitabilityReportHandler shows per van and month: sales, cost of goods sold, gross margin,
+// expenses charged to the van (directly or through its location) and net profit
+func (rh *ReportHandler) VanProfitabilityReportHandler(c echo.Context) error {
+	vanID := c.QueryParam("van_id")
+	fromDate := c.QueryParam("from_date")
+	toDate := c.QueryParam("to_date")
+
+	if fromDate == "" {
+		fromDate = time.Now().AddDate(0, -12, 0).Format("2006-01-02")
+	}
+	if toDate == "" {
+		toDate = time.Now().Format("2006-01-02")
+	}
+
+	type vanMonth struct {
+		VanID        uint    `json:"van_id"`
+		VanName      string  `json:"van_name"`
+		Month        string  `json:"month"`
+		InvoiceCount int64   `json:"invoice_count"`
+		Sales        float64 `json:"sales"`
+		COGS         float64 `json:"cogs"`
+		GrossMargin  float64 `json:"gross_margin"`
+		Expenses     float64 `json:"expenses"`
+		NetProfit    float64 `json:"net_profit"`
+	}
+
+	vanFilter := ""
+	args := []interface{}{rh.companyID(), fromDate, toDate}
+	if vanID != "" {
+		vanFilter = " AND v.id = ?"
+		args = append(args, vanID)
+	}
+
+	var sales []vanMonth
+	if err := rh.db.Raw(`
+		SELECT
+			v.id as van_id,
+			v.name as van_name,
+			DATE_FORMAT(i.created_at, '%Y-%m') as month,
+			COUNT(*) as invoice_count,
+			SUM(i.total_amount) as sales
+		FROM sales_invoices i
+		JOIN locations l ON i.location_id = l.id
+		JOIN vans v ON l.van_id = v.id
+		WHERE i.company_id = ?
+		AND i.deleted_at IS NULL
+		AND DATE(i.created_at) BETWEEN ? AND ?`+vanFilter+`
+		GROUP BY v.id, v.name, month
+	`, args...).Scan(&sales).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	var costs []vanMonth
+	if err := rh.db.Raw(`
+		SELECT
+			v.id as van_id,
+			v.name as van_name,
+			DATE_FORMAT(i.created_at, '%Y-%m') as month,
+			SUM(ii.quantity * p.cost_price) as cogs
+		FROM sales_invoice_items ii
+		JOIN sales_invoices i ON ii.invoice_id = i.id
+		JOIN products p ON ii.product_id = p.id
+		JOIN locations l ON i.location_id = l.id
+		JOIN vans v ON l.van_id = v.id
+		WHERE i.company_id = ?
+		AND i.deleted_at IS NULL
+		AND DATE(i.created_at) BETWEEN ? AND ?`+vanFilter+`
+		GROUP BY v.id, v.name, month
+	`, args...).Scan(&costs).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	var expenses []vanMonth
+	if err := rh.db.Raw(`
+		SELECT
+			v.id as van_id,
+			v.name as van_name,
+			DATE_FORMAT(e.expense_date, '%Y-%m') as month,
+			SUM(e.amount) as expenses
+		FROM expenses e
+		LEFT JOIN locations l ON e.location_id = l.id
+		JOIN vans v ON v.id = COALESCE(e.van_id, l.van_id)
+		WHERE e.company_id = ?
+		AND e.deleted_at IS NULL
+		AND e.expense_date BETWEEN ? AND ?`+vanFilter+`
+		GROUP BY v.id, v.name, month
+	`, args...).Scan(&expenses).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	// Merge the three result sets by van and month
+	rows := make(map[string]*vanMonth)
+	var keys []string
+	row := func(r vanMonth) *vanMonth {
+		key := r.Month + "#" + strconv.Itoa(int(r.VanID))
+		if existing, ok := rows[key]; ok {
+			return existing
+		}
+		rows[key] = &vanMonth{VanID: r.VanID, VanName: r.VanName, Month: r.Month}
+		keys = append(keys, key)
+		return rows[key]
+	}
+	for _, r := range sales {
+		entry := row(r)
+		entry.InvoiceCount = r.InvoiceCount
+		entry.Sales = r.Sales
+	}
+	for _, r := range costs {
+		row(r).COGS = r.COGS
+	}
+	for _, r := range expenses {
+		row(r).Expenses = r.Expenses
+	}
+	sort.Strings(keys)
+
+	var totalSales, totalCOGS, totalExpenses float64
+	vans := make([]vanMonth, 0, len(keys))
+	for _, key := range keys {
+		entry := rows[key]
+		entry.GrossMargin = entry.Sales - entry.COGS
+		entry.NetProfit = entry.GrossMargin - entry.Expenses
+		totalSales += entry.Sales
+		totalCOGS += entry.COGS
+		totalExpenses += entry.Expenses
+		vans = append(vans, *entry)
+	}
+
+	summary := map[string]interface{}{
+		"total_sales":    totalSales,
+		"total_cogs":     totalCOGS,
+		"gross_margin":   totalSales - totalCOGS,
+		"total_expenses": totalExpenses,
+		"net_profit":     totalSales - totalCOGS - totalExpenses,
+		"date_from":      fromDate,
+		"date_to":        toDate,
+	}
+
+	result := map[string]interface{}{
+		"vans":    vans,
+		"summary": summary,
+	}
+
+	return ResponseOK(c, result, "data")
+}
+
+// PromotionReportHandler shows what each promotion cost (the discount it gave) against
+// the sales it was applied to, optionally for one location_id
+func (rh *ReportHandler) PromotionReportHandler(c echo.Context) error {
+	locationID := c.QueryParam("location_id")
+	fromDate := c.QueryParam("from_date")
+	toDate := c.QueryParam("to_date")
+
+	if fromDate == "" {
+		fromDate = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	if toDate == "" {
+		toDate = time.Now().Format("2006-01-02")
+	}
+
+	type promotionCost struct {
+		PromotionID  uint    `json:"promotion_id"`
+		Name         string  `json:"name"`
+		Type         string  `json:"type"`
+		InvoiceCount int64   `json:"invoice_count"`
+		LineCount    int64   `json:"line_count"`
+		Quantity     float64 `json:"quantity"`
+		Sales        float64 `json:"sales"`
+		Cost         float64 `json:"cost"`
+	}
+
+	query := `
+		SELECT
+			pr.id as promotion_id,
+			pr.name,
+			pr.type,
+			COUNT(DISTINCT i.id) as invoice_count,
+			COUNT(*) as line_count,
+			SUM(ii.quantity) as quantity,
+			SUM(ii.total) as sales,
+			SUM(ii.promotion_discount) as cost
+		FROM sales_invoice_items ii
+		JOIN sales_invoices i ON ii.invoice_id = i.id
+		JOIN promotions pr ON ii.promotion_id = pr.id
+		WHERE i.company_id = ?
+		AND i.deleted_at IS NULL
+		AND DATE(i.created_at) BETWEEN ? AND ?
+	`
+
+	args := []interface{}{rh.companyID(), fromDate, toDate}
+
+	if locationID != "" {
+		query += " AND i.location_id = ?"
+		args = append(args, locationID)
+	}
+
+	query += " GROUP BY pr.id, pr.name, pr.type ORDER BY cost DESC"
+
+	var promotions []promotionCost
+	if err := rh.db.Raw(query, args...).Scan(&promotions).Error; err != nil {
+		return ResponseError(c, err)
+	}
+
+	var totalSales, totalCost float64
+	for _, promotion := range promotions {
+		totalSales += promotion.Sales
+		totalCost += promotion.Cost
+	}
+
+	summary := map[string]interface{}{
+		"total_sales": totalSales,
+		"total_cost":  totalCost,
+		"date_from":   fromDate,
+		"date_to":     toDate,
+	}
+
+	result := map[string]interface{}{
+		"promotions": promotions,
+		"summary":    summary,
+	}
+
+	return ResponseOK(c, result, "data")
+}
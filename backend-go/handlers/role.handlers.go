@@ -21,6 +21,9 @@ type RoleServiceInterface interface {
 	GetUserRoles(userID uint) ([]models.Role, error)
 	CheckUserPermission(userID uint, resource, action string) (bool, error)
 	GetUsersWithRoles(companyID uint) ([]map[string]interface{}, error)
+	GetUserLocations(userID uint) ([]uint, error)
+	SetUserLocations(userID uint, locationIDs []uint) error
+	PermissionChecker
 }
 
 type RoleHandler struct {
@@ -148,6 +151,33 @@ func (rh *RoleHandler) AssignPermissions(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	// Nobody can give a role permissions they don't hold themselves
+	user, err := GetUserContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	all, err := rh.RoleService.GetAllPermissions()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	requested := make(map[uint]bool, len(request.PermissionIDs))
+	for _, id := range request.PermissionIDs {
+		requested[id] = true
+	}
+	var granted []models.Permission
+	for _, permission := range all {
+		if requested[permission.ID] {
+			granted = append(granted, permission)
+		}
+	}
+	holds, err := holdsPermissions(c, rh.RoleService, user, granted)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !holds {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "you can't grant permissions you don't have"})
+	}
+
 	if err := rh.RoleService.AssignPermissionsToRole(uint(roleID), request.PermissionIDs); err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
@@ -166,6 +196,23 @@ func (rh *RoleHandler) AssignRoleToUser(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
+	// Nobody can grant a role with more permissions than they hold themselves
+	user, err := GetUserContext(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	role, err := rh.RoleService.GetRoleByID(request.RoleID, user.CompanyID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role not found"})
+	}
+	holds, err := holdsPermissions(c, rh.RoleService, user, role.Permissions)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !holds {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "the role has permissions you don't have"})
+	}
+
 	if err := rh.RoleService.AssignRoleToUser(request.UserID, request.RoleID); err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
@@ -223,3 +270,40 @@ func (rh *RoleHandler) CheckPermission(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]bool{"has_permission": hasPermission})
 }
+
+// GetUserLocations lists the extra locations a location-bound user may access
+func (rh *RoleHandler) GetUserLocations(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	locationIDs, err := rh.RoleService.GetUserLocations(uint(userID))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string][]uint{"location_ids": locationIDs})
+}
+
+// SetUserLocations replaces the extra locations a location-bound user may access
+func (rh *RoleHandler) SetUserLocations(c echo.Context) error {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	var request struct {
+		LocationIDs []uint `json:"location_ids"`
+	}
+
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if err := rh.RoleService.SetUserLocations(uint(userID), request.LocationIDs); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "User locations updated successfully"})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type StatementService interface {
+	GetCustomerStatement(customerID uint, fromDate, toDate time.Time) (models.Statement, error)
+	GetVendorStatement(vendorID uint, fromDate, toDate time.Time) (models.Statement, error)
+}
+
+type StatementHandler struct {
+	StatementServices StatementService
+}
+
+func NewStatementHandler(ss StatementService) *StatementHandler {
+	return &StatementHandler{
+		StatementServices: ss,
+	}
+}
+
+// CustomerStatementHandler returns a customer's statement of account.
+// Query params: from_date, to_date (YYYY-MM-DD), format (json, csv, pdf)
+func (sh *StatementHandler) CustomerStatementHandler(c echo.Context) error {
+	id, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	fromDate, toDate, err := statementDateRange(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	statement, err := sh.StatementServices.GetCustomerStatement(id, fromDate, toDate)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return writeStatement(c, statement)
+}
+
+// VendorStatementHandler returns a vendor's statement of account.
+// Query params: from_date, to_date (YYYY-MM-DD), format (json, csv, pdf)
+func (sh *StatementHandler) VendorStatementHandler(c echo.Context) error {
+	id, err := ParseUint(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	fromDate, toDate, err := statementDateRange(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	statement, err := sh.StatementServices.GetVendorStatement(id, fromDate, toDate)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return writeStatement(c, statement)
+}
+
+// statementDateRange reads from_date/to_date, defaulting to the last 30 days
+func statementDateRange(c echo.Context) (time.Time, time.Time, error) {
+	toDate := time.Now()
+	fromDate := toDate.AddDate(0, 0, -30)
+
+	if value := c.QueryParam("from_date"); value != "" {
+		parsed, err := ParseDate(value)
+		if err != nil {
+			return fromDate, toDate, err
+		}
+		fromDate = parsed
+	}
+	if value := c.QueryParam("to_date"); value != "" {
+		parsed, err := ParseDate(value)
+		if err != nil {
+			return fromDate, toDate, err
+		}
+		toDate = parsed
+	}
+
+	fromDate = time.Date(fromDate.Year(), fromDate.Month(), fromDate.Day(), 0, 0, 0, 0, fromDate.Location())
+	if toDate.Before(fromDate) {
+		return fromDate, toDate, fmt.Errorf("to_date must not be before from_date")
+	}
+	return fromDate, toDate, nil
+}
+
+func writeStatement(c echo.Context, statement models.Statement) error {
+	filename := fmt.Sprintf("statement-%s-%d-%s", statement.PartyType, statement.PartyID, statement.ToDate.Format("20060102"))
+
+	switch c.QueryParam("format") {
+	case "csv":
+		data, err := statementCSV(statement)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+filename+".csv")
+		return c.Blob(http.StatusOK, "text/csv", data)
+	case "pdf":
+		c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+filename+".pdf")
+		return c.Blob(http.StatusOK, "application/pdf", statementPDF(statement))
+	default:
+		return ResponseOK(c, statement, "data")
+	}
+}
+
+func statementCSV(statement models.Statement) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	records := [][]string{
+		{"Statement of account", statement.PartyName},
+		{"Period", statement.FromDate.Format("2006-01-02"), statement.ToDate.Format("2006-01-02")},
+		{},
+		{"Date", "Type", "Reference", "Description", "Debit", "Credit", "Balance"},
+		{statement.FromDate.Format("2006-01-02"), "opening", "", "Opening balance", "", "", formatAmount(statement.OpeningBalance)},
+	}
+	for _, line := range statement.Lines {
+		records = append(records, []string{
+			line.Date.Format("2006-01-02"),
+			line.EntryType,
+			line.Reference,
+			line.Description,
+			formatAmount(line.Debit),
+			formatAmount(line.Credit),
+			formatAmount(line.Balance),
+		})
+	}
+	records = append(records, []string{
+		statement.ToDate.Format("2006-01-02"), "closing", "", "Closing balance",
+		formatAmount(statement.TotalDebit), formatAmount(statement.TotalCredit), formatAmount(statement.ClosingBalance),
+	})
+
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func statementPDF(statement models.Statement) []byte {
+	doc := utils.NewPDFDocument()
+	columns := []float64{40, 105, 175, 265, 400, 465, 530}
+	y := 0.0
+
+	header := func() {
+		doc.AddPage()
+		doc.Text(40, 50, 16, true, "Statement of Account")
+		doc.Text(40, 70, 11, false, fmt.Sprintf("%s: %s", statement.PartyType, statement.PartyName))
+		doc.Text(40, 85, 10, false, fmt.Sprintf("Period: %s to %s",
+			statement.FromDate.Format("2006-01-02"), statement.ToDate.Format("2006-01-02")))
+		for i, title := range []string{"Date", "Type", "Reference", "Description", "Debit", "Credit", "Balance"} {
+			doc.Text(columns[i], 110, 9, true, title)
+		}
+		doc.Line(40, 114, 570, 114)
+		y = 128
+	}
+	row := func(values []string) {
+		if y > utils.PDFPageHeight-50 {
+			header()
+		}
+		for i, value := range values {
+			if i == 3 && len(value) > 30 {
+				value = value[:30]
+			}
+			doc.Text(columns[i], y, 8, false, value)
+		}
+		y += 13
+	}
+
+	header()
+	row([]string{statement.FromDate.Format("2006-01-02"), "", "", "Opening balance", "", "", formatAmount(statement.OpeningBalance)})
+	for _, line := range statement.Lines {
+		row([]string{
+			line.Date.Format("2006-01-02"),
+			line.EntryType,
+			line.Reference,
+			line.Description,
+			formatAmount(line.Debit),
+			formatAmount(line.Credit),
+			formatAmount(line.Balance),
+		})
+	}
+	doc.Line(40, y-8, 570, y-8)
+	y += 4
+	row([]string{statement.ToDate.Format("2006-01-02"), "", "", "Closing balance",
+		formatAmount(statement.TotalDebit), formatAmount(statement.TotalCredit), formatAmount(statement.ClosingBalance)})
+
+	return doc.Bytes()
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
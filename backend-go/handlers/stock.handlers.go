@@ -4,17 +4,18 @@ import (
 	"strconv"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type StockService interface {
-	GetWarehouseStock() ([]map[string]interface{}, error)
-	GetVanStock(vanID string) ([]map[string]interface{}, error)
-	GetLocationStock(locationID string) ([]map[string]interface{}, error)
-	GetAllStockByLocation() ([]map[string]interface{}, error)
-	GetInventorySummary() ([]map[string]interface{}, error)
-	GetMovements(productID, movementType, fromDate, toDate string, limit int) ([]models.StockMovement, error)
+	GetWarehouseStock(scope services.AccessScope) ([]map[string]interface{}, error)
+	GetVanStock(scope services.AccessScope, vanID string) ([]map[string]interface{}, error)
+	GetLocationStock(scope services.AccessScope, locationID string) ([]map[string]interface{}, error)
+	GetAllStockByLocation(scope services.AccessScope) ([]map[string]interface{}, error)
+	GetInventorySummary(scope services.AccessScope, categoryID uint) ([]map[string]interface{}, error)
+	GetMovements(scope services.AccessScope, productID, movementType, fromDate, toDate string, limit int) ([]models.StockMovement, error)
 	CreateMovement(productID uint, movementType string, quantity float64, fromLocationType string, fromLocationID uint, toLocationType string, toLocationID uint, notes string, createdBy uint) error
 	UpdateStock(productID uint, locationType string, locationID uint, quantity float64) error
 	SetStock(productID uint, locationType string, locationID uint, quantity float64) error
@@ -34,7 +35,7 @@ func NewStockHandler(ss StockService) *StockHandler {
 }
 
 func (sh *StockHandler) WarehouseStockHandler(c echo.Context) error {
-	stock, err := sh.StockServices.GetWarehouseStock()
+	stock, err := sh.StockServices.GetWarehouseStock(GetAccessScope(c))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -43,7 +44,7 @@ func (sh *StockHandler) WarehouseStockHandler(c echo.Context) error {
 
 func (sh *StockHandler) VanStockHandler(c echo.Context) error {
 	vanID := c.Param("id")
-	stock, err := sh.StockServices.GetVanStock(vanID)
+	stock, err := sh.StockServices.GetVanStock(GetAccessScope(c), vanID)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -52,7 +53,7 @@ func (sh *StockHandler) VanStockHandler(c echo.Context) error {
 
 func (sh *StockHandler) LocationStockHandler(c echo.Context) error {
 	locationID := c.Param("id")
-	stock, err := sh.StockServices.GetLocationStock(locationID)
+	stock, err := sh.StockServices.GetLocationStock(GetAccessScope(c), locationID)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -60,7 +61,7 @@ func (sh *StockHandler) LocationStockHandler(c echo.Context) error {
 }
 
 func (sh *StockHandler) AllStockHandler(c echo.Context) error {
-	stock, err := sh.StockServices.GetAllStockByLocation()
+	stock, err := sh.StockServices.GetAllStockByLocation(GetAccessScope(c))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -68,7 +69,9 @@ func (sh *StockHandler) AllStockHandler(c echo.Context) error {
 }
 
 func (sh *StockHandler) InventorySummaryHandler(c echo.Context) error {
-	inventory, err := sh.StockServices.GetInventorySummary()
+	// category_id includes the products of its subcategories
+	categoryID, _ := strconv.ParseUint(c.QueryParam("category_id"), 10, 64)
+	inventory, err := sh.StockServices.GetInventorySummary(GetAccessScope(c), uint(categoryID))
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -82,7 +85,7 @@ func (sh *StockHandler) MovementsHandler(c echo.Context) error {
 	toDate := c.QueryParam("to_date")
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 
-	movements, err := sh.StockServices.GetMovements(productID, movementType, fromDate, toDate, limit)
+	movements, err := sh.StockServices.GetMovements(GetAccessScope(c), productID, movementType, fromDate, toDate, limit)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -101,6 +104,9 @@ func (sh *StockHandler) AdjustStockHandler(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return ResponseError(c, err)
 	}
+	if err := GetAccessScope(c).Check(req.LocationID); err != nil {
+		return ResponseError(c, err)
+	}
 
 	// Determine actual location type and ID
 	locationType, locationID := sh.StockServices.GetLocationTypeAndID(req.LocationID)
@@ -125,6 +131,9 @@ func (sh *StockHandler) AddStockHandler(c echo.Context) error {
 	if err := c.Bind(&req); err != nil {
 		return ResponseError(c, err)
 	}
+	if err := GetAccessScope(c).Check(req.LocationID); err != nil {
+		return ResponseError(c, err)
+	}
 
 	// Determine actual location type and ID based on location
 	locationType, locationID := sh.StockServices.GetLocationTypeAndID(req.LocationID)
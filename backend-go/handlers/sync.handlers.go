@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type SyncService interface {
+	Pull(user models.User, request services.SyncPullRequest) (services.SyncPullResponse, error)
+	Push(user models.User, scope services.AccessScope, request services.SyncPushRequest, canPay bool, ipAddress string) (services.SyncPushResponse, error)
+	GetConflicts(all bool, limit, page int) (services.PaginationResponse, error)
+	ResolveConflict(userID, id uint) (models.SyncRecord, error)
+	GetLogs(deviceID string, limit, page int) (services.PaginationResponse, error)
+}
+
+// SyncHandler serves the offline sync API of the mobile van app
+type SyncHandler struct {
+	SyncServices SyncService
+	Access       PermissionChecker
+}
+
+func NewSyncHandler(ss SyncService, access PermissionChecker) *SyncHandler {
+	return &SyncHandler{
+		SyncServices: ss,
+		Access:       access,
+	}
+}
+
+// PullHandler returns products (with their prices), customers and the stock of
+// location_id changed since cursor; leave cursor out for everything. Sections the user
+// may not view come back empty.
+func (sh *SyncHandler) PullHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	request := services.SyncPullRequest{
+		DeviceID:  c.QueryParam("device_id"),
+		Cursor:    c.QueryParam("cursor"),
+		IPAddress: c.RealIP(),
+	}
+	if value := c.QueryParam("location_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return ResponseError(c, errors.New("invalid location id"))
+		}
+		if err := GetAccessScope(c).Check(uint(id)); err != nil {
+			return ResponseError(c, err)
+		}
+		locationID := uint(id)
+		request.LocationID = &locationID
+	}
+	for resource, allowed := range map[string]*bool{
+		"products":  &request.Products,
+		"customers": &request.Customers,
+		"stock":     &request.Stock,
+	} {
+		if *allowed, err = hasPermission(c, sh.Access, user, resource, "view"); err != nil {
+			return ResponseError(c, err)
+		}
+	}
+
+	response, err := sh.SyncServices.Pull(user, request)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, response, "data")
+}
+
+// PushHandler applies invoices and payments recorded offline and returns the outcome of
+// each; see services.SyncService.Push. Payments need payments:create.
+func (sh *SyncHandler) PushHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var request services.SyncPushRequest
+	if err := c.Bind(&request); err != nil {
+		return ResponseError(c, err)
+	}
+	canPay, err := hasPermission(c, sh.Access, user, "payments", "create")
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	response, err := sh.SyncServices.Push(user, GetAccessScope(c), request, canPay, c.RealIP())
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, response, "data")
+}
+
+// GetConflictsHandler lists pushed records flagged for review; all=true includes the
+// resolved ones
+func (sh *SyncHandler) GetConflictsHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+	all, _ := strconv.ParseBool(c.QueryParam("all"))
+
+	response, err := sh.SyncServices.GetConflicts(all, limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (sh *SyncHandler) ResolveConflictHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid sync conflict id"))
+	}
+	record, err := sh.SyncServices.ResolveConflict(user.ID, uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "resolved", record)
+}
+
+// GetLogsHandler lists the sync log, of one device with device_id
+func (sh *SyncHandler) GetLogsHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	response, err := sh.SyncServices.GetLogs(c.QueryParam("device_id"), limit, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
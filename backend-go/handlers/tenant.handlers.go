@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gonext-tech/invoicing-system/backend/database"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const tenantDBKey = "tenant_db"
+
+var errNoCompany = errors.New("user is not assigned to a company")
+
+// TenantMiddleware must run after JWTMiddleware. It gives the request a database handle
+// bound to the user's company (see database.WithCompany), so every GORM statement made
+// through TenantDB only reads and writes that company's records. Changes made through it
+// are audited as the user's (see database.WithActor).
+func TenantMiddleware(db *gorm.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			path := c.Path()
+			// Group catch-all routes only exist to render 404s
+			if path == "/api" || strings.HasSuffix(path, "/*") {
+				return next(c)
+			}
+
+			user, err := GetUserContext(c)
+			if err != nil || user.ID == 0 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid user")
+			}
+			if user.CompanyID == 0 {
+				return echo.NewHTTPError(http.StatusForbidden, errNoCompany.Error())
+			}
+
+			ctx := database.WithCompany(c.Request().Context(), user.CompanyID)
+			actor := database.Actor{UserID: user.ID, IPAddress: c.RealIP()}
+			if key, ok := GetAPIKey(c); ok {
+				actor.APIKeyID = &key.ID
+			}
+			ctx = database.WithActor(ctx, actor)
+			c.Set(tenantDBKey, db.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// TenantDB returns the company-scoped database handle of the current request
+func TenantDB(c echo.Context) (*gorm.DB, error) {
+	if db, ok := c.Get(tenantDBKey).(*gorm.DB); ok {
+		return db, nil
+	}
+	return nil, errNoCompany
+}
@@ -6,24 +6,27 @@ import (
 	"log"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
 )
 
 type TransferService interface {
-	GetALL(status, fromDate, toDate string) ([]models.Transfer, error)
-	GetID(id string) (models.Transfer, error)
+	GetALL(scope services.AccessScope, status, fromDate, toDate string) ([]models.Transfer, error)
+	GetID(scope services.AccessScope, id string) (models.Transfer, error)
 	Create(transfer models.Transfer) (models.Transfer, error)
 }
 
 type TransferHandler struct {
 	TransferServices TransferService
 	StockServices    StockService
+	Units            UnitConverter
 }
 
-func NewTransferHandler(ts TransferService, ss StockService) *TransferHandler {
+func NewTransferHandler(ts TransferService, ss StockService, units UnitConverter) *TransferHandler {
 	return &TransferHandler{
 		TransferServices: ts,
 		StockServices:    ss,
+		Units:            units,
 	}
 }
 func (th *TransferHandler) GetAllHandler(c echo.Context) error {
@@ -31,7 +34,7 @@ func (th *TransferHandler) GetAllHandler(c echo.Context) error {
 	fromDate := c.QueryParam("from_date")
 	toDate := c.QueryParam("to_date")
 
-	transfers, err := th.TransferServices.GetALL(status, fromDate, toDate)
+	transfers, err := th.TransferServices.GetALL(GetAccessScope(c), status, fromDate, toDate)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -40,7 +43,7 @@ func (th *TransferHandler) GetAllHandler(c echo.Context) error {
 
 func (th *TransferHandler) GetIDHandler(c echo.Context) error {
 	id := c.Param("id")
-	transfer, err := th.TransferServices.GetID(id)
+	transfer, err := th.TransferServices.GetID(GetAccessScope(c), id)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -56,6 +59,7 @@ func (th *TransferHandler) CreateHandler(c echo.Context) error {
 		Notes            string `json:"notes"`
 		Items            []struct {
 			ProductID uint    `json:"product_id"`
+			Unit      string  `json:"unit"` // a pack of the product, its base unit when left out
 			Quantity  float64 `json:"quantity"`
 		} `json:"items"`
 	}
@@ -83,6 +87,12 @@ func (th *TransferHandler) CreateHandler(c echo.Context) error {
 		return ResponseError(c, errors.New("to_location_type is required"))
 	}
 
+	// Field users may only move stock out of or into one of their own locations
+	scope := GetAccessScope(c)
+	if !scope.Allows(req.FromLocationID) && !scope.Allows(req.ToLocationID) {
+		return ResponseError(c, services.ErrOutsideScope)
+	}
+
 	log.Printf("[TRANSFER] Creating transfer from %s (ID: %d) to %s (ID: %d)",
 		req.FromLocationType, req.FromLocationID, req.ToLocationType, req.ToLocationID)
 
@@ -92,9 +102,23 @@ func (th *TransferHandler) CreateHandler(c echo.Context) error {
 		return ResponseError(c, err)
 	}
 
+	// Packs move whole; from here on Quantity is in the base unit
+	units := make([]services.LineUnit, len(req.Items))
+	for i, item := range req.Items {
+		unit, err := th.Units.Convert(item.ProductID, item.Unit, item.Quantity)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		if !unit.Whole() {
+			return ResponseError(c, fmt.Errorf("product ID %d: only full %s can be transferred", item.ProductID, unit.Name))
+		}
+		units[i] = unit
+		req.Items[i].Quantity = unit.BaseQuantity()
+	}
+
 	// Validate stock availability
 	for _, item := range req.Items {
-		log.Printf("[TRANSFER] Checking stock for Product ID: %d, Location Type: %s, Location ID: %d, Required Quantity: %d",
+		log.Printf("[TRANSFER] Checking stock for Product ID: %d, Location Type: %s, Location ID: %d, Required Quantity: %.2f",
 			item.ProductID, req.FromLocationType, req.FromLocationID, item.Quantity)
 
 		stock, err := th.StockServices.GetProductStock(item.ProductID, req.FromLocationType, req.FromLocationID)
@@ -135,10 +159,12 @@ func (th *TransferHandler) CreateHandler(c echo.Context) error {
 	}
 
 	// Add items
-	for _, item := range req.Items {
+	for i, item := range req.Items {
 		transfer.Items = append(transfer.Items, models.TransferItem{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
+			ProductID:    item.ProductID,
+			Quantity:     item.Quantity,
+			Unit:         units[i].Name,
+			UnitQuantity: units[i].Quantity,
 		})
 	}
 
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type TwoFactorService interface {
+	GetStatus(user models.User) (services.TwoFactorStatus, error)
+	Setup(user models.User) (services.TwoFactorSetup, error)
+	Enable(userID uint, code string) ([]string, error)
+	RegenerateBackupCodes(userID uint, code string) ([]string, error)
+	Disable(user models.User, code string) error
+	GetCompanyUser(companyID, userID uint) (models.User, error)
+	ResetForUser(companyID, userID uint) error
+}
+
+type TwoFactorHandler struct {
+	TwoFactorServices TwoFactorService
+	Access            UserAccess
+}
+
+func NewTwoFactorHandler(ts TwoFactorService, access UserAccess) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		TwoFactorServices: ts,
+		Access:            access,
+	}
+}
+
+type twoFactorCodeForm struct {
+	Code string `json:"code"`
+}
+
+func (th *TwoFactorHandler) StatusHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	status, err := th.TwoFactorServices.GetStatus(user)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, status, "data")
+}
+
+// SetupHandler returns a new secret and its otpauth:// URI for the authenticator app
+func (th *TwoFactorHandler) SetupHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	setup, err := th.TwoFactorServices.Setup(user)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, setup, "data")
+}
+
+// EnableHandler confirms the setup with a first code and returns the backup codes
+func (th *TwoFactorHandler) EnableHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var formData twoFactorCodeForm
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	codes, err := th.TwoFactorServices.Enable(user.ID, formData.Code)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, map[string]interface{}{"backup_codes": codes}, "data")
+}
+
+func (th *TwoFactorHandler) BackupCodesHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var formData twoFactorCodeForm
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	codes, err := th.TwoFactorServices.RegenerateBackupCodes(user.ID, formData.Code)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, map[string]interface{}{"backup_codes": codes}, "data")
+}
+
+func (th *TwoFactorHandler) DisableHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	var formData twoFactorCodeForm
+	if err := c.Bind(&formData); err != nil {
+		return ResponseError(c, err)
+	}
+	if err := th.TwoFactorServices.Disable(user, formData.Code); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "disabled", nil)
+}
+
+// ResetUserHandler clears another user's two-factor setup and signs them out. Like the
+// other account changes, only a caller holding all of the user's permissions may do it;
+// never an API key, and never on one's own account, which needs a code to disable.
+func (th *TwoFactorHandler) ResetUserHandler(c echo.Context) error {
+	caller, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if _, ok := GetAPIKey(c); ok {
+		return forbidden(c, "two-factor can't be reset with an API key", "")
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid user id"))
+	}
+	if uint(userID) == caller.ID {
+		return ResponseError(c, errors.New("disable your own two-factor with a code instead"))
+	}
+	target, err := th.TwoFactorServices.GetCompanyUser(caller.CompanyID, uint(userID))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := checkManage(c, th.Access, caller, target); err != nil {
+		return forbidden(c, err.Error(), "")
+	}
+	if err := th.TwoFactorServices.ResetForUser(caller.CompanyID, target.ID); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "reset", nil)
+}
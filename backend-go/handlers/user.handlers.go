@@ -10,7 +10,6 @@ import (
 	"github.com/gonext-tech/invoicing-system/backend/models"
 	"github.com/gonext-tech/invoicing-system/backend/services"
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
@@ -22,14 +21,67 @@ type UserService interface {
 	UpdateToDelete(user models.User) (models.User, error)
 }
 
+// UserAccess tells UserHandler what the caller and the user they manage may do
+type UserAccess interface {
+	PermissionChecker
+	GetUserRoles(userID uint) ([]models.Role, error)
+}
+
 type UserHandler struct {
 	UserServices UserService
+	Access       UserAccess
 }
 
-func NewUserHandler(us UserService) *UserHandler {
+func NewUserHandler(us UserService, access UserAccess) *UserHandler {
 	return &UserHandler{
 		UserServices: us,
+		Access:       access,
+	}
+}
+
+// checkRoleChange allows setting target's role to role only with users:assign_role.
+// Admin rights are granted and taken away by admins alone.
+func (uh *UserHandler) checkRoleChange(c echo.Context, caller, target models.User, role string) error {
+	allowed, err := hasPermission(c, uh.Access, caller, "users", "assign_role")
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return errors.New("changing the role of a user needs the users:assign_role permission")
+	}
+	if (services.IsAdminRole(role) || services.IsAdminRole(target.Role)) && !isAdmin(c, caller) {
+		return errors.New("only an admin can grant or take away admin rights")
+	}
+	return nil
+}
+
+// checkManage allows acting on another user's account (editing, deactivating, resetting
+// the password or two-factor) only to a caller who holds every permission target has: an
+// admin only to an admin
+func checkManage(c echo.Context, access UserAccess, caller, target models.User) error {
+	if caller.ID == target.ID {
+		return nil
+	}
+	if services.IsAdminRole(target.Role) {
+		if !isAdmin(c, caller) {
+			return errors.New("only an admin can manage an admin's account")
+		}
+		return nil
+	}
+	roles, err := access.GetUserRoles(target.ID)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		holds, err := holdsPermissions(c, access, caller, role.Permissions)
+		if err != nil {
+			return err
+		}
+		if !holds {
+			return errors.New("the user has permissions you don't have")
+		}
 	}
+	return nil
 }
 
 func (uh *UserHandler) GetAllHandler(c echo.Context) error {
@@ -78,7 +130,7 @@ func (uh *UserHandler) GetIDHandler(c echo.Context) error {
 }
 
 func (uh *UserHandler) CreateHandler(c echo.Context) error {
-	_, err := GetUserContext(c)
+	caller, err := GetUserContext(c)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -111,6 +163,12 @@ func (uh *UserHandler) CreateHandler(c echo.Context) error {
 	if user.Email == "" {
 		return ResponseError(c, errors.New("email is required"))
 	}
+	// New users are plain users unless given a role
+	if user.Role != "" && !strings.EqualFold(user.Role, "USER") {
+		if err := uh.checkRoleChange(c, caller, models.User{}, user.Role); err != nil {
+			return forbidden(c, err.Error(), "")
+		}
+	}
 	existingUser, err := uh.UserServices.GetEmail(user.Email)
 	if err != nil {
 		return ResponseError(c, err)
@@ -118,16 +176,11 @@ func (uh *UserHandler) CreateHandler(c echo.Context) error {
 	if existingUser.ID != 0 {
 		return ResponseError(c, errors.New("email is already exist"))
 	}
-	log.Println("userPassword", len(user.Password))
-	if user.Password == "" || len(user.Password) < 5 {
-		return ResponseError(c, errors.New("password must be at least 6 characters"))
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := services.HashPassword(user.Password)
 	if err != nil {
-		return err
+		return ResponseError(c, err)
 	}
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 
 	user, err = uh.UserServices.Create(user)
 	if err != nil {
@@ -137,7 +190,7 @@ func (uh *UserHandler) CreateHandler(c echo.Context) error {
 }
 
 func (uh *UserHandler) UpdateHandler(c echo.Context) error {
-	_, err := GetUserContext(c)
+	caller, err := GetUserContext(c)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -165,6 +218,15 @@ func (uh *UserHandler) UpdateHandler(c echo.Context) error {
 		return ResponseError(c, err)
 	}
 	
+	if err := checkManage(c, uh.Access, caller, user); err != nil {
+		return forbidden(c, err.Error(), "")
+	}
+	if dto.Role != user.Role {
+		if err := uh.checkRoleChange(c, caller, user, dto.Role); err != nil {
+			return forbidden(c, err.Error(), "")
+		}
+	}
+
 	// Update user fields
 	user.Email = dto.Email
 	user.Phone = dto.Phone
@@ -216,8 +278,10 @@ func (uh *UserHandler) UpdateHandler(c echo.Context) error {
 	return ResponseSuccess(c, "updated", user)
 }
 
+// UpdatePasswordHandler resets a user's password (users:reset_password). Only a caller
+// holding all of the user's permissions may reset it, so no one takes over a stronger account.
 func (uh *UserHandler) UpdatePasswordHandler(c echo.Context) error {
-	_, err := GetUserContext(c)
+	caller, err := GetUserContext(c)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -226,17 +290,20 @@ func (uh *UserHandler) UpdatePasswordHandler(c echo.Context) error {
 	if err != nil {
 		return ResponseError(c, err)
 	}
+	if err := checkManage(c, uh.Access, caller, user); err != nil {
+		return forbidden(c, err.Error(), "")
+	}
 	var formData struct {
 		Password string `json:"password"`
 	}
 	if err = c.Bind(&formData); err != nil {
 		return ResponseError(c, err)
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(formData.Password), bcrypt.DefaultCost)
+	hashedPassword, err := services.HashPassword(formData.Password)
 	if err != nil {
-		return err
+		return ResponseError(c, err)
 	}
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 	user, err = uh.UserServices.Update(user)
 	if err != nil {
 		return ResponseError(c, err)
@@ -245,7 +312,7 @@ func (uh *UserHandler) UpdatePasswordHandler(c echo.Context) error {
 }
 
 func (uh *UserHandler) UpdateToDelete(c echo.Context) error {
-	_, err := GetUserContext(c)
+	caller, err := GetUserContext(c)
 	if err != nil {
 		return ResponseError(c, err)
 	}
@@ -254,6 +321,9 @@ func (uh *UserHandler) UpdateToDelete(c echo.Context) error {
 	if err != nil {
 		return ResponseError(c, err)
 	}
+	if err := checkManage(c, uh.Access, caller, user); err != nil {
+		return forbidden(c, err.Error(), "")
+	}
 
 	if user.Status == "ACTIVE" {
 		user.Status = "NOTACTIVE"
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type VanSettlementService interface {
+	GetALL(limit, page int, vanID, status, fromDate, toDate string) (services.PaginationResponse, error)
+	GetID(id string) (models.VanSettlement, error)
+	GetInvoices(id string) ([]models.SalesInvoice, error)
+	Create(vanID uint, date time.Time, driverID *uint, createdBy uint) (models.VanSettlement, error)
+	Refresh(id string) (models.VanSettlement, error)
+	RecordCount(id string, denominations []models.VanSettlementDenomination, notes *string) (models.VanSettlement, error)
+	Close(id string, closedBy uint) (models.VanSettlement, error)
+	Delete(settlement models.VanSettlement) error
+	GetDriverVariances(userID string) ([]models.DriverCashVariance, error)
+}
+
+type VanSettlementHandler struct {
+	VanSettlementServices VanSettlementService
+}
+
+func NewVanSettlementHandler(vss VanSettlementService) *VanSettlementHandler {
+	return &VanSettlementHandler{
+		VanSettlementServices: vss,
+	}
+}
+
+func (vh *VanSettlementHandler) GetAllHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	response, err := vh.VanSettlementServices.GetALL(
+		limit,
+		page,
+		c.QueryParam("van_id"),
+		c.QueryParam("status"),
+		c.QueryParam("from_date"),
+		c.QueryParam("to_date"),
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (vh *VanSettlementHandler) GetIDHandler(c echo.Context) error {
+	response, err := vh.VanSettlementServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, response, "data")
+}
+
+func (vh *VanSettlementHandler) InvoicesHandler(c echo.Context) error {
+	invoices, err := vh.VanSettlementServices.GetInvoices(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, invoices, "data")
+}
+
+// CreateHandler opens a settlement for a van and day (defaults to today)
+func (vh *VanSettlementHandler) CreateHandler(c echo.Context) error {
+	var req struct {
+		VanID          any    `json:"van_id"`
+		SettlementDate string `json:"settlement_date"`
+		DriverID       any    `json:"driver_id"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+
+	vanID := convertToInt(req.VanID)
+	if vanID <= 0 {
+		return ResponseError(c, errors.New("van_id is required"))
+	}
+
+	date := time.Now()
+	if req.SettlementDate != "" {
+		parsed, err := ParseDate(req.SettlementDate)
+		if err != nil {
+			return ResponseError(c, err)
+		}
+		date = parsed
+	}
+
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+
+	response, err := vh.VanSettlementServices.Create(uint(vanID), date, convertToUintPtr(req.DriverID), user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Settlement created successfully", response)
+}
+
+func (vh *VanSettlementHandler) RefreshHandler(c echo.Context) error {
+	response, err := vh.VanSettlementServices.Refresh(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Settlement recalculated", response)
+}
+
+// CountHandler records the counted cash, e.g. {"denominations": [{"denomination": 20, "quantity": 12}]}
+func (vh *VanSettlementHandler) CountHandler(c echo.Context) error {
+	var req struct {
+		Denominations []struct {
+			Denomination any `json:"denomination"`
+			Quantity     any `json:"quantity"`
+		} `json:"denominations"`
+		Notes *string `json:"notes"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return ResponseError(c, err)
+	}
+
+	denominations := make([]models.VanSettlementDenomination, 0, len(req.Denominations))
+	for _, d := range req.Denominations {
+		denominations = append(denominations, models.VanSettlementDenomination{
+			Denomination: convertToFloat64(d.Denomination),
+			Quantity:     convertToInt(d.Quantity),
+		})
+	}
+
+	response, err := vh.VanSettlementServices.RecordCount(c.Param("id"), denominations, req.Notes)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Cash count recorded", response)
+}
+
+func (vh *VanSettlementHandler) CloseHandler(c echo.Context) error {
+	user, err := GetUserContext(c)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	response, err := vh.VanSettlementServices.Close(c.Param("id"), user.ID)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Settlement closed successfully", response)
+}
+
+func (vh *VanSettlementHandler) Delete(c echo.Context) error {
+	settlement, err := vh.VanSettlementServices.GetID(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if err := vh.VanSettlementServices.Delete(settlement); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "Settlement deleted successfully", nil)
+}
+
+// DriverVariancesHandler lists the shortages and overages of a driver
+func (vh *VanSettlementHandler) DriverVariancesHandler(c echo.Context) error {
+	variances, err := vh.VanSettlementServices.GetDriverVariances(c.Param("id"))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, variances, "data")
+}
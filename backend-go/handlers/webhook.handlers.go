@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+)
+
+type WebhookService interface {
+	GetAll() ([]models.WebhookSubscription, error)
+	GetID(id uint) (models.WebhookSubscription, error)
+	Create(input services.WebhookInput) (models.WebhookSubscription, string, error)
+	Update(id uint, input services.WebhookInput) (models.WebhookSubscription, string, error)
+	Delete(id uint) error
+	GetDeliveries(limit, page int, filters map[string]string) (services.PaginationResponse, error)
+	GetDelivery(id uint) (models.WebhookDelivery, error)
+	Redeliver(id uint) (models.WebhookDelivery, error)
+}
+
+type WebhookHandler struct {
+	WebhookServices WebhookService
+}
+
+func NewWebhookHandler(ws WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		WebhookServices: ws,
+	}
+}
+
+func (wh *WebhookHandler) GetAllHandler(c echo.Context) error {
+	subscriptions, err := wh.WebhookServices.GetAll()
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, subscriptions, "data")
+}
+
+func (wh *WebhookHandler) GetIDHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid webhook id"))
+	}
+	subscription, err := wh.WebhookServices.GetID(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, subscription, "data")
+}
+
+// GetEventsHandler lists the events a webhook can subscribe to
+func (wh *WebhookHandler) GetEventsHandler(c echo.Context) error {
+	return ResponseOK(c, services.WebhookEvents, "data")
+}
+
+// CreateHandler adds a webhook. The response carries the signing secret in "secret"; it
+// can't be retrieved again.
+func (wh *WebhookHandler) CreateHandler(c echo.Context) error {
+	var input services.WebhookInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	subscription, secret, err := wh.WebhookServices.Create(input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", map[string]interface{}{
+		"webhook": subscription,
+		"secret":  secret,
+	})
+}
+
+// UpdateHandler changes a webhook; with rotate_secret the new secret is returned in
+// "secret"
+func (wh *WebhookHandler) UpdateHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid webhook id"))
+	}
+	var input services.WebhookInput
+	if err := c.Bind(&input); err != nil {
+		return ResponseError(c, err)
+	}
+	subscription, secret, err := wh.WebhookServices.Update(uint(id), input)
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	if secret == "" {
+		return ResponseSuccess(c, "updated", subscription)
+	}
+	return ResponseSuccess(c, "updated", map[string]interface{}{
+		"webhook": subscription,
+		"secret":  secret,
+	})
+}
+
+func (wh *WebhookHandler) DeleteHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid webhook id"))
+	}
+	if err := wh.WebhookServices.Delete(uint(id)); err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "deleted", nil)
+}
+
+// GetDeliveriesHandler lists the delivery log; filter with subscription_id, event and
+// status
+func (wh *WebhookHandler) GetDeliveriesHandler(c echo.Context) error {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page <= 0 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("per_page"))
+	if limit <= 0 {
+		limit = 20
+	}
+	filters := map[string]string{
+		"subscription_id": c.QueryParam("subscription_id"),
+		"event":           c.QueryParam("event"),
+		"status":          c.QueryParam("status"),
+	}
+	if id := c.Param("id"); id != "" {
+		filters["subscription_id"] = id
+	}
+
+	response, err := wh.WebhookServices.GetDeliveries(limit, page, filters)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (wh *WebhookHandler) GetDeliveryHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid webhook delivery id"))
+	}
+	delivery, err := wh.WebhookServices.GetDelivery(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseOK(c, delivery, "data")
+}
+
+// RedeliverHandler sends a finished delivery's event again as a new delivery
+func (wh *WebhookHandler) RedeliverHandler(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return ResponseError(c, errors.New("invalid webhook delivery id"))
+	}
+	delivery, err := wh.WebhookServices.Redeliver(uint(id))
+	if err != nil {
+		return ResponseError(c, err)
+	}
+	return ResponseSuccess(c, "created", delivery)
+}
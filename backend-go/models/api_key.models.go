@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// APIKey lets an integration call the API without a login. Requests act on behalf of
+// the user who created the key, but only with the key's own permissions and, when set,
+// only on its location. Only a hash of the key is stored; Prefix identifies it in lists.
+type APIKey struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	CompanyID   uint         `json:"company_id" gorm:"not null;default:0;index"`
+	Name        string       `json:"name" gorm:"size:100;not null"`
+	Prefix      string       `json:"prefix" gorm:"size:16;not null"`
+	KeyHash     string       `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	UserID      uint         `json:"user_id" gorm:"not null;index"`
+	User        *User        `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	LocationID  *uint        `json:"location_id"`
+	Location    *Location    `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:api_key_permissions;"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	LastUsedAt  *time.Time   `json:"last_used_at"`
+	LastUsedIP  string       `json:"last_used_ip" gorm:"size:45"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Approval request statuses. A request is decided once (approved, rejected or cancelled
+// by its requester); approved requests then run and end up executed or failed.
+const (
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalCancelled = "cancelled"
+	ApprovalExecuted  = "executed"
+	ApprovalFailed    = "failed"
+)
+
+// ApprovalRule parks an operation for approval when it goes over Threshold (the meaning
+// depends on the operation, e.g. a discount percentage or a number of units) or, with
+// no threshold, every time. LocationID limits the rule to one location. Holders of
+// ApproverRoleID decide; without one only admins do.
+type ApprovalRule struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	CompanyID      uint      `json:"company_id" gorm:"not null;default:0;index"`
+	Name           string    `json:"name" gorm:"size:100;not null"`
+	Operation      string    `json:"operation" gorm:"size:50;not null;index"`
+	Threshold      *float64  `json:"threshold"`
+	LocationID     *uint     `json:"location_id"`
+	Location       *Location `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	ApproverRoleID *uint     `json:"approver_role_id"`
+	ApproverRole   *Role     `json:"approver_role,omitempty" gorm:"foreignKey:ApproverRoleID"`
+	IsActive       bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ApprovalRequest is an API call held back by an ApprovalRule. Method, Path and Body
+// are the original request, replayed as the requester once approved.
+type ApprovalRequest struct {
+	ID             uint               `json:"id" gorm:"primaryKey"`
+	CompanyID      uint               `json:"company_id" gorm:"not null;default:0;index"`
+	RuleID         uint               `json:"rule_id" gorm:"not null;index"`
+	Rule           *ApprovalRule      `json:"rule,omitempty" gorm:"foreignKey:RuleID"`
+	Operation      string             `json:"operation" gorm:"size:50;not null;index"`
+	Summary        string             `json:"summary" gorm:"size:255"`
+	Amount         float64            `json:"amount"`
+	LocationID     *uint              `json:"location_id"`
+	Method         string             `json:"method" gorm:"size:10;not null"`
+	Path           string             `json:"path" gorm:"size:500;not null"`
+	Body           string             `json:"body" gorm:"type:mediumtext"`
+	Status         string             `json:"status" gorm:"size:20;not null;default:pending;index"`
+	RequestedBy    uint               `json:"requested_by" gorm:"not null;index"`
+	Requester      *User              `json:"requester,omitempty" gorm:"foreignKey:RequestedBy"`
+	RequestedIP    string             `json:"requested_ip" gorm:"size:45"`
+	APIKeyID       *uint              `json:"api_key_id"` // the request was made with this key and runs with it
+	ApproverRoleID *uint              `json:"approver_role_id"`
+	DecidedBy      *uint              `json:"decided_by"`
+	Decider        *User              `json:"decider,omitempty" gorm:"foreignKey:DecidedBy"`
+	DecidedAt      *time.Time         `json:"decided_at"`
+	ResultStatus   int                `json:"result_status"` // HTTP status of the replayed request
+	Result         string             `json:"result" gorm:"type:text"`
+	ExecutedAt     *time.Time         `json:"executed_at"`
+	Decisions      []ApprovalDecision `json:"decisions,omitempty" gorm:"foreignKey:RequestID"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// ApprovalDecision is one step in the history of a request: requested, approved,
+// rejected, cancelled, executed or failed
+type ApprovalDecision struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CompanyID uint      `json:"company_id" gorm:"not null;default:0;index"`
+	RequestID uint      `json:"request_id" gorm:"not null;index"`
+	UserID    *uint     `json:"user_id"`
+	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Action    string    `json:"action" gorm:"size:20;not null"`
+	Comment   string    `json:"comment" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// AuditLog is one created, updated or deleted business record. Changes maps each
+// column to its old and new value; only changed columns are listed on updates.
+type AuditLog struct {
+	ID        uint                   `json:"id" gorm:"primaryKey"`
+	CompanyID uint                   `json:"company_id" gorm:"not null;default:0;index"`
+	UserID    *uint                  `json:"user_id" gorm:"index"` // empty for system changes (seeding, password reset)
+	User      *User                  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	APIKeyID  *uint                  `json:"api_key_id"`
+	IPAddress string                 `json:"ip_address" gorm:"size:45"`
+	Entity    string                 `json:"entity" gorm:"size:50;not null;index:idx_audit_logs_entity,priority:1"` // table name, e.g. sales_invoices
+	EntityID  uint                   `json:"entity_id" gorm:"index:idx_audit_logs_entity,priority:2"`
+	Action    string                 `json:"action" gorm:"size:10;not null"` // create, update, delete
+	Changes   map[string]AuditChange `json:"changes" gorm:"type:text;serializer:json"`
+	CreatedAt time.Time              `json:"created_at" gorm:"index"`
+}
+
+type AuditChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
@@ -2,13 +2,31 @@ package models
 
 import "time"
 
+// Category groups products in a tree of any depth. TaxRate, MinStockLevel and Unit are
+// defaults for the products of the category and its subcategories; left empty they're
+// inherited from the parent category.
 type Category struct {
-	ID          uint       `json:"id" gorm:"primaryKey"`
-	NameEn      string     `json:"name_en" gorm:"size:100;not null"`
-	NameAr      *string    `json:"name_ar" gorm:"size:100"`
-	Description *string    `json:"description" gorm:"type:text"`
-	IsActive    bool       `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	ID            uint              `json:"id" gorm:"primaryKey"`
+	CompanyID     uint              `json:"company_id" gorm:"not null;default:0;index"`
+	ParentID      *uint             `json:"parent_id" gorm:"index"`
+	NameEn        string            `json:"name_en" gorm:"size:100;not null"`
+	NameAr        *string           `json:"name_ar" gorm:"size:100"`
+	Description   *string           `json:"description" gorm:"type:text"`
+	TaxRate       *float64          `json:"tax_rate"`
+	MinStockLevel *int              `json:"min_stock_level"`
+	Unit          *string           `json:"unit" gorm:"size:20"`
+	Defaults      *CategoryDefaults `json:"defaults,omitempty" gorm:"-"` // own and inherited defaults together
+	Children      []Category        `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	IsActive      bool              `json:"is_active" gorm:"default:true"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	DeletedAt     *time.Time        `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// CategoryDefaults are the product defaults that apply in a category, the nearest
+// category up the tree setting each one
+type CategoryDefaults struct {
+	TaxRate       *float64 `json:"tax_rate"`
+	MinStockLevel *int     `json:"min_stock_level"`
+	Unit          *string  `json:"unit"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Company is a tenant. Every business record carries the CompanyID of the company that
+// owns it and is only visible to that company's users.
+type Company struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Name      string     `json:"name" gorm:"size:255;not null"`
+	TaxNumber *string    `json:"tax_number" gorm:"size:100"`
+	Phone     *string    `json:"phone" gorm:"size:50"`
+	Address   *string    `json:"address" gorm:"type:text"`
+	IsActive  bool       `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+}
@@ -4,7 +4,8 @@ import "time"
 
 type CreditNote struct {
 	ID                uint       `json:"id" gorm:"primaryKey"`
-	CreditNoteNumber  string     `json:"credit_note_number" gorm:"size:50;uniqueIndex;not null"`
+	CompanyID         uint       `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_credit_notes_company_number,priority:1"`
+	CreditNoteNumber  string     `json:"credit_note_number" gorm:"size:50;uniqueIndex:idx_credit_notes_company_number,priority:2;not null"`
 	PurchaseInvoiceID *uint      `json:"purchase_invoice_id" gorm:"index"`
 	VendorID          *uint      `json:"vendor_id" gorm:"index"`
 	LocationID        uint       `json:"location_id" gorm:"not null;index"`
@@ -27,6 +28,7 @@ type CreditNote struct {
 
 type CreditNoteItem struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
+	CompanyID    uint      `json:"company_id" gorm:"not null;default:0;index"`
 	CreditNoteID uint      `json:"credit_note_id" gorm:"not null;index"`
 	ProductID    uint      `json:"product_id" gorm:"not null;index"`
 	Quantity     float64   `json:"quantity" gorm:"not null"`
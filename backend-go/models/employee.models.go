@@ -4,6 +4,7 @@ import "time"
 
 type Employee struct {
 	ID          uint       `json:"id" gorm:"primaryKey"`
+	CompanyID   uint       `json:"company_id" gorm:"not null;default:0;index"`
 	FullName    string     `json:"full_name" gorm:"size:100;not null"`
 	Phone       *string    `json:"phone" gorm:"size:20"`
 	Email       *string    `json:"email" gorm:"size:100"`
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+type ExpenseCategory struct {
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	CompanyID       uint           `json:"company_id" gorm:"not null;default:0;index"`
+	Name            string         `json:"name" gorm:"size:100;not null"`
+	Description     *string        `json:"description" gorm:"type:text"`
+	LedgerAccountID *uint          `json:"ledger_account_id"` // Expense account to post to; defaults to the operating_expenses mapping
+	LedgerAccount   *LedgerAccount `json:"ledger_account,omitempty" gorm:"foreignKey:LedgerAccountID"`
+	IsActive        bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       *time.Time     `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// Expense is an operating cost such as fuel, rent or salaries. It can be linked to the
+// van, location or employee it was spent on, and paid from a cash box or bank account.
+type Expense struct {
+	ID                   uint                `json:"id" gorm:"primaryKey"`
+	CompanyID            uint                `json:"company_id" gorm:"not null;default:0;index"`
+	CategoryID           uint                `json:"category_id" gorm:"not null;index"`
+	Category             *ExpenseCategory    `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Amount               float64             `json:"amount" gorm:"type:decimal(15,2);not null"`
+	ExpenseDate          time.Time           `json:"expense_date" gorm:"type:date;not null;index"`
+	Description          string              `json:"description" gorm:"size:255"`
+	PaymentMethod        string              `json:"payment_method" gorm:"size:20"` // cash, card, bank_transfer, unpaid
+	MoneyAccountID       *uint               `json:"money_account_id" gorm:"index"` // Cash box or bank account the money came out of
+	MoneyAccount         *MoneyAccount       `json:"money_account,omitempty" gorm:"foreignKey:MoneyAccountID"`
+	AccountTransactionID *uint               `json:"account_transaction_id"` // Withdrawal posted to the money account
+	ReferenceNumber      *string             `json:"reference_number" gorm:"size:100"`
+	VanID                *uint               `json:"van_id" gorm:"index"`
+	Van                  *Van                `json:"van,omitempty" gorm:"foreignKey:VanID"`
+	LocationID           *uint               `json:"location_id" gorm:"index"`
+	Location             *Location           `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	EmployeeID           *uint               `json:"employee_id" gorm:"index"`
+	Employee             *Employee           `json:"employee,omitempty" gorm:"foreignKey:EmployeeID"`
+	Notes                *string             `json:"notes" gorm:"type:text"`
+	Attachments          []ExpenseAttachment `json:"attachments,omitempty" gorm:"foreignKey:ExpenseID"`
+	CreatedBy            uint                `json:"created_by"`
+	CreatedByUser        *User               `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
+	CreatedAt            time.Time           `json:"created_at"`
+	UpdatedAt            time.Time           `json:"updated_at"`
+	DeletedAt            *time.Time          `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// ExpenseAttachment is a receipt or invoice file stored on disk
+type ExpenseAttachment struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	CompanyID   uint      `json:"company_id" gorm:"not null;default:0;index"`
+	ExpenseID   uint      `json:"expense_id" gorm:"not null;index"`
+	FileName    string    `json:"file_name" gorm:"size:255"`
+	FilePath    string    `json:"-" gorm:"size:500"`
+	ContentType string    `json:"content_type" gorm:"size:100"`
+	Size        int64     `json:"size"`
+	UploadedBy  uint      `json:"uploaded_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
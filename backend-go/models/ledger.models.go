@@ -0,0 +1,114 @@
+package models
+
+import "time"
+
+// LedgerAccount is an account in the chart of accounts
+type LedgerAccount struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CompanyID uint           `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_ledger_accounts_company_code,priority:1"`
+	Code      string         `json:"code" gorm:"size:20;uniqueIndex:idx_ledger_accounts_company_code,priority:2;not null"`
+	Name      string         `json:"name" gorm:"size:100;not null"`
+	Type      string         `json:"type" gorm:"size:20;not null;index"` // asset, liability, equity, revenue, expense
+	ParentID  *uint          `json:"parent_id" gorm:"index"`
+	Parent    *LedgerAccount `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	IsSystem  bool           `json:"is_system" gorm:"default:false"` // Seeded accounts cannot be deleted
+	IsActive  bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt *time.Time     `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// LedgerAccountMapping tells the automatic postings which account to use for a role,
+// e.g. "sales_revenue" -> 4000 Sales
+type LedgerAccountMapping struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CompanyID uint           `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_ledger_mappings_company_key,priority:1"`
+	Key       string         `json:"key" gorm:"size:50;uniqueIndex:idx_ledger_mappings_company_key,priority:2;not null"`
+	AccountID uint           `json:"account_id" gorm:"not null"`
+	Account   *LedgerAccount `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// JournalEntry is a balanced set of debits and credits. Automatic entries are
+// linked to the document that produced them through SourceType/SourceID.
+type JournalEntry struct {
+	ID          uint          `json:"id" gorm:"primaryKey"`
+	CompanyID   uint          `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_journal_entries_company_number,priority:1"`
+	EntryNumber string        `json:"entry_number" gorm:"size:50;uniqueIndex:idx_journal_entries_company_number,priority:2;not null"`
+	EntryDate   time.Time     `json:"entry_date" gorm:"not null;index"`
+	Description string        `json:"description" gorm:"size:255"`
+	SourceType  string        `json:"source_type" gorm:"size:30;index:idx_journal_source"` // sales_invoice, purchase_invoice, payment, credit_note, stock_adjustment, manual
+	SourceID    *uint         `json:"source_id" gorm:"index:idx_journal_source"`
+	IsAutomatic bool          `json:"is_automatic" gorm:"default:false"`
+	TotalDebit  float64       `json:"total_debit" gorm:"type:decimal(15,2)"`
+	TotalCredit float64       `json:"total_credit" gorm:"type:decimal(15,2)"`
+	CreatedBy   uint          `json:"created_by"`
+	Lines       []JournalLine `json:"lines,omitempty" gorm:"foreignKey:EntryID"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type JournalLine struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	CompanyID   uint           `json:"company_id" gorm:"not null;default:0;index"`
+	EntryID     uint           `json:"entry_id" gorm:"not null;index"`
+	AccountID   uint           `json:"account_id" gorm:"not null;index"`
+	Account     *LedgerAccount `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	Debit       float64        `json:"debit" gorm:"type:decimal(15,2);default:0"`
+	Credit      float64        `json:"credit" gorm:"type:decimal(15,2);default:0"`
+	Description string         `json:"description" gorm:"size:255"`
+}
+
+// AccountingPeriod is a date range that can be closed to stop further postings
+type AccountingPeriod struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	CompanyID uint       `json:"company_id" gorm:"not null;default:0;index"`
+	Name      string     `json:"name" gorm:"size:50;not null"`
+	StartDate time.Time  `json:"start_date" gorm:"type:date;not null"`
+	EndDate   time.Time  `json:"end_date" gorm:"type:date;not null"`
+	Status    string     `json:"status" gorm:"size:20;default:'open'"` // open, closed
+	ClosedBy  *uint      `json:"closed_by"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// LedgerReportLine is one account row of a trial balance, P&L or balance sheet
+type LedgerReportLine struct {
+	AccountID      uint    `json:"account_id"`
+	Code           string  `json:"code"`
+	Name           string  `json:"name"`
+	Type           string  `json:"type"`
+	OpeningBalance float64 `json:"opening_balance,omitempty"`
+	Debit          float64 `json:"debit"`
+	Credit         float64 `json:"credit"`
+	Balance        float64 `json:"balance"`
+}
+
+type TrialBalance struct {
+	FromDate    time.Time          `json:"from_date"`
+	ToDate      time.Time          `json:"to_date"`
+	Lines       []LedgerReportLine `json:"lines"`
+	TotalDebit  float64            `json:"total_debit"`
+	TotalCredit float64            `json:"total_credit"`
+}
+
+type ProfitAndLoss struct {
+	FromDate      time.Time          `json:"from_date"`
+	ToDate        time.Time          `json:"to_date"`
+	Revenue       []LedgerReportLine `json:"revenue"`
+	Expenses      []LedgerReportLine `json:"expenses"`
+	TotalRevenue  float64            `json:"total_revenue"`
+	TotalExpenses float64            `json:"total_expenses"`
+	NetIncome     float64            `json:"net_income"`
+}
+
+type BalanceSheet struct {
+	AsOf             time.Time          `json:"as_of"`
+	Assets           []LedgerReportLine `json:"assets"`
+	Liabilities      []LedgerReportLine `json:"liabilities"`
+	Equity           []LedgerReportLine `json:"equity"`
+	CurrentEarnings  float64            `json:"current_earnings"` // Net income not yet closed into equity
+	TotalAssets      float64            `json:"total_assets"`
+	TotalLiabilities float64            `json:"total_liabilities"`
+	TotalEquity      float64            `json:"total_equity"`
+}
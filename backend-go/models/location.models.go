@@ -4,6 +4,7 @@ import "time"
 
 type Location struct {
 	ID          uint       `json:"id" gorm:"primaryKey"`
+	CompanyID   uint       `json:"company_id" gorm:"not null;default:0;index"`
 	Name        string     `json:"name" gorm:"size:100;not null"`
 	Type        string     `json:"type" gorm:"size:20;default:warehouse"` // warehouse, store, van, etc
 	Address     *string    `json:"address" gorm:"type:text"`
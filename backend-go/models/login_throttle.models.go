@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one account (scope "account", identified
+// by the email) or one client (scope "ip"). Once the count reaches the limit it is locked
+// out for a period that doubles with every further failure.
+type LoginThrottle struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Scope        string     `json:"scope" gorm:"size:10;not null;uniqueIndex:idx_login_throttles_scope_key,priority:1"`
+	Identifier   string     `json:"identifier" gorm:"size:255;not null;uniqueIndex:idx_login_throttles_scope_key,priority:2"`
+	Failures     int        `json:"failures" gorm:"not null;default:0"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
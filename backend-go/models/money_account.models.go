@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// MoneyAccount is a place money is held: a cash box (one per location/van) or a bank account
+type MoneyAccount struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	CompanyID      uint       `json:"company_id" gorm:"not null;default:0;index"`
+	Name           string     `json:"name" gorm:"size:100;not null"`
+	Type           string     `json:"type" gorm:"size:20;not null;index"` // cash_box, bank
+	LocationID     *uint      `json:"location_id" gorm:"index"`
+	Location       *Location  `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	VanID          *uint      `json:"van_id" gorm:"index"`
+	Van            *Van       `json:"van,omitempty" gorm:"foreignKey:VanID"`
+	BankName       *string    `json:"bank_name" gorm:"size:100"`
+	AccountNumber  *string    `json:"account_number" gorm:"size:50"`
+	IBAN           *string    `json:"iban" gorm:"size:50"`
+	Currency       string     `json:"currency" gorm:"size:3;default:'USD'"`
+	OpeningBalance float64    `json:"opening_balance" gorm:"type:decimal(15,2);default:0"`
+	Balance        float64    `json:"balance" gorm:"type:decimal(15,2);default:0"`
+	IsDefault      bool       `json:"is_default" gorm:"default:false"` // Default bank account for card/bank_transfer payments
+	IsActive       bool       `json:"is_active" gorm:"default:true"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// MoneyAccountTransaction is a single movement of money into or out of a MoneyAccount.
+// Amount is signed: positive for money in, negative for money out.
+type MoneyAccountTransaction struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	CompanyID       uint       `json:"company_id" gorm:"not null;default:0;index"`
+	AccountID       uint       `json:"account_id" gorm:"not null;index"`
+	Type            string     `json:"type" gorm:"size:20;not null"` // payment_in, payment_out, transfer_in, transfer_out, expense, opening, adjustment
+	Amount          float64    `json:"amount" gorm:"type:decimal(15,2);not null"`
+	TransactionDate time.Time  `json:"transaction_date" gorm:"not null;index"`
+	PaymentID       *uint      `json:"payment_id" gorm:"index"`
+	Payment         *Payment   `json:"payment,omitempty" gorm:"foreignKey:PaymentID"`
+	TransferID      *uint      `json:"transfer_id" gorm:"index"`
+	ReferenceNumber *string    `json:"reference_number" gorm:"size:100"`
+	Description     string     `json:"description" gorm:"size:255"`
+	IsReconciled    bool       `json:"is_reconciled" gorm:"default:false"`
+	ReconciledAt    *time.Time `json:"reconciled_at"`
+	CreatedBy       uint       `json:"created_by"`
+	CreatedByUser   *User      `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// MoneyTransfer moves money between two accounts, e.g. a van cash box to the bank
+type MoneyTransfer struct {
+	ID              uint          `json:"id" gorm:"primaryKey"`
+	CompanyID       uint          `json:"company_id" gorm:"not null;default:0;index"`
+	FromAccountID   uint          `json:"from_account_id" gorm:"not null;index"`
+	FromAccount     *MoneyAccount `json:"from_account,omitempty" gorm:"foreignKey:FromAccountID"`
+	ToAccountID     uint          `json:"to_account_id" gorm:"not null;index"`
+	ToAccount       *MoneyAccount `json:"to_account,omitempty" gorm:"foreignKey:ToAccountID"`
+	Amount          float64       `json:"amount" gorm:"type:decimal(15,2);not null"`
+	TransferDate    time.Time     `json:"transfer_date" gorm:"not null"`
+	ReferenceNumber *string       `json:"reference_number" gorm:"size:100"`
+	Notes           *string       `json:"notes" gorm:"type:text"`
+	CreatedBy       uint          `json:"created_by"`
+	CreatedByUser   *User         `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
+	CreatedAt       time.Time     `json:"created_at"`
+}
+
+// BankStatementImport is one uploaded bank statement file
+type BankStatementImport struct {
+	ID           uint                `json:"id" gorm:"primaryKey"`
+	CompanyID    uint                `json:"company_id" gorm:"not null;default:0;index"`
+	AccountID    uint                `json:"account_id" gorm:"not null;index"`
+	Account      *MoneyAccount       `json:"account,omitempty" gorm:"foreignKey:AccountID"`
+	FileName     string              `json:"file_name" gorm:"size:255"`
+	Format       string              `json:"format" gorm:"size:10"`                // csv, ofx
+	Status       string              `json:"status" gorm:"size:20;default:'open'"` // open, completed
+	LineCount    int                 `json:"line_count"`
+	MatchedCount int                 `json:"matched_count"`
+	CreatedBy    uint                `json:"created_by"`
+	Lines        []BankStatementLine `json:"lines,omitempty" gorm:"foreignKey:ImportID"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+}
+
+// BankStatementLine is a single line of an imported statement and its match
+type BankStatementLine struct {
+	ID                   uint                     `json:"id" gorm:"primaryKey"`
+	CompanyID            uint                     `json:"company_id" gorm:"not null;default:0;index"`
+	ImportID             uint                     `json:"import_id" gorm:"not null;index"`
+	AccountID            uint                     `json:"account_id" gorm:"not null;index"`
+	TransactionDate      time.Time                `json:"transaction_date"`
+	Amount               float64                  `json:"amount" gorm:"type:decimal(15,2)"`
+	Description          string                   `json:"description" gorm:"size:255"`
+	Reference            string                   `json:"reference" gorm:"size:100"`
+	ExternalID           string                   `json:"external_id" gorm:"size:100"`               // FITID for OFX
+	Status               string                   `json:"status" gorm:"size:20;default:'unmatched'"` // unmatched, matched, reconciled, ignored
+	MatchedTransactionID *uint                    `json:"matched_transaction_id" gorm:"index"`
+	MatchedTransaction   *MoneyAccountTransaction `json:"matched_transaction,omitempty" gorm:"foreignKey:MatchedTransactionID"`
+	MatchedPaymentID     *uint                    `json:"matched_payment_id"`
+	CreatedAt            time.Time                `json:"created_at"`
+	UpdatedAt            time.Time                `json:"updated_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Notification is an entry in a user's in-app inbox. Link is the frontend page it is
+// about, e.g. /approvals/12; Data holds the event's details for the client.
+type Notification struct {
+	ID        uint                   `json:"id" gorm:"primaryKey"`
+	CompanyID uint                   `json:"company_id" gorm:"not null;default:0;index"`
+	UserID    uint                   `json:"user_id" gorm:"not null;index:idx_notifications_user_read,priority:1"`
+	Event     string                 `json:"event" gorm:"size:50;not null"`
+	Title     string                 `json:"title" gorm:"size:255;not null"`
+	Body      string                 `json:"body" gorm:"type:text"`
+	Link      string                 `json:"link" gorm:"size:255"`
+	Data      map[string]interface{} `json:"data,omitempty" gorm:"type:text;serializer:json"`
+	ReadAt    *time.Time             `json:"read_at" gorm:"index:idx_notifications_user_read,priority:2"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// NotificationPreference turns a delivery channel (in_app, email, webhook) on or off for
+// one event and user. Target is where the channel delivers to when it isn't the user's
+// account, e.g. the webhook URL; webhook deliveries are signed with Secret. Without a
+// preference the event's default applies.
+type NotificationPreference struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CompanyID uint      `json:"company_id" gorm:"not null;default:0;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_notification_preferences_user_event,priority:1"`
+	Event     string    `json:"event" gorm:"size:50;not null;uniqueIndex:idx_notification_preferences_user_event,priority:2"`
+	Channel   string    `json:"channel" gorm:"size:20;not null;uniqueIndex:idx_notification_preferences_user_event,priority:3"`
+	Enabled   bool      `json:"enabled"`
+	Target    string    `json:"target" gorm:"size:500"`
+	Secret    string    `json:"-" gorm:"size:100"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PasswordResetToken lets a user who forgot their password set a new one. Only a hash of
+// the token is stored; it expires and can be used once.
+type PasswordResetToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash   string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	RequestedIP string     `json:"requested_ip" gorm:"size:45"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -4,6 +4,7 @@ import "time"
 
 type Payment struct {
 	ID                uint      `json:"id" gorm:"primaryKey"`
+	CompanyID         uint      `json:"company_id" gorm:"not null;default:0;index"`
 	InvoiceID         uint      `json:"invoice_id" gorm:"not null"`
 	InvoiceType       string    `json:"invoice_type" gorm:"size:20"` // sales, purchase
 	CustomerID        *uint     `json:"customer_id"`
@@ -11,6 +12,7 @@ type Payment struct {
 	Amount            float64   `json:"amount" gorm:"not null"`
 	PaymentMethod     string    `json:"payment_method" gorm:"size:20;not null"` // cash, card, bank_transfer
 	ReferenceNumber   *string   `json:"reference_number" gorm:"size:100"`
+	AccountID         *uint     `json:"account_id" gorm:"index"` // Cash box or bank account the money landed in
 	Notes             *string   `json:"notes" gorm:"type:text"`
 	AllocationType    string    `json:"allocation_type" gorm:"size:20;default:'single'"` // single, multiple
 	TotalAllocated    float64   `json:"total_allocated" gorm:"type:decimal(15,2);default:0"`
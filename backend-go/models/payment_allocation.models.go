@@ -4,6 +4,7 @@ import "time"
 
 type PaymentAllocation struct {
 	ID              uint      `json:"id" gorm:"primaryKey"`
+	CompanyID       uint      `json:"company_id" gorm:"not null;default:0;index"`
 	PaymentID       uint      `json:"payment_id" gorm:"not null;index"`
 	InvoiceID       uint      `json:"invoice_id" gorm:"not null;index"`
 	InvoiceType     string    `json:"invoice_type" gorm:"size:20;not null"` // sales, purchase
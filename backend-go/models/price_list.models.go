@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// PriceList holds the prices of a group of customers (retail, wholesale, a contract).
+// Customers use the list assigned to them, or the default list when they have none; a
+// product missing from the list sells at its UnitPrice. A list only applies between
+// ValidFrom and ValidTo when those are set.
+type PriceList struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	CompanyID   uint            `json:"company_id" gorm:"not null;default:0;index"`
+	Name        string          `json:"name" gorm:"size:100;not null"`
+	Description *string         `json:"description" gorm:"type:text"`
+	IsDefault   bool            `json:"is_default" gorm:"default:false"`
+	ValidFrom   *time.Time      `json:"valid_from"`
+	ValidTo     *time.Time      `json:"valid_to"`
+	IsActive    bool            `json:"is_active" gorm:"default:true"`
+	Items       []PriceListItem `json:"items,omitempty" gorm:"foreignKey:PriceListID"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// PriceListItem is the price of a product from MinQuantity up. Several items of one
+// product make quantity breaks; the one with the highest MinQuantity not above the
+// quantity sold applies.
+type PriceListItem struct {
+	ID          uint     `json:"id" gorm:"primaryKey"`
+	CompanyID   uint     `json:"company_id" gorm:"not null;default:0;index"`
+	PriceListID uint     `json:"price_list_id" gorm:"not null;uniqueIndex:idx_price_list_items_tier,priority:1"`
+	ProductID   uint     `json:"product_id" gorm:"not null;uniqueIndex:idx_price_list_items_tier,priority:2"`
+	Product     *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	MinQuantity float64  `json:"min_quantity" gorm:"not null;default:0;uniqueIndex:idx_price_list_items_tier,priority:3"`
+	UnitPrice   float64  `json:"unit_price" gorm:"not null"`
+}
@@ -2,29 +2,60 @@ package models
 
 import "time"
 
+// Product is something bought and sold. A product with variants is their parent: each
+// variant is a Product of its own (SKU, barcode, prices, stock) whose category, type,
+// brand, tax rate and description come from the parent. A tax rate, unit or min stock
+// level left empty follows the category's default (Inherits*), also when it changes.
 type Product struct {
-	ID            uint         `json:"id" gorm:"primaryKey"`
-	SKU           string       `json:"sku" gorm:"size:50;unique;not null"`
-	Barcode       *string      `json:"barcode" gorm:"size:50"`
-	NameEn        string       `json:"name_en" gorm:"size:100;not null"`
-	NameAr        *string      `json:"name_ar" gorm:"size:100"`
-	Description   *string      `json:"description" gorm:"type:text"`
-	CategoryID    *uint        `json:"category_id"`
-	Category      *Category    `json:"category" gorm:"foreignKey:CategoryID"`
-	TypeID        *uint        `json:"type_id"`
-	ProductType   *ProductType `json:"product_type" gorm:"foreignKey:TypeID"`
-	UnitPrice     float64      `json:"unit_price" gorm:"not null"`
-	CostPrice     float64      `json:"cost_price" gorm:"not null"`
-	Unit          string       `json:"unit" gorm:"size:20;default:piece"`
-	MinStockLevel int          `json:"min_stock_level" gorm:"default:0"`
-	IsActive      bool         `json:"is_active" gorm:"default:true"`
-	CreatedAt     time.Time    `json:"created_at"`
-	UpdatedAt     time.Time    `json:"updated_at"`
-	DeletedAt     *time.Time   `json:"deleted_at,omitempty" gorm:"index"`
+	ID                    uint              `json:"id" gorm:"primaryKey"`
+	CompanyID             uint              `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_products_company_sku,priority:1"`
+	SKU                   string            `json:"sku" gorm:"size:50;uniqueIndex:idx_products_company_sku,priority:2;not null"`
+	Barcode               *string           `json:"barcode" gorm:"size:50"`
+	NameEn                string            `json:"name_en" gorm:"size:100;not null"`
+	NameAr                *string           `json:"name_ar" gorm:"size:100"`
+	Description           *string           `json:"description" gorm:"type:text"`
+	CategoryID            *uint             `json:"category_id"`
+	Category              *Category         `json:"category" gorm:"foreignKey:CategoryID"`
+	TypeID                *uint             `json:"type_id"`
+	ProductType           *ProductType      `json:"product_type" gorm:"foreignKey:TypeID"`
+	BrandID               *uint             `json:"brand_id" gorm:"index"`
+	Brand                 *ProductBrand     `json:"brand,omitempty" gorm:"foreignKey:BrandID"`
+	UnitPrice             float64           `json:"unit_price" gorm:"not null"`
+	CostPrice             float64           `json:"cost_price" gorm:"not null"`
+	TaxRate               *float64          `json:"tax_rate"`                          // percent
+	Unit                  string            `json:"unit" gorm:"size:20;default:piece"` // base unit, stock is held in it
+	Units                 []ProductUnit     `json:"units,omitempty" gorm:"foreignKey:ProductID"`
+	ParentID              *uint             `json:"parent_id" gorm:"index"`                                // set on variants
+	Attributes            map[string]string `json:"attributes,omitempty" gorm:"type:text;serializer:json"` // what sets a variant apart, e.g. {"flavour": "mint"}
+	Variants              []Product         `json:"variants,omitempty" gorm:"foreignKey:ParentID"`
+	MinStockLevel         int               `json:"min_stock_level" gorm:"default:0"`
+	InheritsTaxRate       bool              `json:"inherits_tax_rate"` // the field follows the category's default
+	InheritsUnit          bool              `json:"inherits_unit"`
+	InheritsMinStockLevel bool              `json:"inherits_min_stock_level"`
+	IsActive              bool              `json:"is_active" gorm:"default:true"`
+	CreatedAt             time.Time         `json:"created_at"`
+	UpdatedAt             time.Time         `json:"updated_at"`
+	DeletedAt             *time.Time        `json:"deleted_at,omitempty" gorm:"index"`
+}
+
+// ProductUnit is a pack the product is also bought, sold or moved in, e.g. a carton of
+// Factor (24) base units. UnitPrice is the sales price of one pack; without it a pack
+// sells at Factor times the price of the base unit.
+type ProductUnit struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CompanyID uint      `json:"company_id" gorm:"not null;default:0;index"`
+	ProductID uint      `json:"product_id" gorm:"not null;uniqueIndex:idx_product_units_name,priority:1"`
+	Name      string    `json:"name" gorm:"size:20;not null;uniqueIndex:idx_product_units_name,priority:2"`
+	Factor    float64   `json:"factor" gorm:"not null"`
+	Barcode   *string   `json:"barcode" gorm:"size:50"`
+	UnitPrice *float64  `json:"unit_price"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type ProductBrand struct {
 	ID        uint       `json:"id" gorm:"primaryKey"`
+	CompanyID uint       `json:"company_id" gorm:"not null;default:0;index"`
 	NameEn    string     `json:"name_en" gorm:"size:100;not null"`
 	NameAr    *string    `json:"name_ar" gorm:"size:100"`
 	IsActive  bool       `json:"is_active" gorm:"default:true"`
@@ -35,6 +66,7 @@ type ProductBrand struct {
 
 type ProductType struct {
 	ID        uint       `json:"id" gorm:"primaryKey"`
+	CompanyID uint       `json:"company_id" gorm:"not null;default:0;index"`
 	NameEn    string     `json:"name_en" gorm:"size:100;not null"`
 	NameAr    *string    `json:"name_ar" gorm:"size:100"`
 	IsActive  bool       `json:"is_active" gorm:"default:true"`
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Promotion types
+const (
+	PromotionBuyXGetY        = "buy_x_get_y"      // buy BuyQuantity of ProductID, get FreeQuantity of FreeProductID free
+	PromotionBundle          = "bundle"           // the products in Items together for BundlePrice
+	PromotionCategoryPercent = "category_percent" // DiscountPercent off the products of CategoryID and its subcategories
+	PromotionMinSpend        = "min_spend"        // DiscountPercent off a basket of at least MinSpend
+)
+
+// Promotion is a discount applied automatically to sales invoices while it runs
+// (between StartsAt and EndsAt when set). LocationIDs, VanIDs and CustomerGroups narrow
+// it down; left empty they match everything. A line takes part in one promotion at most:
+// they're tried by Priority, highest first.
+type Promotion struct {
+	ID              uint            `json:"id" gorm:"primaryKey"`
+	CompanyID       uint            `json:"company_id" gorm:"not null;default:0;index"`
+	Name            string          `json:"name" gorm:"size:100;not null"`
+	Description     *string         `json:"description" gorm:"type:text"`
+	Type            string          `json:"type" gorm:"size:30;not null"`
+	ProductID       *uint           `json:"product_id"`
+	BuyQuantity     float64         `json:"buy_quantity" gorm:"default:0"`
+	FreeProductID   *uint           `json:"free_product_id"` // the product bought when not set
+	FreeQuantity    float64         `json:"free_quantity" gorm:"default:0"`
+	BundlePrice     float64         `json:"bundle_price" gorm:"default:0"`
+	Items           []PromotionItem `json:"items,omitempty" gorm:"foreignKey:PromotionID"`
+	CategoryID      *uint           `json:"category_id"`
+	DiscountPercent float64         `json:"discount_percent" gorm:"default:0"`
+	MinSpend        float64         `json:"min_spend" gorm:"default:0"`
+	StartsAt        *time.Time      `json:"starts_at"`
+	EndsAt          *time.Time      `json:"ends_at"`
+	LocationIDs     []uint          `json:"location_ids" gorm:"type:text;serializer:json"`
+	VanIDs          []uint          `json:"van_ids" gorm:"type:text;serializer:json"`
+	CustomerGroups  []string        `json:"customer_groups" gorm:"type:text;serializer:json"`
+	Priority        int             `json:"priority" gorm:"default:0"`
+	IsActive        bool            `json:"is_active" gorm:"default:true"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// PromotionItem is a product and quantity of a bundle
+type PromotionItem struct {
+	ID          uint     `json:"id" gorm:"primaryKey"`
+	CompanyID   uint     `json:"company_id" gorm:"not null;default:0;index"`
+	PromotionID uint     `json:"promotion_id" gorm:"not null;index"`
+	ProductID   uint     `json:"product_id" gorm:"not null"`
+	Product     *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity    float64  `json:"quantity" gorm:"not null"`
+}
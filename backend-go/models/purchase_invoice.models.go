@@ -4,7 +4,8 @@ import "time"
 
 type PurchaseInvoice struct {
 	ID            uint                  `json:"id" gorm:"primaryKey"`
-	InvoiceNumber string                `json:"invoice_number" gorm:"size:50;unique;not null"`
+	CompanyID     uint                  `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_purchase_invoices_company_number,priority:1"`
+	InvoiceNumber string                `json:"invoice_number" gorm:"size:50;uniqueIndex:idx_purchase_invoices_company_number,priority:2;not null"`
 	VendorID      *uint                 `json:"vendor_id"`
 	Vendor        *Vendor               `json:"vendor,omitempty" gorm:"foreignKey:VendorID"`
 	LocationID    uint                  `json:"location_id" gorm:"not null"`
@@ -25,6 +26,7 @@ type PurchaseInvoice struct {
 
 type PurchaseInvoiceItem struct {
 	ID              uint     `json:"id" gorm:"primaryKey"`
+	CompanyID       uint     `json:"company_id" gorm:"not null;default:0;index"`
 	InvoiceID       uint     `json:"invoice_id" gorm:"not null"`
 	ProductID       uint     `json:"product_id" gorm:"not null"`
 	Product         *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
@@ -32,4 +34,6 @@ type PurchaseInvoiceItem struct {
 	UnitPrice       float64  `json:"unit_price" gorm:"not null"`
 	DiscountPercent float64  `json:"discount_percent" gorm:"default:0"`
 	Total           float64  `json:"total" gorm:"not null"`
+	Unit            string   `json:"unit" gorm:"size:20"`            // unit the line was entered in, the base unit when empty
+	UnitQuantity    float64  `json:"unit_quantity" gorm:"default:0"` // quantity in Unit; Quantity and UnitPrice are per base unit
 }
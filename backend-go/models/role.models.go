@@ -8,6 +8,10 @@ type Role struct {
 	Description string       `json:"description"`
 	CompanyID   uint         `json:"company_id"`
 	IsSystem    bool         `json:"is_system" gorm:"default:false"` // System roles can't be deleted
+	// LocationBound limits holders to the data of their assigned locations (e.g. van sales)
+	LocationBound bool `json:"location_bound" gorm:"default:false"`
+	// RequireTwoFactor makes holders set up two-factor authentication before they can work
+	RequireTwoFactor bool `json:"require_two_factor" gorm:"default:false"`
 	CreatedAt   time.Time    `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
 	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
@@ -35,6 +39,15 @@ type UserRole struct {
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
+// UserLocation assigns an extra location to a user on top of User.LocationID
+type UserLocation struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_user_location"`
+	LocationID uint      `json:"location_id" gorm:"not null;uniqueIndex:idx_user_location"`
+	Location   *Location `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
 // PermissionCheck represents a permission check request
 type PermissionCheck struct {
 	UserID   uint   `json:"user_id"`
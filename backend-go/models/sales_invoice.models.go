@@ -4,7 +4,8 @@ import "time"
 
 type SalesInvoice struct {
 	ID            uint               `json:"id" gorm:"primaryKey"`
-	InvoiceNumber string             `json:"invoice_number" gorm:"size:50;unique;not null"`
+	CompanyID     uint               `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_sales_invoices_company_number,priority:1"`
+	InvoiceNumber string             `json:"invoice_number" gorm:"size:50;uniqueIndex:idx_sales_invoices_company_number,priority:2;not null"`
 	CustomerID    *uint              `json:"customer_id"`
 	Customer      *Customer          `json:"customer,omitempty" gorm:"foreignKey:CustomerID"`
 	LocationID    uint               `json:"location_id" gorm:"not null"`
@@ -17,18 +18,27 @@ type SalesInvoice struct {
 	CreatedBy     uint               `json:"created_by"`
 	CreatedByUser *User              `json:"created_by_user,omitempty" gorm:"foreignKey:CreatedBy"`
 	Items         []SalesInvoiceItem `json:"items,omitempty" gorm:"foreignKey:InvoiceID"`
+	SettlementID  *uint              `json:"settlement_id" gorm:"index"` // Set when a closed van settlement locks the invoice
 	CreatedAt     time.Time          `json:"created_at"`
 	UpdatedAt     time.Time          `json:"updated_at"`
 	DeletedAt     *time.Time         `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 type SalesInvoiceItem struct {
-	ID              uint     `json:"id" gorm:"primaryKey"`
-	InvoiceID       uint     `json:"invoice_id" gorm:"not null"`
-	ProductID       uint     `json:"product_id" gorm:"not null"`
-	Product         *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Quantity        float64  `json:"quantity" gorm:"not null"`
-	UnitPrice       float64  `json:"unit_price" gorm:"not null"`
-	DiscountPercent float64  `json:"discount_percent" gorm:"default:0"`
-	Total           float64  `json:"total" gorm:"not null"`
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	CompanyID         uint       `json:"company_id" gorm:"not null;default:0;index"`
+	InvoiceID         uint       `json:"invoice_id" gorm:"not null"`
+	ProductID         uint       `json:"product_id" gorm:"not null"`
+	Product           *Product   `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity          float64    `json:"quantity" gorm:"not null"`
+	UnitPrice         float64    `json:"unit_price" gorm:"not null"`
+	DiscountPercent   float64    `json:"discount_percent" gorm:"default:0"`
+	Total             float64    `json:"total" gorm:"not null"`
+	ListPrice         float64    `json:"list_price" gorm:"default:0"`    // resolved price when the line was priced
+	PriceFlag         string     `json:"price_flag" gorm:"size:20"`      // below_list or below_cost when sold under it
+	Unit              string     `json:"unit" gorm:"size:20"`            // unit the line was entered in, the base unit when empty
+	UnitQuantity      float64    `json:"unit_quantity" gorm:"default:0"` // quantity in Unit; Quantity and UnitPrice are per base unit
+	PromotionID       *uint      `json:"promotion_id" gorm:"index"`
+	Promotion         *Promotion `json:"promotion,omitempty" gorm:"foreignKey:PromotionID"`
+	PromotionDiscount float64    `json:"promotion_discount" gorm:"default:0"` // taken off Total by the promotion, its cost
 }
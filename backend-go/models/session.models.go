@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// UserSession is one login of a user on a device. Only a hash of its refresh token is
+// stored. Access tokens carry the session ID, so revoking the session ends them as well.
+type UserSession struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"not null;index"`
+	RefreshTokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	PreviousTokenHash *string    `json:"-" gorm:"size:64;index"` // the token the last refresh replaced
+	UserAgent         string     `json:"user_agent" gorm:"size:255"`
+	IPAddress         string     `json:"ip_address" gorm:"size:45"`
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"not null"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" gorm:"index"`
+	RevokedReason     string     `json:"revoked_reason,omitempty" gorm:"size:50"` // logout, logout_all, password_changed, deactivated, refresh_reuse, revoked
+	// TwoFactorPending limits the session to setting up two-factor authentication, which
+	// the user's role requires but they haven't done yet
+	TwoFactorPending bool      `json:"two_factor_pending" gorm:"default:false"`
+	Current          bool      `json:"current" gorm:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
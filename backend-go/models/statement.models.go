@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// StatementLine is a single row of a customer or vendor account statement
+type StatementLine struct {
+	Date        time.Time `json:"date"`
+	EntryType   string    `json:"entry_type"` // invoice, payment, allocation, credit_note, refund
+	Reference   string    `json:"reference"`
+	Description string    `json:"description"`
+	ReferenceID uint      `json:"reference_id"`
+	Debit       float64   `json:"debit"`
+	Credit      float64   `json:"credit"`
+	Balance     float64   `json:"balance"`
+}
+
+// Statement is a running-balance ledger for a customer or vendor over a date range
+type Statement struct {
+	PartyType      string          `json:"party_type"` // customer, vendor
+	PartyID        uint            `json:"party_id"`
+	PartyName      string          `json:"party_name"`
+	FromDate       time.Time       `json:"from_date"`
+	ToDate         time.Time       `json:"to_date"`
+	OpeningBalance float64         `json:"opening_balance"`
+	TotalDebit     float64         `json:"total_debit"`
+	TotalCredit    float64         `json:"total_credit"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+}
@@ -4,6 +4,7 @@ import "time"
 
 type Stock struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
+	CompanyID    uint      `json:"company_id" gorm:"not null;default:0;index"`
 	ProductID    uint      `json:"product_id" gorm:"not null"`
 	Product      *Product  `json:"product,omitempty" gorm:"foreignKey:ProductID"`
 	LocationType string    `json:"location_type" gorm:"size:20;not null"` // warehouse, van, location
@@ -15,6 +16,7 @@ type Stock struct {
 
 type StockMovement struct {
 	ID               uint      `json:"id" gorm:"primaryKey"`
+	CompanyID        uint      `json:"company_id" gorm:"not null;default:0;index"`
 	ProductID        uint      `json:"product_id" gorm:"not null"`
 	Product          *Product  `json:"product,omitempty" gorm:"foreignKey:ProductID"`
 	FromLocationType string    `json:"from_location_type" gorm:"size:20"`
@@ -22,7 +24,7 @@ type StockMovement struct {
 	ToLocationType   string    `json:"to_location_type" gorm:"size:20"`
 	ToLocationID     uint      `json:"to_location_id"`
 	Quantity         float64   `json:"quantity" gorm:"not null"`
-	MovementType     string    `json:"movement_type" gorm:"size:20;not null"` // transfer, sale, purchase, adjustment
+	MovementType     string    `json:"movement_type" gorm:"size:20;not null"` // transfer, sale, purchase, adjustment, opening
 	ReferenceID      *uint     `json:"reference_id"`
 	Notes            *string   `json:"notes" gorm:"type:text"`
 	CreatedBy        *uint     `json:"created_by"`
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Sync record statuses. A conflict was applied like any other record but needs someone
+// to look at it, see SyncRecord.Conflicts.
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+)
+
+// SyncRecord is an invoice or payment pushed by the mobile app, under the UUID the
+// device gave it. A push replayed with the same UUID returns this outcome instead of
+// recording the sale twice.
+type SyncRecord struct {
+	ID              uint       `json:"id" gorm:"primaryKey"`
+	CompanyID       uint       `json:"company_id" gorm:"not null;default:0;uniqueIndex:idx_sync_records_client,priority:1"`
+	ClientUUID      string     `json:"client_uuid" gorm:"size:36;not null;uniqueIndex:idx_sync_records_client,priority:2"`
+	DeviceID        string     `json:"device_id" gorm:"size:100;not null;index"`
+	UserID          uint       `json:"user_id"`
+	User            *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Kind            string     `json:"kind" gorm:"size:20;not null"` // invoice, payment
+	RecordID        uint       `json:"record_id"`                    // the sales invoice or payment
+	Status          string     `json:"status" gorm:"size:20;not null;index"`
+	Conflicts       []string   `json:"conflicts" gorm:"type:text;serializer:json"`
+	ClientCreatedAt *time.Time `json:"client_created_at"` // when the device recorded it
+	ResolvedBy      *uint      `json:"resolved_by"`
+	ResolvedAt      *time.Time `json:"resolved_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SyncLog is one pull or push made by a device
+type SyncLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CompanyID  uint      `json:"company_id" gorm:"not null;default:0;index"`
+	DeviceID   string    `json:"device_id" gorm:"size:100;not null;index"`
+	UserID     uint      `json:"user_id"`
+	User       *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	LocationID *uint     `json:"location_id"`
+	Direction  string    `json:"direction" gorm:"size:10;not null"` // pull, push
+	Cursor     string    `json:"cursor" gorm:"size:30"`
+	NextCursor string    `json:"next_cursor" gorm:"size:30"`
+	Sent       int       `json:"sent"` // records in a pull
+	Received   int       `json:"received"`
+	Applied    int       `json:"applied"`
+	Duplicates int       `json:"duplicates"`
+	Conflicts  int       `json:"conflicts"`
+	Rejected   int       `json:"rejected"`
+	IPAddress  string    `json:"ip_address" gorm:"size:45"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
@@ -4,6 +4,7 @@ import "time"
 
 type Transfer struct {
 	ID               uint           `json:"id" gorm:"primaryKey"`
+	CompanyID        uint           `json:"company_id" gorm:"not null;default:0;index"`
 	FromLocationType string         `json:"from_location_type" gorm:"size:20;not null"`
 	FromLocationID   uint           `json:"from_location_id" gorm:"not null"`
 	ToLocationType   string         `json:"to_location_type" gorm:"size:20;not null"`
@@ -18,9 +19,12 @@ type Transfer struct {
 }
 
 type TransferItem struct {
-	ID         uint     `json:"id" gorm:"primaryKey"`
-	TransferID uint     `json:"transfer_id" gorm:"not null"`
-	ProductID  uint     `json:"product_id" gorm:"not null"`
-	Product    *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
-	Quantity   float64  `json:"quantity" gorm:"not null"`
+	ID           uint     `json:"id" gorm:"primaryKey"`
+	CompanyID    uint     `json:"company_id" gorm:"not null;default:0;index"`
+	TransferID   uint     `json:"transfer_id" gorm:"not null"`
+	ProductID    uint     `json:"product_id" gorm:"not null"`
+	Product      *Product `json:"product,omitempty" gorm:"foreignKey:ProductID"`
+	Quantity     float64  `json:"quantity" gorm:"not null"`
+	Unit         string   `json:"unit" gorm:"size:20"`            // unit the line was entered in, the base unit when empty
+	UnitQuantity float64  `json:"unit_quantity" gorm:"default:0"` // quantity in Unit; Quantity is in the base unit
 }
@@ -9,7 +9,7 @@ import (
 type User struct {
 	ID         uint      `json:"id" gorm:"primaryKey"`
 	Email      string    `json:"email" gorm:"unique"`
-	CompanyID  uint      `json:"company_id"`
+	CompanyID  uint      `json:"company_id" gorm:"index"`
 	FirstName  string    `json:"first_name" gorm:"size:255"`
 	LastName   string    `json:"last_name" gorm:"size:255"`
 	FullName   string    `json:"full_name" gorm:"-"` // Computed field
@@ -28,6 +28,9 @@ type User struct {
 }
 
 type Claims struct {
-	Email string `json:"email"`
+	Email     string `json:"email"`
+	UserID    uint   `json:"uid"`
+	SessionID uint   `json:"sid"`
+	Purpose   string `json:"pur,omitempty"` // set on tokens that are not access tokens, e.g. "two_factor"
 	jwt.StandardClaims
 }
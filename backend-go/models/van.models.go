@@ -4,6 +4,7 @@ import "time"
 
 type Van struct {
 	ID          uint       `json:"id" gorm:"primaryKey"`
+	CompanyID   uint       `json:"company_id" gorm:"not null;default:0;index"`
 	Name        string     `json:"name" gorm:"size:100;not null"`
 	PlateNumber *string    `json:"plate_number" gorm:"size:50"`
 	OwnerType   string     `json:"owner_type" gorm:"size:20;not null"` // company, rental
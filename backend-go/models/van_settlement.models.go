@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// VanSettlement is the end-of-day cash count of a van against what its users sold and collected
+type VanSettlement struct {
+	ID             uint                        `json:"id" gorm:"primaryKey"`
+	CompanyID      uint                        `json:"company_id" gorm:"not null;default:0;index"`
+	VanID          uint                        `json:"van_id" gorm:"not null;uniqueIndex:idx_van_settlement_day"`
+	Van            *Van                        `json:"van,omitempty" gorm:"foreignKey:VanID"`
+	LocationID     uint                        `json:"location_id" gorm:"not null;index"`
+	Location       *Location                   `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	DriverID       *uint                       `json:"driver_id" gorm:"index"`
+	Driver         *User                       `json:"driver,omitempty" gorm:"foreignKey:DriverID"`
+	SettlementDate time.Time                   `json:"settlement_date" gorm:"type:date;not null;uniqueIndex:idx_van_settlement_day"`
+	Status         string                      `json:"status" gorm:"size:20;default:'open'"` // open, closed
+	InvoiceCount   int                         `json:"invoice_count"`
+	SalesTotal     float64                     `json:"sales_total" gorm:"type:decimal(15,2);default:0"`
+	ExpectedTotal  float64                     `json:"expected_total" gorm:"type:decimal(15,2);default:0"` // All payments collected, any method
+	ExpectedCash   float64                     `json:"expected_cash" gorm:"type:decimal(15,2);default:0"`
+	CountedCash    float64                     `json:"counted_cash" gorm:"type:decimal(15,2);default:0"`
+	Difference     float64                     `json:"difference" gorm:"type:decimal(15,2);default:0"` // counted - expected cash
+	DifferenceType string                      `json:"difference_type" gorm:"size:20"`                 // shortage, overage, balanced
+	Notes          *string                     `json:"notes" gorm:"type:text"`
+	MethodTotals   []VanSettlementMethodTotal  `json:"method_totals,omitempty" gorm:"foreignKey:SettlementID"`
+	Denominations  []VanSettlementDenomination `json:"denominations,omitempty" gorm:"foreignKey:SettlementID"`
+	CreatedBy      uint                        `json:"created_by"`
+	ClosedBy       *uint                       `json:"closed_by"`
+	ClosedAt       *time.Time                  `json:"closed_at"`
+	CreatedAt      time.Time                   `json:"created_at"`
+	UpdatedAt      time.Time                   `json:"updated_at"`
+}
+
+// VanSettlementMethodTotal is the expected amount collected with one payment method
+type VanSettlementMethodTotal struct {
+	ID            uint    `json:"id" gorm:"primaryKey"`
+	CompanyID     uint    `json:"company_id" gorm:"not null;default:0;index"`
+	SettlementID  uint    `json:"settlement_id" gorm:"not null;index"`
+	PaymentMethod string  `json:"payment_method" gorm:"size:20"`
+	PaymentCount  int     `json:"payment_count"`
+	Amount        float64 `json:"amount" gorm:"type:decimal(15,2)"`
+}
+
+// VanSettlementDenomination is one line of the cash count, e.g. 12 x 20.00
+type VanSettlementDenomination struct {
+	ID           uint    `json:"id" gorm:"primaryKey"`
+	CompanyID    uint    `json:"company_id" gorm:"not null;default:0;index"`
+	SettlementID uint    `json:"settlement_id" gorm:"not null;index"`
+	Denomination float64 `json:"denomination" gorm:"type:decimal(10,2)"`
+	Quantity     int     `json:"quantity"`
+	Total        float64 `json:"total" gorm:"type:decimal(15,2)"`
+}
+
+// DriverCashVariance records a settlement shortage (negative) or overage (positive) against a driver
+type DriverCashVariance struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	CompanyID    uint           `json:"company_id" gorm:"not null;default:0;index"`
+	UserID       uint           `json:"user_id" gorm:"not null;index"`
+	User         *User          `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	SettlementID uint           `json:"settlement_id" gorm:"not null;index"`
+	Settlement   *VanSettlement `json:"settlement,omitempty" gorm:"foreignKey:SettlementID"`
+	Type         string         `json:"type" gorm:"size:20"` // shortage, overage
+	Amount       float64        `json:"amount" gorm:"type:decimal(15,2)"`
+	VarianceDate time.Time      `json:"variance_date" gorm:"type:date"`
+	IsCleared    bool           `json:"is_cleared" gorm:"default:false"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
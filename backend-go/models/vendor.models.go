@@ -4,6 +4,7 @@ import "time"
 
 type Vendor struct {
 	ID           uint       `json:"id" gorm:"primaryKey"`
+	CompanyID    uint       `json:"company_id" gorm:"not null;default:0;index"`
 	Name         string     `json:"name" gorm:"size:100;not null"`
 	CompanyName  *string    `json:"company_name" gorm:"size:100"`
 	Phone        *string    `json:"phone" gorm:"size:20"`
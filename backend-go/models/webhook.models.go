@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription posts the listed business events to URL. Each request is signed
+// with Secret, which is only shown when the subscription is created or rotated.
+type WebhookSubscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CompanyID uint      `json:"company_id" gorm:"not null;default:0;index"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	URL       string    `json:"url" gorm:"size:500;not null"`
+	Secret    string    `json:"-" gorm:"size:100;not null"`
+	Events    []string  `json:"events" gorm:"type:text;serializer:json"`
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OutboxEvent is a business event waiting to be handed to the webhook subscriptions. It
+// is written in the transaction of the change it describes, so events are never lost
+// nor sent for changes that were rolled back. DispatchedAt is set once its deliveries
+// exist.
+type OutboxEvent struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	CompanyID    uint       `json:"company_id" gorm:"not null;default:0;index"`
+	Event        string     `json:"event" gorm:"size:50;not null"`
+	Payload      string     `json:"payload" gorm:"type:mediumtext"`
+	DispatchedAt *time.Time `json:"dispatched_at" gorm:"index"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// WebhookDelivery is one event sent to one subscription, with the outcome of the last
+// attempt. Failed attempts are retried at NextAttemptAt until the attempts run out.
+type WebhookDelivery struct {
+	ID             uint                 `json:"id" gorm:"primaryKey"`
+	CompanyID      uint                 `json:"company_id" gorm:"not null;default:0;index"`
+	SubscriptionID uint                 `json:"subscription_id" gorm:"not null;index"`
+	Subscription   *WebhookSubscription `json:"subscription,omitempty" gorm:"foreignKey:SubscriptionID"`
+	EventID        uint                 `json:"event_id" gorm:"not null;index"`
+	OutboxEvent    *OutboxEvent         `json:"outbox_event,omitempty" gorm:"foreignKey:EventID"`
+	Event          string               `json:"event" gorm:"size:50;not null"`
+	Status         string               `json:"status" gorm:"size:20;not null;default:pending;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int                  `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  *time.Time           `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastAttemptAt  *time.Time           `json:"last_attempt_at"`
+	ResponseStatus int                  `json:"response_status"`
+	ResponseBody   string               `json:"response_body" gorm:"type:text"`
+	Error          string               `json:"error" gorm:"type:text"`
+	RedeliveryOf   *uint                `json:"redelivery_of"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+}
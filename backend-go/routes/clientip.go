@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ClientIPExtractor decides where c.RealIP() takes the client address from, which the
+// login lockout, the auth rate limit and the sessions rely on. By default it's the
+// address of the connection and X-Forwarded-For / X-Real-IP are ignored, since any client
+// can set them. Behind a reverse proxy or load balancer list its addresses or ranges in
+// TRUSTED_PROXIES (comma separated, e.g. 10.0.0.0/8,127.0.0.1); then X-Forwarded-For is
+// read, skipping those proxies from the right.
+func ClientIPExtractor() echo.IPExtractor {
+	var options []echo.TrustOption
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipRange, err := net.ParseCIDR(entry)
+		if err != nil {
+			continue
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	if len(options) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	// Only the listed proxies are trusted, not every private or loopback address
+	options = append(options, echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false))
+	return echo.ExtractIPFromXFFHeader(options...)
+}
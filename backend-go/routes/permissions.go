@@ -0,0 +1,389 @@
+package routes
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gonext-tech/invoicing-system/backend/handlers"
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/labstack/echo/v4"
+)
+
+// authenticated marks routes any logged-in user may call
+var authenticated = handlers.RoutePermission{}
+
+func perm(resource, action string) handlers.RoutePermission {
+	return handlers.RoutePermission{Resource: resource, Action: action}
+}
+
+// publicRoutes are registered outside apiGroup and need no token
+var publicRoutes = map[string]bool{
+	"POST /api/login":                true,
+	"POST /api/auth/refresh":         true,
+	"POST /api/auth/2fa/verify":      true,
+	"POST /api/auth/password/forgot": true,
+	"POST /api/auth/password/reset":  true,
+}
+
+// routePermissions declares the permission every /api route requires. Every route added
+// to apiGroup needs an entry here; unmapped routes are refused with 403.
+var routePermissions = map[string]handlers.RoutePermission{
+	// Auth
+	"GET /api/me":                     authenticated,
+	"GET /api/check-permission":       authenticated,
+	"POST /api/auth/logout":           authenticated,
+	"POST /api/auth/logout-all":       authenticated,
+	"GET /api/auth/sessions":          authenticated,
+	"DELETE /api/auth/sessions/:id":   authenticated,
+	"GET /api/auth/2fa":               authenticated,
+	"POST /api/auth/2fa/setup":        authenticated,
+	"POST /api/auth/2fa/enable":       authenticated,
+	"POST /api/auth/2fa/backup-codes": authenticated,
+	"POST /api/auth/2fa/disable":      authenticated,
+
+	// Products
+	"GET /api/products":                          perm("products", "view"),
+	"GET /api/products/:id":                      perm("products", "view"),
+	"POST /api/products":                         perm("products", "create"),
+	"PUT /api/products/:id":                      perm("products", "update"),
+	"DELETE /api/products/:id":                   perm("products", "delete"),
+	"GET /api/products/:id/units":                perm("products", "view"),
+	"PUT /api/products/:id/units":                perm("products", "update"),
+	"GET /api/products/:id/variants":             perm("products", "view"),
+	"POST /api/products/:id/variants":            perm("products", "create"),
+	"PUT /api/products/:id/variants/:variant_id": perm("products", "update"),
+
+	// Categories
+	"GET /api/categories":        perm("categories", "view"),
+	"GET /api/categories/tree":   perm("categories", "view"),
+	"GET /api/categories/:id":    perm("categories", "view"),
+	"POST /api/categories":       perm("categories", "create"),
+	"PUT /api/categories/:id":    perm("categories", "update"),
+	"DELETE /api/categories/:id": perm("categories", "delete"),
+
+	// Product types
+	"GET /api/product-types":        perm("product_types", "view"),
+	"POST /api/product-types":       perm("product_types", "create"),
+	"PUT /api/product-types/:id":    perm("product_types", "update"),
+	"DELETE /api/product-types/:id": perm("product_types", "delete"),
+
+	// Product brands
+	"GET /api/product-brands":        perm("product_brands", "view"),
+	"GET /api/product-brands/:id":    perm("product_brands", "view"),
+	"POST /api/product-brands":       perm("product_brands", "create"),
+	"PUT /api/product-brands/:id":    perm("product_brands", "update"),
+	"DELETE /api/product-brands/:id": perm("product_brands", "delete"),
+
+	// Customers
+	"GET /api/customers":               perm("customers", "view"),
+	"GET /api/customers/:id":           perm("customers", "view"),
+	"POST /api/customers":              perm("customers", "create"),
+	"PUT /api/customers/:id":           perm("customers", "update"),
+	"DELETE /api/customers/:id":        perm("customers", "delete"),
+	"GET /api/customers/:id/statement": perm("statements", "view"),
+
+	// Price lists
+	"GET /api/price-lists":        perm("price_lists", "view"),
+	"GET /api/price-lists/:id":    perm("price_lists", "view"),
+	"POST /api/price-lists":       perm("price_lists", "create"),
+	"PUT /api/price-lists/:id":    perm("price_lists", "update"),
+	"DELETE /api/price-lists/:id": perm("price_lists", "delete"),
+	"GET /api/prices/resolve":     perm("invoices", "create"),
+
+	// Promotions
+	"GET /api/promotions":           perm("promotions", "view"),
+	"GET /api/promotions/:id":       perm("promotions", "view"),
+	"POST /api/promotions":          perm("promotions", "create"),
+	"PUT /api/promotions/:id":       perm("promotions", "update"),
+	"DELETE /api/promotions/:id":    perm("promotions", "delete"),
+	"POST /api/promotions/evaluate": perm("invoices", "create"),
+
+	// Locations
+	"GET /api/locations":           perm("locations", "view"),
+	"GET /api/locations/:id":       perm("locations", "view"),
+	"POST /api/locations":          perm("locations", "create"),
+	"PUT /api/locations/:id":       perm("locations", "update"),
+	"DELETE /api/locations/:id":    perm("locations", "delete"),
+	"GET /api/locations/:id/stock": perm("stock", "view"),
+
+	// Employees
+	"GET /api/employees":        perm("employees", "view"),
+	"GET /api/employees/:id":    perm("employees", "view"),
+	"POST /api/employees":       perm("employees", "create"),
+	"PUT /api/employees/:id":    perm("employees", "update"),
+	"DELETE /api/employees/:id": perm("employees", "delete"),
+
+	// Vans
+	"GET /api/vans":           perm("vans", "view"),
+	"GET /api/vans/:id":       perm("vans", "view"),
+	"GET /api/vans/:id/stock": perm("stock", "view"),
+	"POST /api/vans":          perm("vans", "create"),
+	"PUT /api/vans/:id":       perm("vans", "update"),
+	"DELETE /api/vans/:id":    perm("vans", "delete"),
+
+	// Stock
+	"GET /api/stock":              perm("stock", "view"),
+	"GET /api/stock/all":          perm("stock", "view"),
+	"GET /api/stock/inventory":    perm("stock", "view"),
+	"GET /api/stock/location/:id": perm("stock", "view"),
+	"GET /api/stock/movements":    perm("stock", "view"),
+	"POST /api/stock/adjust":      perm("stock", "update"),
+	"POST /api/stock/add":         perm("stock", "create"),
+
+	// Transfers
+	"GET /api/transfers":     perm("transfers", "view"),
+	"GET /api/transfers/:id": perm("transfers", "view"),
+	"POST /api/transfers":    perm("transfers", "create"),
+
+	// Invoices
+	"GET /api/invoices/stats":                       perm("invoices", "view"),
+	"GET /api/invoices":                             perm("invoices", "view"),
+	"GET /api/invoices/:id":                         perm("invoices", "view"),
+	"PUT /api/invoices/:id":                         perm("invoices", "update"),
+	"POST /api/invoices/purchase":                   perm("invoices", "create"),
+	"POST /api/invoices/sales":                      perm("invoices", "create"),
+	"PUT /api/invoices/sales/:id/items/:item_id":    perm("invoices", "update"),
+	"PUT /api/invoices/purchase/:id/items/:item_id": perm("invoices", "update"),
+	"POST /api/invoices/sales/:id/items":            perm("invoices", "update"),
+	"POST /api/invoices/purchase/:id/items":         perm("invoices", "update"),
+	"DELETE /api/invoices/:id":                      perm("invoices", "delete"),
+	"GET /api/invoices/:id/allocations":             perm("payments", "view"),
+
+	// Payments
+	"GET /api/payments":                                   perm("payments", "view"),
+	"POST /api/payments":                                  perm("payments", "create"),
+	"POST /api/payment-allocations/allocate-fifo":         perm("payments", "create"),
+	"GET /api/payment-allocations/:id/allocations":        perm("payments", "view"),
+	"GET /api/payment-allocations/:id/allocation-summary": perm("payments", "view"),
+
+	// Reports
+	"GET /api/reports/sales":               perm("reports", "view"),
+	"GET /api/reports/stock-movements":     perm("reports", "view"),
+	"GET /api/reports/receivables":         perm("reports", "view"),
+	"GET /api/reports/product-performance": perm("reports", "view"),
+	"GET /api/reports/brand-performance":   perm("reports", "view"),
+	"GET /api/reports/location-sales":      perm("reports", "view"),
+	"GET /api/reports/dashboard":           perm("reports", "view"),
+	"GET /api/reports/expenses":            perm("reports", "view"),
+	"GET /api/reports/van-profitability":   perm("reports", "view"),
+	"GET /api/reports/promotions":          perm("reports", "view"),
+
+	// Users
+	"GET /api/users":                   perm("users", "view"),
+	"GET /api/users/:id":               perm("users", "view"),
+	"POST /api/users":                  perm("users", "create"),
+	"PUT /api/users/:id":               perm("users", "update"),
+	"PUT /api/users/:id/password":      perm("users", "reset_password"),
+	"DELETE /api/users/:id":            perm("users", "delete"),
+	"DELETE /api/users/:id/two-factor": perm("users", "update"),
+
+	// Vendors
+	"GET /api/vendors":               perm("vendors", "view"),
+	"GET /api/vendors/:id":           perm("vendors", "view"),
+	"POST /api/vendors":              perm("vendors", "create"),
+	"PUT /api/vendors/:id":           perm("vendors", "update"),
+	"DELETE /api/vendors/:id":        perm("vendors", "delete"),
+	"GET /api/vendors/:id/statement": perm("statements", "view"),
+
+	// Cash boxes and bank accounts
+	"GET /api/money-accounts":                  perm("money_accounts", "view"),
+	"GET /api/money-accounts/:id":              perm("money_accounts", "view"),
+	"POST /api/money-accounts":                 perm("money_accounts", "create"),
+	"PUT /api/money-accounts/:id":              perm("money_accounts", "update"),
+	"DELETE /api/money-accounts/:id":           perm("money_accounts", "delete"),
+	"GET /api/money-accounts/:id/transactions": perm("money_accounts", "view"),
+	"POST /api/money-accounts/:id/withdrawals": perm("money_accounts", "update"),
+	"GET /api/money-transfers":                 perm("money_accounts", "view"),
+	"POST /api/money-transfers":                perm("money_accounts", "update"),
+
+	// Bank reconciliation
+	"POST /api/money-accounts/:id/statements/import": perm("bank_reconciliation", "create"),
+	"GET /api/bank-statements":                       perm("bank_reconciliation", "view"),
+	"GET /api/bank-statements/:id":                   perm("bank_reconciliation", "view"),
+	"POST /api/bank-statements/:id/auto-match":       perm("bank_reconciliation", "update"),
+	"POST /api/bank-statements/:id/confirm":          perm("bank_reconciliation", "approve"),
+	"POST /api/bank-statement-lines/:id/match":       perm("bank_reconciliation", "update"),
+	"POST /api/bank-statement-lines/:id/unmatch":     perm("bank_reconciliation", "update"),
+	"POST /api/bank-statement-lines/:id/ignore":      perm("bank_reconciliation", "update"),
+
+	// Bulk imports
+	"POST /api/imports/products":  perm("products", "create"),
+	"POST /api/imports/customers": perm("customers", "create"),
+	"POST /api/imports/vendors":   perm("vendors", "create"),
+	"POST /api/imports/stock":     perm("stock", "create"),
+
+	// Van settlements
+	"GET /api/van-settlements":              perm("van_settlements", "view"),
+	"GET /api/van-settlements/:id":          perm("van_settlements", "view"),
+	"GET /api/van-settlements/:id/invoices": perm("van_settlements", "view"),
+	"POST /api/van-settlements":             perm("van_settlements", "create"),
+	"POST /api/van-settlements/:id/refresh": perm("van_settlements", "update"),
+	"PUT /api/van-settlements/:id/count":    perm("van_settlements", "update"),
+	"POST /api/van-settlements/:id/close":   perm("van_settlements", "approve"),
+	"DELETE /api/van-settlements/:id":       perm("van_settlements", "delete"),
+	"GET /api/users/:id/cash-variances":     perm("van_settlements", "view"),
+
+	// General ledger
+	"GET /api/ledger/accounts":                perm("ledger", "view"),
+	"GET /api/ledger/accounts/:id":            perm("ledger", "view"),
+	"POST /api/ledger/accounts":               perm("ledger", "create"),
+	"PUT /api/ledger/accounts/:id":            perm("ledger", "update"),
+	"DELETE /api/ledger/accounts/:id":         perm("ledger", "delete"),
+	"GET /api/ledger/mappings":                perm("ledger", "view"),
+	"PUT /api/ledger/mappings/:key":           perm("ledger", "update"),
+	"GET /api/ledger/journal-entries":         perm("ledger", "view"),
+	"GET /api/ledger/journal-entries/:id":     perm("ledger", "view"),
+	"POST /api/ledger/journal-entries":        perm("ledger", "create"),
+	"DELETE /api/ledger/journal-entries/:id":  perm("ledger", "delete"),
+	"GET /api/ledger/reports/trial-balance":   perm("ledger", "view"),
+	"GET /api/ledger/reports/profit-loss":     perm("ledger", "view"),
+	"GET /api/ledger/reports/balance-sheet":   perm("ledger", "view"),
+	"GET /api/accounting-periods":             perm("ledger", "view"),
+	"POST /api/accounting-periods":            perm("ledger", "create"),
+	"POST /api/accounting-periods/:id/close":  perm("ledger", "approve"),
+	"POST /api/accounting-periods/:id/reopen": perm("ledger", "approve"),
+
+	// Expenses
+	"GET /api/expense-categories":                         perm("expenses", "view"),
+	"POST /api/expense-categories":                        perm("expenses", "create"),
+	"PUT /api/expense-categories/:id":                     perm("expenses", "update"),
+	"DELETE /api/expense-categories/:id":                  perm("expenses", "delete"),
+	"GET /api/expenses":                                   perm("expenses", "view"),
+	"GET /api/expenses/:id":                               perm("expenses", "view"),
+	"POST /api/expenses":                                  perm("expenses", "create"),
+	"PUT /api/expenses/:id":                               perm("expenses", "update"),
+	"DELETE /api/expenses/:id":                            perm("expenses", "delete"),
+	"POST /api/expenses/:id/attachments":                  perm("expenses", "update"),
+	"GET /api/expenses/:id/attachments/:attachment_id":    perm("expenses", "view"),
+	"DELETE /api/expenses/:id/attachments/:attachment_id": perm("expenses", "update"),
+
+	// Credit notes
+	"GET /api/credit-notes":              perm("credit_notes", "view"),
+	"GET /api/credit-notes/:id":          perm("credit_notes", "view"),
+	"POST /api/credit-notes":             perm("credit_notes", "create"),
+	"PUT /api/credit-notes/:id":          perm("credit_notes", "update"),
+	"POST /api/credit-notes/:id/approve": perm("credit_notes", "approve"),
+	"POST /api/credit-notes/:id/cancel":  perm("credit_notes", "update"),
+	"DELETE /api/credit-notes/:id":       perm("credit_notes", "delete"),
+
+	// Roles and permissions
+	"GET /api/roles":                  perm("roles", "view"),
+	"GET /api/roles/:id":              perm("roles", "view"),
+	"POST /api/roles":                 perm("roles", "create"),
+	"PUT /api/roles/:id":              perm("roles", "update"),
+	"DELETE /api/roles/:id":           perm("roles", "delete"),
+	"GET /api/permissions":            perm("roles", "view"),
+	"POST /api/roles/:id/permissions": perm("roles", "update"),
+	"POST /api/users/assign-role":     perm("roles", "update"),
+	"POST /api/users/remove-role":     perm("roles", "update"),
+	"GET /api/users-with-roles":       perm("roles", "view"),
+	"GET /api/users/:id/locations":    perm("users", "view"),
+	"PUT /api/users/:id/locations":    perm("users", "update"),
+
+	// API keys
+	"GET /api/api-keys":        perm("api_keys", "view"),
+	"GET /api/api-keys/:id":    perm("api_keys", "view"),
+	"POST /api/api-keys":       perm("api_keys", "create"),
+	"PUT /api/api-keys/:id":    perm("api_keys", "update"),
+	"DELETE /api/api-keys/:id": perm("api_keys", "delete"),
+
+	// Audit log
+	"GET /api/audit-logs": perm("audit_logs", "view"),
+
+	// Approval workflows - anyone may list the requests they made or may decide;
+	// ApprovalService checks who decides
+	"GET /api/approval-rules":         perm("approval_rules", "view"),
+	"GET /api/approval-rules/:id":     perm("approval_rules", "view"),
+	"POST /api/approval-rules":        perm("approval_rules", "create"),
+	"PUT /api/approval-rules/:id":     perm("approval_rules", "update"),
+	"DELETE /api/approval-rules/:id":  perm("approval_rules", "delete"),
+	"GET /api/approvals":              authenticated,
+	"GET /api/approvals/:id":          authenticated,
+	"POST /api/approvals/:id/approve": authenticated,
+	"POST /api/approvals/:id/reject":  authenticated,
+	"POST /api/approvals/:id/cancel":  authenticated,
+
+	// Notifications - every user has their own inbox
+	"GET /api/notifications":              authenticated,
+	"GET /api/notifications/unread-count": authenticated,
+	"POST /api/notifications/:id/read":    authenticated,
+	"POST /api/notifications/read-all":    authenticated,
+	"GET /api/notifications/preferences":  authenticated,
+	"PUT /api/notifications/preferences":  authenticated,
+
+	// Outbound webhooks
+	"GET /api/webhooks":                          perm("webhooks", "view"),
+	"GET /api/webhooks/events":                   perm("webhooks", "view"),
+	"GET /api/webhooks/:id":                      perm("webhooks", "view"),
+	"POST /api/webhooks":                         perm("webhooks", "create"),
+	"PUT /api/webhooks/:id":                      perm("webhooks", "update"),
+	"DELETE /api/webhooks/:id":                   perm("webhooks", "delete"),
+	"GET /api/webhooks/:id/deliveries":           perm("webhooks", "view"),
+	"GET /api/webhook-deliveries":                perm("webhooks", "view"),
+	"GET /api/webhook-deliveries/:id":            perm("webhooks", "view"),
+	"POST /api/webhook-deliveries/:id/redeliver": perm("webhooks", "update"),
+
+	// Offline sync - a pull only returns the sections the user may view
+	"GET /api/sync/pull":                   authenticated,
+	"POST /api/sync/push":                  perm("invoices", "create"),
+	"GET /api/sync/conflicts":              perm("sync", "view"),
+	"POST /api/sync/conflicts/:id/resolve": perm("sync", "update"),
+	"GET /api/sync/logs":                   perm("sync", "view"),
+
+	// Live events - each event is checked against the user's permissions and locations
+	"GET /api/events": authenticated,
+}
+
+// handlerPermissions are checked by handlers rather than on a route
+var handlerPermissions = []handlers.RoutePermission{
+	// Selling under the resolved price or under cost, see InvoiceHandler.priceSalesLine
+	perm("prices", "sell_below_list"),
+	perm("prices", "sell_below_cost"),
+	// Setting the role of a user, see UserHandler.checkRoleChange
+	perm("users", "assign_role"),
+}
+
+// requiredPermissions lists every distinct resource/action pair used by routePermissions
+// and handlerPermissions
+func requiredPermissions() []models.Permission {
+	seen := make(map[string]bool)
+	var permissions []models.Permission
+	all := append([]handlers.RoutePermission{}, handlerPermissions...)
+	for _, required := range routePermissions {
+		all = append(all, required)
+	}
+	for _, required := range all {
+		if required.Resource == "" || seen[required.String()] {
+			continue
+		}
+		seen[required.String()] = true
+		permissions = append(permissions, models.Permission{
+			Resource:    required.Resource,
+			Action:      required.Action,
+			Description: strings.ToUpper(required.Action[:1]) + strings.ReplaceAll(required.Action[1:], "_", " ") + " " + strings.ReplaceAll(required.Resource, "_", " "),
+		})
+	}
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Resource != permissions[j].Resource {
+			return permissions[i].Resource < permissions[j].Resource
+		}
+		return permissions[i].Action < permissions[j].Action
+	})
+	return permissions
+}
+
+// warnUnmappedRoutes logs /api routes that were registered without a permission entry
+func warnUnmappedRoutes(e *echo.Echo) {
+	for _, route := range e.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") || strings.HasSuffix(route.Path, "/*") {
+			continue
+		}
+		if route.Method == echo.RouteNotFound || publicRoutes[route.Method+" "+route.Path] {
+			continue
+		}
+		if _, ok := routePermissions[route.Method+" "+route.Path]; !ok {
+			log.Printf("permission: route %s %s has no permission mapping and will be refused", route.Method, route.Path)
+		}
+	}
+}
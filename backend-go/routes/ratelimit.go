@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/services"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+// authRateLimiter throttles the login, refresh and password reset endpoints per client
+// address, so they can't be hammered even where the lockout doesn't apply
+func authRateLimiter() echo.MiddlewareFunc {
+	perMinute := services.AuthRateLimit()
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(float64(perMinute) / 60),
+			Burst:     perMinute,
+			ExpiresIn: 3 * time.Minute,
+		}),
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			c.Response().Header().Set("Retry-After", "60")
+			return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests, try again later")
+		},
+	})
+}
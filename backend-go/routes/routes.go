@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/gonext-tech/invoicing-system/backend/handlers"
 	"github.com/gonext-tech/invoicing-system/backend/models"
 	"github.com/gonext-tech/invoicing-system/backend/services"
@@ -11,165 +13,370 @@ import (
 func SetupRoutes(e *echo.Echo, store *gorm.DB) {
 	// Initialize services
 	authService := services.NewAuthService(models.User{}, store)
-	auth := handlers.NewAuthHandler(authService)
+	twoFactorService := services.NewTwoFactorService(store)
+	auth := handlers.NewAuthHandler(authService, services.NewLoginThrottleService(store), twoFactorService)
+	// Email goes out over SMTP when SMTP_HOST is set and to the log otherwise
+	notifier := services.NotifierFromEnv()
+	services.RegisterNotificationChannel(services.ChannelEmail, services.EmailChannel{Notifier: notifier})
+	services.RegisterNotificationChannel(services.ChannelWebhook, services.NewWebhookChannel())
+	passwordReset := handlers.NewPasswordResetHandler(services.NewPasswordResetService(store, notifier))
+
+	// Public routes (no authentication required), rate limited per client
+	limited := authRateLimiter()
+	e.POST("/api/login", auth.LoginHandler, limited)
+	e.POST("/api/auth/refresh", auth.RefreshHandler, limited)
+	e.POST("/api/auth/2fa/verify", auth.VerifyTwoFactorHandler, limited)
+	e.POST("/api/auth/password/forgot", passwordReset.ForgotPasswordHandler, limited)
+	e.POST("/api/auth/password/reset", passwordReset.ResetPasswordHandler, limited)
 
-	// Public routes (no authentication required)
-	e.POST("/api/login", auth.LoginHandler)
+	// Permission checks - every apiGroup route must be declared in routePermissions
+	permissionService := services.NewRoleService(models.Role{}, store)
+	if err := permissionService.EnsurePermissions(requiredPermissions()); err != nil {
+		log.Printf("Warning: Could not create permissions: %v", err)
+	}
+	permissions := handlers.NewPermissionMiddleware(permissionService, routePermissions)
+	twoFactor := handlers.NewTwoFactorHandler(twoFactorService, permissionService)
 
-	// Protected routes (authentication required)
-	apiGroup := e.Group("/api", auth.JWTMiddleware)
+	// Protected routes (authentication and permission required). Handlers are built per
+	// request on the user's company database, see scoped.
+	apiGroup := e.Group("/api", auth.JWTMiddleware, permissions.Middleware, handlers.TenantMiddleware(store))
+
+	// Sensitive operations are held back for approval when a rule matches, see the
+	// approval routes below
+	approvals := handlers.NewApprovalGate(func(db *gorm.DB) handlers.ApprovalService {
+		return services.NewApprovalService(db)
+	})
 
 	// Auth routes
 	apiGroup.GET("/me", auth.GetUserHandler)
+	apiGroup.POST("/auth/logout", auth.LogoutHandler)
+	apiGroup.POST("/auth/logout-all", auth.LogoutAllHandler)
+	apiGroup.GET("/auth/sessions", auth.SessionsHandler)
+	apiGroup.DELETE("/auth/sessions/:id", auth.RevokeSessionHandler)
+	apiGroup.GET("/auth/2fa", twoFactor.StatusHandler)
+	apiGroup.POST("/auth/2fa/setup", twoFactor.SetupHandler)
+	apiGroup.POST("/auth/2fa/enable", twoFactor.EnableHandler)
+	apiGroup.POST("/auth/2fa/backup-codes", twoFactor.BackupCodesHandler)
+	apiGroup.POST("/auth/2fa/disable", twoFactor.DisableHandler)
 
 	// Product routes - matches PHP: /api/products
-	productService := services.NewProductServices(models.Product{}, store)
-	productHandler := handlers.NewProductHandler(productService)
-	apiGroup.GET("/products", productHandler.GetAllHandler)
-	apiGroup.GET("/products/:id", productHandler.GetIDHandler)
-	apiGroup.POST("/products", productHandler.CreateHandler)
-	apiGroup.PUT("/products/:id", productHandler.UpdateHandler)
-	apiGroup.DELETE("/products/:id", productHandler.Delete)
+	products := scoped(func(db *gorm.DB) *handlers.ProductHandler {
+		return handlers.NewProductHandler(services.NewProductServices(models.Product{}, db))
+	})
+	apiGroup.GET("/products", products((*handlers.ProductHandler).GetAllHandler))
+	apiGroup.GET("/products/:id", products((*handlers.ProductHandler).GetIDHandler))
+	apiGroup.POST("/products", products((*handlers.ProductHandler).CreateHandler))
+	apiGroup.PUT("/products/:id", products((*handlers.ProductHandler).UpdateHandler))
+	apiGroup.DELETE("/products/:id", products((*handlers.ProductHandler).Delete))
+
+	productUnits := scoped(func(db *gorm.DB) *handlers.ProductUnitHandler {
+		return handlers.NewProductUnitHandler(services.NewProductUnitService(db))
+	})
+	apiGroup.GET("/products/:id/units", productUnits((*handlers.ProductUnitHandler).GetAllHandler))
+	apiGroup.PUT("/products/:id/units", productUnits((*handlers.ProductUnitHandler).ReplaceHandler))
+
+	productVariants := scoped(func(db *gorm.DB) *handlers.ProductVariantHandler {
+		return handlers.NewProductVariantHandler(services.NewProductVariantService(db))
+	})
+	apiGroup.GET("/products/:id/variants", productVariants((*handlers.ProductVariantHandler).GetAllHandler))
+	apiGroup.POST("/products/:id/variants", productVariants((*handlers.ProductVariantHandler).CreateHandler))
+	apiGroup.PUT("/products/:id/variants/:variant_id", productVariants((*handlers.ProductVariantHandler).UpdateHandler))
 
 	// Category routes - matches PHP: /api/categories
-	categoryService := services.NewCategoryService(models.Category{}, store)
-	categoryHandler := handlers.NewCategoryHandler(categoryService)
-	apiGroup.GET("/categories", categoryHandler.GetAllHandler)
-	apiGroup.POST("/categories", categoryHandler.CreateHandler)
-	apiGroup.PUT("/categories/:id", categoryHandler.UpdateHandler)
-	apiGroup.DELETE("/categories/:id", categoryHandler.Delete)
+	categories := scoped(func(db *gorm.DB) *handlers.CategoryHandler {
+		return handlers.NewCategoryHandler(services.NewCategoryService(models.Category{}, db))
+	})
+	apiGroup.GET("/categories", categories((*handlers.CategoryHandler).GetAllHandler))
+	apiGroup.GET("/categories/tree", categories((*handlers.CategoryHandler).GetTreeHandler))
+	apiGroup.GET("/categories/:id", categories((*handlers.CategoryHandler).GetIDHandler))
+	apiGroup.POST("/categories", categories((*handlers.CategoryHandler).CreateHandler))
+	apiGroup.PUT("/categories/:id", categories((*handlers.CategoryHandler).UpdateHandler))
+	apiGroup.DELETE("/categories/:id", categories((*handlers.CategoryHandler).Delete))
 
 	// Product Type routes - matches PHP: /api/product-types
-	productTypeService := services.NewProductTypeServices(models.ProductType{}, store)
-	productTypeHandler := handlers.NewProductTypeHandler(productTypeService)
-	apiGroup.GET("/product-types", productTypeHandler.GetAllHandler)
-	apiGroup.POST("/product-types", productTypeHandler.CreateHandler)
-	apiGroup.PUT("/product-types/:id", productTypeHandler.UpdateHandler)
-	apiGroup.DELETE("/product-types/:id", productTypeHandler.Delete)
+	productTypes := scoped(func(db *gorm.DB) *handlers.ProductTypeHandler {
+		return handlers.NewProductTypeHandler(services.NewProductTypeServices(models.ProductType{}, db))
+	})
+	apiGroup.GET("/product-types", productTypes((*handlers.ProductTypeHandler).GetAllHandler))
+	apiGroup.POST("/product-types", productTypes((*handlers.ProductTypeHandler).CreateHandler))
+	apiGroup.PUT("/product-types/:id", productTypes((*handlers.ProductTypeHandler).UpdateHandler))
+	apiGroup.DELETE("/product-types/:id", productTypes((*handlers.ProductTypeHandler).Delete))
+
+	productBrands := scoped(func(db *gorm.DB) *handlers.ProductBrandHandler {
+		return handlers.NewProductBrandHandler(services.NewProductBrandServices(models.ProductBrand{}, db))
+	})
+	apiGroup.GET("/product-brands", productBrands((*handlers.ProductBrandHandler).GetAllHandler))
+	apiGroup.GET("/product-brands/:id", productBrands((*handlers.ProductBrandHandler).GetIDHandler))
+	apiGroup.POST("/product-brands", productBrands((*handlers.ProductBrandHandler).CreateHandler))
+	apiGroup.PUT("/product-brands/:id", productBrands((*handlers.ProductBrandHandler).UpdateHandler))
+	apiGroup.DELETE("/product-brands/:id", productBrands((*handlers.ProductBrandHandler).Delete))
 
 	// Customer routes - matches PHP: /api/customers
-	customerService := services.NewCustomerService(models.Customer{}, store)
-	customerHandler := handlers.NewCustomerHandler(customerService)
-	apiGroup.GET("/customers", customerHandler.GetAllHandler)
-	apiGroup.GET("/customers/:id", customerHandler.GetIDHandler)
-	apiGroup.POST("/customers", customerHandler.CreateHandler)
-	apiGroup.PUT("/customers/:id", customerHandler.UpdateHandler)
-	apiGroup.DELETE("/customers/:id", customerHandler.Delete)
+	customers := scoped(func(db *gorm.DB) *handlers.CustomerHandler {
+		return handlers.NewCustomerHandler(services.NewCustomerService(models.Customer{}, db))
+	})
+	apiGroup.GET("/customers", customers((*handlers.CustomerHandler).GetAllHandler))
+	apiGroup.GET("/customers/:id", customers((*handlers.CustomerHandler).GetIDHandler))
+	apiGroup.POST("/customers", customers((*handlers.CustomerHandler).CreateHandler))
+	apiGroup.PUT("/customers/:id", customers((*handlers.CustomerHandler).UpdateHandler))
+	apiGroup.DELETE("/customers/:id", customers((*handlers.CustomerHandler).Delete))
+
+	// Price lists - assigned to customers and resolved by the sales endpoints
+	priceLists := scoped(func(db *gorm.DB) *handlers.PriceListHandler {
+		return handlers.NewPriceListHandler(services.NewPriceListService(db))
+	})
+	apiGroup.GET("/price-lists", priceLists((*handlers.PriceListHandler).GetAllHandler))
+	apiGroup.GET("/price-lists/:id", priceLists((*handlers.PriceListHandler).GetIDHandler))
+	apiGroup.POST("/price-lists", priceLists((*handlers.PriceListHandler).CreateHandler))
+	apiGroup.PUT("/price-lists/:id", priceLists((*handlers.PriceListHandler).UpdateHandler))
+	apiGroup.DELETE("/price-lists/:id", priceLists((*handlers.PriceListHandler).DeleteHandler))
+	apiGroup.GET("/prices/resolve", priceLists((*handlers.PriceListHandler).ResolveHandler))
+
+	promotions := scoped(func(db *gorm.DB) *handlers.PromotionHandler {
+		return handlers.NewPromotionHandler(services.NewPromotionService(db))
+	})
+	apiGroup.GET("/promotions", promotions((*handlers.PromotionHandler).GetAllHandler))
+	apiGroup.GET("/promotions/:id", promotions((*handlers.PromotionHandler).GetIDHandler))
+	apiGroup.POST("/promotions", promotions((*handlers.PromotionHandler).CreateHandler))
+	apiGroup.PUT("/promotions/:id", promotions((*handlers.PromotionHandler).UpdateHandler))
+	apiGroup.DELETE("/promotions/:id", promotions((*handlers.PromotionHandler).DeleteHandler))
+	apiGroup.POST("/promotions/evaluate", promotions((*handlers.PromotionHandler).EvaluateHandler))
 
 	// Location routes - matches PHP: /api/locations
-	locationService := services.NewLocationService(models.Location{}, store)
-	locationHandler := handlers.NewLocationHandler(locationService)
-	apiGroup.GET("/locations", locationHandler.GetAllHandler)
-	apiGroup.GET("/locations/:id", locationHandler.GetIDHandler)
-	apiGroup.POST("/locations", locationHandler.CreateHandler)
-	apiGroup.PUT("/locations/:id", locationHandler.UpdateHandler)
-	apiGroup.DELETE("/locations/:id", locationHandler.Delete)
+	locations := scoped(func(db *gorm.DB) *handlers.LocationHandler {
+		return handlers.NewLocationHandler(services.NewLocationService(models.Location{}, db))
+	})
+	apiGroup.GET("/locations", locations((*handlers.LocationHandler).GetAllHandler))
+	apiGroup.GET("/locations/:id", locations((*handlers.LocationHandler).GetIDHandler))
+	apiGroup.POST("/locations", locations((*handlers.LocationHandler).CreateHandler))
+	apiGroup.PUT("/locations/:id", locations((*handlers.LocationHandler).UpdateHandler))
+	apiGroup.DELETE("/locations/:id", locations((*handlers.LocationHandler).Delete))
 
 	// Stock service for location stock endpoint
-	stockService := services.NewStockService(models.Stock{}, store)
-	stockHandler := handlers.NewStockHandler(stockService)
-	apiGroup.GET("/locations/:id/stock", stockHandler.LocationStockHandler)
+	stock := scoped(func(db *gorm.DB) *handlers.StockHandler {
+		return handlers.NewStockHandler(services.NewStockService(models.Stock{}, db))
+	})
+	apiGroup.GET("/locations/:id/stock", stock((*handlers.StockHandler).LocationStockHandler))
 
 	// Employee routes - matches PHP: /api/employees
-	employeeService := services.NewEmployeeService(models.Employee{}, store)
-	employeeHandler := handlers.NewEmployeeHandler(employeeService)
-	apiGroup.GET("/employees", employeeHandler.GetAllHandler)
-	apiGroup.GET("/employees/:id", employeeHandler.GetIDHandler)
-	apiGroup.POST("/employees", employeeHandler.CreateHandler)
-	apiGroup.PUT("/employees/:id", employeeHandler.UpdateHandler)
-	apiGroup.DELETE("/employees/:id", employeeHandler.Delete)
+	employees := scoped(func(db *gorm.DB) *handlers.EmployeeHandler {
+		return handlers.NewEmployeeHandler(services.NewEmployeeService(models.Employee{}, db))
+	})
+	apiGroup.GET("/employees", employees((*handlers.EmployeeHandler).GetAllHandler))
+	apiGroup.GET("/employees/:id", employees((*handlers.EmployeeHandler).GetIDHandler))
+	apiGroup.POST("/employees", employees((*handlers.EmployeeHandler).CreateHandler))
+	apiGroup.PUT("/employees/:id", employees((*handlers.EmployeeHandler).UpdateHandler))
+	apiGroup.DELETE("/employees/:id", employees((*handlers.EmployeeHandler).Delete))
 
 	// Van routes - matches PHP: /api/vans
-	vanService := services.NewVanService(models.Van{}, store)
-	vanHandler := handlers.NewVanHandler(vanService)
-	apiGroup.GET("/vans", vanHandler.GetAllHandler)
-	apiGroup.GET("/vans/:id", vanHandler.GetIDHandler)
-	apiGroup.GET("/vans/:id/stock", stockHandler.VanStockHandler)
-	apiGroup.POST("/vans", vanHandler.CreateHandler)
-	apiGroup.PUT("/vans/:id", vanHandler.UpdateHandler)
-	apiGroup.DELETE("/vans/:id", vanHandler.Delete)
+	vans := scoped(func(db *gorm.DB) *handlers.VanHandler {
+		return handlers.NewVanHandler(services.NewVanService(models.Van{}, db))
+	})
+	apiGroup.GET("/vans", vans((*handlers.VanHandler).GetAllHandler))
+	apiGroup.GET("/vans/:id", vans((*handlers.VanHandler).GetIDHandler))
+	apiGroup.GET("/vans/:id/stock", stock((*handlers.StockHandler).VanStockHandler))
+	apiGroup.POST("/vans", vans((*handlers.VanHandler).CreateHandler))
+	apiGroup.PUT("/vans/:id", vans((*handlers.VanHandler).UpdateHandler))
+	apiGroup.DELETE("/vans/:id", vans((*handlers.VanHandler).Delete))
 
 	// Stock routes - matches PHP: /api/stock
-	apiGroup.GET("/stock", stockHandler.WarehouseStockHandler)
-	apiGroup.GET("/stock/all", stockHandler.AllStockHandler)
-	apiGroup.GET("/stock/inventory", stockHandler.InventorySummaryHandler)
-	apiGroup.GET("/stock/location/:id", stockHandler.LocationStockHandler)
-	apiGroup.GET("/stock/movements", stockHandler.MovementsHandler)
-	apiGroup.POST("/stock/adjust", stockHandler.AdjustStockHandler)
-	apiGroup.POST("/stock/add", stockHandler.AddStockHandler)
+	apiGroup.GET("/stock", stock((*handlers.StockHandler).WarehouseStockHandler))
+	apiGroup.GET("/stock/all", stock((*handlers.StockHandler).AllStockHandler))
+	apiGroup.GET("/stock/inventory", stock((*handlers.StockHandler).InventorySummaryHandler))
+	apiGroup.GET("/stock/location/:id", stock((*handlers.StockHandler).LocationStockHandler))
+	apiGroup.GET("/stock/movements", stock((*handlers.StockHandler).MovementsHandler))
+	apiGroup.POST("/stock/adjust", stock((*handlers.StockHandler).AdjustStockHandler), approvals.Require(handlers.StockAdjustApproval))
+	apiGroup.POST("/stock/add", stock((*handlers.StockHandler).AddStockHandler))
 
 	// Transfer routes - matches PHP: /api/transfers
-	transferService := services.NewTransferService(models.Transfer{}, store)
-	transferHandler := handlers.NewTransferHandler(transferService, stockService)
-	apiGroup.GET("/transfers", transferHandler.GetAllHandler)
-	apiGroup.GET("/transfers/:id", transferHandler.GetIDHandler)
-	apiGroup.POST("/transfers", transferHandler.CreateHandler)
-
-	// Payment service - needed by invoice handler
-	paymentService := services.NewPaymentService(models.Payment{}, store)
+	transfers := scoped(func(db *gorm.DB) *handlers.TransferHandler {
+		return handlers.NewTransferHandler(services.NewTransferService(models.Transfer{}, db), services.NewStockService(models.Stock{}, db),
+			services.NewProductUnitService(db))
+	})
+	apiGroup.GET("/transfers", transfers((*handlers.TransferHandler).GetAllHandler))
+	apiGroup.GET("/transfers/:id", transfers((*handlers.TransferHandler).GetIDHandler))
+	apiGroup.POST("/transfers", transfers((*handlers.TransferHandler).CreateHandler), approvals.Require(handlers.TransferApproval))
 
 	// Invoice routes - matches PHP: /api/invoices
-	salesInvoiceService := services.NewSalesInvoiceService(models.SalesInvoice{}, store)
-	purchaseInvoiceService := services.NewPurchaseInvoiceService(models.PurchaseInvoice{}, store)
-	invoiceHandler := handlers.NewInvoiceHandler(salesInvoiceService, purchaseInvoiceService, stockService, paymentService)
-	apiGroup.GET("/invoices/stats", invoiceHandler.StatsHandler)
-	apiGroup.GET("/invoices", invoiceHandler.GetAllHandler)
-	apiGroup.GET("/invoices/:id", invoiceHandler.GetIDHandler)
-	apiGroup.PUT("/invoices/:id", invoiceHandler.UpdateHandler)
-	apiGroup.POST("/invoices/purchase", invoiceHandler.CreatePurchaseHandler)
-	apiGroup.POST("/invoices/sales", invoiceHandler.CreateSalesHandler)
-	apiGroup.PUT("/invoices/sales/:id/items/:item_id", invoiceHandler.UpdateSalesInvoiceItem)
-	apiGroup.PUT("/invoices/purchase/:id/items/:item_id", invoiceHandler.UpdatePurchaseInvoiceItem)
-	apiGroup.POST("/invoices/sales/:id/items", invoiceHandler.AddSalesInvoiceItem)
-	apiGroup.POST("/invoices/purchase/:id/items", invoiceHandler.AddPurchaseInvoiceItem)
-	apiGroup.DELETE("/invoices/:id", invoiceHandler.DeleteInvoiceHandler)
+	invoices := scoped(func(db *gorm.DB) *handlers.InvoiceHandler {
+		sales := services.NewSalesInvoiceService(models.SalesInvoice{}, db)
+		purchases := services.NewPurchaseInvoiceService(models.PurchaseInvoice{}, db)
+		return handlers.NewInvoiceHandler(sales, purchases, services.NewStockService(models.Stock{}, db), services.NewPaymentService(models.Payment{}, db),
+			services.NewPriceListService(db), services.NewPromotionService(db), services.NewProductUnitService(db),
+			services.NewRoleService(models.Role{}, db))
+	})
+	apiGroup.GET("/invoices/stats", invoices((*handlers.InvoiceHandler).StatsHandler))
+	apiGroup.GET("/invoices", invoices((*handlers.InvoiceHandler).GetAllHandler))
+	apiGroup.GET("/invoices/:id", invoices((*handlers.InvoiceHandler).GetIDHandler))
+	apiGroup.PUT("/invoices/:id", invoices((*handlers.InvoiceHandler).UpdateHandler))
+	apiGroup.POST("/invoices/purchase", invoices((*handlers.InvoiceHandler).CreatePurchaseHandler))
+	apiGroup.POST("/invoices/sales", invoices((*handlers.InvoiceHandler).CreateSalesHandler), approvals.Require(handlers.SalesDiscountApproval))
+	apiGroup.PUT("/invoices/sales/:id/items/:item_id", invoices((*handlers.InvoiceHandler).UpdateSalesInvoiceItem), approvals.Require(handlers.SalesDiscountApproval))
+	apiGroup.PUT("/invoices/purchase/:id/items/:item_id", invoices((*handlers.InvoiceHandler).UpdatePurchaseInvoiceItem))
+	apiGroup.POST("/invoices/sales/:id/items", invoices((*handlers.InvoiceHandler).AddSalesInvoiceItem), approvals.Require(handlers.SalesDiscountApproval))
+	apiGroup.POST("/invoices/purchase/:id/items", invoices((*handlers.InvoiceHandler).AddPurchaseInvoiceItem))
+	apiGroup.DELETE("/invoices/:id", invoices((*handlers.InvoiceHandler).DeleteInvoiceHandler), approvals.Require(handlers.InvoiceDeleteApproval))
 
 	// Payment routes - matches PHP: /api/payments
-	paymentHandler := handlers.NewPaymentHandler(paymentService, salesInvoiceService, purchaseInvoiceService)
-	apiGroup.GET("/payments", paymentHandler.GetAllHandler)
-	apiGroup.POST("/payments", paymentHandler.CreateHandler)
+	payments := scoped(func(db *gorm.DB) *handlers.PaymentHandler {
+		sales := services.NewSalesInvoiceService(models.SalesInvoice{}, db)
+		purchases := services.NewPurchaseInvoiceService(models.PurchaseInvoice{}, db)
+		return handlers.NewPaymentHandler(services.NewPaymentService(models.Payment{}, db), sales, purchases)
+	})
+	apiGroup.GET("/payments", payments((*handlers.PaymentHandler).GetAllHandler))
+	apiGroup.POST("/payments", payments((*handlers.PaymentHandler).CreateHandler))
 
 	// Report routes - matches PHP: /api/reports
-	reportHandler := handlers.NewReportHandler(store)
-	apiGroup.GET("/reports/sales", reportHandler.SalesReportHandler)
-	apiGroup.GET("/reports/stock-movements", reportHandler.StockMovementsReportHandler)
-	apiGroup.GET("/reports/receivables", reportHandler.ReceivablesReportHandler)
-	apiGroup.GET("/reports/product-performance", reportHandler.ProductPerformanceReportHandler)
-	apiGroup.GET("/reports/location-sales", reportHandler.LocationSalesReportHandler)
-	apiGroup.GET("/reports/dashboard", reportHandler.DashboardReportHandler)
+	reports := scoped(func(db *gorm.DB) *handlers.ReportHandler {
+		return handlers.NewReportHandler(db)
+	})
+	apiGroup.GET("/reports/sales", reports((*handlers.ReportHandler).SalesReportHandler))
+	apiGroup.GET("/reports/stock-movements", reports((*handlers.ReportHandler).StockMovementsReportHandler))
+	apiGroup.GET("/reports/receivables", reports((*handlers.ReportHandler).ReceivablesReportHandler))
+	apiGroup.GET("/reports/product-performance", reports((*handlers.ReportHandler).ProductPerformanceReportHandler))
+	apiGroup.GET("/reports/brand-performance", reports((*handlers.ReportHandler).BrandPerformanceReportHandler))
+	apiGroup.GET("/reports/location-sales", reports((*handlers.ReportHandler).LocationSalesReportHandler))
+	apiGroup.GET("/reports/dashboard", reports((*handlers.ReportHandler).DashboardReportHandler))
+	apiGroup.GET("/reports/expenses", reports((*handlers.ReportHandler).ExpenseReportHandler))
+	apiGroup.GET("/reports/van-profitability", reports((*handlers.ReportHandler).VanProfitabilityReportHandler))
+	apiGroup.GET("/reports/promotions", reports((*handlers.ReportHandler).PromotionReportHandler))
 
 	// User routes - matches PHP: /api/users
-	userservice := services.NewUserService(models.User{}, store)
-	userHandler := handlers.NewUserHandler(userservice)
-	apiGroup.GET("/users", userHandler.GetAllHandler)
-	apiGroup.GET("/users/:id", userHandler.GetIDHandler)
-	apiGroup.POST("/users", userHandler.CreateHandler)
-	apiGroup.PUT("/users/:id", userHandler.UpdateHandler)
-	apiGroup.DELETE("/users/:id", userHandler.UpdateToDelete)
+	users := scoped(func(db *gorm.DB) *handlers.UserHandler {
+		return handlers.NewUserHandler(services.NewUserService(models.User{}, db), services.NewRoleService(models.Role{}, db))
+	})
+	apiGroup.GET("/users", users((*handlers.UserHandler).GetAllHandler))
+	apiGroup.GET("/users/:id", users((*handlers.UserHandler).GetIDHandler))
+	apiGroup.POST("/users", users((*handlers.UserHandler).CreateHandler))
+	apiGroup.PUT("/users/:id", users((*handlers.UserHandler).UpdateHandler))
+	apiGroup.PUT("/users/:id/password", users((*handlers.UserHandler).UpdatePasswordHandler))
+	apiGroup.DELETE("/users/:id", users((*handlers.UserHandler).UpdateToDelete))
+	apiGroup.DELETE("/users/:id/two-factor", twoFactor.ResetUserHandler)
 
 	// Vendor routes - matches PHP: /api/vendors
-	vendorService := services.NewVendorService(models.Vendor{}, store)
-	vendorHandler := handlers.NewVendorHandler(vendorService)
-	apiGroup.GET("/vendors", vendorHandler.GetAllHandler)
-	apiGroup.GET("/vendors/:id", vendorHandler.GetIDHandler)
-	apiGroup.POST("/vendors", vendorHandler.CreateHandler)
-	apiGroup.PUT("/vendors/:id", vendorHandler.UpdateHandler)
-	apiGroup.DELETE("/vendors/:id", vendorHandler.Delete)
+	vendors := scoped(func(db *gorm.DB) *handlers.VendorHandler {
+		return handlers.NewVendorHandler(services.NewVendorService(models.Vendor{}, db))
+	})
+	apiGroup.GET("/vendors", vendors((*handlers.VendorHandler).GetAllHandler))
+	apiGroup.GET("/vendors/:id", vendors((*handlers.VendorHandler).GetIDHandler))
+	apiGroup.POST("/vendors", vendors((*handlers.VendorHandler).CreateHandler))
+	apiGroup.PUT("/vendors/:id", vendors((*handlers.VendorHandler).UpdateHandler))
+	apiGroup.DELETE("/vendors/:id", vendors((*handlers.VendorHandler).Delete))
+
+	// Statement of account routes
+	statements := scoped(func(db *gorm.DB) *handlers.StatementHandler {
+		return handlers.NewStatementHandler(services.NewStatementService(db))
+	})
+	apiGroup.GET("/customers/:id/statement", statements((*handlers.StatementHandler).CustomerStatementHandler))
+	apiGroup.GET("/vendors/:id/statement", statements((*handlers.StatementHandler).VendorStatementHandler))
+
+	// Cash box and bank account routes
+	moneyAccounts := scoped(func(db *gorm.DB) *handlers.MoneyAccountHandler {
+		return handlers.NewMoneyAccountHandler(services.NewMoneyAccountService(db))
+	})
+	apiGroup.GET("/money-accounts", moneyAccounts((*handlers.MoneyAccountHandler).GetAllHandler))
+	apiGroup.GET("/money-accounts/:id", moneyAccounts((*handlers.MoneyAccountHandler).GetIDHandler))
+	apiGroup.POST("/money-accounts", moneyAccounts((*handlers.MoneyAccountHandler).CreateHandler))
+	apiGroup.PUT("/money-accounts/:id", moneyAccounts((*handlers.MoneyAccountHandler).UpdateHandler))
+	apiGroup.DELETE("/money-accounts/:id", moneyAccounts((*handlers.MoneyAccountHandler).Delete))
+	apiGroup.GET("/money-accounts/:id/transactions", moneyAccounts((*handlers.MoneyAccountHandler).TransactionsHandler))
+	apiGroup.POST("/money-accounts/:id/withdrawals", moneyAccounts((*handlers.MoneyAccountHandler).WithdrawHandler))
+	apiGroup.GET("/money-transfers", moneyAccounts((*handlers.MoneyAccountHandler).GetTransfersHandler))
+	apiGroup.POST("/money-transfers", moneyAccounts((*handlers.MoneyAccountHandler).TransferHandler))
+
+	// Van end-of-day settlement routes
+	vanSettlements := scoped(func(db *gorm.DB) *handlers.VanSettlementHandler {
+		return handlers.NewVanSettlementHandler(services.NewVanSettlementService(db))
+	})
+	apiGroup.GET("/van-settlements", vanSettlements((*handlers.VanSettlementHandler).GetAllHandler))
+	apiGroup.GET("/van-settlements/:id", vanSettlements((*handlers.VanSettlementHandler).GetIDHandler))
+	apiGroup.GET("/van-settlements/:id/invoices", vanSettlements((*handlers.VanSettlementHandler).InvoicesHandler))
+	apiGroup.POST("/van-settlements", vanSettlements((*handlers.VanSettlementHandler).CreateHandler))
+	apiGroup.POST("/van-settlements/:id/refresh", vanSettlements((*handlers.VanSettlementHandler).RefreshHandler))
+	apiGroup.PUT("/van-settlements/:id/count", vanSettlements((*handlers.VanSettlementHandler).CountHandler))
+	apiGroup.POST("/van-settlements/:id/close", vanSettlements((*handlers.VanSettlementHandler).CloseHandler))
+	apiGroup.DELETE("/van-settlements/:id", vanSettlements((*handlers.VanSettlementHandler).Delete))
+	apiGroup.GET("/users/:id/cash-variances", vanSettlements((*handlers.VanSettlementHandler).DriverVariancesHandler))
+
+	// General ledger routes
+	ledger := scoped(func(db *gorm.DB) *handlers.LedgerHandler {
+		return handlers.NewLedgerHandler(services.NewLedgerService(db))
+	})
+	apiGroup.GET("/ledger/accounts", ledger((*handlers.LedgerHandler).GetAccountsHandler))
+	apiGroup.GET("/ledger/accounts/:id", ledger((*handlers.LedgerHandler).GetAccountHandler))
+	apiGroup.POST("/ledger/accounts", ledger((*handlers.LedgerHandler).CreateAccountHandler))
+	apiGroup.PUT("/ledger/accounts/:id", ledger((*handlers.LedgerHandler).UpdateAccountHandler))
+	apiGroup.DELETE("/ledger/accounts/:id", ledger((*handlers.LedgerHandler).DeleteAccountHandler))
+	apiGroup.GET("/ledger/mappings", ledger((*handlers.LedgerHandler).GetMappingsHandler))
+	apiGroup.PUT("/ledger/mappings/:key", ledger((*handlers.LedgerHandler).UpdateMappingHandler))
+	apiGroup.GET("/ledger/journal-entries", ledger((*handlers.LedgerHandler).GetEntriesHandler))
+	apiGroup.GET("/ledger/journal-entries/:id", ledger((*handlers.LedgerHandler).GetEntryHandler))
+	apiGroup.POST("/ledger/journal-entries", ledger((*handlers.LedgerHandler).CreateEntryHandler))
+	apiGroup.DELETE("/ledger/journal-entries/:id", ledger((*handlers.LedgerHandler).DeleteEntryHandler))
+	apiGroup.GET("/ledger/reports/trial-balance", ledger((*handlers.LedgerHandler).TrialBalanceHandler))
+	apiGroup.GET("/ledger/reports/profit-loss", ledger((*handlers.LedgerHandler).ProfitAndLossHandler))
+	apiGroup.GET("/ledger/reports/balance-sheet", ledger((*handlers.LedgerHandler).BalanceSheetHandler))
+	apiGroup.GET("/accounting-periods", ledger((*handlers.LedgerHandler).GetPeriodsHandler))
+	apiGroup.POST("/accounting-periods", ledger((*handlers.LedgerHandler).CreatePeriodHandler))
+	apiGroup.POST("/accounting-periods/:id/close", ledger((*handlers.LedgerHandler).ClosePeriodHandler))
+	apiGroup.POST("/accounting-periods/:id/reopen", ledger((*handlers.LedgerHandler).ReopenPeriodHandler))
+
+	// Expense routes
+	expenses := scoped(func(db *gorm.DB) *handlers.ExpenseHandler {
+		return handlers.NewExpenseHandler(services.NewExpenseService(db))
+	})
+	apiGroup.GET("/expense-categories", expenses((*handlers.ExpenseHandler).GetCategoriesHandler))
+	apiGroup.POST("/expense-categories", expenses((*handlers.ExpenseHandler).CreateCategoryHandler))
+	apiGroup.PUT("/expense-categories/:id", expenses((*handlers.ExpenseHandler).UpdateCategoryHandler))
+	apiGroup.DELETE("/expense-categories/:id", expenses((*handlers.ExpenseHandler).DeleteCategoryHandler))
+	apiGroup.GET("/expenses", expenses((*handlers.ExpenseHandler).GetAllHandler))
+	apiGroup.GET("/expenses/:id", expenses((*handlers.ExpenseHandler).GetIDHandler))
+	apiGroup.POST("/expenses", expenses((*handlers.ExpenseHandler).CreateHandler))
+	apiGroup.PUT("/expenses/:id", expenses((*handlers.ExpenseHandler).UpdateHandler))
+	apiGroup.DELETE("/expenses/:id", expenses((*handlers.ExpenseHandler).Delete))
+	apiGroup.POST("/expenses/:id/attachments", expenses((*handlers.ExpenseHandler).UploadAttachmentHandler))
+	apiGroup.GET("/expenses/:id/attachments/:attachment_id", expenses((*handlers.ExpenseHandler).DownloadAttachmentHandler))
+	apiGroup.DELETE("/expenses/:id/attachments/:attachment_id", expenses((*handlers.ExpenseHandler).DeleteAttachmentHandler))
+
+	// Bank reconciliation routes
+	bankReconciliation := scoped(func(db *gorm.DB) *handlers.BankReconciliationHandler {
+		return handlers.NewBankReconciliationHandler(services.NewBankReconciliationService(db))
+	})
+	apiGroup.POST("/money-accounts/:id/statements/import", bankReconciliation((*handlers.BankReconciliationHandler).ImportHandler))
+	apiGroup.GET("/bank-statements", bankReconciliation((*handlers.BankReconciliationHandler).GetAllHandler))
+	apiGroup.GET("/bank-statements/:id", bankReconciliation((*handlers.BankReconciliationHandler).GetIDHandler))
+	apiGroup.POST("/bank-statements/:id/auto-match", bankReconciliation((*handlers.BankReconciliationHandler).AutoMatchHandler))
+	apiGroup.POST("/bank-statements/:id/confirm", bankReconciliation((*handlers.BankReconciliationHandler).ConfirmHandler))
+	apiGroup.POST("/bank-statement-lines/:id/match", bankReconciliation((*handlers.BankReconciliationHandler).MatchHandler))
+	apiGroup.POST("/bank-statement-lines/:id/unmatch", bankReconciliation((*handlers.BankReconciliationHandler).UnmatchHandler))
+	apiGroup.POST("/bank-statement-lines/:id/ignore", bankReconciliation((*handlers.BankReconciliationHandler).IgnoreHandler))
+
+	// Bulk import routes, dry run unless ?dry_run=false
+	imports := scoped(func(db *gorm.DB) *handlers.ImportHandler {
+		return handlers.NewImportHandler(services.NewImportService(db))
+	})
+	apiGroup.POST("/imports/products", imports((*handlers.ImportHandler).ProductsHandler))
+	apiGroup.POST("/imports/customers", imports((*handlers.ImportHandler).CustomersHandler))
+	apiGroup.POST("/imports/vendors", imports((*handlers.ImportHandler).VendorsHandler))
+	apiGroup.POST("/imports/stock", imports((*handlers.ImportHandler).StockHandler))
 
 	// Credit Note routes
-	creditNoteService := services.NewCreditNoteService(store)
-	creditNoteHandler := handlers.NewCreditNoteHandler(creditNoteService)
-	apiGroup.GET("/credit-notes", creditNoteHandler.GetAllHandler)
-	apiGroup.GET("/credit-notes/:id", creditNoteHandler.GetByIDHandler)
-	apiGroup.POST("/credit-notes", creditNoteHandler.CreateHandler)
-	apiGroup.PUT("/credit-notes/:id", creditNoteHandler.UpdateHandler)
-	apiGroup.POST("/credit-notes/:id/approve", creditNoteHandler.ApproveHandler)
-	apiGroup.POST("/credit-notes/:id/cancel", creditNoteHandler.CancelHandler)
-	apiGroup.DELETE("/credit-notes/:id", creditNoteHandler.DeleteHandler)
+	creditNotes := scoped(func(db *gorm.DB) *handlers.CreditNoteHandler {
+		return handlers.NewCreditNoteHandler(services.NewCreditNoteService(db))
+	})
+	apiGroup.GET("/credit-notes", creditNotes((*handlers.CreditNoteHandler).GetAllHandler))
+	apiGroup.GET("/credit-notes/:id", creditNotes((*handlers.CreditNoteHandler).GetByIDHandler))
+	apiGroup.POST("/credit-notes", creditNotes((*handlers.CreditNoteHandler).CreateHandler))
+	apiGroup.PUT("/credit-notes/:id", creditNotes((*handlers.CreditNoteHandler).UpdateHandler))
+	apiGroup.POST("/credit-notes/:id/approve", creditNotes((*handlers.CreditNoteHandler).ApproveHandler), approvals.Require(handlers.CreditNoteApproval))
+	apiGroup.POST("/credit-notes/:id/cancel", creditNotes((*handlers.CreditNoteHandler).CancelHandler))
+	apiGroup.DELETE("/credit-notes/:id", creditNotes((*handlers.CreditNoteHandler).DeleteHandler))
 
 	// Payment Allocation routes
-	paymentAllocationService := services.NewPaymentAllocationService(store)
-	apiGroup.POST("/payment-allocations/allocate-fifo", func(c echo.Context) error {
+	paymentAllocations := scoped(func(db *gorm.DB) *services.PaymentAllocationService {
+		return services.NewPaymentAllocationService(db)
+	})
+	apiGroup.POST("/payment-allocations/allocate-fifo", paymentAllocations(func(pas *services.PaymentAllocationService, c echo.Context) error {
 		var req struct {
 			CustomerID      *uint   `json:"customer_id"`
 			VendorID        *uint   `json:"vendor_id"`
@@ -179,6 +386,7 @@ func SetupRoutes(e *echo.Echo, store *gorm.DB) {
 			PaymentDate     string  `json:"payment_date"`
 			ReferenceNumber *string `json:"reference_number"`
 			Notes           *string `json:"notes"`
+			AccountID       *uint   `json:"account_id"`
 		}
 		if err := c.Bind(&req); err != nil {
 			return handlers.ResponseError(c, err)
@@ -193,7 +401,8 @@ func SetupRoutes(e *echo.Echo, store *gorm.DB) {
 		// Get user ID from context
 		userID := handlers.GetUserIDFromContext(c)
 
-		payment, allocations, err := paymentAllocationService.AllocatePaymentFIFO(
+		payment, allocations, err := pas.AllocatePaymentFIFO(
+			handlers.GetAccessScope(c),
 			req.CustomerID,
 			req.VendorID,
 			req.InvoiceType,
@@ -202,6 +411,7 @@ func SetupRoutes(e *echo.Echo, store *gorm.DB) {
 			paymentDate,
 			req.ReferenceNumber,
 			req.Notes,
+			req.AccountID,
 			userID,
 		)
 		if err != nil {
@@ -212,57 +422,133 @@ func SetupRoutes(e *echo.Echo, store *gorm.DB) {
 			"payment":     payment,
 			"allocations": allocations,
 		})
-	})
-	apiGroup.GET("/payment-allocations/:id/allocations", func(c echo.Context) error {
+	}))
+	apiGroup.GET("/payment-allocations/:id/allocations", paymentAllocations(func(pas *services.PaymentAllocationService, c echo.Context) error {
 		paymentID := c.Param("id")
 		id, err := handlers.ParseUint(paymentID)
 		if err != nil {
 			return handlers.ResponseError(c, err)
 		}
-		allocations, err := paymentAllocationService.GetPaymentAllocations(id)
+		allocations, err := pas.GetPaymentAllocations(id)
 		if err != nil {
 			return handlers.ResponseError(c, err)
 		}
 		return handlers.ResponseOK(c, allocations, "data")
-	})
-	apiGroup.GET("/invoices/:id/allocations", func(c echo.Context) error {
+	}))
+	apiGroup.GET("/invoices/:id/allocations", paymentAllocations(func(pas *services.PaymentAllocationService, c echo.Context) error {
 		invoiceID := c.Param("id")
 		invoiceType := c.QueryParam("invoice_type")
 		id, err := handlers.ParseUint(invoiceID)
 		if err != nil {
 			return handlers.ResponseError(c, err)
 		}
-		allocations, err := paymentAllocationService.GetInvoiceAllocations(id, invoiceType)
+		allocations, err := pas.GetInvoiceAllocations(id, invoiceType)
 		if err != nil {
 			return handlers.ResponseError(c, err)
 		}
 		return handlers.ResponseOK(c, allocations, "data")
-	})
-	apiGroup.GET("/payment-allocations/:id/allocation-summary", func(c echo.Context) error {
+	}))
+	apiGroup.GET("/payment-allocations/:id/allocation-summary", paymentAllocations(func(pas *services.PaymentAllocationService, c echo.Context) error {
 		paymentID := c.Param("id")
 		id, err := handlers.ParseUint(paymentID)
 		if err != nil {
 			return handlers.ResponseError(c, err)
 		}
-		summary, err := paymentAllocationService.GetAllocationSummary(id)
+		summary, err := pas.GetAllocationSummary(id)
 		if err != nil {
 			return handlers.ResponseError(c, err)
 		}
 		return handlers.ResponseOK(c, summary, "data")
-	})
+	}))
 
 	// Role and Permission routes
-	roleService := services.NewRoleService(models.Role{}, store)
-	roleHandler := handlers.NewRoleHandler(roleService)
-	apiGroup.GET("/roles", roleHandler.GetRoles)
-	apiGroup.GET("/roles/:id", roleHandler.GetRole)
-	apiGroup.POST("/roles", roleHandler.CreateRole)
-	apiGroup.PUT("/roles/:id", roleHandler.UpdateRole)
-	apiGroup.DELETE("/roles/:id", roleHandler.DeleteRole)
-	apiGroup.GET("/permissions", roleHandler.GetPermissions)
-	apiGroup.POST("/roles/:id/permissions", roleHandler.AssignPermissions)
-	apiGroup.POST("/users/assign-role", roleHandler.AssignRoleToUser)
-	apiGroup.POST("/users/remove-role", roleHandler.RemoveRoleFromUser)
-	apiGroup.GET("/users-with-roles", roleHandler.GetUsersWithRoles)
-	apiGroup.GET("/check-permission", roleHandler.CheckPermission)
+	roles := scoped(func(db *gorm.DB) *handlers.RoleHandler {
+		return handlers.NewRoleHandler(services.NewRoleService(models.Role{}, db))
+	})
+	apiGroup.GET("/roles", roles((*handlers.RoleHandler).GetRoles))
+	apiGroup.GET("/roles/:id", roles((*handlers.RoleHandler).GetRole))
+	apiGroup.POST("/roles", roles((*handlers.RoleHandler).CreateRole))
+	apiGroup.PUT("/roles/:id", roles((*handlers.RoleHandler).UpdateRole))
+	apiGroup.DELETE("/roles/:id", roles((*handlers.RoleHandler).DeleteRole))
+	apiGroup.GET("/permissions", roles((*handlers.RoleHandler).GetPermissions))
+	apiGroup.POST("/roles/:id/permissions", roles((*handlers.RoleHandler).AssignPermissions))
+	apiGroup.POST("/users/assign-role", roles((*handlers.RoleHandler).AssignRoleToUser))
+	apiGroup.POST("/users/remove-role", roles((*handlers.RoleHandler).RemoveRoleFromUser))
+	apiGroup.GET("/users-with-roles", roles((*handlers.RoleHandler).GetUsersWithRoles))
+	apiGroup.GET("/users/:id/locations", roles((*handlers.RoleHandler).GetUserLocations))
+	apiGroup.PUT("/users/:id/locations", roles((*handlers.RoleHandler).SetUserLocations))
+	apiGroup.GET("/check-permission", roles((*handlers.RoleHandler).CheckPermission))
+
+	// API key routes - keys for integrations, see JWTMiddleware
+	apiKeys := scoped(func(db *gorm.DB) *handlers.APIKeyHandler {
+		return handlers.NewAPIKeyHandler(services.NewAPIKeyService(db), services.NewRoleService(models.Role{}, db))
+	})
+	apiGroup.GET("/api-keys", apiKeys((*handlers.APIKeyHandler).GetAllHandler))
+	apiGroup.GET("/api-keys/:id", apiKeys((*handlers.APIKeyHandler).GetIDHandler))
+	apiGroup.POST("/api-keys", apiKeys((*handlers.APIKeyHandler).CreateHandler))
+	apiGroup.PUT("/api-keys/:id", apiKeys((*handlers.APIKeyHandler).UpdateHandler))
+	apiGroup.DELETE("/api-keys/:id", apiKeys((*handlers.APIKeyHandler).RevokeHandler))
+
+	// Audit log routes
+	auditLogs := scoped(func(db *gorm.DB) *handlers.AuditLogHandler {
+		return handlers.NewAuditLogHandler(services.NewAuditLogService(db))
+	})
+	apiGroup.GET("/audit-logs", auditLogs((*handlers.AuditLogHandler).GetAllHandler))
+
+	// Approval workflow routes - approved requests are run again through e
+	approvalRequests := scoped(func(db *gorm.DB) *handlers.ApprovalHandler {
+		return handlers.NewApprovalHandler(services.NewApprovalService(db), e)
+	})
+	apiGroup.GET("/approval-rules", approvalRequests((*handlers.ApprovalHandler).GetRulesHandler))
+	apiGroup.GET("/approval-rules/:id", approvalRequests((*handlers.ApprovalHandler).GetRuleHandler))
+	apiGroup.POST("/approval-rules", approvalRequests((*handlers.ApprovalHandler).CreateRuleHandler))
+	apiGroup.PUT("/approval-rules/:id", approvalRequests((*handlers.ApprovalHandler).UpdateRuleHandler))
+	apiGroup.DELETE("/approval-rules/:id", approvalRequests((*handlers.ApprovalHandler).DeleteRuleHandler))
+	apiGroup.GET("/approvals", approvalRequests((*handlers.ApprovalHandler).GetAllHandler))
+	apiGroup.GET("/approvals/:id", approvalRequests((*handlers.ApprovalHandler).GetIDHandler))
+	apiGroup.POST("/approvals/:id/approve", approvalRequests((*handlers.ApprovalHandler).ApproveHandler))
+	apiGroup.POST("/approvals/:id/reject", approvalRequests((*handlers.ApprovalHandler).RejectHandler))
+	apiGroup.POST("/approvals/:id/cancel", approvalRequests((*handlers.ApprovalHandler).CancelHandler))
+
+	// Notification inbox and preferences of the current user
+	notifications := scoped(func(db *gorm.DB) *handlers.NotificationHandler {
+		return handlers.NewNotificationHandler(services.NewNotificationService(db))
+	})
+	apiGroup.GET("/notifications", notifications((*handlers.NotificationHandler).GetAllHandler))
+	apiGroup.GET("/notifications/unread-count", notifications((*handlers.NotificationHandler).UnreadCountHandler))
+	apiGroup.POST("/notifications/:id/read", notifications((*handlers.NotificationHandler).MarkReadHandler))
+	apiGroup.POST("/notifications/read-all", notifications((*handlers.NotificationHandler).MarkAllReadHandler))
+	apiGroup.GET("/notifications/preferences", notifications((*handlers.NotificationHandler).GetPreferencesHandler))
+	apiGroup.PUT("/notifications/preferences", notifications((*handlers.NotificationHandler).UpdatePreferencesHandler))
+
+	// Outbound webhooks - sending is done by services.WebhookDispatcher
+	webhooks := scoped(func(db *gorm.DB) *handlers.WebhookHandler {
+		return handlers.NewWebhookHandler(services.NewWebhookService(db))
+	})
+	apiGroup.GET("/webhooks", webhooks((*handlers.WebhookHandler).GetAllHandler))
+	apiGroup.GET("/webhooks/events", webhooks((*handlers.WebhookHandler).GetEventsHandler))
+	apiGroup.GET("/webhooks/:id", webhooks((*handlers.WebhookHandler).GetIDHandler))
+	apiGroup.POST("/webhooks", webhooks((*handlers.WebhookHandler).CreateHandler))
+	apiGroup.PUT("/webhooks/:id", webhooks((*handlers.WebhookHandler).UpdateHandler))
+	apiGroup.DELETE("/webhooks/:id", webhooks((*handlers.WebhookHandler).DeleteHandler))
+	apiGroup.GET("/webhooks/:id/deliveries", webhooks((*handlers.WebhookHandler).GetDeliveriesHandler))
+	apiGroup.GET("/webhook-deliveries", webhooks((*handlers.WebhookHandler).GetDeliveriesHandler))
+	apiGroup.GET("/webhook-deliveries/:id", webhooks((*handlers.WebhookHandler).GetDeliveryHandler))
+	apiGroup.POST("/webhook-deliveries/:id/redeliver", webhooks((*handlers.WebhookHandler).RedeliverHandler))
+
+	// Offline sync of the mobile van app
+	offlineSync := scoped(func(db *gorm.DB) *handlers.SyncHandler {
+		return handlers.NewSyncHandler(services.NewSyncService(db), services.NewRoleService(models.Role{}, db))
+	})
+	apiGroup.GET("/sync/pull", offlineSync((*handlers.SyncHandler).PullHandler))
+	apiGroup.POST("/sync/push", offlineSync((*handlers.SyncHandler).PushHandler))
+	apiGroup.GET("/sync/conflicts", offlineSync((*handlers.SyncHandler).GetConflictsHandler))
+	apiGroup.POST("/sync/conflicts/:id/resolve", offlineSync((*handlers.SyncHandler).ResolveConflictHandler))
+	apiGroup.GET("/sync/logs", offlineSync((*handlers.SyncHandler).GetLogsHandler))
+
+	// Live dashboard updates as server-sent events
+	events := handlers.NewEventHandler(permissionService)
+	apiGroup.GET("/events", events.StreamHandler)
+
+	warnUnmappedRoutes(e)
 }
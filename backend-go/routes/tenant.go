@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gonext-tech/invoicing-system/backend/handlers"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// scoped builds the handler for every request on the caller's company database (see
+// handlers.TenantMiddleware), so nothing the handler's services query can reach another
+// company's records. Actions are method expressions, e.g. (*handlers.ProductHandler).GetAllHandler.
+func scoped[H any](build func(db *gorm.DB) H) func(action func(H, echo.Context) error) echo.HandlerFunc {
+	return func(action func(H, echo.Context) error) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			db, err := handlers.TenantDB(c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+			return action(build(db), c)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrOutsideScope = errors.New("location is outside your assigned locations")
+
+// AccessScope limits which locations a request may see and act on. Field users with a
+// location-bound role are restricted to their assigned locations; the zero value is
+// unrestricted and is what admins and internal callers use.
+type AccessScope struct {
+	Restricted  bool
+	LocationIDs []uint
+}
+
+// IsAdminRole reports whether a user's role grants unrestricted access
+func IsAdminRole(role string) bool {
+	return strings.EqualFold(role, "ADMIN")
+}
+
+// Filter returns a GORM scope keeping rows where any of the given location columns
+// belongs to the scope
+func (a AccessScope) Filter(columns ...string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !a.Restricted {
+			return db
+		}
+		if len(a.LocationIDs) == 0 {
+			return db.Where("1 = 0")
+		}
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = column + " IN ?"
+			args[i] = a.LocationIDs
+		}
+		return db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+}
+
+// Allows reports whether a location belongs to the scope
+func (a AccessScope) Allows(locationID uint) bool {
+	if !a.Restricted {
+		return true
+	}
+	for _, id := range a.LocationIDs {
+		if id == locationID {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns ErrOutsideScope unless every given location belongs to the scope
+func (a AccessScope) Check(locationIDs ...uint) error {
+	for _, locationID := range locationIDs {
+		if !a.Allows(locationID) {
+			return ErrOutsideScope
+		}
+	}
+	return nil
+}
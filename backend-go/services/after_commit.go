@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Side effects that leave the database (live events, notification deliveries) must only
+// happen once the change they're about is committed. Services queue them with
+// afterCommit; when the services run inside a transaction started with Transaction, the
+// queue is run after the outermost commit and dropped on rollback.
+
+type afterCommitKey struct{}
+
+type afterCommitQueue struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// Transaction runs fn in a transaction on db, then the side effects fn's services queued
+// with afterCommit. Nested calls leave the side effects to the outermost one.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.Statement.Context.Value(afterCommitKey{}).(*afterCommitQueue); ok {
+		return db.Transaction(fn)
+	}
+	queue := &afterCommitQueue{}
+	ctx := context.WithValue(db.Statement.Context, afterCommitKey{}, queue)
+	if err := db.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+	queue.mu.Lock()
+	fns := queue.fns
+	queue.fns = nil
+	queue.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	return nil
+}
+
+// afterCommit runs fn once the Transaction db belongs to has committed, or right away
+// outside of one. fn must not use db, which may be a finished transaction by then.
+func afterCommit(db *gorm.DB, fn func()) {
+	if queue, ok := db.Statement.Context.Value(afterCommitKey{}).(*afterCommitQueue); ok {
+		queue.mu.Lock()
+		queue.fns = append(queue.fns, fn)
+		queue.mu.Unlock()
+		return
+	}
+	fn()
+}
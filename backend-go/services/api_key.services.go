@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// API keys start with apiKeyPrefix so they can't be mistaken for a JWT
+const apiKeyPrefix = "sk_"
+
+var ErrInvalidAPIKey = errors.New("invalid, expired or revoked API key")
+
+// IsAPIKey reports whether a credential is an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, apiKeyPrefix)
+}
+
+// APIKeyAllows reports whether the key was granted resource:action
+func APIKeyAllows(key models.APIKey, resource, action string) bool {
+	for _, permission := range key.Permissions {
+		if permission.Resource == resource && permission.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyScope is the location scope of requests made with the key
+func APIKeyScope(key models.APIKey) AccessScope {
+	if key.LocationID == nil {
+		return AccessScope{}
+	}
+	return AccessScope{Restricted: true, LocationIDs: []uint{*key.LocationID}}
+}
+
+// APIKeyInput creates or changes a key. Permissions are "resource:action" names.
+type APIKeyInput struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	LocationID  *uint      `json:"location_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type APIKeyService struct {
+	DB *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{
+		DB: db,
+	}
+}
+
+func (s *APIKeyService) GetAll() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.DB.Preload("Permissions").Preload("Location").Preload("User").
+		Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *APIKeyService) GetID(id uint) (models.APIKey, error) {
+	var key models.APIKey
+	if err := s.DB.Preload("Permissions").Preload("Location").Preload("User").First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.APIKey{}, errors.New("api key not found")
+		}
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// Create issues a key acting for userID. The key itself is returned only here.
+func (s *APIKeyService) Create(userID uint, input APIKeyInput) (models.APIKey, string, error) {
+	permissions, err := s.validate(input)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	token, _, err := newOpaqueToken()
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	secret := apiKeyPrefix + token
+	key := models.APIKey{
+		Name:        strings.TrimSpace(input.Name),
+		Prefix:      secret[:len(apiKeyPrefix)+8],
+		KeyHash:     hashOpaqueToken(secret),
+		UserID:      userID,
+		LocationID:  input.LocationID,
+		ExpiresAt:   input.ExpiresAt,
+		Permissions: permissions,
+	}
+	if err := s.DB.Omit("Permissions.*").Create(&key).Error; err != nil {
+		return models.APIKey{}, "", err
+	}
+	return key, secret, nil
+}
+
+// Update changes a key's name, permissions, location and expiry; the secret stays the same
+func (s *APIKeyService) Update(id uint, input APIKeyInput) (models.APIKey, error) {
+	key, err := s.GetID(id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if key.RevokedAt != nil {
+		return models.APIKey{}, errors.New("api key is revoked")
+	}
+	permissions, err := s.validate(input)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&key).Select("name", "location_id", "expires_at").Updates(models.APIKey{
+			Name:       strings.TrimSpace(input.Name),
+			LocationID: input.LocationID,
+			ExpiresAt:  input.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&key).Omit("Permissions.*").Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return s.GetID(id)
+}
+
+// Revoke disables a key for good
+func (s *APIKeyService) Revoke(id uint) error {
+	if _, err := s.GetID(id); err != nil {
+		return err
+	}
+	return s.DB.Model(&models.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// validate checks the input and resolves its permission names
+func (s *APIKeyService) validate(input APIKeyInput) ([]models.Permission, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(input.Permissions) == 0 {
+		return nil, errors.New("at least one permission is required")
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	if input.LocationID != nil {
+		var count int64
+		if err := s.DB.Model(&models.Location{}).Where("id = ?", *input.LocationID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, errors.New("location not found")
+		}
+	}
+
+	permissions := make([]models.Permission, 0, len(input.Permissions))
+	for _, name := range input.Permissions {
+		resource, action, ok := strings.Cut(strings.TrimSpace(name), ":")
+		if !ok {
+			return nil, fmt.Errorf("permission %q must be resource:action", name)
+		}
+		var permission models.Permission
+		if err := s.DB.Where("resource = ? AND action = ?", resource, action).First(&permission).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("unknown permission %q", name)
+			}
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// Operations approval rules can hold back
+const (
+	ApprovalSalesDiscount     = "sales_invoice.discount"
+	ApprovalInvoiceDelete     = "invoice.delete"
+	ApprovalStockAdjust       = "stock.adjust"
+	ApprovalTransferCreate    = "transfer.create"
+	ApprovalCreditNoteApprove = "credit_note.approve"
+)
+
+// ApprovalOperations describes what a rule's threshold is compared to, per operation
+var ApprovalOperations = map[string]string{
+	ApprovalSalesDiscount:     "highest line discount, in percent",
+	ApprovalInvoiceDelete:     "invoice total",
+	ApprovalStockAdjust:       "units added or removed",
+	ApprovalTransferCreate:    "units transferred",
+	ApprovalCreditNoteApprove: "credit note total",
+}
+
+// maxApprovalResult caps the stored response of an executed request
+const maxApprovalResult = 16 * 1024
+
+var (
+	ErrApprovalNotFound = errors.New("approval request not found")
+	ErrApprovalDecided  = errors.New("approval request is no longer pending")
+	ErrNotApprover      = errors.New("you can't decide this approval request")
+)
+
+// ApprovalFacts describe an operation for matching against the rules: Amount is compared
+// to the rule threshold and LocationID to the rule location
+type ApprovalFacts struct {
+	Operation  string
+	Amount     float64
+	LocationID *uint
+	Summary    string
+}
+
+type ApprovalRuleInput struct {
+	Name           string   `json:"name"`
+	Operation      string   `json:"operation"`
+	Threshold      *float64 `json:"threshold"`
+	LocationID     *uint    `json:"location_id"`
+	ApproverRoleID *uint    `json:"approver_role_id"`
+	IsActive       *bool    `json:"is_active"`
+}
+
+// ApprovalSubmission is the API call to hold back
+type ApprovalSubmission struct {
+	Method    string
+	Path      string
+	Body      string
+	IPAddress string
+	APIKeyID  *uint // set when the request was made with an API key
+}
+
+type ApprovalService struct {
+	DB *gorm.DB
+}
+
+func NewApprovalService(db *gorm.DB) *ApprovalService {
+	return &ApprovalService{
+		DB: db,
+	}
+}
+
+func (s *ApprovalService) GetRules() ([]models.ApprovalRule, error) {
+	var rules []models.ApprovalRule
+	if err := s.DB.Preload("Location").Preload("ApproverRole").
+		Order("operation ASC, id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *ApprovalService) GetRule(id uint) (models.ApprovalRule, error) {
+	var rule models.ApprovalRule
+	if err := s.DB.Preload("Location").Preload("ApproverRole").First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ApprovalRule{}, errors.New("approval rule not found")
+		}
+		return models.ApprovalRule{}, err
+	}
+	return rule, nil
+}
+
+func (s *ApprovalService) CreateRule(input ApprovalRuleInput) (models.ApprovalRule, error) {
+	if err := s.validateRule(input); err != nil {
+		return models.ApprovalRule{}, err
+	}
+	rule := models.ApprovalRule{
+		Name:           input.Name,
+		Operation:      input.Operation,
+		Threshold:      input.Threshold,
+		LocationID:     input.LocationID,
+		ApproverRoleID: input.ApproverRoleID,
+		IsActive:       input.IsActive == nil || *input.IsActive,
+	}
+	if err := s.DB.Create(&rule).Error; err != nil {
+		return models.ApprovalRule{}, err
+	}
+	return s.GetRule(rule.ID)
+}
+
+func (s *ApprovalService) UpdateRule(id uint, input ApprovalRuleInput) (models.ApprovalRule, error) {
+	rule, err := s.GetRule(id)
+	if err != nil {
+		return models.ApprovalRule{}, err
+	}
+	if err := s.validateRule(input); err != nil {
+		return models.ApprovalRule{}, err
+	}
+	updates := map[string]interface{}{
+		"name":             input.Name,
+		"operation":        input.Operation,
+		"threshold":        input.Threshold,
+		"location_id":      input.LocationID,
+		"approver_role_id": input.ApproverRoleID,
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+	if err := s.DB.Model(&rule).Updates(updates).Error; err != nil {
+		return models.ApprovalRule{}, err
+	}
+	return s.GetRule(id)
+}
+
+// DeleteRule stops the rule from matching. Requests it already parked stay pending.
+func (s *ApprovalService) DeleteRule(id uint) error {
+	rule, err := s.GetRule(id)
+	if err != nil {
+		return err
+	}
+	return s.DB.Delete(&rule).Error
+}
+
+func (s *ApprovalService) validateRule(input ApprovalRuleInput) error {
+	if input.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, ok := ApprovalOperations[input.Operation]; !ok {
+		return fmt.Errorf("unknown operation %q", input.Operation)
+	}
+	if input.Threshold != nil && *input.Threshold < 0 {
+		return errors.New("threshold can't be negative")
+	}
+	if input.LocationID != nil {
+		if err := s.DB.Select("id").First(&models.Location{}, *input.LocationID).Error; err != nil {
+			return errors.New("location not found")
+		}
+	}
+	if input.ApproverRoleID != nil {
+		if err := s.DB.Select("id").First(&models.Role{}, *input.ApproverRoleID).Error; err != nil {
+			return errors.New("approver role not found")
+		}
+	}
+	return nil
+}
+
+// Match returns the first active rule the operation falls under, or nil
+func (s *ApprovalService) Match(facts ApprovalFacts) (*models.ApprovalRule, error) {
+	var rules []models.ApprovalRule
+	if err := s.DB.Where("operation = ? AND is_active = ?", facts.Operation, true).
+		Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if rule.Threshold != nil && facts.Amount <= *rule.Threshold {
+			continue
+		}
+		if rule.LocationID != nil && (facts.LocationID == nil || *facts.LocationID != *rule.LocationID) {
+			continue
+		}
+		return &rules[i], nil
+	}
+	return nil, nil
+}
+
+// CanDecide reports whether the user may decide requests of the approver role. Admins
+// decide everything; without a role only they do.
+func (s *ApprovalService) CanDecide(user models.User, approverRoleID *uint) (bool, error) {
+	if IsAdminRole(user.Role) {
+		return true, nil
+	}
+	if approverRoleID == nil {
+		return false, nil
+	}
+	var count int64
+	if err := s.DB.Model(&models.UserRole{}).
+		Where("user_id = ? AND role_id = ?", user.ID, *approverRoleID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Submit parks an API call under the rule and tells the approvers
+func (s *ApprovalService) Submit(rule models.ApprovalRule, facts ApprovalFacts, call ApprovalSubmission, requester models.User) (models.ApprovalRequest, error) {
+	request := models.ApprovalRequest{
+		RuleID:         rule.ID,
+		Operation:      facts.Operation,
+		Summary:        facts.Summary,
+		Amount:         facts.Amount,
+		LocationID:     facts.LocationID,
+		Method:         call.Method,
+		Path:           call.Path,
+		Body:           call.Body,
+		Status:         models.ApprovalPending,
+		RequestedBy:    requester.ID,
+		RequestedIP:    call.IPAddress,
+		APIKeyID:       call.APIKeyID,
+		ApproverRoleID: rule.ApproverRoleID,
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ApprovalDecision{
+			RequestID: request.ID,
+			UserID:    &requester.ID,
+			Action:    "requested",
+			Comment:   rule.Name,
+		}).Error
+	})
+	if err != nil {
+		return models.ApprovalRequest{}, err
+	}
+
+	s.notifyApprovers(request, requester)
+	return request, nil
+}
+
+// GetAll lists the requests the user made or may decide, newest first
+func (s *ApprovalService) GetAll(user models.User, status string, limit, page int) (PaginationResponse, error) {
+	var requests []models.ApprovalRequest
+	var total int64
+
+	query := s.DB.Model(&models.ApprovalRequest{}).Scopes(s.visibleTo(user)).Preload("Requester").Preload("Decider")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&requests).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	return PaginationResponse{
+		Data:        requests,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
+
+// GetID returns a request the user made or may decide, with its history
+func (s *ApprovalService) GetID(user models.User, id uint) (models.ApprovalRequest, error) {
+	var request models.ApprovalRequest
+	err := s.DB.Scopes(s.visibleTo(user)).
+		Preload("Rule").Preload("Requester").Preload("Decider").
+		Preload("Decisions", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC, id ASC") }).
+		Preload("Decisions.User").
+		First(&request, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.ApprovalRequest{}, ErrApprovalNotFound
+		}
+		return models.ApprovalRequest{}, err
+	}
+	return request, nil
+}
+
+func (s *ApprovalService) visibleTo(user models.User) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if IsAdminRole(user.Role) {
+			return db
+		}
+		roles := s.DB.Model(&models.UserRole{}).Select("role_id").Where("user_id = ?", user.ID)
+		return db.Where("approval_requests.requested_by = ? OR approval_requests.approver_role_id IN (?)", user.ID, roles)
+	}
+}
+
+// Approve marks the request approved. The caller then runs it and reports the outcome
+// with RecordResult.
+func (s *ApprovalService) Approve(user models.User, id uint, comment string) (models.ApprovalRequest, error) {
+	return s.decide(user, id, models.ApprovalApproved, comment)
+}
+
+func (s *ApprovalService) Reject(user models.User, id uint, comment string) (models.ApprovalRequest, error) {
+	request, err := s.decide(user, id, models.ApprovalRejected, comment)
+	if err != nil {
+		return request, err
+	}
+	s.notifyRequester(request, "rejected", comment)
+	return request, nil
+}
+
+// Cancel withdraws a pending request; only its requester can
+func (s *ApprovalService) Cancel(user models.User, id uint, comment string) (models.ApprovalRequest, error) {
+	request, err := s.GetID(user, id)
+	if err != nil {
+		return models.ApprovalRequest{}, err
+	}
+	if request.RequestedBy != user.ID {
+		return models.ApprovalRequest{}, errors.New("only the requester can cancel an approval request")
+	}
+	if err := s.transition(request.ID, user.ID, models.ApprovalCancelled, "cancelled", comment); err != nil {
+		return models.ApprovalRequest{}, err
+	}
+	return s.GetID(user, id)
+}
+
+func (s *ApprovalService) decide(user models.User, id uint, status, comment string) (models.ApprovalRequest, error) {
+	request, err := s.GetID(user, id)
+	if err != nil {
+		return models.ApprovalRequest{}, err
+	}
+	if request.RequestedBy == user.ID {
+		return models.ApprovalRequest{}, errors.New("you can't decide your own approval request")
+	}
+	allowed, err := s.CanDecide(user, request.ApproverRoleID)
+	if err != nil {
+		return models.ApprovalRequest{}, err
+	}
+	if !allowed {
+		return models.ApprovalRequest{}, ErrNotApprover
+	}
+	if err := s.transition(request.ID, user.ID, status, status, comment); err != nil {
+		return models.ApprovalRequest{}, err
+	}
+	return s.GetID(user, id)
+}
+
+// transition moves a pending request to status and records the step. The status
+// condition makes sure two deciders can't both act on the same request.
+func (s *ApprovalService) transition(id, userID uint, status, action, comment string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.ApprovalRequest{}).
+			Where("id = ? AND status = ?", id, models.ApprovalPending).
+			Updates(map[string]interface{}{"status": status, "decided_by": userID, "decided_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrApprovalDecided
+		}
+		return tx.Create(&models.ApprovalDecision{
+			RequestID: id,
+			UserID:    &userID,
+			Action:    action,
+			Comment:   comment,
+		}).Error
+	})
+}
+
+// RecordResult stores the outcome of running an approved request. Responses outside
+// 2xx mark it failed; it is not retried.
+func (s *ApprovalService) RecordResult(user models.User, id uint, statusCode int, body string) (models.ApprovalRequest, error) {
+	status, action := models.ApprovalExecuted, "executed"
+	if statusCode < 200 || statusCode >= 300 {
+		status, action = models.ApprovalFailed, "failed"
+	}
+	if len(body) > maxApprovalResult {
+		body = body[:maxApprovalResult]
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.ApprovalRequest{}).
+			Where("id = ? AND status = ?", id, models.ApprovalApproved).
+			Updates(map[string]interface{}{"status": status, "result_status": statusCode, "result": body, "executed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrApprovalDecided
+		}
+		return tx.Create(&models.ApprovalDecision{
+			RequestID: id,
+			Action:    action,
+			Comment:   fmt.Sprintf("HTTP %d", statusCode),
+		}).Error
+	})
+	if err != nil {
+		return models.ApprovalRequest{}, err
+	}
+
+	request, err := s.GetID(user, id)
+	if err != nil {
+		return models.ApprovalRequest{}, err
+	}
+	s.notifyRequester(request, action, "")
+	return request, nil
+}
+
+// notifyApprovers tells everyone who may decide the request. The request is listed for
+// approvers whether or not that works.
+func (s *ApprovalService) notifyApprovers(request models.ApprovalRequest, requester models.User) {
+	query := s.DB.Model(&models.User{}).Where("users.status = ? AND users.id <> ?", "ACTIVE", requester.ID)
+	if request.ApproverRoleID != nil {
+		query = query.Where("users.role = ? OR users.id IN (?)", "ADMIN",
+			s.DB.Model(&models.UserRole{}).Select("user_id").Where("role_id = ?", *request.ApproverRoleID))
+	} else {
+		query = query.Where("users.role = ?", "ADMIN")
+	}
+	var approvers []models.User
+	if err := query.Find(&approvers).Error; err != nil {
+		log.Printf("approval: could not look up approvers for request #%d: %v", request.ID, err)
+		return
+	}
+
+	Notify(s.DB, approvers, models.Notification{
+		Event: EventApprovalRequested,
+		Title: fmt.Sprintf("Approval needed: %s", request.Summary),
+		Body: fmt.Sprintf("%s %s asked to %s. Review approval request #%d to approve or reject it.",
+			requester.FirstName, requester.LastName, request.Summary, request.ID),
+		Link: fmt.Sprintf("/approvals/%d", request.ID),
+		Data: map[string]interface{}{"approval_request_id": request.ID, "operation": request.Operation},
+	})
+}
+
+func (s *ApprovalService) notifyRequester(request models.ApprovalRequest, outcome, comment string) {
+	if request.Requester == nil {
+		return
+	}
+	body := fmt.Sprintf("Your request to %s (#%d) was %s.", request.Summary, request.ID, outcome)
+	if comment != "" {
+		body += "\n\nComment: " + comment
+	}
+	Notify(s.DB, []models.User{*request.Requester}, models.Notification{
+		Event: EventApprovalDecided,
+		Title: fmt.Sprintf("Approval request #%d %s", request.ID, outcome),
+		Body:  body,
+		Link:  fmt.Sprintf("/approvals/%d", request.ID),
+		Data:  map[string]interface{}{"approval_request_id": request.ID, "status": request.Status},
+	})
+}
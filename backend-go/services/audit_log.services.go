@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// The audit log is written by database.RegisterAuditCallbacks; this service only reads it
+type AuditLogService struct {
+	db *gorm.DB
+}
+
+func NewAuditLogService(db *gorm.DB) *AuditLogService {
+	return &AuditLogService{db: db}
+}
+
+// GetALL lists entries newest first. Filters: entity, entity_id, user_id, action and
+// from_date / to_date (YYYY-MM-DD, both days included).
+func (s *AuditLogService) GetALL(limit, page int, filters map[string]string) (PaginationResponse, error) {
+	var logs []models.AuditLog
+	var total int64
+
+	query := s.db.Model(&models.AuditLog{}).Preload("User")
+	for _, column := range []string{"entity", "entity_id", "user_id", "action"} {
+		if value := filters[column]; value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if value := filters["from_date"]; value != "" {
+		from, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return PaginationResponse{}, errors.New("from_date must be YYYY-MM-DD")
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if value := filters["to_date"]; value != "" {
+		to, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return PaginationResponse{}, errors.New("to_date must be YYYY-MM-DD")
+		}
+		query = query.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	return PaginationResponse{
+		Data:        logs,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
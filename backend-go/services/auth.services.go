@@ -2,11 +2,9 @@ package services
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/golang-jwt/jwt"
 	"github.com/gonext-tech/invoicing-system/backend/models"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -25,14 +23,14 @@ func NewAuthService(u models.User, db *gorm.DB) *AuthService {
 }
 
 func (s *AuthService) Register(email, password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(password)
 	if err != nil {
 		return err
 	}
 
 	user := models.User{
 		Email:    email,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 	}
 	result := s.DB.Create(&user)
 	if result.Error != nil {
@@ -73,41 +71,216 @@ func (as *AuthService) CheckDeactive(user models.User) error {
 	return nil
 }
 
-func (as *AuthService) Login(email, password string) (*http.Cookie, string, error) {
+// TokenPair is what a login or refresh hands to the client
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        uint      `json:"session_id"`
+}
+
+// Login starts a new session for an authenticated user. A session that is
+// twoFactorPending only allows setting up two-factor authentication.
+func (as *AuthService) Login(user models.User, userAgent, ipAddress string, twoFactorPending bool) (TokenPair, error) {
+	refreshToken, refreshHash, err := newOpaqueToken()
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	now := time.Now()
+	session := models.UserSession{
+		UserID:           user.ID,
+		RefreshTokenHash: refreshHash,
+		UserAgent:        truncate(userAgent, 255),
+		IPAddress:        truncate(ipAddress, 45),
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+		LastUsedAt:       now,
+		TwoFactorPending: twoFactorPending,
+	}
+	if err := as.DB.Create(&session).Error; err != nil {
+		return TokenPair{}, err
+	}
+	return as.issue(user, session, refreshToken)
+}
+
+// Refresh exchanges a refresh token for new tokens. The refresh token is rotated on every
+// use; presenting one that was already rotated out means it leaked, so the session ends.
+func (as *AuthService) Refresh(refreshToken, userAgent, ipAddress string) (TokenPair, models.User, error) {
+	if refreshToken == "" {
+		return TokenPair{}, models.User{}, ErrInvalidRefreshToken
+	}
+	hash := hashOpaqueToken(refreshToken)
+
+	var session models.UserSession
+	if err := as.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return TokenPair{}, models.User{}, err
+		}
+		var reused models.UserSession
+		if err := as.DB.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&reused).Error; err == nil {
+			as.revoke(as.DB.Where("id = ?", reused.ID), "refresh_reuse")
+		}
+		return TokenPair{}, models.User{}, ErrInvalidRefreshToken
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return TokenPair{}, models.User{}, ErrInvalidRefreshToken
+	}
+
+	user, err := as.GetUserByID(session.UserID)
+	if err != nil {
+		return TokenPair{}, models.User{}, ErrInvalidRefreshToken
+	}
+	if err := as.CheckDeactive(user); err != nil {
+		as.revoke(as.DB.Where("id = ?", session.ID), "deactivated")
+		return TokenPair{}, models.User{}, err
+	}
 
-	tokenString, err := generateJWT(email)
+	newToken, newHash, err := newOpaqueToken()
 	if err != nil {
-		return nil, "", err
+		return TokenPair{}, models.User{}, err
 	}
-	// Create a cookie containing the token
-	cookie := as.GenerateCookie("jwt", tokenString, "ADD")
-	return cookie, tokenString, nil
+	now := time.Now()
+	// Matching on the old hash makes concurrent refreshes with the same token fail instead of forking the session
+	result := as.DB.Model(&models.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": hash,
+			"user_agent":          truncate(userAgent, 255),
+			"ip_address":          truncate(ipAddress, 45),
+			"expires_at":          now.Add(RefreshTokenTTL()),
+			"last_used_at":        now,
+		})
+	if result.Error != nil {
+		return TokenPair{}, models.User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return TokenPair{}, models.User{}, ErrInvalidRefreshToken
+	}
+
+	session.ExpiresAt = now.Add(RefreshTokenTTL())
+	tokens, err := as.issue(user, session, newToken)
+	return tokens, user, err
 }
 
-func (as *AuthService) Logout() *http.Cookie {
-	// Create a new cookie with an expired value to clear the existing JWT token cookie
-	cookie := as.GenerateCookie("jwt", "", "REMOVE")
+func (as *AuthService) issue(user models.User, session models.UserSession, refreshToken string) (TokenPair, error) {
+	expiresAt := time.Now().Add(AccessTokenTTL())
+	accessToken, err := signAccessToken(user, session.ID, expiresAt)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		SessionID:        session.ID,
+	}, nil
+}
 
-	return cookie
+// TwoFactorChallenge issues the token that, with a second factor, completes a login
+func (as *AuthService) TwoFactorChallenge(user models.User) (string, time.Time, error) {
+	expiresAt := time.Now().Add(twoFactorChallengeTTL)
+	token, err := signChallengeToken(user, expiresAt)
+	return token, expiresAt, err
 }
 
-func (s *AuthService) GetUser(tokenString string) (*models.User, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte("secret"), nil // Replace "secret" with your secret key
-	})
+// CheckChallenge returns the user a two-factor challenge token was issued to
+func (as *AuthService) CheckChallenge(tokenString string) (models.User, error) {
+	claims, err := parseChallengeToken(tokenString)
 	if err != nil {
-		return nil, err
+		return models.User{}, ErrInvalidToken
+	}
+	user, err := as.GetUserByID(claims.UserID)
+	if err != nil {
+		return models.User{}, ErrInvalidToken
 	}
+	if err := as.CheckDeactive(user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
 
-	claims, ok := token.Claims.(*models.Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+// Authenticate validates an access token and its session and returns the user it belongs to
+func (as *AuthService) Authenticate(tokenString string) (models.User, models.UserSession, error) {
+	claims, err := parseAccessToken(tokenString)
+	if err != nil {
+		return models.User{}, models.UserSession{}, err
 	}
 
+	var session models.UserSession
+	if err := as.DB.Select("id", "user_id", "expires_at", "revoked_at", "two_factor_pending").
+		First(&session, claims.SessionID).Error; err != nil {
+		return models.User{}, models.UserSession{}, ErrInvalidToken
+	}
+	if session.UserID != claims.UserID {
+		return models.User{}, models.UserSession{}, ErrInvalidToken
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return models.User{}, models.UserSession{}, ErrSessionRevoked
+	}
+
+	user, err := as.GetUserByID(claims.UserID)
+	if err != nil {
+		return models.User{}, models.UserSession{}, ErrInvalidToken
+	}
+	// A deleted (deactivated) user is locked out straight away, not when the token expires
+	if err := as.CheckDeactive(user); err != nil {
+		as.revoke(as.DB.Where("id = ?", session.ID), "deactivated")
+		return models.User{}, models.UserSession{}, err
+	}
+	return user, session, nil
+}
+
+// AuthenticateAPIKey validates an API key and returns it with the user it acts for
+func (as *AuthService) AuthenticateAPIKey(secret, ipAddress string) (models.User, models.APIKey, error) {
+	var key models.APIKey
+	if err := as.DB.Preload("Permissions").Where("key_hash = ?", hashOpaqueToken(secret)).
+		First(&key).Error; err != nil {
+		return models.User{}, models.APIKey{}, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if !apiKeyUsable(key, now) {
+		return models.User{}, models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	user, err := as.GetUserByID(key.UserID)
+	if err != nil || user.CompanyID != key.CompanyID {
+		return models.User{}, models.APIKey{}, ErrInvalidAPIKey
+	}
+	if err := as.CheckDeactive(user); err != nil {
+		return models.User{}, models.APIKey{}, ErrInvalidAPIKey
+	}
+
+	// Recorded at most once a minute so busy integrations don't write on every request
+	as.DB.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-time.Minute)).
+		Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": truncate(ipAddress, 45)})
+	return user, key, nil
+}
+
+// GetAPIKey returns an API key that can still be used, with its permissions
+func (as *AuthService) GetAPIKey(id uint) (models.APIKey, error) {
+	var key models.APIKey
+	if err := as.DB.Preload("Permissions").First(&key, id).Error; err != nil {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	if !apiKeyUsable(key, time.Now()) {
+		return models.APIKey{}, ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+// apiKeyUsable reports whether the key is neither revoked nor expired
+func apiKeyUsable(key models.APIKey, now time.Time) bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || !now.After(*key.ExpiresAt))
+}
+
+func (as *AuthService) GetUserByID(id uint) (models.User, error) {
 	var user models.User
-	result := s.DB.Preload("Location").Where("email = ?", claims.Email).First(&user)
-	if result.Error != nil {
-		return nil, result.Error
+	if err := as.DB.Preload("Location").First(&user, id).Error; err != nil {
+		return models.User{}, err
 	}
 
 	// Populate computed fields
@@ -116,48 +289,64 @@ func (s *AuthService) GetUser(tokenString string) (*models.User, error) {
 	if user.Location != nil {
 		user.LocationName = user.Location.Name
 	}
+	return user, nil
+}
 
-	return &user, nil
+// GetSessions lists the user's sessions that can still be used
+func (as *AuthService) GetSessions(userID, currentSessionID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	if err := as.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
 }
 
-func generateJWT(email string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &models.Claims{
-		Email: email,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
-		},
-	})
-	tokenString, err := token.SignedString([]byte("secret")) // Replace "secret" with your secret key
-	if err != nil {
-		return "", err
+// RevokeSession ends one of the user's sessions
+func (as *AuthService) RevokeSession(userID, sessionID uint, reason string) error {
+	result := as.revoke(as.DB.Where("id = ? AND user_id = ?", sessionID, userID), reason)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("session not found")
 	}
-	return tokenString, nil
+	return nil
 }
 
-func (as *AuthService) GenerateCookie(name, value, action string) *http.Cookie {
-	if action == "ADD" {
-		return &http.Cookie{
-			Name:     name,
-			Value:    value,
-			Path:     "/",
-			Expires:  time.Now().Add(24 * time.Hour), // Token expires in 24 hours
-			Domain:   ".linksbridge.top",             // Available only on anotherdomain.com
-			HttpOnly: true,
-			Secure:   true, // Set to true in production with HTTPS
-			//SameSite: http.SameSiteNoneMode, // For cross-origin requests
+// RevokeAllSessions ends every session of the user
+func (as *AuthService) RevokeAllSessions(userID uint, reason string) error {
+	return RevokeUserSessions(as.DB, userID, reason)
+}
 
-		}
-	} else {
-		return &http.Cookie{
-			Name:    "jwt",
-			Value:   "",
-			Path:    "/",
-			Expires: time.Now().Add(-time.Hour), // Set expiry in the past to expire immediately
-			//Domain:   "gonext.tech",
-			Domain:   ".linksbridge.top",
-			HttpOnly: true,
-			Secure:   true, // Set to true in production with HTTPS
-			//SameSite: http.SameSiteNoneMode, // For cross-origin requests
-		}
+func (as *AuthService) revoke(query *gorm.DB, reason string) *gorm.DB {
+	return query.Model(&models.UserSession{}).Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason})
+}
+
+// RevokeUserSessions ends every session of a user, e.g. after a password change or
+// deactivation. Their access tokens stop working on the next request.
+func RevokeUserSessions(db *gorm.DB, userID uint, reason string) error {
+	return db.Model(&models.UserSession{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+}
+
+// GenerateCookie returns an HttpOnly cookie; an empty value clears it
+func (as *AuthService) GenerateCookie(name, value, path string, expires time.Time) *http.Cookie {
+	if value == "" {
+		expires = time.Now().Add(-time.Hour) // Set expiry in the past to expire immediately
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expires,
+		Domain:   ".linksbridge.top",
+		HttpOnly: true,
+		Secure:   true, // Set to true in production with HTTPS
+		//SameSite: http.SameSiteNoneMode, // For cross-origin requests
 	}
 }
@@ -0,0 +1,522 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// matchWindowDays is how far apart a statement line and a ledger transaction may be dated
+const matchWindowDays = 3
+
+type BankReconciliationService struct {
+	db *gorm.DB
+}
+
+func NewBankReconciliationService(db *gorm.DB) *BankReconciliationService {
+	return &BankReconciliationService{
+		db: db,
+	}
+}
+
+// ImportStatement parses a CSV or OFX statement file, stores its lines and auto-matches them
+func (s *BankReconciliationService) ImportStatement(accountID uint, fileName string, data []byte, createdBy uint) (models.BankStatementImport, error) {
+	var account models.MoneyAccount
+	if err := s.db.Where("deleted_at IS NULL").First(&account, accountID).Error; err != nil {
+		return models.BankStatementImport{}, errors.New("money account not found")
+	}
+
+	format := "csv"
+	if strings.HasSuffix(strings.ToLower(fileName), ".ofx") || bytes.Contains(data, []byte("<OFX>")) {
+		format = "ofx"
+	}
+
+	var lines []models.BankStatementLine
+	var err error
+	if format == "ofx" {
+		lines, err = parseOFXStatement(data)
+	} else {
+		lines, err = parseCSVStatement(data)
+	}
+	if err != nil {
+		return models.BankStatementImport{}, err
+	}
+	if len(lines) == 0 {
+		return models.BankStatementImport{}, errors.New("statement file contains no transactions")
+	}
+
+	statementImport := models.BankStatementImport{
+		AccountID: account.ID,
+		FileName:  fileName,
+		Format:    format,
+		Status:    "open",
+		LineCount: len(lines),
+		CreatedBy: createdBy,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&statementImport).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].ImportID = statementImport.ID
+			lines[i].AccountID = account.ID
+			lines[i].Status = "unmatched"
+		}
+		return tx.Create(&lines).Error
+	})
+	if err != nil {
+		return statementImport, err
+	}
+
+	if _, err := s.AutoMatch(statementImport.ID); err != nil {
+		return statementImport, err
+	}
+	return s.GetImport(strconv.Itoa(int(statementImport.ID)))
+}
+
+func (s *BankReconciliationService) GetImports(accountID string) ([]models.BankStatementImport, error) {
+	var imports []models.BankStatementImport
+	query := s.db.Model(&models.BankStatementImport{}).Preload("Account")
+	if accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	if err := query.Order("created_at DESC").Find(&imports).Error; err != nil {
+		return nil, err
+	}
+	return imports, nil
+}
+
+func (s *BankReconciliationService) GetImport(id string) (models.BankStatementImport, error) {
+	var statementImport models.BankStatementImport
+	if err := s.db.Preload("Account").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB {
+			return db.Order("transaction_date ASC, id ASC")
+		}).
+		Preload("Lines.MatchedTransaction").
+		First(&statementImport, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return statementImport, errors.New("bank statement not found")
+		}
+		return statementImport, err
+	}
+	return statementImport, nil
+}
+
+// AutoMatch matches unmatched lines to unreconciled ledger transactions with the same
+// signed amount within the date window. A matching reference number wins over date proximity.
+func (s *BankReconciliationService) AutoMatch(importID uint) (int, error) {
+	var lines []models.BankStatementLine
+	if err := s.db.Where("import_id = ? AND status = ?", importID, "unmatched").
+		Order("transaction_date ASC").Find(&lines).Error; err != nil {
+		return 0, err
+	}
+
+	matched := 0
+	for _, line := range lines {
+		candidates, err := s.candidates(line)
+		if err != nil {
+			return matched, err
+		}
+		best := bestCandidate(line, candidates)
+		if best == nil {
+			continue
+		}
+		if err := s.setMatch(line, *best); err != nil {
+			return matched, err
+		}
+		matched++
+	}
+
+	if err := s.refreshCounts(importID); err != nil {
+		return matched, err
+	}
+	return matched, nil
+}
+
+// Match manually matches a statement line to a ledger transaction
+func (s *BankReconciliationService) Match(lineID, transactionID uint) (models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	if err := s.db.First(&line, lineID).Error; err != nil {
+		return line, errors.New("statement line not found")
+	}
+	if line.Status == "reconciled" {
+		return line, errors.New("statement line is already reconciled")
+	}
+
+	var transaction models.MoneyAccountTransaction
+	if err := s.db.First(&transaction, transactionID).Error; err != nil {
+		return line, errors.New("account transaction not found")
+	}
+	if transaction.AccountID != line.AccountID {
+		return line, errors.New("transaction belongs to a different account")
+	}
+	if transaction.IsReconciled {
+		return line, errors.New("transaction is already reconciled")
+	}
+	if s.isClaimed(transaction.ID, line.ID) {
+		return line, errors.New("transaction is already matched to another statement line")
+	}
+
+	if err := s.setMatch(line, transaction); err != nil {
+		return line, err
+	}
+	if err := s.refreshCounts(line.ImportID); err != nil {
+		return line, err
+	}
+	return s.getLine(line.ID)
+}
+
+// Unmatch clears the match of a line that has not been reconciled yet
+func (s *BankReconciliationService) Unmatch(lineID uint) (models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	if err := s.db.First(&line, lineID).Error; err != nil {
+		return line, errors.New("statement line not found")
+	}
+	if line.Status == "reconciled" {
+		return line, errors.New("statement line is already reconciled")
+	}
+
+	if err := s.db.Model(&line).Updates(map[string]interface{}{
+		"status":                 "unmatched",
+		"matched_transaction_id": nil,
+		"matched_payment_id":     nil,
+	}).Error; err != nil {
+		return line, err
+	}
+	if err := s.refreshCounts(line.ImportID); err != nil {
+		return line, err
+	}
+	return s.getLine(line.ID)
+}
+
+// Ignore marks a line that has no counterpart in the ledger (e.g. bank fees handled elsewhere)
+func (s *BankReconciliationService) Ignore(lineID uint) (models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	if err := s.db.First(&line, lineID).Error; err != nil {
+		return line, errors.New("statement line not found")
+	}
+	if line.Status == "reconciled" {
+		return line, errors.New("statement line is already reconciled")
+	}
+
+	if err := s.db.Model(&line).Updates(map[string]interface{}{
+		"status":                 "ignored",
+		"matched_transaction_id": nil,
+		"matched_payment_id":     nil,
+	}).Error; err != nil {
+		return line, err
+	}
+	if err := s.refreshCounts(line.ImportID); err != nil {
+		return line, err
+	}
+	return s.getLine(line.ID)
+}
+
+// Confirm reconciles all matched lines of a statement and marks their transactions as reconciled
+func (s *BankReconciliationService) Confirm(importID uint) (models.BankStatementImport, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var lines []models.BankStatementLine
+		if err := tx.Where("import_id = ? AND status = ?", importID, "matched").Find(&lines).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, line := range lines {
+			if line.MatchedTransactionID == nil {
+				continue
+			}
+			if err := tx.Model(&models.MoneyAccountTransaction{}).Where("id = ?", *line.MatchedTransactionID).
+				Updates(map[string]interface{}{"is_reconciled": true, "reconciled_at": &now}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&line).Update("status", "reconciled").Error; err != nil {
+				return err
+			}
+		}
+
+		var remaining int64
+		tx.Model(&models.BankStatementLine{}).Where("import_id = ? AND status IN ?", importID, []string{"unmatched", "matched"}).
+			Count(&remaining)
+		if remaining == 0 {
+			return tx.Model(&models.BankStatementImport{}).Where("id = ?", importID).Update("status", "completed").Error
+		}
+		return nil
+	})
+	if err != nil {
+		return models.BankStatementImport{}, err
+	}
+	return s.GetImport(strconv.Itoa(int(importID)))
+}
+
+// candidates returns unreconciled, unclaimed transactions with the same amount near the line date
+func (s *BankReconciliationService) candidates(line models.BankStatementLine) ([]models.MoneyAccountTransaction, error) {
+	var transactions []models.MoneyAccountTransaction
+	claimed := s.db.Model(&models.BankStatementLine{}).Select("matched_transaction_id").
+		Where("matched_transaction_id IS NOT NULL AND status IN ?", []string{"matched", "reconciled"})
+
+	err := s.db.Where("account_id = ? AND is_reconciled = ?", line.AccountID, false).
+		Where("amount = ?", roundAmount(line.Amount)).
+		Where("transaction_date BETWEEN ? AND ?",
+			line.TransactionDate.AddDate(0, 0, -matchWindowDays), line.TransactionDate.AddDate(0, 0, matchWindowDays+1)).
+		Where("id NOT IN (?)", claimed).
+		Find(&transactions).Error
+	return transactions, err
+}
+
+func (s *BankReconciliationService) isClaimed(transactionID, exceptLineID uint) bool {
+	var count int64
+	s.db.Model(&models.BankStatementLine{}).
+		Where("matched_transaction_id = ? AND id != ? AND status IN ?", transactionID, exceptLineID, []string{"matched", "reconciled"}).
+		Count(&count)
+	return count > 0
+}
+
+func (s *BankReconciliationService) setMatch(line models.BankStatementLine, transaction models.MoneyAccountTransaction) error {
+	return s.db.Model(&line).Updates(map[string]interface{}{
+		"status":                 "matched",
+		"matched_transaction_id": transaction.ID,
+		"matched_payment_id":     transaction.PaymentID,
+	}).Error
+}
+
+func (s *BankReconciliationService) refreshCounts(importID uint) error {
+	var matched int64
+	s.db.Model(&models.BankStatementLine{}).
+		Where("import_id = ? AND status IN ?", importID, []string{"matched", "reconciled"}).
+		Count(&matched)
+	return s.db.Model(&models.BankStatementImport{}).Where("id = ?", importID).
+		Update("matched_count", matched).Error
+}
+
+func (s *BankReconciliationService) getLine(id uint) (models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	err := s.db.Preload("MatchedTransaction").First(&line, id).Error
+	return line, err
+}
+
+// bestCandidate prefers a reference number match, then the closest date
+func bestCandidate(line models.BankStatementLine, candidates []models.MoneyAccountTransaction) *models.MoneyAccountTransaction {
+	var best *models.MoneyAccountTransaction
+	bestScore := math.MaxFloat64
+
+	for i := range candidates {
+		candidate := &candidates[i]
+		score := math.Abs(candidate.TransactionDate.Sub(line.TransactionDate).Hours())
+		if referenceMatches(line, candidate) {
+			score -= 1000
+		}
+		if score < bestScore {
+			best = candidate
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func referenceMatches(line models.BankStatementLine, transaction *models.MoneyAccountTransaction) bool {
+	if transaction.ReferenceNumber == nil || *transaction.ReferenceNumber == "" {
+		return false
+	}
+	reference := strings.ToLower(strings.TrimSpace(*transaction.ReferenceNumber))
+	return strings.EqualFold(strings.TrimSpace(line.Reference), reference) ||
+		strings.Contains(strings.ToLower(line.Description), reference)
+}
+
+// parseCSVStatement reads a CSV statement with a header row. Recognised columns:
+// date, amount (or debit/credit), description, reference.
+func parseCSVStatement(data []byte) ([]models.BankStatementLine, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("could not read CSV header")
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch {
+		case strings.Contains(name, "date"):
+			if _, ok := columns["date"]; !ok {
+				columns["date"] = i
+			}
+		case name == "amount" || name == "value":
+			columns["amount"] = i
+		case strings.Contains(name, "debit") || name == "withdrawal":
+			columns["debit"] = i
+		case strings.Contains(name, "credit") || name == "deposit":
+			columns["credit"] = i
+		case strings.Contains(name, "description") || name == "details" || name == "narrative" || name == "memo":
+			columns["description"] = i
+		case strings.Contains(name, "reference") || name == "ref":
+			columns["reference"] = i
+		}
+	}
+	if _, ok := columns["date"]; !ok {
+		return nil, errors.New("CSV statement must have a date column")
+	}
+	_, hasAmount := columns["amount"]
+	_, hasDebit := columns["debit"]
+	_, hasCredit := columns["credit"]
+	if !hasAmount && !hasDebit && !hasCredit {
+		return nil, errors.New("CSV statement must have an amount column or debit/credit columns")
+	}
+
+	field := func(record []string, key string) string {
+		i, ok := columns[key]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var lines []models.BankStatementLine
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", row, err)
+		}
+		if field(record, "date") == "" {
+			continue
+		}
+
+		date, err := parseStatementDate(field(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", row, err)
+		}
+
+		var amount float64
+		if hasAmount {
+			amount, err = parseStatementAmount(field(record, "amount"))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", row, err)
+			}
+		} else {
+			credit, err := parseStatementAmount(field(record, "credit"))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", row, err)
+			}
+			debit, err := parseStatementAmount(field(record, "debit"))
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", row, err)
+			}
+			amount = math.Abs(credit) - math.Abs(debit)
+		}
+
+		lines = append(lines, models.BankStatementLine{
+			TransactionDate: date,
+			Amount:          roundAmount(amount),
+			Description:     truncate(field(record, "description"), 255),
+			Reference:       truncate(field(record, "reference"), 100),
+		})
+	}
+	return lines, nil
+}
+
+var ofxTransactionPattern = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+
+// parseOFXStatement reads the STMTTRN blocks of an OFX (SGML or XML) statement
+func parseOFXStatement(data []byte) ([]models.BankStatementLine, error) {
+	var lines []models.BankStatementLine
+	for _, block := range ofxTransactionPattern.FindAllSubmatch(data, -1) {
+		body := string(block[1])
+
+		date, err := parseStatementDate(ofxValue(body, "DTPOSTED"))
+		if err != nil {
+			return nil, err
+		}
+		amount, err := parseStatementAmount(ofxValue(body, "TRNAMT"))
+		if err != nil {
+			return nil, err
+		}
+
+		description := ofxValue(body, "NAME")
+		if memo := ofxValue(body, "MEMO"); memo != "" {
+			if description != "" {
+				description += " - "
+			}
+			description += memo
+		}
+		reference := ofxValue(body, "REFNUM")
+		if reference == "" {
+			reference = ofxValue(body, "CHECKNUM")
+		}
+
+		lines = append(lines, models.BankStatementLine{
+			TransactionDate: date,
+			Amount:          roundAmount(amount),
+			Description:     truncate(description, 255),
+			Reference:       truncate(reference, 100),
+			ExternalID:      truncate(ofxValue(body, "FITID"), 100),
+		})
+	}
+	return lines, nil
+}
+
+// ofxValue returns the value of an OFX element; SGML OFX leaves elements unclosed
+func ofxValue(body, tag string) string {
+	pattern := regexp.MustCompile(`(?i)<` + tag + `>([^<\r\n]*)`)
+	match := pattern.FindStringSubmatch(body)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(match[1])
+}
+
+func parseStatementDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	// OFX dates look like 20240131120000[-5:EST]
+	if len(value) >= 8 && regexp.MustCompile(`^\d{8}`).MatchString(value) {
+		return time.ParseInLocation("20060102", value[:8], time.Local)
+	}
+	for _, layout := range []string{"2006-01-02", "02/01/2006", "01/02/2006", "2006/01/02", "02-01-2006", "02.01.2006"} {
+		if date, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+func parseStatementAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	value = strings.Trim(value, "()")
+	value = strings.NewReplacer(",", "", " ", "", "$", "").Replace(value)
+
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
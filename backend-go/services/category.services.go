@@ -48,6 +48,37 @@ func (s *CategoryService) GetALL(limit, page int, orderBy, sortBy, searchTerm st
 	}, nil
 }
 
+// GetTree returns the top-level categories with their subcategories nested in Children
+func (s *CategoryService) GetTree() ([]models.Category, error) {
+	var categories []models.Category
+	if err := s.db.Order("name_en ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]models.Category)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+	var nest func(category models.Category) models.Category
+	nest = func(category models.Category) models.Category {
+		for _, child := range children[category.ID] {
+			category.Children = append(category.Children, nest(child))
+		}
+		return category
+	}
+
+	tree := []models.Category{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			tree = append(tree, nest(category))
+		}
+	}
+	return tree, nil
+}
+
+// GetID returns the category with the defaults its products get, inherited ones included
 func (s *CategoryService) GetID(id string) (models.Category, error) {
 	var category models.Category
 	if err := s.db.First(&category, id).Error; err != nil {
@@ -56,10 +87,18 @@ func (s *CategoryService) GetID(id string) (models.Category, error) {
 		}
 		return category, err
 	}
+	defaults, err := categoryDefaults(s.db, category.ID)
+	if err != nil {
+		return category, err
+	}
+	category.Defaults = &defaults
 	return category, nil
 }
 
 func (s *CategoryService) Create(category models.Category) (models.Category, error) {
+	if err := s.validateParent(category); err != nil {
+		return category, err
+	}
 	if err := s.db.Create(&category).Error; err != nil {
 		return category, err
 	}
@@ -67,15 +106,178 @@ func (s *CategoryService) Create(category models.Category) (models.Category, err
 }
 
 func (s *CategoryService) Update(category models.Category) (models.Category, error) {
-	if err := s.db.Save(&category).Error; err != nil {
+	if err := s.validateParent(category); err != nil {
+		return category, err
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Children").Save(&category).Error; err != nil {
+			return err
+		}
+		return propagateCategoryDefaults(tx, category.ID)
+	})
+	if err != nil {
+		return category, err
+	}
+	defaults, err := categoryDefaults(s.db, category.ID)
+	if err != nil {
 		return category, err
 	}
+	category.Defaults = &defaults
 	return category, nil
 }
 
 func (s *CategoryService) Delete(category models.Category) error {
+	var children int64
+	if err := s.db.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		return err
+	}
+	if children > 0 {
+		return errors.New("category has subcategories, move or delete them first")
+	}
 	if err := s.db.Delete(&category).Error; err != nil {
 		return err
 	}
 	return nil
 }
+
+// validateParent checks the parent exists and isn't the category or one of its subcategories
+func (s *CategoryService) validateParent(category models.Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	var parent models.Category
+	if err := s.db.First(&parent, *category.ParentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("parent category not found")
+		}
+		return err
+	}
+	if category.ID == 0 {
+		return nil
+	}
+	descendants, err := CategoryWithDescendants(s.db, category.ID)
+	if err != nil {
+		return err
+	}
+	for _, id := range descendants {
+		if id == parent.ID {
+			return errors.New("a category can't be moved under itself or one of its subcategories")
+		}
+	}
+	return nil
+}
+
+// CategoryWithDescendants returns the id of the category followed by those of all its
+// subcategories, at any depth. Filtering on them makes "Beverages" include "Soft Drinks".
+func CategoryWithDescendants(db *gorm.DB, id uint) ([]uint, error) {
+	var categories []models.Category
+	if err := db.Model(&models.Category{}).Select("id", "parent_id").Where("parent_id IS NOT NULL").
+		Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	children := make(map[uint][]uint)
+	for _, category := range categories {
+		children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+	}
+
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// categoryDefaults walks up from the category, each default coming from the nearest
+// category that sets it
+func categoryDefaults(db *gorm.DB, id uint) (models.CategoryDefaults, error) {
+	var defaults models.CategoryDefaults
+	seen := make(map[uint]bool)
+	for next := &id; next != nil && !seen[*next]; {
+		seen[*next] = true
+		var category models.Category
+		if err := db.First(&category, *next).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return defaults, err
+		}
+		if defaults.TaxRate == nil {
+			defaults.TaxRate = category.TaxRate
+		}
+		if defaults.MinStockLevel == nil {
+			defaults.MinStockLevel = category.MinStockLevel
+		}
+		if defaults.Unit == nil {
+			defaults.Unit = category.Unit
+		}
+		next = category.ParentID
+	}
+	return defaults, nil
+}
+
+// applyCategoryDefaults sets the fields the product inherits (see Product.Inherits*) to
+// its category's defaults. Without a default the tax rate is empty, the min stock level
+// 0 and the unit stays as it is.
+func applyCategoryDefaults(db *gorm.DB, product *models.Product) error {
+	var defaults models.CategoryDefaults
+	if product.CategoryID != nil {
+		var err error
+		if defaults, err = categoryDefaults(db, *product.CategoryID); err != nil {
+			return err
+		}
+	}
+	if product.InheritsTaxRate {
+		product.TaxRate = defaults.TaxRate
+	}
+	if product.InheritsUnit && defaults.Unit != nil {
+		product.Unit = *defaults.Unit
+	}
+	if product.InheritsMinStockLevel {
+		product.MinStockLevel = 0
+		if defaults.MinStockLevel != nil {
+			product.MinStockLevel = *defaults.MinStockLevel
+		}
+	}
+	return nil
+}
+
+// propagateCategoryDefaults passes the defaults of the category and its subcategories on
+// to their products' inherited fields, after the defaults or the tree changed
+func propagateCategoryDefaults(tx *gorm.DB, id uint) error {
+	ids, err := CategoryWithDescendants(tx, id)
+	if err != nil {
+		return err
+	}
+	for _, categoryID := range ids {
+		defaults, err := categoryDefaults(tx, categoryID)
+		if err != nil {
+			return err
+		}
+		products := tx.Model(&models.Product{}).Where("category_id = ?", categoryID).Session(&gorm.Session{})
+		if err := products.Where("inherits_tax_rate = ?", true).
+			Update("tax_rate", defaults.TaxRate).Error; err != nil {
+			return err
+		}
+		minimum := 0
+		if defaults.MinStockLevel != nil {
+			minimum = *defaults.MinStockLevel
+		}
+		if err := products.Where("inherits_min_stock_level = ?", true).
+			Update("min_stock_level", minimum).Error; err != nil {
+			return err
+		}
+		if defaults.Unit != nil {
+			if err := products.Where("inherits_unit = ?", true).
+				Update("unit", *defaults.Unit).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
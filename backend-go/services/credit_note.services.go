@@ -135,6 +135,17 @@ func (s *CreditNoteService) Create(creditNote models.CreditNote) (models.CreditN
 		return creditNote, err
 	}
 
+	notifyHolders(s.db, "credit_notes", "approve", models.Notification{
+		Event: EventCreditNotePending,
+		Title: fmt.Sprintf("Credit note %s needs approval", creditNote.CreditNoteNumber),
+		Body:  fmt.Sprintf("Credit note %s for %.2f was created and is waiting to be approved.", creditNote.CreditNoteNumber, creditNote.TotalAmount),
+		Link:  fmt.Sprintf("/credit-notes/%d", creditNote.ID),
+		Data: map[string]interface{}{
+			"credit_note_id": creditNote.ID,
+			"total_amount":   creditNote.TotalAmount,
+		},
+	})
+
 	return s.GetByID(strconv.Itoa(int(creditNote.ID)))
 }
 
@@ -273,6 +284,17 @@ func (s *CreditNoteService) Approve(id string, approvedBy uint) (models.CreditNo
 		return creditNote, err
 	}
 
+	// Post the return to the general ledger
+	if err := postCreditNoteJournal(tx, creditNote); err != nil {
+		tx.Rollback()
+		return creditNote, err
+	}
+
+	if err := PublishEvent(tx, WebhookCreditNoteApproved, map[string]interface{}{"credit_note": creditNote}); err != nil {
+		tx.Rollback()
+		return creditNote, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return creditNote, err
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+type ExpenseService struct {
+	db *gorm.DB
+}
+
+func NewExpenseService(db *gorm.DB) *ExpenseService {
+	return &ExpenseService{
+		db: db,
+	}
+}
+
+func (s *ExpenseService) GetCategories() ([]models.ExpenseCategory, error) {
+	var categories []models.ExpenseCategory
+	if err := s.db.Preload("LedgerAccount").Where("deleted_at IS NULL").
+		Order("name ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (s *ExpenseService) GetCategory(id string) (models.ExpenseCategory, error) {
+	var category models.ExpenseCategory
+	if err := s.db.Preload("LedgerAccount").Where("deleted_at IS NULL").First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return category, errors.New("expense category not found")
+		}
+		return category, err
+	}
+	return category, nil
+}
+
+func (s *ExpenseService) CreateCategory(category models.ExpenseCategory) (models.ExpenseCategory, error) {
+	if category.Name == "" {
+		return category, errors.New("category name is required")
+	}
+	if err := s.validateLedgerAccount(category.LedgerAccountID); err != nil {
+		return category, err
+	}
+	if err := s.db.Create(&category).Error; err != nil {
+		return category, err
+	}
+	return s.GetCategory(strconv.Itoa(int(category.ID)))
+}
+
+func (s *ExpenseService) UpdateCategory(category models.ExpenseCategory) (models.ExpenseCategory, error) {
+	if category.Name == "" {
+		return category, errors.New("category name is required")
+	}
+	if err := s.validateLedgerAccount(category.LedgerAccountID); err != nil {
+		return category, err
+	}
+	if err := s.db.Model(&category).Select("Name", "Description", "LedgerAccountID", "IsActive").
+		Updates(category).Error; err != nil {
+		return category, err
+	}
+	return s.GetCategory(strconv.Itoa(int(category.ID)))
+}
+
+func (s *ExpenseService) DeleteCategory(category models.ExpenseCategory) error {
+	now := time.Now()
+	return s.db.Model(&category).Update("deleted_at", &now).Error
+}
+
+// GetALL lists expenses. Filters: category_id, van_id, location_id, employee_id, money_account_id, from_date, to_date, search
+func (s *ExpenseService) GetALL(limit, page int, filters map[string]string) (PaginationResponse, error) {
+	var expenses []models.Expense
+	var total int64
+
+	query := s.db.Model(&models.Expense{}).
+		Preload("Category").
+		Preload("MoneyAccount").
+		Preload("Van").
+		Preload("Location").
+		Preload("Employee").
+		Where("deleted_at IS NULL")
+
+	for _, column := range []string{"category_id", "van_id", "location_id", "employee_id", "money_account_id"} {
+		if value := filters[column]; value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if filters["from_date"] != "" && filters["to_date"] != "" {
+		query = query.Where("expense_date BETWEEN ? AND ?", filters["from_date"], filters["to_date"])
+	}
+	if search := filters["search"]; search != "" {
+		query = query.Where("description LIKE ? OR reference_number LIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("expense_date DESC, id DESC").Limit(limit).Offset(offset).Find(&expenses).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	return PaginationResponse{
+		Data:        expenses,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  totalPages,
+	}, nil
+}
+
+func (s *ExpenseService) GetID(id string) (models.Expense, error) {
+	var expense models.Expense
+	if err := s.db.Preload("Category").
+		Preload("MoneyAccount").
+		Preload("Van").
+		Preload("Location").
+		Preload("Employee").
+		Preload("Attachments").
+		Preload("CreatedByUser").
+		Where("deleted_at IS NULL").
+		First(&expense, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return expense, errors.New("expense not found")
+		}
+		return expense, err
+	}
+	return expense, nil
+}
+
+// Create records an expense, takes the money out of the paying account and posts it to the ledger
+func (s *ExpenseService) Create(expense models.Expense) (models.Expense, error) {
+	if err := s.validate(expense); err != nil {
+		return expense, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&expense).Error; err != nil {
+			return err
+		}
+		if err := s.withdraw(tx, &expense, expense.CreatedBy); err != nil {
+			return err
+		}
+		return postExpenseJournal(tx, expense)
+	})
+	if err != nil {
+		return expense, err
+	}
+	return s.GetID(strconv.Itoa(int(expense.ID)))
+}
+
+// Update changes an expense; a new amount or paying account re-posts the withdrawal
+func (s *ExpenseService) Update(expense models.Expense, updatedBy uint) (models.Expense, error) {
+	if err := s.validate(expense); err != nil {
+		return expense, err
+	}
+
+	var previous models.Expense
+	if err := s.db.First(&previous, expense.ID).Error; err != nil {
+		return expense, errors.New("expense not found")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		moneyChanged := previous.Amount != expense.Amount ||
+			!sameUintPtr(previous.MoneyAccountID, expense.MoneyAccountID)
+		if moneyChanged {
+			if err := s.reverseWithdrawal(tx, previous, updatedBy); err != nil {
+				return err
+			}
+			expense.AccountTransactionID = nil
+		}
+
+		if err := tx.Model(&expense).Select(
+			"CategoryID", "Amount", "ExpenseDate", "Description", "PaymentMethod", "MoneyAccountID",
+			"AccountTransactionID", "ReferenceNumber", "VanID", "LocationID", "EmployeeID", "Notes",
+		).Updates(expense).Error; err != nil {
+			return err
+		}
+
+		if moneyChanged {
+			if err := s.withdraw(tx, &expense, updatedBy); err != nil {
+				return err
+			}
+		}
+		return postExpenseJournal(tx, expense)
+	})
+	if err != nil {
+		return expense, err
+	}
+	return s.GetID(strconv.Itoa(int(expense.ID)))
+}
+
+// Delete soft deletes an expense and gives the money back to the paying account
+func (s *ExpenseService) Delete(expense models.Expense, deletedBy uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.reverseWithdrawal(tx, expense, deletedBy); err != nil {
+			return err
+		}
+		if err := removeJournal(tx, "expense", expense.ID); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&models.Expense{}).Where("id = ?", expense.ID).Update("deleted_at", &now).Error
+	})
+}
+
+func (s *ExpenseService) AddAttachment(attachment models.ExpenseAttachment) (models.ExpenseAttachment, error) {
+	if err := s.db.Create(&attachment).Error; err != nil {
+		return attachment, err
+	}
+	return attachment, nil
+}
+
+func (s *ExpenseService) GetAttachment(expenseID, attachmentID string) (models.ExpenseAttachment, error) {
+	var attachment models.ExpenseAttachment
+	if err := s.db.Where("expense_id = ?", expenseID).First(&attachment, attachmentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return attachment, errors.New("attachment not found")
+		}
+		return attachment, err
+	}
+	return attachment, nil
+}
+
+// DeleteAttachment removes the attachment record and its file
+func (s *ExpenseService) DeleteAttachment(attachment models.ExpenseAttachment) error {
+	if err := s.db.Delete(&attachment).Error; err != nil {
+		return err
+	}
+	if err := os.Remove(attachment.FilePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *ExpenseService) validate(expense models.Expense) error {
+	if expense.Amount <= 0 {
+		return errors.New("expense amount must be greater than zero")
+	}
+	if expense.ExpenseDate.IsZero() {
+		return errors.New("expense date is required")
+	}
+	if _, err := s.GetCategory(strconv.Itoa(int(expense.CategoryID))); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *ExpenseService) validateLedgerAccount(accountID *uint) error {
+	if accountID == nil {
+		return nil
+	}
+	var account models.LedgerAccount
+	if err := s.db.Where("deleted_at IS NULL").First(&account, *accountID).Error; err != nil {
+		return errors.New("ledger account not found")
+	}
+	if account.Type != "expense" {
+		return errors.New("expense categories must post to an expense account")
+	}
+	return nil
+}
+
+// withdraw takes the expense amount out of its money account, if it was paid from one
+func (s *ExpenseService) withdraw(tx *gorm.DB, expense *models.Expense, createdBy uint) error {
+	if expense.MoneyAccountID == nil {
+		return nil
+	}
+	transaction, err := withdrawFromAccount(tx, *expense.MoneyAccountID, expense.Amount, expense.ExpenseDate,
+		fmt.Sprintf("Expense #%d: %s", expense.ID, expense.Description), expense.ReferenceNumber, createdBy)
+	if err != nil {
+		return err
+	}
+	expense.AccountTransactionID = &transaction.ID
+	return tx.Model(&models.Expense{}).Where("id = ?", expense.ID).Update("account_transaction_id", transaction.ID).Error
+}
+
+// reverseWithdrawal puts the money of an earlier withdrawal back into its account
+func (s *ExpenseService) reverseWithdrawal(tx *gorm.DB, expense models.Expense, createdBy uint) error {
+	if expense.MoneyAccountID == nil || expense.AccountTransactionID == nil {
+		return nil
+	}
+	_, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+		AccountID:       *expense.MoneyAccountID,
+		Type:            "adjustment",
+		Amount:          expense.Amount,
+		TransactionDate: time.Now(),
+		ReferenceNumber: expense.ReferenceNumber,
+		Description:     fmt.Sprintf("Reversal of expense #%d", expense.ID),
+		CreatedBy:       createdBy,
+	})
+	return err
+}
+
+func sameUintPtr(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"github.com/gonext-tech/invoicing-system/backend/utils"
+	"gorm.io/gorm"
+)
+
+// What can be imported
+const (
+	ImportProducts  = "products"
+	ImportCustomers = "customers"
+	ImportVendors   = "vendors"
+	ImportStock     = "stock" // opening balances, added to what's in stock
+)
+
+// maxImportRows limits the rows of one import file
+const maxImportRows = 10000
+
+// ImportRowError is what's wrong with a row of an import file. Row is the line of the
+// file, the header being row 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult reports on an import. Nothing is written on a dry run, nor when any row
+// has errors: the file is imported whole or not at all.
+type ImportResult struct {
+	Type    string           `json:"type"`
+	DryRun  bool             `json:"dry_run"`
+	Rows    int              `json:"rows"`
+	Applied bool             `json:"applied"`
+	Created int              `json:"created"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+type ImportService struct {
+	DB *gorm.DB
+}
+
+func NewImportService(db *gorm.DB) *ImportService {
+	return &ImportService{
+		DB: db,
+	}
+}
+
+// importRow is a row of an import file by (normalised) column name
+type importRow struct {
+	number int
+	values map[string]string
+}
+
+func (r importRow) get(column string) string {
+	return r.values[column]
+}
+
+// importer checks the rows of a file and returns what writes the valid ones
+type importer func(rows []importRow, result *ImportResult) (func(tx *gorm.DB) error, error)
+
+// Import validates every row of a CSV or XLSX file with a header row and, unless dryRun,
+// writes them in a single transaction. Columns are matched by name, regardless of case:
+//
+//	products:  sku, name_en, name_ar, barcode, description, category, brand, unit_price,
+//	           cost_price, tax_rate, unit, min_stock_level, is_active
+//	customers: name, phone, email, address, tax_number, credit_limit, customer_group
+//	vendors:   name, company_name, phone, email, address, tax_number, payment_terms
+//	stock:     sku, location, quantity
+//
+// category, brand and location take a name or an id.
+func (s *ImportService) Import(kind, fileName string, data []byte, scope AccessScope, dryRun bool, createdBy uint) (ImportResult, error) {
+	result := ImportResult{Type: kind, DryRun: dryRun, Errors: []ImportRowError{}}
+
+	var check importer
+	switch kind {
+	case ImportProducts:
+		check = s.products
+	case ImportCustomers:
+		check = s.customers
+	case ImportVendors:
+		check = s.vendors
+	case ImportStock:
+		check = func(rows []importRow, result *ImportResult) (func(tx *gorm.DB) error, error) {
+			return s.stock(rows, result, scope, createdBy)
+		}
+	default:
+		return result, errors.New("import type must be products, customers, vendors or stock")
+	}
+
+	rows, err := readImportFile(fileName, data)
+	if err != nil {
+		return result, err
+	}
+	result.Rows = len(rows)
+	if len(rows) == 0 {
+		return result, errors.New("import file has no rows")
+	}
+	if len(rows) > maxImportRows {
+		return result, fmt.Errorf("import file has more than %d rows", maxImportRows)
+	}
+
+	write, err := check(rows, &result)
+	if err != nil {
+		return result, err
+	}
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	if err := s.DB.Transaction(write); err != nil {
+		return result, err
+	}
+	result.Applied = true
+	result.Created = len(rows)
+	return result, nil
+}
+
+func (s *ImportService) products(rows []importRow, result *ImportResult) (func(tx *gorm.DB) error, error) {
+	categories, err := importLookup(s.DB, &models.Category{}, "name_en")
+	if err != nil {
+		return nil, err
+	}
+	brands, err := importLookup(s.DB, &models.ProductBrand{}, "name_en")
+	if err != nil {
+		return nil, err
+	}
+	var existing []string
+	if err := s.DB.Model(&models.Product{}).Pluck("sku", &existing).Error; err != nil {
+		return nil, err
+	}
+	skus := make(map[string]int)
+	for _, sku := range existing {
+		skus[strings.ToLower(sku)] = 0
+	}
+
+	products := make([]models.Product, 0, len(rows))
+	for _, row := range rows {
+		e := rowErrors{result: result, row: row.number}
+		product := models.Product{
+			SKU:         row.get("sku"),
+			Barcode:     optionalImportValue(row.get("barcode")),
+			NameEn:      row.get("name_en"),
+			NameAr:      optionalImportValue(row.get("name_ar")),
+			Description: optionalImportValue(row.get("description")),
+			Unit:        row.get("unit"),
+			IsActive:    importBool(row.get("is_active")),
+		}
+
+		switch first, seen := skus[strings.ToLower(product.SKU)]; {
+		case product.SKU == "":
+			e.add("sku", "is required")
+		case seen && first == 0:
+			e.add("sku", fmt.Sprintf("%s already exists", product.SKU))
+		case seen:
+			e.add("sku", fmt.Sprintf("%s is also on row %d", product.SKU, first))
+		default:
+			skus[strings.ToLower(product.SKU)] = row.number
+		}
+		if product.NameEn == "" {
+			e.add("name_en", "is required")
+		}
+		product.CategoryID = e.lookup("category", row.get("category"), categories)
+		product.BrandID = e.lookup("brand", row.get("brand"), brands)
+		product.UnitPrice, _ = e.number("unit_price", row.get("unit_price"))
+		product.CostPrice, _ = e.number("cost_price", row.get("cost_price"))
+		if value := row.get("tax_rate"); value != "" {
+			rate, _ := e.number("tax_rate", value)
+			product.TaxRate = &rate
+		}
+		minimum, _ := e.number("min_stock_level", row.get("min_stock_level"))
+		product.MinStockLevel = int(minimum)
+		// Left empty, they follow the category
+		product.InheritsTaxRate = row.get("tax_rate") == ""
+		product.InheritsUnit = row.get("unit") == ""
+		product.InheritsMinStockLevel = row.get("min_stock_level") == ""
+
+		products = append(products, product)
+	}
+
+	return func(tx *gorm.DB) error {
+		for i := range products {
+			if err := applyCategoryDefaults(tx, &products[i]); err != nil {
+				return err
+			}
+			if err := tx.Create(&products[i]).Error; err != nil {
+				return fmt.Errorf("row %d: %v", rows[i].number, err)
+			}
+			// Create leaves false to the column's default of true
+			if !products[i].IsActive {
+				if err := tx.Model(&products[i]).Update("is_active", false).Error; err != nil {
+					return fmt.Errorf("row %d: %v", rows[i].number, err)
+				}
+			}
+		}
+		return nil
+	}, nil
+}
+
+func (s *ImportService) customers(rows []importRow, result *ImportResult) (func(tx *gorm.DB) error, error) {
+	// Customers are told apart by phone number; names repeat
+	var existing []string
+	if err := s.DB.Model(&models.Customer{}).Where("phone IS NOT NULL AND phone <> ''").Pluck("phone", &existing).Error; err != nil {
+		return nil, err
+	}
+	phones := make(map[string]int)
+	for _, phone := range existing {
+		phones[phone] = 0
+	}
+
+	customers := make([]models.Customer, 0, len(rows))
+	for _, row := range rows {
+		e := rowErrors{result: result, row: row.number}
+		customer := models.Customer{
+			Name:          row.get("name"),
+			Phone:         optionalImportValue(row.get("phone")),
+			Email:         optionalImportValue(row.get("email")),
+			Address:       optionalImportValue(row.get("address")),
+			TaxNumber:     optionalImportValue(row.get("tax_number")),
+			CustomerGroup: optionalImportValue(row.get("customer_group")),
+			IsActive:      true,
+		}
+		if customer.Name == "" {
+			e.add("name", "is required")
+		}
+		if customer.Phone != nil {
+			switch first, seen := phones[*customer.Phone]; {
+			case seen && first == 0:
+				e.add("phone", fmt.Sprintf("a customer with phone %s already exists", *customer.Phone))
+			case seen:
+				e.add("phone", fmt.Sprintf("%s is also on row %d", *customer.Phone, first))
+			default:
+				phones[*customer.Phone] = row.number
+			}
+		}
+		customer.CreditLimit, _ = e.number("credit_limit", row.get("credit_limit"))
+		customers = append(customers, customer)
+	}
+
+	return func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&customers, 500).Error
+	}, nil
+}
+
+func (s *ImportService) vendors(rows []importRow, result *ImportResult) (func(tx *gorm.DB) error, error) {
+	var existing []string
+	if err := s.DB.Model(&models.Vendor{}).Pluck("name", &existing).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[string]int)
+	for _, name := range existing {
+		names[strings.ToLower(name)] = 0
+	}
+
+	vendors := make([]models.Vendor, 0, len(rows))
+	for _, row := range rows {
+		e := rowErrors{result: result, row: row.number}
+		vendor := models.Vendor{
+			Name:         row.get("name"),
+			CompanyName:  optionalImportValue(row.get("company_name")),
+			Phone:        optionalImportValue(row.get("phone")),
+			Email:        optionalImportValue(row.get("email")),
+			Address:      optionalImportValue(row.get("address")),
+			TaxNumber:    optionalImportValue(row.get("tax_number")),
+			PaymentTerms: optionalImportValue(row.get("payment_terms")),
+			IsActive:     true,
+		}
+		switch first, seen := names[strings.ToLower(vendor.Name)]; {
+		case vendor.Name == "":
+			e.add("name", "is required")
+		case seen && first == 0:
+			e.add("name", fmt.Sprintf("%s already exists", vendor.Name))
+		case seen:
+			e.add("name", fmt.Sprintf("%s is also on row %d", vendor.Name, first))
+		default:
+			names[strings.ToLower(vendor.Name)] = row.number
+		}
+		vendors = append(vendors, vendor)
+	}
+
+	return func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&vendors, 500).Error
+	}, nil
+}
+
+// stock adds opening balances to the stock of existing products, recording a movement
+// for each and valuing it at cost in the ledger like a stock adjustment
+func (s *ImportService) stock(rows []importRow, result *ImportResult, scope AccessScope, createdBy uint) (func(tx *gorm.DB) error, error) {
+	var products []models.Product
+	if err := s.DB.Select("id", "sku").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	productIDs := make(map[string]uint, len(products))
+	for _, product := range products {
+		productIDs[strings.ToLower(product.SKU)] = product.ID
+	}
+	var locations []models.Location
+	if err := s.DB.Select("id", "name", "type").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	locationIDs := make(map[string]uint, len(locations))
+	locationTypes := make(map[uint]string, len(locations))
+	for _, location := range locations {
+		locationIDs[strings.ToLower(location.Name)] = location.ID
+		locationIDs[strconv.Itoa(int(location.ID))] = location.ID
+		locationTypes[location.ID] = location.Type
+	}
+
+	type opening struct {
+		row       int
+		productID uint
+		location  uint
+		quantity  float64
+	}
+	openings := make([]opening, 0, len(rows))
+	for _, row := range rows {
+		e := rowErrors{result: result, row: row.number}
+		line := opening{row: row.number}
+		if sku := row.get("sku"); sku == "" {
+			e.add("sku", "is required")
+		} else if id, ok := productIDs[strings.ToLower(sku)]; !ok {
+			e.add("sku", fmt.Sprintf("unknown product %s", sku))
+		} else {
+			line.productID = id
+		}
+		if location := row.get("location"); location == "" {
+			e.add("location", "is required")
+		} else if id, ok := locationIDs[strings.ToLower(location)]; !ok {
+			e.add("location", fmt.Sprintf("unknown location %s", location))
+		} else if !scope.Allows(id) {
+			e.add("location", fmt.Sprintf("%s is outside your locations", location))
+		} else {
+			line.location = id
+		}
+		if quantity, ok := e.number("quantity", row.get("quantity")); ok && quantity == 0 {
+			e.add("quantity", "must be more than 0")
+		} else {
+			line.quantity = quantity
+		}
+		openings = append(openings, line)
+	}
+
+	notes := "Opening stock import"
+	return func(tx *gorm.DB) error {
+		for _, line := range openings {
+			stock := models.Stock{ProductID: line.productID, LocationType: locationTypes[line.location], LocationID: line.location}
+			if err := tx.Where(stock).FirstOrCreate(&stock).Error; err != nil {
+				return fmt.Errorf("row %d: %v", line.row, err)
+			}
+			stock.Quantity += line.quantity
+			if err := tx.Save(&stock).Error; err != nil {
+				return fmt.Errorf("row %d: %v", line.row, err)
+			}
+			if err := tx.Create(&models.StockMovement{
+				ProductID:      line.productID,
+				ToLocationType: stock.LocationType,
+				ToLocationID:   stock.LocationID,
+				Quantity:       line.quantity,
+				MovementType:   "opening",
+				Notes:          &notes,
+				CreatedBy:      &createdBy,
+			}).Error; err != nil {
+				return fmt.Errorf("row %d: %v", line.row, err)
+			}
+			if err := postStockAdjustmentJournal(tx, stock, line.quantity); err != nil {
+				return fmt.Errorf("row %d: %v", line.row, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// rowErrors collects the errors of one row into the result
+type rowErrors struct {
+	result *ImportResult
+	row    int
+}
+
+func (e rowErrors) add(column, message string) {
+	e.result.Errors = append(e.result.Errors, ImportRowError{Row: e.row, Column: column, Message: message})
+}
+
+// number reads an amount as leniently as the forms do: empty is 0, and thousands
+// separators, currency signs and accounting brackets are allowed. It reports whether
+// the amount is valid.
+func (e rowErrors) number(column, value string) (float64, bool) {
+	amount, err := parseStatementAmount(value)
+	if err != nil {
+		e.add(column, fmt.Sprintf("%q is not a number", value))
+		return 0, false
+	}
+	if amount < 0 {
+		e.add(column, "can't be negative")
+		return 0, false
+	}
+	return amount, true
+}
+
+// lookup resolves a name or id with ids, from importLookup. Empty is nil.
+func (e rowErrors) lookup(column, value string, ids map[string]uint) *uint {
+	if value == "" {
+		return nil
+	}
+	id, ok := ids[strings.ToLower(value)]
+	if !ok {
+		e.add(column, fmt.Sprintf("unknown %s %s", column, value))
+		return nil
+	}
+	return &id
+}
+
+// importLookup maps the lowercased names and the ids of the records of model to their ids
+func importLookup(db *gorm.DB, model interface{}, nameColumn string) (map[string]uint, error) {
+	var records []struct {
+		ID   uint
+		Name string
+	}
+	if err := db.Model(model).Select("id, " + nameColumn + " as name").Scan(&records).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint, 2*len(records))
+	for _, record := range records {
+		ids[strings.ToLower(record.Name)] = record.ID
+		ids[strconv.Itoa(int(record.ID))] = record.ID
+	}
+	return ids, nil
+}
+
+// importBool reads yes/no values; empty is true, like a new record
+func importBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "false", "0", "no", "n", "inactive":
+		return false
+	}
+	return true
+}
+
+func optionalImportValue(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// readImportFile reads the rows of a CSV or XLSX file (told apart by extension or
+// content) after its header row, skipping empty ones
+func readImportFile(fileName string, data []byte) ([]importRow, error) {
+	var records [][]string
+	if strings.HasSuffix(strings.ToLower(fileName), ".xlsx") || bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		var err error
+		// The header and up to maxImportRows rows
+		if records, err = utils.ReadXLSX(data, maxImportRows+1); err != nil {
+			return nil, err
+		}
+	} else {
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		var err error
+		if records, err = reader.ReadAll(); err != nil {
+			return nil, fmt.Errorf("could not read CSV file: %v", err)
+		}
+	}
+	if len(records) == 0 {
+		return nil, errors.New("import file is empty")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+	}
+
+	var rows []importRow
+	for i, record := range records[1:] {
+		row := importRow{number: i + 2, values: make(map[string]string, len(header))}
+		empty := true
+		for j, value := range record {
+			if j >= len(header) || header[j] == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			row.values[header[j]] = value
+			if value != "" {
+				empty = false
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
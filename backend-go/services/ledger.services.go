@@ -0,0 +1,468 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+type LedgerService struct {
+	db *gorm.DB
+}
+
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{
+		db: db,
+	}
+}
+
+// GetAccounts returns the chart of accounts, seeding the defaults when it is empty
+func (s *LedgerService) GetAccounts(accountType string) ([]models.LedgerAccount, error) {
+	var count int64
+	s.db.Model(&models.LedgerAccount{}).Count(&count)
+	if count == 0 {
+		if err := seedChartOfAccounts(s.db); err != nil {
+			return nil, err
+		}
+	}
+
+	var accounts []models.LedgerAccount
+	query := s.db.Model(&models.LedgerAccount{}).Where("deleted_at IS NULL")
+	if accountType != "" {
+		query = query.Where("type = ?", accountType)
+	}
+	if err := query.Order("code ASC").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (s *LedgerService) GetAccount(id string) (models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	if err := s.db.Preload("Parent").Where("deleted_at IS NULL").First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return account, errors.New("ledger account not found")
+		}
+		return account, err
+	}
+	return account, nil
+}
+
+func (s *LedgerService) CreateAccount(account models.LedgerAccount) (models.LedgerAccount, error) {
+	if err := validateLedgerAccount(account); err != nil {
+		return account, err
+	}
+	account.IsSystem = false
+	if err := s.db.Create(&account).Error; err != nil {
+		return account, err
+	}
+	return s.GetAccount(strconv.Itoa(int(account.ID)))
+}
+
+func (s *LedgerService) UpdateAccount(account models.LedgerAccount) (models.LedgerAccount, error) {
+	if err := validateLedgerAccount(account); err != nil {
+		return account, err
+	}
+	if err := s.db.Model(&account).Select("Code", "Name", "Type", "ParentID", "IsActive").Updates(account).Error; err != nil {
+		return account, err
+	}
+	return s.GetAccount(strconv.Itoa(int(account.ID)))
+}
+
+// DeleteAccount soft deletes an unused, non-system account
+func (s *LedgerService) DeleteAccount(account models.LedgerAccount) error {
+	if account.IsSystem {
+		return errors.New("system accounts cannot be deleted")
+	}
+	var count int64
+	s.db.Model(&models.JournalLine{}).Where("account_id = ?", account.ID).Count(&count)
+	if count > 0 {
+		return errors.New("account has journal postings and cannot be deleted")
+	}
+	s.db.Model(&models.LedgerAccountMapping{}).Where("account_id = ?", account.ID).Count(&count)
+	if count > 0 {
+		return errors.New("account is used by an automatic posting mapping")
+	}
+	now := time.Now()
+	return s.db.Model(&account).Update("deleted_at", &now).Error
+}
+
+// GetMappings returns the account used for each automatic posting role
+func (s *LedgerService) GetMappings() ([]models.LedgerAccountMapping, error) {
+	if err := seedChartOfAccounts(s.db); err != nil {
+		return nil, err
+	}
+	var mappings []models.LedgerAccountMapping
+	if err := s.db.Preload("Account").Order("`key` ASC").Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// UpdateMapping points a posting role at another account
+func (s *LedgerService) UpdateMapping(key string, accountID uint) (models.LedgerAccountMapping, error) {
+	valid := false
+	for _, k := range LedgerMappingKeys() {
+		if k == key {
+			valid = true
+		}
+	}
+	if !valid {
+		return models.LedgerAccountMapping{}, fmt.Errorf("unknown mapping key %s", key)
+	}
+	if _, err := s.GetAccount(strconv.Itoa(int(accountID))); err != nil {
+		return models.LedgerAccountMapping{}, err
+	}
+	if err := seedChartOfAccounts(s.db); err != nil {
+		return models.LedgerAccountMapping{}, err
+	}
+
+	if err := s.db.Model(&models.LedgerAccountMapping{}).Where("`key` = ?", key).
+		Update("account_id", accountID).Error; err != nil {
+		return models.LedgerAccountMapping{}, err
+	}
+
+	var mapping models.LedgerAccountMapping
+	err := s.db.Preload("Account").Where("`key` = ?", key).First(&mapping).Error
+	return mapping, err
+}
+
+func (s *LedgerService) GetEntries(limit, page int, sourceType, accountID, fromDate, toDate string) (PaginationResponse, error) {
+	var entries []models.JournalEntry
+	var total int64
+
+	query := s.db.Model(&models.JournalEntry{}).Preload("Lines").Preload("Lines.Account")
+	if sourceType != "" {
+		query = query.Where("source_type = ?", sourceType)
+	}
+	if accountID != "" {
+		query = query.Where("id IN (?)", s.db.Model(&models.JournalLine{}).Select("entry_id").Where("account_id = ?", accountID))
+	}
+	if fromDate != "" && toDate != "" {
+		query = query.Where("DATE(entry_date) BETWEEN ? AND ?", fromDate, toDate)
+	}
+
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("entry_date DESC, id DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	return PaginationResponse{
+		Data:        entries,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  totalPages,
+	}, nil
+}
+
+func (s *LedgerService) GetEntry(id string) (models.JournalEntry, error) {
+	var entry models.JournalEntry
+	if err := s.db.Preload("Lines").Preload("Lines.Account").First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entry, errors.New("journal entry not found")
+		}
+		return entry, err
+	}
+	return entry, nil
+}
+
+// CreateManualEntry records a hand-written journal entry
+func (s *LedgerService) CreateManualEntry(entry models.JournalEntry) (models.JournalEntry, error) {
+	if len(entry.Lines) < 2 {
+		return entry, errors.New("a journal entry needs at least two lines")
+	}
+	for i, line := range entry.Lines {
+		if line.Debit < 0 || line.Credit < 0 || (line.Debit > 0) == (line.Credit > 0) {
+			return entry, fmt.Errorf("line %d must have either a debit or a credit amount", i+1)
+		}
+		if _, err := s.GetAccount(strconv.Itoa(int(line.AccountID))); err != nil {
+			return entry, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		entry.Lines[i].ID = 0
+		entry.Lines[i].Debit = roundAmount(line.Debit)
+		entry.Lines[i].Credit = roundAmount(line.Credit)
+	}
+	if entry.EntryDate.IsZero() {
+		entry.EntryDate = time.Now()
+	}
+	entry.SourceType = "manual"
+	entry.SourceID = nil
+	entry.IsAutomatic = false
+
+	var created models.JournalEntry
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = createJournal(tx, entry)
+		return err
+	})
+	if err != nil {
+		return entry, err
+	}
+	return s.GetEntry(strconv.Itoa(int(created.ID)))
+}
+
+// DeleteManualEntry removes a manual entry in an open period; automatic entries follow their documents
+func (s *LedgerService) DeleteManualEntry(entry models.JournalEntry) error {
+	if entry.IsAutomatic {
+		return errors.New("automatic entries are removed with the document that created them")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return removeJournalEntries(tx, []models.JournalEntry{entry})
+	})
+}
+
+// TrialBalance lists every account with its opening balance, movements in the period and closing balance
+func (s *LedgerService) TrialBalance(fromDate, toDate time.Time) (models.TrialBalance, error) {
+	lines, err := s.accountBalances(fromDate, endOfDay(toDate))
+	if err != nil {
+		return models.TrialBalance{}, err
+	}
+
+	report := models.TrialBalance{FromDate: fromDate, ToDate: toDate, Lines: []models.LedgerReportLine{}}
+	for _, line := range lines {
+		if line.OpeningBalance == 0 && line.Debit == 0 && line.Credit == 0 {
+			continue
+		}
+		report.TotalDebit += line.Debit
+		report.TotalCredit += line.Credit
+		report.Lines = append(report.Lines, line)
+	}
+	report.TotalDebit = roundAmount(report.TotalDebit)
+	report.TotalCredit = roundAmount(report.TotalCredit)
+	return report, nil
+}
+
+// ProfitAndLoss reports revenue and expenses posted in the period
+func (s *LedgerService) ProfitAndLoss(fromDate, toDate time.Time) (models.ProfitAndLoss, error) {
+	lines, err := s.accountBalances(fromDate, endOfDay(toDate))
+	if err != nil {
+		return models.ProfitAndLoss{}, err
+	}
+
+	report := models.ProfitAndLoss{
+		FromDate: fromDate,
+		ToDate:   toDate,
+		Revenue:  []models.LedgerReportLine{},
+		Expenses: []models.LedgerReportLine{},
+	}
+	for _, line := range lines {
+		movement := roundAmount(line.Debit - line.Credit)
+		switch line.Type {
+		case "revenue":
+			line.Balance = -movement
+			if line.Balance != 0 {
+				report.Revenue = append(report.Revenue, line)
+				report.TotalRevenue += line.Balance
+			}
+		case "expense":
+			line.Balance = movement
+			if line.Balance != 0 {
+				report.Expenses = append(report.Expenses, line)
+				report.TotalExpenses += line.Balance
+			}
+		}
+	}
+	report.TotalRevenue = roundAmount(report.TotalRevenue)
+	report.TotalExpenses = roundAmount(report.TotalExpenses)
+	report.NetIncome = roundAmount(report.TotalRevenue - report.TotalExpenses)
+	return report, nil
+}
+
+// BalanceSheet reports asset, liability and equity balances as of a date.
+// Revenue and expense balances are shown as current earnings under equity.
+func (s *LedgerService) BalanceSheet(asOf time.Time) (models.BalanceSheet, error) {
+	lines, err := s.accountBalances(time.Time{}, endOfDay(asOf))
+	if err != nil {
+		return models.BalanceSheet{}, err
+	}
+
+	report := models.BalanceSheet{
+		AsOf:        asOf,
+		Assets:      []models.LedgerReportLine{},
+		Liabilities: []models.LedgerReportLine{},
+		Equity:      []models.LedgerReportLine{},
+	}
+	for _, line := range lines {
+		balance := roundAmount(line.Debit - line.Credit)
+		switch line.Type {
+		case "asset":
+			line.Balance = balance
+			if balance != 0 {
+				report.Assets = append(report.Assets, line)
+				report.TotalAssets += balance
+			}
+		case "liability":
+			line.Balance = -balance
+			if balance != 0 {
+				report.Liabilities = append(report.Liabilities, line)
+				report.TotalLiabilities += line.Balance
+			}
+		case "equity":
+			line.Balance = -balance
+			if balance != 0 {
+				report.Equity = append(report.Equity, line)
+				report.TotalEquity += line.Balance
+			}
+		case "revenue", "expense":
+			report.CurrentEarnings -= balance
+		}
+	}
+	report.CurrentEarnings = roundAmount(report.CurrentEarnings)
+	report.TotalAssets = roundAmount(report.TotalAssets)
+	report.TotalLiabilities = roundAmount(report.TotalLiabilities)
+	report.TotalEquity = roundAmount(report.TotalEquity + report.CurrentEarnings)
+	return report, nil
+}
+
+// accountBalances sums journal lines per account: postings before fromDate become the
+// opening balance, postings from fromDate to toDate are the period debits and credits
+func (s *LedgerService) accountBalances(fromDate, toDate time.Time) ([]models.LedgerReportLine, error) {
+	accounts, err := s.GetAccounts("")
+	if err != nil {
+		return nil, err
+	}
+
+	var sums []struct {
+		AccountID uint
+		Opening   float64
+		Debit     float64
+		Credit    float64
+	}
+	if err := s.db.Table("journal_lines").
+		Select(`journal_lines.account_id,
+			COALESCE(SUM(CASE WHEN journal_entries.entry_date < ? THEN journal_lines.debit - journal_lines.credit ELSE 0 END), 0) AS opening,
+			COALESCE(SUM(CASE WHEN journal_entries.entry_date >= ? THEN journal_lines.debit ELSE 0 END), 0) AS debit,
+			COALESCE(SUM(CASE WHEN journal_entries.entry_date >= ? THEN journal_lines.credit ELSE 0 END), 0) AS credit`,
+			fromDate, fromDate, fromDate).
+		Joins("JOIN journal_entries ON journal_entries.id = journal_lines.entry_id").
+		Where("journal_entries.entry_date <= ?", toDate).
+		Group("journal_lines.account_id").
+		Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+
+	byAccount := make(map[uint]int, len(sums))
+	for i, sum := range sums {
+		byAccount[sum.AccountID] = i
+	}
+
+	lines := make([]models.LedgerReportLine, 0, len(accounts))
+	for _, account := range accounts {
+		line := models.LedgerReportLine{
+			AccountID: account.ID,
+			Code:      account.Code,
+			Name:      account.Name,
+			Type:      account.Type,
+		}
+		if i, ok := byAccount[account.ID]; ok {
+			line.OpeningBalance = roundAmount(sums[i].Opening)
+			line.Debit = roundAmount(sums[i].Debit)
+			line.Credit = roundAmount(sums[i].Credit)
+		}
+		line.Balance = roundAmount(line.OpeningBalance + line.Debit - line.Credit)
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func (s *LedgerService) GetPeriods() ([]models.AccountingPeriod, error) {
+	var periods []models.AccountingPeriod
+	if err := s.db.Order("start_date DESC").Find(&periods).Error; err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+func (s *LedgerService) CreatePeriod(period models.AccountingPeriod) (models.AccountingPeriod, error) {
+	if period.Name == "" {
+		return period, errors.New("period name is required")
+	}
+	if period.EndDate.Before(period.StartDate) {
+		return period, errors.New("end_date must not be before start_date")
+	}
+
+	var count int64
+	s.db.Model(&models.AccountingPeriod{}).
+		Where("start_date <= ? AND end_date >= ?", period.EndDate.Format("2006-01-02"), period.StartDate.Format("2006-01-02")).
+		Count(&count)
+	if count > 0 {
+		return period, errors.New("period overlaps an existing accounting period")
+	}
+
+	period.Status = "open"
+	if err := s.db.Create(&period).Error; err != nil {
+		return period, err
+	}
+	return period, nil
+}
+
+// ClosePeriod stops any further posting dated inside the period
+func (s *LedgerService) ClosePeriod(id string, closedBy uint) (models.AccountingPeriod, error) {
+	period, err := s.getPeriod(id)
+	if err != nil {
+		return period, err
+	}
+	if period.Status == "closed" {
+		return period, errors.New("period is already closed")
+	}
+
+	now := time.Now()
+	period.Status = "closed"
+	period.ClosedBy = &closedBy
+	period.ClosedAt = &now
+	if err := s.db.Save(&period).Error; err != nil {
+		return period, err
+	}
+	return period, nil
+}
+
+func (s *LedgerService) ReopenPeriod(id string) (models.AccountingPeriod, error) {
+	period, err := s.getPeriod(id)
+	if err != nil {
+		return period, err
+	}
+	if period.Status != "closed" {
+		return period, errors.New("period is not closed")
+	}
+
+	if err := s.db.Model(&period).Updates(map[string]interface{}{
+		"status":    "open",
+		"closed_by": nil,
+		"closed_at": nil,
+	}).Error; err != nil {
+		return period, err
+	}
+	return s.getPeriod(id)
+}
+
+func (s *LedgerService) getPeriod(id string) (models.AccountingPeriod, error) {
+	var period models.AccountingPeriod
+	if err := s.db.First(&period, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return period, errors.New("accounting period not found")
+		}
+		return period, err
+	}
+	return period, nil
+}
+
+func validateLedgerAccount(account models.LedgerAccount) error {
+	if account.Code == "" || account.Name == "" {
+		return errors.New("account code and name are required")
+	}
+	switch account.Type {
+	case "asset", "liability", "equity", "revenue", "expense":
+		return nil
+	}
+	return errors.New("account type must be asset, liability, equity, revenue or expense")
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// Ledger mapping keys used by the automatic postings
+const (
+	LedgerCash                = "cash"
+	LedgerBank                = "bank"
+	LedgerAccountsReceivable  = "accounts_receivable"
+	LedgerInventory           = "inventory"
+	LedgerAccountsPayable     = "accounts_payable"
+	LedgerSalesRevenue        = "sales_revenue"
+	LedgerCostOfGoodsSold     = "cost_of_goods_sold"
+	LedgerInventoryAdjustment = "inventory_adjustment"
+	LedgerOperatingExpenses   = "operating_expenses"
+)
+
+// defaultChartOfAccounts is seeded the first time the ledger is used
+var defaultChartOfAccounts = []struct {
+	Code, Name, Type, Key string
+}{
+	{"1000", "Cash on Hand", "asset", LedgerCash},
+	{"1010", "Bank", "asset", LedgerBank},
+	{"1100", "Accounts Receivable", "asset", LedgerAccountsReceivable},
+	{"1200", "Inventory", "asset", LedgerInventory},
+	{"2000", "Accounts Payable", "liability", LedgerAccountsPayable},
+	{"3000", "Owner's Equity", "equity", ""},
+	{"3100", "Retained Earnings", "equity", ""},
+	{"4000", "Sales Revenue", "revenue", LedgerSalesRevenue},
+	{"5000", "Cost of Goods Sold", "expense", LedgerCostOfGoodsSold},
+	{"5100", "Inventory Adjustments", "expense", LedgerInventoryAdjustment},
+	{"6000", "Operating Expenses", "expense", LedgerOperatingExpenses},
+}
+
+// LedgerMappingKeys lists every mapping key the automatic postings rely on
+func LedgerMappingKeys() []string {
+	keys := []string{}
+	for _, account := range defaultChartOfAccounts {
+		if account.Key != "" {
+			keys = append(keys, account.Key)
+		}
+	}
+	return keys
+}
+
+// seedChartOfAccounts creates the default accounts and mappings that are missing
+func seedChartOfAccounts(tx *gorm.DB) error {
+	for _, def := range defaultChartOfAccounts {
+		var account models.LedgerAccount
+		err := tx.Where("code = ?", def.Code).First(&account).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			account = models.LedgerAccount{Code: def.Code, Name: def.Name, Type: def.Type, IsSystem: true, IsActive: true}
+			if err := tx.Create(&account).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if def.Key == "" {
+			continue
+		}
+		var count int64
+		tx.Model(&models.LedgerAccountMapping{}).Where("`key` = ?", def.Key).Count(&count)
+		if count == 0 {
+			if err := tx.Create(&models.LedgerAccountMapping{Key: def.Key, AccountID: account.ID}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ledgerAccountID resolves a mapping key to an account, seeding the defaults on first use
+func ledgerAccountID(tx *gorm.DB, key string) (uint, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		var mapping models.LedgerAccountMapping
+		err := tx.Where("`key` = ?", key).First(&mapping).Error
+		if err == nil {
+			return mapping.AccountID, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		if err := seedChartOfAccounts(tx); err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("no ledger account is mapped to %s", key)
+}
+
+// journalBuilder collects debit/credit lines by mapping key
+type journalBuilder struct {
+	tx    *gorm.DB
+	lines []models.JournalLine
+	err   error
+}
+
+func newJournalBuilder(tx *gorm.DB) *journalBuilder {
+	return &journalBuilder{tx: tx}
+}
+
+// debit adds a debit line; a negative amount becomes a credit
+func (b *journalBuilder) debit(key string, amount float64, description string) {
+	b.add(key, amount, description)
+}
+
+// credit adds a credit line; a negative amount becomes a debit
+func (b *journalBuilder) credit(key string, amount float64, description string) {
+	b.add(key, -amount, description)
+}
+
+func (b *journalBuilder) add(key string, amount float64, description string) {
+	if b.err != nil || roundAmount(amount) == 0 {
+		return
+	}
+	accountID, err := ledgerAccountID(b.tx, key)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.addAccount(accountID, amount, description)
+}
+
+// addAccount adds a line for a specific account; positive amounts are debits
+func (b *journalBuilder) addAccount(accountID uint, amount float64, description string) {
+	amount = roundAmount(amount)
+	if b.err != nil || amount == 0 {
+		return
+	}
+	line := models.JournalLine{AccountID: accountID, Description: description}
+	if amount > 0 {
+		line.Debit = amount
+	} else {
+		line.Credit = -amount
+	}
+	b.lines = append(b.lines, line)
+}
+
+// checkPeriodOpen rejects postings dated inside a closed accounting period
+func checkPeriodOpen(tx *gorm.DB, date time.Time) error {
+	var period models.AccountingPeriod
+	err := tx.Where("status = ? AND start_date <= ? AND end_date >= ?", "closed", date.Format("2006-01-02"), date.Format("2006-01-02")).
+		First(&period).Error
+	if err == nil {
+		return fmt.Errorf("accounting period %s is closed; postings dated %s are not allowed", period.Name, date.Format("2006-01-02"))
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+// createJournal validates and stores a journal entry
+func createJournal(tx *gorm.DB, entry models.JournalEntry) (models.JournalEntry, error) {
+	if len(entry.Lines) == 0 {
+		return entry, errors.New("journal entry has no lines")
+	}
+
+	entry.TotalDebit, entry.TotalCredit = 0, 0
+	for _, line := range entry.Lines {
+		entry.TotalDebit += line.Debit
+		entry.TotalCredit += line.Credit
+	}
+	entry.TotalDebit = roundAmount(entry.TotalDebit)
+	entry.TotalCredit = roundAmount(entry.TotalCredit)
+	if entry.TotalDebit != entry.TotalCredit {
+		return entry, fmt.Errorf("journal entry is not balanced: debit %.2f, credit %.2f", entry.TotalDebit, entry.TotalCredit)
+	}
+	if err := checkPeriodOpen(tx, entry.EntryDate); err != nil {
+		return entry, err
+	}
+
+	// Entry numbers follow the ID, so create with a temporary number first
+	entry.EntryNumber = fmt.Sprintf("TMP-%d", time.Now().UnixNano())
+	if err := tx.Create(&entry).Error; err != nil {
+		return entry, err
+	}
+	entry.EntryNumber = fmt.Sprintf("JE-%06d", entry.ID)
+	if err := tx.Model(&models.JournalEntry{}).Where("id = ?", entry.ID).Update("entry_number", entry.EntryNumber).Error; err != nil {
+		return entry, err
+	}
+	return entry, nil
+}
+
+// replaceJournal makes the automatic entry of a document match the given lines.
+// An unchanged entry is left alone so documents in closed periods can still be touched
+// (e.g. to record their payment status) without a posting.
+func replaceJournal(tx *gorm.DB, sourceType string, sourceID uint, date time.Time, description string, createdBy uint, b *journalBuilder) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	var existing []models.JournalEntry
+	if err := tx.Preload("Lines").Where("source_type = ? AND source_id = ? AND is_automatic = ?", sourceType, sourceID, true).
+		Find(&existing).Error; err != nil {
+		return err
+	}
+	if len(existing) == 1 && sameJournal(existing[0], date, b.lines) {
+		return nil
+	}
+
+	if err := removeJournalEntries(tx, existing); err != nil {
+		return err
+	}
+	if len(b.lines) == 0 {
+		return nil
+	}
+
+	_, err := createJournal(tx, models.JournalEntry{
+		EntryDate:   date,
+		Description: description,
+		SourceType:  sourceType,
+		SourceID:    &sourceID,
+		IsAutomatic: true,
+		CreatedBy:   createdBy,
+		Lines:       b.lines,
+	})
+	return err
+}
+
+// removeJournal deletes the automatic entries of a document that no longer exists
+func removeJournal(tx *gorm.DB, sourceType string, sourceID uint) error {
+	var existing []models.JournalEntry
+	if err := tx.Where("source_type = ? AND source_id = ? AND is_automatic = ?", sourceType, sourceID, true).
+		Find(&existing).Error; err != nil {
+		return err
+	}
+	return removeJournalEntries(tx, existing)
+}
+
+func removeJournalEntries(tx *gorm.DB, entries []models.JournalEntry) error {
+	for _, entry := range entries {
+		if err := checkPeriodOpen(tx, entry.EntryDate); err != nil {
+			return err
+		}
+		if err := tx.Where("entry_id = ?", entry.ID).Delete(&models.JournalLine{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.JournalEntry{}, entry.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameJournal(entry models.JournalEntry, date time.Time, lines []models.JournalLine) bool {
+	if entry.EntryDate.Format("2006-01-02") != date.Format("2006-01-02") || len(entry.Lines) != len(lines) {
+		return false
+	}
+	signature := func(lines []models.JournalLine) string {
+		parts := make([]string, 0, len(lines))
+		for _, line := range lines {
+			parts = append(parts, fmt.Sprintf("%d:%.2f:%.2f", line.AccountID, line.Debit, line.Credit))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, "|")
+	}
+	return signature(entry.Lines) == signature(lines)
+}
+
+// postSalesInvoiceJournal posts Dr receivable / Cr revenue and Dr COGS / Cr inventory at cost
+func postSalesInvoiceJournal(tx *gorm.DB, invoiceID uint) error {
+	var invoice models.SalesInvoice
+	if err := tx.Preload("Items").Preload("Items.Product").First(&invoice, invoiceID).Error; err != nil {
+		return err
+	}
+
+	cost := 0.0
+	for _, item := range invoice.Items {
+		if item.Product != nil {
+			cost += item.Quantity * item.Product.CostPrice
+		}
+	}
+
+	description := "Sales invoice " + invoice.InvoiceNumber
+	b := newJournalBuilder(tx)
+	b.debit(LedgerAccountsReceivable, invoice.TotalAmount, description)
+	b.credit(LedgerSalesRevenue, invoice.TotalAmount, description)
+	b.debit(LedgerCostOfGoodsSold, cost, "Cost of goods sold "+invoice.InvoiceNumber)
+	b.credit(LedgerInventory, cost, "Cost of goods sold "+invoice.InvoiceNumber)
+	return replaceJournal(tx, "sales_invoice", invoice.ID, invoice.CreatedAt, description, invoice.CreatedBy, b)
+}
+
+// postPurchaseInvoiceJournal posts Dr inventory / Cr payable
+func postPurchaseInvoiceJournal(tx *gorm.DB, invoiceID uint) error {
+	var invoice models.PurchaseInvoice
+	if err := tx.First(&invoice, invoiceID).Error; err != nil {
+		return err
+	}
+
+	description := "Purchase invoice " + invoice.InvoiceNumber
+	b := newJournalBuilder(tx)
+	b.debit(LedgerInventory, invoice.TotalAmount, description)
+	b.credit(LedgerAccountsPayable, invoice.TotalAmount, description)
+	return replaceJournal(tx, "purchase_invoice", invoice.ID, invoice.InvoiceDate, description, invoice.CreatedBy, b)
+}
+
+// postPaymentJournal posts Dr cash/bank / Cr receivable for sales and Dr payable / Cr cash/bank for purchases
+func postPaymentJournal(tx *gorm.DB, payment *models.Payment) error {
+	moneyKey := LedgerBank
+	if payment.AccountID != nil {
+		var account models.MoneyAccount
+		if err := tx.First(&account, *payment.AccountID).Error; err == nil && account.Type == "cash_box" {
+			moneyKey = LedgerCash
+		}
+	} else if payment.PaymentMethod == "cash" {
+		moneyKey = LedgerCash
+	}
+
+	date := payment.CreatedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	description := fmt.Sprintf("Payment #%d (%s)", payment.ID, payment.PaymentMethod)
+	b := newJournalBuilder(tx)
+	if payment.InvoiceType == "purchase" {
+		b.debit(LedgerAccountsPayable, payment.Amount, description)
+		b.credit(moneyKey, payment.Amount, description)
+	} else {
+		b.debit(moneyKey, payment.Amount, description)
+		b.credit(LedgerAccountsReceivable, payment.Amount, description)
+	}
+	return replaceJournal(tx, "payment", payment.ID, date, description, payment.CreatedBy, b)
+}
+
+// postCreditNoteJournal posts Dr payable / Cr inventory for goods returned to a vendor
+func postCreditNoteJournal(tx *gorm.DB, creditNote models.CreditNote) error {
+	description := "Credit note " + creditNote.CreditNoteNumber
+	b := newJournalBuilder(tx)
+	b.debit(LedgerAccountsPayable, creditNote.TotalAmount, description)
+	b.credit(LedgerInventory, creditNote.TotalAmount, description)
+
+	var createdBy uint
+	if creditNote.CreatedBy != nil {
+		createdBy = *creditNote.CreatedBy
+	}
+	return replaceJournal(tx, "credit_note", creditNote.ID, creditNote.CreditNoteDate, description, createdBy, b)
+}
+
+// postStockAdjustmentJournal values a manual stock correction at cost price
+func postStockAdjustmentJournal(tx *gorm.DB, stock models.Stock, quantityChange float64) error {
+	var product models.Product
+	if err := tx.First(&product, stock.ProductID).Error; err != nil {
+		return err
+	}
+	value := roundAmount(quantityChange * product.CostPrice)
+	if value == 0 {
+		return nil
+	}
+
+	description := fmt.Sprintf("Stock adjustment %s (%+.2f) at %s #%d", product.SKU, quantityChange, stock.LocationType, stock.LocationID)
+	b := newJournalBuilder(tx)
+	b.debit(LedgerInventory, value, description)
+	b.credit(LedgerInventoryAdjustment, value, description)
+	if b.err != nil {
+		return b.err
+	}
+
+	// Each adjustment is its own entry rather than a replacement
+	_, err := createJournal(tx, models.JournalEntry{
+		EntryDate:   time.Now(),
+		Description: description,
+		SourceType:  "stock_adjustment",
+		SourceID:    &stock.ID,
+		IsAutomatic: true,
+		Lines:       b.lines,
+	})
+	return err
+}
+
+// postExpenseJournal posts Dr expense / Cr cash, bank or payable when the expense is unpaid
+func postExpenseJournal(tx *gorm.DB, expense models.Expense) error {
+	description := "Expense: " + expense.Description
+	b := newJournalBuilder(tx)
+
+	var category models.ExpenseCategory
+	if err := tx.First(&category, expense.CategoryID).Error; err == nil && category.LedgerAccountID != nil {
+		b.addAccount(*category.LedgerAccountID, expense.Amount, description)
+	} else {
+		b.debit(LedgerOperatingExpenses, expense.Amount, description)
+	}
+
+	creditKey := LedgerAccountsPayable
+	if expense.MoneyAccountID != nil {
+		creditKey = LedgerBank
+		var account models.MoneyAccount
+		if err := tx.First(&account, *expense.MoneyAccountID).Error; err == nil && account.Type == "cash_box" {
+			creditKey = LedgerCash
+		}
+	}
+	b.credit(creditKey, expense.Amount, description)
+
+	return replaceJournal(tx, "expense", expense.ID, expense.ExpenseDate, description, expense.CreatedBy, b)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// Live event types pushed on /api/events
+const (
+	LiveStockChanged    = "stock.changed"
+	LiveSaleCreated     = "sale.created"
+	LivePaymentCreated  = "payment.created"
+	LiveTransferCreated = "transfer.created"
+)
+
+// LiveEventResources maps each live event to the resource whose view permission is
+// needed to receive it
+var LiveEventResources = map[string]string{
+	LiveStockChanged:    "stock",
+	LiveSaleCreated:     "invoices",
+	LivePaymentCreated:  "payments",
+	LiveTransferCreated: "transfers",
+}
+
+// LiveEvent is a change pushed to the dashboards connected to /api/events. Users limited
+// to some locations only receive events that happened at one of LocationIDs.
+type LiveEvent struct {
+	Type        string      `json:"type"`
+	CompanyID   uint        `json:"-"`
+	LocationIDs []uint      `json:"location_ids"`
+	Data        interface{} `json:"data"`
+	At          time.Time   `json:"at"`
+}
+
+// liveSubscriberBuffer is how many events a slow stream may fall behind before it
+// misses some
+const liveSubscriberBuffer = 64
+
+// LiveEventHub hands events to the streams connected to this instance. It lives in
+// memory, so with several API instances behind a load balancer a stream only sees the
+// changes made through its own instance.
+type LiveEventHub struct {
+	mu          sync.RWMutex
+	subscribers map[chan LiveEvent]uint
+}
+
+var liveEvents = &LiveEventHub{subscribers: map[chan LiveEvent]uint{}}
+
+// LiveEvents returns the process wide hub
+func LiveEvents() *LiveEventHub {
+	return liveEvents
+}
+
+// Subscribe receives the company's events until the returned function is called
+func (h *LiveEventHub) Subscribe(companyID uint) (<-chan LiveEvent, func()) {
+	events := make(chan LiveEvent, liveSubscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[events] = companyID
+	h.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, events)
+			h.mu.Unlock()
+		})
+	}
+}
+
+// Publish never blocks; a subscriber whose buffer is full misses the event
+func (h *LiveEventHub) Publish(event LiveEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for events, companyID := range h.subscribers {
+		if companyID != event.CompanyID {
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+			log.Printf("live events: stream is behind, dropped %s", event.Type)
+		}
+	}
+}
+
+// publishLive publishes event once the change db is making has been committed
+func publishLive(db *gorm.DB, event LiveEvent) {
+	afterCommit(db, func() {
+		liveEvents.Publish(event)
+	})
+}
+
+// publishStockChange tells the dashboards about a new stock level; change is the
+// difference to the previous level
+func publishStockChange(db *gorm.DB, stock models.Stock, change float64) {
+	publishLive(db, LiveEvent{
+		Type:        LiveStockChanged,
+		CompanyID:   stock.CompanyID,
+		LocationIDs: []uint{stock.LocationID},
+		Data: map[string]interface{}{
+			"product_id":    stock.ProductID,
+			"location_type": stock.LocationType,
+			"location_id":   stock.LocationID,
+			"quantity":      stock.Quantity,
+			"change":        change,
+		},
+	})
+}
+
+// publishPayment tells the dashboards about a payment, at the locations of the invoices
+// it pays
+func publishPayment(db *gorm.DB, payment models.Payment, invoiceIDs []uint) {
+	var locations []uint
+	table := "sales_invoices"
+	if payment.InvoiceType == "purchase" {
+		table = "purchase_invoices"
+	}
+	if err := db.Table(table).Where("id IN ?", invoiceIDs).Distinct().Pluck("location_id", &locations).Error; err != nil {
+		log.Printf("live events: could not find the locations of payment #%d: %v", payment.ID, err)
+		return
+	}
+	publishLive(db, LiveEvent{
+		Type:        LivePaymentCreated,
+		CompanyID:   payment.CompanyID,
+		LocationIDs: locations,
+		Data: map[string]interface{}{
+			"id":             payment.ID,
+			"invoice_id":     payment.InvoiceID,
+			"invoice_type":   payment.InvoiceType,
+			"customer_id":    payment.CustomerID,
+			"vendor_id":      payment.VendorID,
+			"amount":         payment.Amount,
+			"payment_method": payment.PaymentMethod,
+			"created_at":     payment.CreatedAt,
+		},
+	})
+}
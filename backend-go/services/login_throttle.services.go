@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	throttleScopeAccount = "account"
+	throttleScopeIP      = "ip"
+)
+
+// LockedError is returned while an account or client is locked out after failed logins
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
+// RetryAfter is how long until the lockout ends
+func (e *LockedError) RetryAfter() time.Duration {
+	if wait := time.Until(e.Until); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// lockoutPolicy decides when a key is locked and for how long. The first lockout lasts
+// base and each failure after it doubles the time, up to max. Failures older than window
+// (counted from the last failure or the end of the lockout) are forgotten.
+type lockoutPolicy struct {
+	maxFailures int
+	base        time.Duration
+	max         time.Duration
+	window      time.Duration
+}
+
+func (p lockoutPolicy) duration(extraFailures int) time.Duration {
+	d := p.base
+	for i := 0; i < extraFailures && d < p.max; i++ {
+		d *= 2
+	}
+	if d > p.max {
+		return p.max
+	}
+	return d
+}
+
+// LOGIN_MAX_ATTEMPTS failures lock the account; a client gets LOGIN_IP_MAX_ATTEMPTS, as
+// one office often shares an address
+func loginLockoutPolicy(scope string) lockoutPolicy {
+	policy := lockoutPolicy{
+		maxFailures: intFromEnv("LOGIN_MAX_ATTEMPTS", 5),
+		base:        durationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		max:         durationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+		window:      durationFromEnv("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	}
+	if scope == throttleScopeIP {
+		policy.maxFailures = intFromEnv("LOGIN_IP_MAX_ATTEMPTS", 20)
+	}
+	return policy
+}
+
+type LoginThrottleService struct {
+	DB *gorm.DB
+}
+
+func NewLoginThrottleService(db *gorm.DB) *LoginThrottleService {
+	return &LoginThrottleService{
+		DB: db,
+	}
+}
+
+// Check returns a *LockedError if the account or the client is locked out
+func (s *LoginThrottleService) Check(email, ipAddress string) error {
+	var throttles []models.LoginThrottle
+	if err := s.DB.Where("((scope = ? AND identifier = ?) OR (scope = ? AND identifier = ?)) AND locked_until > ?",
+		throttleScopeAccount, normalizeLoginEmail(email), throttleScopeIP, ipAddress, time.Now()).
+		Find(&throttles).Error; err != nil {
+		return err
+	}
+
+	var locked *LockedError
+	for _, throttle := range throttles {
+		if locked == nil || throttle.LockedUntil.After(locked.Until) {
+			locked = &LockedError{Until: *throttle.LockedUntil}
+		}
+	}
+	if locked != nil {
+		return locked
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login against the account and the client
+func (s *LoginThrottleService) RecordFailure(email, ipAddress string) error {
+	now := time.Now()
+	if err := s.recordFailure(throttleScopeAccount, normalizeLoginEmail(email), now); err != nil {
+		return err
+	}
+	return s.recordFailure(throttleScopeIP, ipAddress, now)
+}
+
+func (s *LoginThrottleService) recordFailure(scope, identifier string, now time.Time) error {
+	if identifier == "" {
+		return nil
+	}
+	policy := loginLockoutPolicy(scope)
+
+	var throttle models.LoginThrottle
+	err := s.DB.Where("scope = ? AND identifier = ?", scope, identifier).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		throttle = models.LoginThrottle{Scope: scope, Identifier: identifier}
+	} else if err != nil {
+		return err
+	}
+
+	lastActivity := throttle.LastFailedAt
+	if throttle.LockedUntil != nil && throttle.LockedUntil.After(lastActivity) {
+		lastActivity = *throttle.LockedUntil
+	}
+	if now.Sub(lastActivity) > policy.window {
+		throttle.Failures = 0
+		throttle.LockedUntil = nil
+	}
+
+	throttle.Failures++
+	throttle.LastFailedAt = now
+	if throttle.Failures >= policy.maxFailures {
+		until := now.Add(policy.duration(throttle.Failures - policy.maxFailures))
+		throttle.LockedUntil = &until
+	}
+	return s.DB.Save(&throttle).Error
+}
+
+// ClearAccount forgets the account's failed logins, after a successful login or a
+// password reset. The client's count is left alone so one valid account can't be used
+// to keep guessing others from the same address.
+func (s *LoginThrottleService) ClearAccount(email string) error {
+	return s.DB.Where("scope = ? AND identifier = ?", throttleScopeAccount, normalizeLoginEmail(email)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// AuthRateLimit is how many requests a minute one client may send to the public auth
+// endpoints (AUTH_RATE_LIMIT), on top of the failed login lockout
+func AuthRateLimit() int {
+	return intFromEnv("AUTH_RATE_LIMIT", 10)
+}
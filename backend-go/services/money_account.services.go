@@ -0,0 +1,442 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MoneyAccountService struct {
+	db *gorm.DB
+}
+
+func NewMoneyAccountService(db *gorm.DB) *MoneyAccountService {
+	return &MoneyAccountService{
+		db: db,
+	}
+}
+
+// GetALL lists money accounts, optionally filtered by type and location
+func (s *MoneyAccountService) GetALL(accountType, locationID string) ([]models.MoneyAccount, error) {
+	var accounts []models.MoneyAccount
+
+	query := s.db.Model(&models.MoneyAccount{}).
+		Preload("Location").
+		Preload("Van").
+		Where("deleted_at IS NULL")
+
+	if accountType != "" {
+		query = query.Where("type = ?", accountType)
+	}
+	if locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+
+	if err := query.Order("type ASC, name ASC").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (s *MoneyAccountService) GetID(id string) (models.MoneyAccount, error) {
+	var account models.MoneyAccount
+	if err := s.db.Preload("Location").Preload("Van").
+		Where("deleted_at IS NULL").
+		First(&account, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return account, errors.New("money account not found")
+		}
+		return account, err
+	}
+	return account, nil
+}
+
+// Create creates a cash box or bank account and records its opening balance
+func (s *MoneyAccountService) Create(account models.MoneyAccount, createdBy uint) (models.MoneyAccount, error) {
+	if err := s.validate(account); err != nil {
+		return account, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if account.IsDefault {
+			if err := tx.Model(&models.MoneyAccount{}).Where("type = ?", account.Type).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+
+		openingBalance := account.OpeningBalance
+		account.Balance = 0
+		if err := tx.Create(&account).Error; err != nil {
+			return err
+		}
+
+		if openingBalance != 0 {
+			if _, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+				AccountID:       account.ID,
+				Type:            "opening",
+				Amount:          openingBalance,
+				TransactionDate: time.Now(),
+				Description:     "Opening balance",
+				CreatedBy:       createdBy,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return account, err
+	}
+	return s.GetID(strconv.Itoa(int(account.ID)))
+}
+
+// Update updates account details. The balance is only changed through transactions.
+func (s *MoneyAccountService) Update(account models.MoneyAccount) (models.MoneyAccount, error) {
+	if err := s.validate(account); err != nil {
+		return account, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if account.IsDefault {
+			if err := tx.Model(&models.MoneyAccount{}).Where("type = ? AND id != ?", account.Type, account.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&account).Select(
+			"Name", "LocationID", "VanID", "BankName", "AccountNumber", "IBAN",
+			"Currency", "IsDefault", "IsActive",
+		).Updates(account).Error
+	})
+	if err != nil {
+		return account, err
+	}
+	return s.GetID(strconv.Itoa(int(account.ID)))
+}
+
+// Delete soft deletes an account that holds no money
+func (s *MoneyAccountService) Delete(account models.MoneyAccount) error {
+	if roundAmount(account.Balance) != 0 {
+		return errors.New("cannot delete an account with a non-zero balance")
+	}
+	now := time.Now()
+	return s.db.Model(&account).Update("deleted_at", &now).Error
+}
+
+// GetTransactions returns the account ledger, newest first
+func (s *MoneyAccountService) GetTransactions(accountID, fromDate, toDate, reconciled string, limit int) ([]models.MoneyAccountTransaction, error) {
+	var transactions []models.MoneyAccountTransaction
+
+	query := s.db.Model(&models.MoneyAccountTransaction{}).
+		Preload("Payment").
+		Preload("CreatedByUser").
+		Where("account_id = ?", accountID)
+
+	if fromDate != "" && toDate != "" {
+		query = query.Where("DATE(transaction_date) BETWEEN ? AND ?", fromDate, toDate)
+	}
+	if reconciled != "" {
+		query = query.Where("is_reconciled = ?", reconciled == "true")
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	if err := query.Order("transaction_date DESC, id DESC").Limit(limit).Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Transfer moves money from one account to another
+func (s *MoneyAccountService) Transfer(transfer models.MoneyTransfer) (models.MoneyTransfer, error) {
+	if transfer.Amount <= 0 {
+		return transfer, errors.New("transfer amount must be greater than zero")
+	}
+	if transfer.FromAccountID == transfer.ToAccountID {
+		return transfer, errors.New("cannot transfer to the same account")
+	}
+	if transfer.TransferDate.IsZero() {
+		transfer.TransferDate = time.Now()
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var from, to models.MoneyAccount
+		// Lock the source so concurrent withdrawals can't both pass the balance check
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("deleted_at IS NULL").First(&from, transfer.FromAccountID).Error; err != nil {
+			return errors.New("source account not found")
+		}
+		if err := tx.Where("deleted_at IS NULL").First(&to, transfer.ToAccountID).Error; err != nil {
+			return errors.New("destination account not found")
+		}
+		if from.Type == "cash_box" && from.Balance < transfer.Amount {
+			return fmt.Errorf("insufficient cash in %s: available %.2f, required %.2f", from.Name, from.Balance, transfer.Amount)
+		}
+
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+
+		if _, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+			AccountID:       from.ID,
+			Type:            "transfer_out",
+			Amount:          -transfer.Amount,
+			TransactionDate: transfer.TransferDate,
+			TransferID:      &transfer.ID,
+			ReferenceNumber: transfer.ReferenceNumber,
+			Description:     "Transfer to " + to.Name,
+			CreatedBy:       transfer.CreatedBy,
+		}); err != nil {
+			return err
+		}
+		_, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+			AccountID:       to.ID,
+			Type:            "transfer_in",
+			Amount:          transfer.Amount,
+			TransactionDate: transfer.TransferDate,
+			TransferID:      &transfer.ID,
+			ReferenceNumber: transfer.ReferenceNumber,
+			Description:     "Transfer from " + from.Name,
+			CreatedBy:       transfer.CreatedBy,
+		})
+		return err
+	})
+	if err != nil {
+		return transfer, err
+	}
+
+	var created models.MoneyTransfer
+	if err := s.db.Preload("FromAccount").Preload("ToAccount").First(&created, transfer.ID).Error; err != nil {
+		return transfer, err
+	}
+	return created, nil
+}
+
+// GetTransfers lists transfers touching an account (or all transfers when accountID is empty)
+func (s *MoneyAccountService) GetTransfers(accountID string) ([]models.MoneyTransfer, error) {
+	var transfers []models.MoneyTransfer
+
+	query := s.db.Model(&models.MoneyTransfer{}).
+		Preload("FromAccount").
+		Preload("ToAccount").
+		Preload("CreatedByUser")
+	if accountID != "" {
+		query = query.Where("from_account_id = ? OR to_account_id = ?", accountID, accountID)
+	}
+
+	if err := query.Order("transfer_date DESC").Limit(200).Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// Withdraw takes money out of an account to pay for an expense
+func (s *MoneyAccountService) Withdraw(accountID uint, amount float64, date time.Time, description string, referenceNumber *string, createdBy uint) (models.MoneyAccountTransaction, error) {
+	var transaction models.MoneyAccountTransaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		transaction, err = withdrawFromAccount(tx, accountID, amount, date, description, referenceNumber, createdBy)
+		return err
+	})
+	return transaction, err
+}
+
+func (s *MoneyAccountService) validate(account models.MoneyAccount) error {
+	if account.Name == "" {
+		return errors.New("account name is required")
+	}
+
+	switch account.Type {
+	case "cash_box":
+		if account.LocationID == nil {
+			return errors.New("a cash box must belong to a location")
+		}
+		// One cash box per location
+		var count int64
+		s.db.Model(&models.MoneyAccount{}).
+			Where("type = ? AND location_id = ? AND id != ? AND deleted_at IS NULL", "cash_box", *account.LocationID, account.ID).
+			Count(&count)
+		if count > 0 {
+			return errors.New("this location already has a cash box")
+		}
+	case "bank":
+	default:
+		return errors.New("account type must be 'cash_box' or 'bank'")
+	}
+	return nil
+}
+
+// withdrawFromAccount posts an expense withdrawal inside an existing transaction
+func withdrawFromAccount(tx *gorm.DB, accountID uint, amount float64, date time.Time, description string, referenceNumber *string, createdBy uint) (models.MoneyAccountTransaction, error) {
+	if amount <= 0 {
+		return models.MoneyAccountTransaction{}, errors.New("withdrawal amount must be greater than zero")
+	}
+
+	var account models.MoneyAccount
+	// Locked until the withdrawal commits, so the balance check holds
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("deleted_at IS NULL").First(&account, accountID).Error; err != nil {
+		return models.MoneyAccountTransaction{}, errors.New("money account not found")
+	}
+	if account.Type == "cash_box" && account.Balance < amount {
+		return models.MoneyAccountTransaction{}, fmt.Errorf("insufficient cash in %s: available %.2f, required %.2f", account.Name, account.Balance, amount)
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	return postAccountTransaction(tx, models.MoneyAccountTransaction{
+		AccountID:       account.ID,
+		Type:            "expense",
+		Amount:          -amount,
+		TransactionDate: date,
+		ReferenceNumber: referenceNumber,
+		Description:     description,
+		CreatedBy:       createdBy,
+	})
+}
+
+// postAccountTransaction records a transaction and applies it to the account balance
+func postAccountTransaction(tx *gorm.DB, transaction models.MoneyAccountTransaction) (models.MoneyAccountTransaction, error) {
+	transaction.Amount = roundAmount(transaction.Amount)
+	if err := tx.Create(&transaction).Error; err != nil {
+		return transaction, err
+	}
+	if err := tx.Model(&models.MoneyAccount{}).Where("id = ?", transaction.AccountID).
+		Update("balance", gorm.Expr("balance + ?", transaction.Amount)).Error; err != nil {
+		return transaction, err
+	}
+	return transaction, nil
+}
+
+// postPaymentToAccount lands a payment in a cash box or bank account.
+// When the payment has no account, cash goes to the invoice location's cash box and
+// other methods go to the default bank account (if one is configured).
+func postPaymentToAccount(tx *gorm.DB, payment *models.Payment) error {
+	if payment.AccountID == nil {
+		accountID, err := resolvePaymentAccount(tx, payment)
+		if err != nil {
+			return err
+		}
+		if accountID == nil {
+			return nil
+		}
+		payment.AccountID = accountID
+		if err := tx.Model(payment).Update("account_id", *accountID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Money comes in for sales and goes out for purchases; refunds flip the sign
+	amount := payment.Amount
+	transactionType := "payment_in"
+	if payment.InvoiceType == "purchase" {
+		amount = -amount
+	}
+	if amount < 0 {
+		transactionType = "payment_out"
+	}
+
+	date := payment.CreatedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	_, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+		AccountID:       *payment.AccountID,
+		Type:            transactionType,
+		Amount:          amount,
+		TransactionDate: date,
+		PaymentID:       &payment.ID,
+		ReferenceNumber: payment.ReferenceNumber,
+		Description:     fmt.Sprintf("Payment #%d (%s invoice #%d)", payment.ID, payment.InvoiceType, payment.InvoiceID),
+		CreatedBy:       payment.CreatedBy,
+	})
+	return err
+}
+
+// reversePaymentPostings cancels the account transactions of a payment that is being deleted
+func reversePaymentPostings(tx *gorm.DB, paymentID uint) error {
+	var postings []models.MoneyAccountTransaction
+	if err := tx.Where("payment_id = ?", paymentID).Find(&postings).Error; err != nil {
+		return err
+	}
+	for _, posting := range postings {
+		if _, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+			AccountID:       posting.AccountID,
+			Type:            "adjustment",
+			Amount:          -posting.Amount,
+			TransactionDate: time.Now(),
+			ReferenceNumber: posting.ReferenceNumber,
+			Description:     fmt.Sprintf("Reversal of payment #%d", paymentID),
+			CreatedBy:       posting.CreatedBy,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePaymentAccount picks the default account for a payment without an explicit account
+func resolvePaymentAccount(tx *gorm.DB, payment *models.Payment) (*uint, error) {
+	if payment.PaymentMethod == "cash" {
+		var locationID uint
+		if payment.InvoiceType == "purchase" {
+			tx.Model(&models.PurchaseInvoice{}).Select("location_id").Where("id = ?", payment.InvoiceID).Scan(&locationID)
+		} else {
+			tx.Model(&models.SalesInvoice{}).Select("location_id").Where("id = ?", payment.InvoiceID).Scan(&locationID)
+		}
+		if locationID == 0 {
+			return nil, nil
+		}
+		account, err := ensureCashBox(tx, locationID)
+		if err != nil {
+			return nil, err
+		}
+		return &account.ID, nil
+	}
+
+	var account models.MoneyAccount
+	err := tx.Where("type = ? AND is_default = ? AND is_active = ? AND deleted_at IS NULL", "bank", true, true).
+		First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &account.ID, nil
+}
+
+// ensureCashBox returns the cash box of a location, creating it on first use
+func ensureCashBox(tx *gorm.DB, locationID uint) (models.MoneyAccount, error) {
+	var account models.MoneyAccount
+	err := tx.Where("type = ? AND location_id = ? AND deleted_at IS NULL", "cash_box", locationID).First(&account).Error
+	if err == nil {
+		return account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return account, err
+	}
+
+	var location models.Location
+	if err := tx.First(&location, locationID).Error; err != nil {
+		return account, fmt.Errorf("location %d not found", locationID)
+	}
+	account = models.MoneyAccount{
+		Name:       "Cash - " + location.Name,
+		Type:       "cash_box",
+		LocationID: &location.ID,
+		VanID:      location.VanID,
+		IsActive:   true,
+	}
+	if err := tx.Create(&account).Error; err != nil {
+		return account, err
+	}
+	return account, nil
+}
@@ -0,0 +1,441 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// Notification events
+const (
+	EventStockLow            = "stock.low"
+	EventCreditNotePending   = "credit_note.pending"
+	EventCreditLimitExceeded = "customer.credit_limit_exceeded"
+	EventApprovalRequested   = "approval.requested"
+	EventApprovalDecided     = "approval.decided"
+)
+
+// Delivery channels. in_app is the inbox and always available; the others have to be
+// registered with RegisterNotificationChannel.
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// NotificationEvent describes an event users can be notified of. Channels missing from
+// Defaults stay off until the user turns them on.
+type NotificationEvent struct {
+	Description string
+	Defaults    []string
+}
+
+var NotificationEvents = map[string]NotificationEvent{
+	EventStockLow:            {"A product's stock at a location fell to its minimum level", []string{ChannelInApp}},
+	EventCreditNotePending:   {"A credit note is waiting to be approved", []string{ChannelInApp}},
+	EventCreditLimitExceeded: {"A customer's unpaid balance went over their credit limit", []string{ChannelInApp}},
+	EventApprovalRequested:   {"An operation is waiting for your approval", []string{ChannelInApp, ChannelEmail}},
+	EventApprovalDecided:     {"Your approval request was decided or run", []string{ChannelInApp, ChannelEmail}},
+}
+
+// NotificationChannel delivers a notification outside the app. preference.Target is the
+// user's address on the channel when it isn't their account, e.g. a webhook URL.
+type NotificationChannel interface {
+	Deliver(recipient models.User, notification models.Notification, preference models.NotificationPreference) error
+}
+
+var (
+	notificationChannelsMu sync.RWMutex
+	notificationChannels   = map[string]NotificationChannel{}
+)
+
+// RegisterNotificationChannel makes a delivery channel available to every user
+func RegisterNotificationChannel(name string, channel NotificationChannel) {
+	notificationChannelsMu.Lock()
+	defer notificationChannelsMu.Unlock()
+	notificationChannels[name] = channel
+}
+
+// NotificationChannels lists the available channels, in_app first
+func NotificationChannels() []string {
+	notificationChannelsMu.RLock()
+	defer notificationChannelsMu.RUnlock()
+	names := make([]string, 0, len(notificationChannels))
+	for name := range notificationChannels {
+		if name != ChannelInApp {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{ChannelInApp}, names...)
+}
+
+func notificationChannel(name string) (NotificationChannel, bool) {
+	notificationChannelsMu.RLock()
+	defer notificationChannelsMu.RUnlock()
+	channel, ok := notificationChannels[name]
+	return channel, ok
+}
+
+// EmailChannel mails notifications to the user's address. With NOTIFICATION_APP_URL set
+// the message links to the page the notification is about.
+type EmailChannel struct {
+	Notifier Notifier
+}
+
+func (ch EmailChannel) Deliver(recipient models.User, notification models.Notification, preference models.NotificationPreference) error {
+	if recipient.Email == "" {
+		return errors.New("user has no email address")
+	}
+	body := notification.Body
+	if appURL := os.Getenv("NOTIFICATION_APP_URL"); appURL != "" && notification.Link != "" {
+		body += "\n\n" + strings.TrimRight(appURL, "/") + notification.Link
+	}
+	return ch.Notifier.Send(Message{To: recipient.Email, Subject: notification.Title, Body: body})
+}
+
+// WebhookChannel posts notifications as JSON to the URL the user set as target, signed
+// like webhook deliveries (X-Webhook-Timestamp and X-Webhook-Signature) with the secret
+// of the preference. Only public addresses are reached.
+type WebhookChannel struct {
+	Client *http.Client
+}
+
+func NewWebhookChannel() WebhookChannel {
+	return WebhookChannel{Client: newOutboundClient(10 * time.Second)}
+}
+
+func (ch WebhookChannel) Deliver(recipient models.User, notification models.Notification, preference models.NotificationPreference) error {
+	if preference.Target == "" {
+		return errors.New("no webhook URL set")
+	}
+	if preference.Secret == "" {
+		return errors.New("webhook has no signing secret, save its target again")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":      notification.Event,
+		"title":      notification.Title,
+		"body":       notification.Body,
+		"link":       notification.Link,
+		"data":       notification.Data,
+		"user_id":    recipient.ID,
+		"created_at": notification.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	request, err := http.NewRequest(http.MethodPost, preference.Target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "invoicing-system-webhooks")
+	request.Header.Set("X-Webhook-Event", notification.Event)
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", SignWebhook(preference.Secret, timestamp, payload))
+
+	response, err := ch.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
+
+// Notify puts the notification in the inbox of every recipient who has in_app enabled
+// for the event and hands it to their other enabled channels in the background, once db's
+// transaction has committed. Failures are only logged: a notification never fails the
+// change it is about.
+func Notify(db *gorm.DB, recipients []models.User, notification models.Notification) {
+	if len(recipients) == 0 {
+		return
+	}
+	unique := make([]models.User, 0, len(recipients))
+	seen := map[uint]bool{}
+	ids := make([]uint, 0, len(recipients))
+	for _, recipient := range recipients {
+		if !seen[recipient.ID] {
+			seen[recipient.ID] = true
+			unique = append(unique, recipient)
+			ids = append(ids, recipient.ID)
+		}
+	}
+
+	var preferences []models.NotificationPreference
+	if err := db.Where("user_id IN ? AND event = ?", ids, notification.Event).Find(&preferences).Error; err != nil {
+		log.Printf("notification: could not load preferences for %s, using defaults: %v", notification.Event, err)
+	}
+	chosen := map[uint]map[string]models.NotificationPreference{}
+	for _, preference := range preferences {
+		if chosen[preference.UserID] == nil {
+			chosen[preference.UserID] = map[string]models.NotificationPreference{}
+		}
+		chosen[preference.UserID][preference.Channel] = preference
+	}
+
+	if notification.CreatedAt.IsZero() {
+		notification.CreatedAt = time.Now()
+	}
+	for _, recipient := range unique {
+		entry := notification
+		entry.UserID = recipient.ID
+		for _, name := range NotificationChannels() {
+			preference, enabled := effectivePreference(chosen[recipient.ID], notification.Event, name)
+			if !enabled {
+				continue
+			}
+			if name == ChannelInApp {
+				if err := db.Create(&entry).Error; err != nil {
+					log.Printf("notification: could not store %s for user %d: %v", notification.Event, recipient.ID, err)
+				}
+				continue
+			}
+			channel, ok := notificationChannel(name)
+			if !ok {
+				continue
+			}
+			name, recipient := name, recipient
+			afterCommit(db, func() {
+				go func() {
+					if err := channel.Deliver(recipient, entry, preference); err != nil {
+						log.Printf("notification: %s delivery of %s to user %d failed: %v", name, entry.Event, recipient.ID, err)
+					}
+				}()
+			})
+		}
+	}
+}
+
+// effectivePreference returns the user's preference for the channel, or the event default
+func effectivePreference(preferences map[string]models.NotificationPreference, event, channel string) (models.NotificationPreference, bool) {
+	if preference, ok := preferences[channel]; ok {
+		return preference, preference.Enabled
+	}
+	for _, name := range NotificationEvents[event].Defaults {
+		if name == channel {
+			return models.NotificationPreference{Event: event, Channel: channel, Enabled: true}, true
+		}
+	}
+	return models.NotificationPreference{Event: event, Channel: channel}, false
+}
+
+// UsersWithPermission returns the active users holding resource:action through one of
+// their roles, and the admins
+func UsersWithPermission(db *gorm.DB, resource, action string) ([]models.User, error) {
+	holders := db.Table("user_roles").Select("user_roles.user_id").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("permissions.resource = ? AND permissions.action = ?", resource, action)
+	var users []models.User
+	if err := db.Where("status = ? AND (role = ? OR id IN (?))", "ACTIVE", "ADMIN", holders).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// notifyHolders notifies everyone holding resource:action
+func notifyHolders(db *gorm.DB, resource, action string, notification models.Notification) {
+	recipients, err := UsersWithPermission(db, resource, action)
+	if err != nil {
+		log.Printf("notification: could not look up recipients of %s: %v", notification.Event, err)
+		return
+	}
+	Notify(db, recipients, notification)
+}
+
+// NotificationSetting is one event and channel in a user's preferences. A webhook gets a
+// new signing secret when its target changes or RotateSecret is set; Secret holds it only
+// in the response to that update.
+type NotificationSetting struct {
+	Event        string `json:"event"`
+	Description  string `json:"description"`
+	Channel      string `json:"channel"`
+	Enabled      bool   `json:"enabled"`
+	Target       string `json:"target"`
+	RotateSecret bool   `json:"rotate_secret,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+}
+
+// NotificationService serves the current user's inbox and preferences
+type NotificationService struct {
+	DB *gorm.DB
+}
+
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{
+		DB: db,
+	}
+}
+
+func (s *NotificationService) GetAll(userID uint, unreadOnly bool, limit, page int) (PaginationResponse, error) {
+	var notifications []models.Notification
+	var total int64
+
+	query := s.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	return PaginationResponse{
+		Data:        notifications,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
+
+func (s *NotificationService) UnreadCount(userID uint) (int64, error) {
+	var count int64
+	err := s.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+func (s *NotificationService) MarkRead(userID, id uint) (models.Notification, error) {
+	var notification models.Notification
+	if err := s.DB.Where("user_id = ?", userID).First(&notification, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Notification{}, errors.New("notification not found")
+		}
+		return models.Notification{}, err
+	}
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := s.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			return models.Notification{}, err
+		}
+		notification.ReadAt = &now
+	}
+	return notification, nil
+}
+
+// MarkAllRead marks the whole inbox read and returns how many notifications were unread
+func (s *NotificationService) MarkAllRead(userID uint) (int64, error) {
+	result := s.DB.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// GetPreferences lists every event and channel with what currently applies to the user
+func (s *NotificationService) GetPreferences(userID uint) ([]NotificationSetting, error) {
+	var preferences []models.NotificationPreference
+	if err := s.DB.Where("user_id = ?", userID).Find(&preferences).Error; err != nil {
+		return nil, err
+	}
+	chosen := map[string]map[string]models.NotificationPreference{}
+	for _, preference := range preferences {
+		if chosen[preference.Event] == nil {
+			chosen[preference.Event] = map[string]models.NotificationPreference{}
+		}
+		chosen[preference.Event][preference.Channel] = preference
+	}
+
+	events := make([]string, 0, len(NotificationEvents))
+	for event := range NotificationEvents {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+
+	var settings []NotificationSetting
+	for _, event := range events {
+		for _, channel := range NotificationChannels() {
+			preference, enabled := effectivePreference(chosen[event], event, channel)
+			settings = append(settings, NotificationSetting{
+				Event:       event,
+				Description: NotificationEvents[event].Description,
+				Channel:     channel,
+				Enabled:     enabled,
+				Target:      preference.Target,
+			})
+		}
+	}
+	return settings, nil
+}
+
+// UpdatePreferences saves the given settings; events and channels left out keep theirs
+func (s *NotificationService) UpdatePreferences(userID uint, settings []NotificationSetting) ([]NotificationSetting, error) {
+	channels := map[string]bool{}
+	for _, channel := range NotificationChannels() {
+		channels[channel] = true
+	}
+	for _, setting := range settings {
+		if _, ok := NotificationEvents[setting.Event]; !ok {
+			return nil, fmt.Errorf("unknown event %q", setting.Event)
+		}
+		if !channels[setting.Channel] {
+			return nil, fmt.Errorf("unknown channel %q", setting.Channel)
+		}
+		if setting.Channel == ChannelWebhook && setting.Enabled {
+			if err := validateOutboundURL(setting.Target); err != nil {
+				return nil, fmt.Errorf("%s: webhook target: %v", setting.Event, err)
+			}
+		}
+	}
+
+	secrets := map[string]string{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, setting := range settings {
+			var preference models.NotificationPreference
+			if err := tx.Where("user_id = ? AND event = ? AND channel = ?", userID, setting.Event, setting.Channel).
+				Limit(1).Find(&preference).Error; err != nil {
+				return err
+			}
+			preference.UserID = userID
+			preference.Event = setting.Event
+			preference.Channel = setting.Channel
+			preference.Enabled = setting.Enabled
+			if setting.Channel == ChannelWebhook && setting.Target != "" &&
+				(preference.Secret == "" || preference.Target != setting.Target || setting.RotateSecret) {
+				secret, err := newWebhookSecret()
+				if err != nil {
+					return err
+				}
+				preference.Secret = secret
+				secrets[setting.Event] = secret
+			}
+			preference.Target = setting.Target
+			if err := tx.Save(&preference).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range updated {
+		if updated[i].Channel == ChannelWebhook {
+			updated[i].Secret = secrets[updated[i].Event]
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is something to tell a user outside the app, e.g. a password reset link
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users (email, SMS, ...). Plug a real one in where the
+// services are built; LogNotifier is the development default.
+type Notifier interface {
+	Send(message Message) error
+}
+
+// LogNotifier writes messages to the server log instead of delivering them. Reset links
+// end up in the log, so don't run it in production.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(message Message) error {
+	log.Printf("notifier: to=%s subject=%q\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// SMTPNotifier sends messages as plain text email. Without a username it sends without
+// authentication, which is what local stand-ins such as Mailpit or MailHog expect.
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (n SMTPNotifier) Send(message Message) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	var email strings.Builder
+	fmt.Fprintf(&email, "From: %s\r\n", n.From)
+	fmt.Fprintf(&email, "To: %s\r\n", message.To)
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&email, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	email.WriteString("MIME-Version: 1.0\r\n")
+	email.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	email.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(net.JoinHostPort(n.Host, n.Port), auth, n.From, []string{message.To}, []byte(email.String()))
+}
+
+// NotifierFromEnv returns an SMTPNotifier when SMTP_HOST is set and LogNotifier otherwise
+func NotifierFromEnv() Notifier {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return LogNotifier{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@" + host
+	}
+	return SMTPNotifier{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+	}
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"bufio"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+)
+
+// smtpMessage is what the stub server received for one mail
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// startSMTPStub runs a minimal SMTP server on a local port: enough of the protocol for
+// net/smtp, no TLS and no authentication. Received mails come out of the channel.
+func startSMTPStub(t *testing.T) (host, port string, received <-chan smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan smtpMessage, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	host, port, _ = net.SplitHostPort(listener.Addr().String())
+	return host, port, messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- smtpMessage) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	var message smtpMessage
+	reply("220 stub ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		switch strings.ToUpper(strings.SplitN(command, " ", 2)[0]) {
+		case "EHLO", "HELO":
+			reply("250 stub")
+		case "MAIL":
+			message.From = strings.Trim(strings.TrimPrefix(command[len("MAIL FROM:"):], " "), "<>")
+			reply("250 OK")
+		case "RCPT":
+			message.To = append(message.To, strings.Trim(strings.TrimPrefix(command[len("RCPT TO:"):], " "), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			message.Data = data.String()
+			reply("250 OK")
+			messages <- message
+			message = smtpMessage{}
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func receive(t *testing.T, received <-chan smtpMessage) smtpMessage {
+	t.Helper()
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail arrived at the SMTP stub")
+		return smtpMessage{}
+	}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	host, port, received := startSMTPStub(t)
+	notifier := SMTPNotifier{Host: host, Port: port, From: "billing@example.com"}
+
+	err := notifier.Send(Message{
+		To:      "sara@example.com",
+		Subject: "Réinitialisation du mot de passe",
+		Body:    "Hello Sara,\nopen the link to reset your password.",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	message := receive(t, received)
+	if message.From != "billing@example.com" {
+		t.Errorf("envelope sender = %q", message.From)
+	}
+	if len(message.To) != 1 || message.To[0] != "sara@example.com" {
+		t.Errorf("envelope recipients = %v", message.To)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(message.Data))
+	if err != nil {
+		t.Fatalf("parse mail: %v", err)
+	}
+	headers := map[string]string{
+		"From":         "billing@example.com",
+		"To":           "sara@example.com",
+		"MIME-Version": "1.0",
+		"Content-Type": "text/plain; charset=utf-8",
+	}
+	for name, want := range headers {
+		if got := parsed.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	// Non-ASCII subjects are sent as encoded words
+	subject := parsed.Header.Get("Subject")
+	if !strings.HasPrefix(subject, "=?utf-8?q?") {
+		t.Errorf("subject %q isn't Q-encoded", subject)
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err != nil || decoded != "Réinitialisation du mot de passe" {
+		t.Errorf("subject decodes to %q, err %v", decoded, err)
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("date header: %v", err)
+	}
+
+	var body strings.Builder
+	bufio.NewReader(parsed.Body).WriteTo(&body)
+	if want := "Hello Sara,\r\nopen the link to reset your password.\r\n"; body.String() != want {
+		t.Errorf("body = %q, want %q", body.String(), want)
+	}
+}
+
+func TestEmailChannelDeliver(t *testing.T) {
+	host, port, received := startSMTPStub(t)
+	t.Setenv("NOTIFICATION_APP_URL", "https://app.example.com/")
+	channel := EmailChannel{Notifier: SMTPNotifier{Host: host, Port: port, From: "alerts@example.com"}}
+
+	err := channel.Deliver(models.User{ID: 4, Email: "omar@example.com"}, models.Notification{
+		Event: EventStockLow,
+		Title: "Low stock: Mint tea",
+		Body:  "Mint tea (TEA-01) is down to 3.00 at warehouse #2; the minimum is 10.",
+		Link:  "/stock/location/2",
+	}, models.NotificationPreference{Channel: ChannelEmail, Enabled: true})
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	message := receive(t, received)
+	parsed, err := mail.ReadMessage(strings.NewReader(message.Data))
+	if err != nil {
+		t.Fatalf("parse mail: %v", err)
+	}
+	if got := parsed.Header.Get("To"); got != "omar@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	if got := parsed.Header.Get("Subject"); got != "Low stock: Mint tea" {
+		t.Errorf("Subject = %q", got)
+	}
+	var body strings.Builder
+	bufio.NewReader(parsed.Body).WriteTo(&body)
+	want := "Mint tea (TEA-01) is down to 3.00 at warehouse #2; the minimum is 10.\r\n\r\n" +
+		"https://app.example.com/stock/location/2\r\n"
+	if body.String() != want {
+		t.Errorf("body = %q, want %q", body.String(), want)
+	}
+}
+
+func TestEmailChannelNeedsAddress(t *testing.T) {
+	channel := EmailChannel{Notifier: LogNotifier{}}
+	if err := channel.Deliver(models.User{ID: 4}, models.Notification{Title: "x"}, models.NotificationPreference{}); err == nil {
+		t.Fatal("expected an error for a user without an email address")
+	}
+}
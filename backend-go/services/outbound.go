@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Requests to addresses users configure (webhooks, notification channels) must not reach
+// the server itself or the internal network. URLs are checked when they're saved, and
+// again on every connection, since a name can resolve to something else later.
+// OUTBOUND_ALLOW_PRIVATE=true lifts the restriction, e.g. for a local test receiver.
+
+var errOutboundAddress = errors.New("url must point to a public address")
+
+// carrierNAT is the shared address space of RFC 6598, not covered by net.IP.IsPrivate
+var carrierNAT = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// publicAddress reports whether ip may be the target of an outbound request
+func publicAddress(ip net.IP) bool {
+	if boolFromEnv("OUTBOUND_ALLOW_PRIVATE", false) {
+		return true
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierNAT.Contains(ip))
+}
+
+// validateOutboundURL checks that rawURL is http(s) and that its host resolves to public
+// addresses only
+func validateOutboundURL(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return errors.New("url must be an http(s) URL")
+	}
+	ips, err := net.LookupIP(target.Hostname())
+	if err != nil || len(ips) == 0 {
+		return fmt.Errorf("could not resolve %s", target.Hostname())
+	}
+	for _, ip := range ips {
+		if !publicAddress(ip) {
+			return errOutboundAddress
+		}
+	}
+	return nil
+}
+
+// newOutboundClient returns an HTTP client that only connects to public addresses,
+// doesn't follow redirects and ignores proxy settings
+func newOutboundClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// Checked after resolution, on the address actually dialled
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicAddress(ip) {
+				return errOutboundAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
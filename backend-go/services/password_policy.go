@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt ignores everything after 72 bytes
+const maxPasswordBytes = 72
+
+// PasswordPolicy is what a new password must satisfy
+type PasswordPolicy struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// CurrentPasswordPolicy reads the policy from PASSWORD_MIN_LENGTH and
+// PASSWORD_REQUIRE_UPPER / _LOWER / _DIGIT / _SYMBOL
+func CurrentPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     intFromEnv("PASSWORD_MIN_LENGTH", 8),
+		RequireUpper:  boolFromEnv("PASSWORD_REQUIRE_UPPER", true),
+		RequireLower:  boolFromEnv("PASSWORD_REQUIRE_LOWER", true),
+		RequireDigit:  boolFromEnv("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol: boolFromEnv("PASSWORD_REQUIRE_SYMBOL", false),
+	}
+}
+
+// Validate returns an error naming every requirement the password misses
+func (p PasswordPolicy) Validate(password string) error {
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password must be at most %d characters", maxPasswordBytes)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	var missing []string
+	if len([]rune(password)) < p.MinLength {
+		missing = append(missing, fmt.Sprintf("at least %d characters", p.MinLength))
+	}
+	if p.RequireUpper && !upper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return errors.New("password must contain " + strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// HashPassword checks the password against the current policy and returns its bcrypt hash
+func HashPassword(password string) (string, error) {
+	if err := CurrentPasswordPolicy().Validate(password); err != nil {
+		return "", err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// A user can ask for a new reset link at most once per resetRequestInterval
+const resetRequestInterval = time.Minute
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+type PasswordResetService struct {
+	DB       *gorm.DB
+	Notifier Notifier
+}
+
+func NewPasswordResetService(db *gorm.DB, notifier Notifier) *PasswordResetService {
+	return &PasswordResetService{
+		DB:       db,
+		Notifier: notifier,
+	}
+}
+
+// PasswordResetTTL is how long a reset link works (PASSWORD_RESET_TTL, e.g. "30m")
+func PasswordResetTTL() time.Duration {
+	return durationFromEnv("PASSWORD_RESET_TTL", 30*time.Minute)
+}
+
+// RequestReset sends the user a reset link. Unknown and deactivated emails are ignored
+// without an error, so the endpoint can't be used to find out who has an account.
+func (s *PasswordResetService) RequestReset(email, ipAddress string) error {
+	var user models.User
+	if err := s.DB.Where("email = ?", strings.TrimSpace(email)).Limit(1).Find(&user).Error; err != nil {
+		return err
+	}
+	if user.ID == 0 || user.Status == "NOTACTIVE" {
+		return nil
+	}
+
+	var recent int64
+	if err := s.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-resetRequestInterval)).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	token, hash, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(PasswordResetTTL())
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the newest link works
+		if err := tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:      user.ID,
+			TokenHash:   hash,
+			ExpiresAt:   expiresAt,
+			RequestedIP: truncate(ipAddress, 45),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.Notifier.Send(resetMessage(user, token, expiresAt)); err != nil {
+		log.Printf("password reset: could not notify user %d: %v", user.ID, err)
+		return errors.New("could not send the password reset message")
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token. The token is used up, all of the
+// user's sessions end and the account's login lockout is lifted.
+func (s *PasswordResetService) ResetPassword(token, password string) error {
+	hashedPassword, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashOpaqueToken(token), time.Now()).
+			First(&reset).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		// Claiming the token with a conditional update keeps two concurrent resets from both using it
+		result := tx.Model(&models.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, reset.UserID).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+		if user.Status == "NOTACTIVE" {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+			return err
+		}
+		if err := RevokeUserSessions(tx, user.ID, "password_changed"); err != nil {
+			return err
+		}
+		return NewLoginThrottleService(tx).ClearAccount(user.Email)
+	})
+}
+
+// resetMessage links to PASSWORD_RESET_URL with the token added as "token" query
+// parameter; without a URL the token itself is sent
+func resetMessage(user models.User, token string, expiresAt time.Time) Message {
+	instructions := fmt.Sprintf("Use this code to reset your password: %s", token)
+	if base := os.Getenv("PASSWORD_RESET_URL"); base != "" {
+		if link, err := url.Parse(base); err == nil {
+			query := link.Query()
+			query.Set("token", token)
+			link.RawQuery = query.Encode()
+			instructions = fmt.Sprintf("Open this link to reset your password: %s", link.String())
+		}
+	}
+	return Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n%s\n\nIt expires at %s. If you did not ask for this, ignore this message.",
+			strings.TrimSpace(user.FirstName+" "+user.LastName), instructions, expiresAt.Format(time.RFC1123)),
+	}
+}
@@ -21,10 +21,11 @@ func NewPaymentService(model models.Payment, db *gorm.DB) *PaymentService {
 	}
 }
 
-func (s *PaymentService) GetALL(invoiceID string, limit int) ([]models.Payment, error) {
+// GetALL lists payments on invoices within the caller's location scope
+func (s *PaymentService) GetALL(scope AccessScope, invoiceID string, limit int) ([]models.Payment, error) {
 	var payments []models.Payment
 
-	query := s.db.Model(&models.Payment{})
+	query := s.db.Model(&models.Payment{}).Scopes(s.inScope(scope))
 
 	if invoiceID != "" {
 		query = query.Where("invoice_id = ?", invoiceID)
@@ -56,17 +57,34 @@ func (s *PaymentService) GetID(id string) (models.Payment, error) {
 }
 
 func (s *PaymentService) Create(payment models.Payment) (models.Payment, error) {
-	if err := s.db.Create(&payment).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		// Land the money in a cash box or bank account
+		if err := postPaymentToAccount(tx, &payment); err != nil {
+			return err
+		}
+		if err := postPaymentJournal(tx, &payment); err != nil {
+			return err
+		}
+		if payment.InvoiceType != "sales" {
+			return nil
+		}
+		return PublishEvent(tx, WebhookPaymentReceived, map[string]interface{}{"payment": payment})
+	})
+	if err != nil {
 		return payment, err
 	}
+	publishPayment(s.db, payment, []uint{payment.InvoiceID})
 	return s.GetID(strconv.Itoa(int(payment.ID)))
 }
 
-func (s *PaymentService) GetPaginated(limit, page int, orderBy, sortBy, invoiceID string) (PaginationResponse, error) {
+func (s *PaymentService) GetPaginated(scope AccessScope, limit, page int, orderBy, sortBy, invoiceID string) (PaginationResponse, error) {
 	var payments []models.Payment
 	var total int64
 
-	query := s.db.Model(&models.Payment{}).Preload("CreatedByUser")
+	query := s.db.Model(&models.Payment{}).Scopes(s.inScope(scope)).Preload("CreatedByUser")
 
 	if invoiceID != "" {
 		query = query.Where("invoice_id = ?", invoiceID)
@@ -90,10 +108,36 @@ func (s *PaymentService) GetPaginated(limit, page int, orderBy, sortBy, invoiceI
 	}, nil
 }
 
-func (s *PaymentService) DeleteByInvoiceID(invoiceID uint) error {
-	// Delete all payments for the given invoice
-	if err := s.db.Where("invoice_id = ?", invoiceID).Delete(&models.Payment{}).Error; err != nil {
-		return err
+// inScope keeps payments whose sales or purchase invoice is at a location in the scope
+func (s *PaymentService) inScope(scope AccessScope) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !scope.Restricted {
+			return db
+		}
+		salesInvoices := s.db.Model(&models.SalesInvoice{}).Select("id").Scopes(scope.Filter("location_id"))
+		purchaseInvoices := s.db.Model(&models.PurchaseInvoice{}).Select("id").Scopes(scope.Filter("location_id"))
+		return db.Where("(invoice_type = ? AND invoice_id IN (?)) OR (invoice_type = ? AND invoice_id IN (?))",
+			"sales", salesInvoices, "purchase", purchaseInvoices)
 	}
-	return nil
+}
+
+func (s *PaymentService) DeleteByInvoiceID(invoiceID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var paymentIDs []uint
+		if err := tx.Model(&models.Payment{}).Where("invoice_id = ?", invoiceID).Pluck("id", &paymentIDs).Error; err != nil {
+			return err
+		}
+		// Take the money back out of the accounts it was posted to
+		for _, paymentID := range paymentIDs {
+			if err := reversePaymentPostings(tx, paymentID); err != nil {
+				return err
+			}
+			if err := removeJournal(tx, "payment", paymentID); err != nil {
+				return err
+			}
+		}
+
+		// Delete all payments for the given invoice
+		return tx.Where("invoice_id = ?", invoiceID).Delete(&models.Payment{}).Error
+	})
 }
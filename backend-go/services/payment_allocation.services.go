@@ -18,8 +18,10 @@ func NewPaymentAllocationService(db *gorm.DB) *PaymentAllocationService {
 	}
 }
 
-// AllocatePaymentFIFO allocates payment to unpaid invoices using FIFO (First In First Out) logic
+// AllocatePaymentFIFO allocates payment to unpaid invoices using FIFO (First In First Out) logic.
+// Only invoices within the caller's location scope are paid.
 func (s *PaymentAllocationService) AllocatePaymentFIFO(
+	scope AccessScope,
 	customerID *uint,
 	vendorID *uint,
 	invoiceType string,
@@ -28,6 +30,7 @@ func (s *PaymentAllocationService) AllocatePaymentFIFO(
 	paymentDate time.Time,
 	referenceNumber *string,
 	notes *string,
+	accountID *uint,
 	createdBy uint,
 ) (*models.Payment, []models.PaymentAllocation, error) {
 
@@ -53,8 +56,11 @@ func (s *PaymentAllocationService) AllocatePaymentFIFO(
 
 	if invoiceType == "sales" {
 		var salesInvoices []models.SalesInvoice
+		// Invoices locked by a closed van settlement take no further payments
 		query := tx.Model(&models.SalesInvoice{}).
-			Where("payment_status IN ?", []string{"unpaid", "partial"})
+			Scopes(scope.Filter("location_id")).
+			Where("payment_status IN ?", []string{"unpaid", "partial"}).
+			Where("settlement_id IS NULL")
 
 		if customerID != nil {
 			query = query.Where("customer_id = ?", *customerID)
@@ -72,6 +78,7 @@ func (s *PaymentAllocationService) AllocatePaymentFIFO(
 	} else {
 		var purchaseInvoices []models.PurchaseInvoice
 		query := tx.Model(&models.PurchaseInvoice{}).
+			Scopes(scope.Filter("location_id")).
 			Where("payment_status IN ?", []string{"unpaid", "partial"})
 
 		if vendorID != nil {
@@ -111,6 +118,7 @@ func (s *PaymentAllocationService) AllocatePaymentFIFO(
 		PaymentMethod:     paymentMethod,
 		ReferenceNumber:   referenceNumber,
 		Notes:             notes,
+		AccountID:         accountID,
 		AllocationType:    "multiple",
 		TotalAllocated:    0,
 		UnallocatedAmount: amount,
@@ -124,6 +132,16 @@ func (s *PaymentAllocationService) AllocatePaymentFIFO(
 		return nil, nil, err
 	}
 
+	if err := postPaymentToAccount(tx, &payment); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := postPaymentJournal(tx, &payment); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
 	// Allocate payment to invoices using FIFO
 	// Use absolute value for allocation logic (handle negative amounts for purchases)
 	remainingAmount := amount
@@ -243,11 +261,25 @@ func (s *PaymentAllocationService) AllocatePaymentFIFO(
 		return nil, nil, err
 	}
 
+	if invoiceType == "sales" {
+		event := map[string]interface{}{"payment": payment, "allocations": allocations}
+		if err := PublishEvent(tx, WebhookPaymentReceived, event); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return nil, nil, err
 	}
 
+	invoiceIDs := make([]uint, len(allocations))
+	for i, allocation := range allocations {
+		invoiceIDs[i] = allocation.InvoiceID
+	}
+	publishPayment(s.db, payment, invoiceIDs)
+
 	return &payment, allocations, nil
 }
 
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// Where a resolved price comes from
+const (
+	PriceFromCustomerList = "customer_list"
+	PriceFromDefaultList  = "default_list"
+	PriceFromProduct      = "product"
+)
+
+// Flags of sales lines sold under their resolved price. Selling so needs the prices
+// permission "sell_" + flag.
+const (
+	PriceBelowList = "below_list"
+	PriceBelowCost = "below_cost"
+)
+
+// priceTolerance absorbs rounding in prices typed by hand
+const priceTolerance = 0.005
+
+// ResolvedPrice is what a product sells at to a customer for a quantity
+type ResolvedPrice struct {
+	ProductID   uint    `json:"product_id"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	CostPrice   float64 `json:"-"`
+	PriceListID *uint   `json:"price_list_id"`
+	Source      string  `json:"source"`
+}
+
+// Flag tells whether selling at unitPrice with the line discount goes under the
+// resolved price (the unit price is lower) or under cost (the net price is lower). Line
+// discounts are left to the approval workflow, so only cost counts them.
+func (r ResolvedPrice) Flag(unitPrice, discountPercent float64) string {
+	net := unitPrice * (1 - discountPercent/100)
+	if r.CostPrice > 0 && net < r.CostPrice-priceTolerance {
+		return PriceBelowCost
+	}
+	if unitPrice < r.UnitPrice-priceTolerance {
+		return PriceBelowList
+	}
+	return ""
+}
+
+// InUnit applies the price of the pack a line is sold in. A pack price only stands in for
+// the product's own price; prices from a list stay per base unit.
+func (r ResolvedPrice) InUnit(unit LineUnit) ResolvedPrice {
+	if unit.PackPrice != nil && r.Source == PriceFromProduct {
+		r.UnitPrice = *unit.PackPrice / unit.Factor
+	}
+	return r
+}
+
+// LinePrice is the pricing of a sales line as stored on it
+type LinePrice struct {
+	ListPrice float64
+	Flag      string
+}
+
+type PriceListItemInput struct {
+	ProductID   uint    `json:"product_id"`
+	MinQuantity float64 `json:"min_quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+}
+
+// PriceListInput creates or replaces a price list, items included
+type PriceListInput struct {
+	Name        string               `json:"name"`
+	Description *string              `json:"description"`
+	IsDefault   bool                 `json:"is_default"`
+	ValidFrom   *time.Time           `json:"valid_from"`
+	ValidTo     *time.Time           `json:"valid_to"`
+	IsActive    *bool                `json:"is_active"`
+	Items       []PriceListItemInput `json:"items"`
+}
+
+type PriceListService struct {
+	DB *gorm.DB
+}
+
+func NewPriceListService(db *gorm.DB) *PriceListService {
+	return &PriceListService{
+		DB: db,
+	}
+}
+
+func (s *PriceListService) GetAll() ([]models.PriceList, error) {
+	var lists []models.PriceList
+	if err := s.DB.Order("name ASC").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (s *PriceListService) GetID(id uint) (models.PriceList, error) {
+	var list models.PriceList
+	err := s.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("product_id ASC, min_quantity ASC")
+	}).Preload("Items.Product").First(&list, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.PriceList{}, errors.New("price list not found")
+		}
+		return models.PriceList{}, err
+	}
+	return list, nil
+}
+
+func (s *PriceListService) Create(input PriceListInput) (models.PriceList, error) {
+	if err := s.validate(input); err != nil {
+		return models.PriceList{}, err
+	}
+	list := models.PriceList{IsActive: true}
+	applyPriceListInput(&list, input)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(&list).Error; err != nil {
+			return err
+		}
+		return savePriceListItems(tx, list, input.Items)
+	})
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	return s.GetID(list.ID)
+}
+
+// Update replaces the list and all of its items
+func (s *PriceListService) Update(id uint, input PriceListInput) (models.PriceList, error) {
+	list, err := s.GetID(id)
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	if err := s.validate(input); err != nil {
+		return models.PriceList{}, err
+	}
+	applyPriceListInput(&list, input)
+	list.Items = nil
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Save(&list).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("price_list_id = ?", list.ID).Delete(&models.PriceListItem{}).Error; err != nil {
+			return err
+		}
+		return savePriceListItems(tx, list, input.Items)
+	})
+	if err != nil {
+		return models.PriceList{}, err
+	}
+	return s.GetID(list.ID)
+}
+
+// Delete removes the list; its customers go back to the default list
+func (s *PriceListService) Delete(id uint) error {
+	list, err := s.GetID(id)
+	if err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Customer{}).Where("price_list_id = ?", list.ID).
+			Update("price_list_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("price_list_id = ?", list.ID).Delete(&models.PriceListItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&list).Error
+	})
+}
+
+func applyPriceListInput(list *models.PriceList, input PriceListInput) {
+	list.Name = input.Name
+	list.Description = input.Description
+	list.IsDefault = input.IsDefault
+	list.ValidFrom = input.ValidFrom
+	list.ValidTo = input.ValidTo
+	if input.IsActive != nil {
+		list.IsActive = *input.IsActive
+	}
+}
+
+// savePriceListItems adds the items to the list; a default list takes over from the
+// previous one
+func savePriceListItems(tx *gorm.DB, list models.PriceList, inputs []PriceListItemInput) error {
+	if list.IsDefault {
+		if err := tx.Model(&models.PriceList{}).Where("id <> ? AND is_default = ?", list.ID, true).
+			Update("is_default", false).Error; err != nil {
+			return err
+		}
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+	items := make([]models.PriceListItem, len(inputs))
+	for i, input := range inputs {
+		items[i] = models.PriceListItem{
+			PriceListID: list.ID,
+			ProductID:   input.ProductID,
+			MinQuantity: input.MinQuantity,
+			UnitPrice:   input.UnitPrice,
+		}
+	}
+	return tx.Create(&items).Error
+}
+
+func (s *PriceListService) validate(input PriceListInput) error {
+	if input.Name == "" {
+		return errors.New("name is required")
+	}
+	if input.ValidFrom != nil && input.ValidTo != nil && input.ValidTo.Before(*input.ValidFrom) {
+		return errors.New("valid_to must not be before valid_from")
+	}
+
+	tiers := make(map[string]bool, len(input.Items))
+	products := make(map[uint]bool)
+	for _, item := range input.Items {
+		if item.UnitPrice < 0 || item.MinQuantity < 0 {
+			return fmt.Errorf("product #%d: unit_price and min_quantity can't be negative", item.ProductID)
+		}
+		tier := fmt.Sprintf("%d/%g", item.ProductID, item.MinQuantity)
+		if tiers[tier] {
+			return fmt.Errorf("product #%d is listed twice from quantity %g", item.ProductID, item.MinQuantity)
+		}
+		tiers[tier] = true
+		products[item.ProductID] = true
+	}
+	if len(products) > 0 {
+		ids := make([]uint, 0, len(products))
+		for id := range products {
+			ids = append(ids, id)
+		}
+		var found int64
+		if err := s.DB.Model(&models.Product{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return err
+		}
+		if int(found) != len(ids) {
+			return errors.New("price list refers to unknown products")
+		}
+	}
+	return nil
+}
+
+// Resolve finds the price of a product for a customer (nil for walk-in sales) and
+// quantity: the customer's list first, then the default list, then the product's
+// UnitPrice. Lists outside their validity dates or inactive are skipped.
+func (s *PriceListService) Resolve(customerID *uint, productID uint, quantity float64) (ResolvedPrice, error) {
+	var product models.Product
+	if err := s.DB.Select("id", "unit_price", "cost_price").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ResolvedPrice{}, fmt.Errorf("product #%d not found", productID)
+		}
+		return ResolvedPrice{}, err
+	}
+	resolved := ResolvedPrice{
+		ProductID: productID,
+		Quantity:  quantity,
+		UnitPrice: product.UnitPrice,
+		CostPrice: product.CostPrice,
+		Source:    PriceFromProduct,
+	}
+
+	now := time.Now()
+	applicable := func(db *gorm.DB) *gorm.DB {
+		return db.Where("is_active = ? AND (valid_from IS NULL OR valid_from <= ?) AND (valid_to IS NULL OR valid_to >= ?)",
+			true, now, now)
+	}
+	type candidate struct {
+		query  func(*gorm.DB) *gorm.DB
+		source string
+	}
+	var candidates []candidate
+	if customerID != nil {
+		var customer models.Customer
+		if err := s.DB.Select("id", "price_list_id").Limit(1).Find(&customer, *customerID).Error; err != nil {
+			return ResolvedPrice{}, err
+		}
+		if customer.PriceListID != nil {
+			listID := *customer.PriceListID
+			candidates = append(candidates, candidate{
+				query:  func(db *gorm.DB) *gorm.DB { return db.Where("id = ?", listID) },
+				source: PriceFromCustomerList,
+			})
+		}
+	}
+	candidates = append(candidates, candidate{
+		query:  func(db *gorm.DB) *gorm.DB { return db.Where("is_default = ?", true) },
+		source: PriceFromDefaultList,
+	})
+
+	for _, candidate := range candidates {
+		var list models.PriceList
+		if err := s.DB.Scopes(candidate.query, applicable).Limit(1).Find(&list).Error; err != nil {
+			return ResolvedPrice{}, err
+		}
+		if list.ID == 0 {
+			continue
+		}
+		var item models.PriceListItem
+		if err := s.DB.Where("price_list_id = ? AND product_id = ? AND min_quantity <= ?", list.ID, productID, quantity).
+			Order("min_quantity DESC").Limit(1).Find(&item).Error; err != nil {
+			return ResolvedPrice{}, err
+		}
+		if item.ID == 0 {
+			continue
+		}
+		resolved.UnitPrice = item.UnitPrice
+		resolved.PriceListID = &list.ID
+		resolved.Source = candidate.source
+		return resolved, nil
+	}
+	return resolved, nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"math"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
@@ -19,18 +20,44 @@ func NewProductServices(c models.Product, db *gorm.DB) *ProductServices {
 	}
 }
 
-func (ss *ProductServices) GetALL(limit, page int, orderBy, sortBy, searchTerm string) (PaginationResponse, error) {
+// GetALL lists the products, of one brand when brandID isn't 0 and of a category and its
+// subcategories when categoryID isn't 0. With groupByParent variants come inside their
+// parent instead of on their own, and a parent matches the search when one of them does.
+func (ss *ProductServices) GetALL(limit, page int, orderBy, sortBy, searchTerm string, brandID, categoryID uint, groupByParent bool) (PaginationResponse, error) {
 	products := []models.Product{}
 	var totalRecords int64
 	
 	query := ss.DB.Model(&models.Product{}).
 		Preload("Category").
 		Preload("ProductType").
+		Preload("Brand").
 		Where("is_active = ?", true)
 
+	if brandID != 0 {
+		query = query.Where("brand_id = ?", brandID)
+	}
+
+	if categoryID != 0 {
+		categories, err := CategoryWithDescendants(ss.DB, categoryID)
+		if err != nil {
+			return PaginationResponse{}, err
+		}
+		query = query.Where("category_id IN ?", categories)
+	}
+
+	if groupByParent {
+		query = query.Where("parent_id IS NULL").Preload("Variants", "is_active = ?", true)
+	}
+
 	if searchTerm != "" {
-		query = query.Where("name_en LIKE ? OR name_ar LIKE ? OR sku LIKE ? OR description LIKE ?",
-			"%"+searchTerm+"%", "%"+searchTerm+"%", "%"+searchTerm+"%", "%"+searchTerm+"%")
+		match := "name_en LIKE ? OR name_ar LIKE ? OR sku LIKE ? OR description LIKE ?"
+		args := []interface{}{"%" + searchTerm + "%", "%" + searchTerm + "%", "%" + searchTerm + "%", "%" + searchTerm + "%"}
+		if groupByParent {
+			variants := ss.DB.Model(&models.Product{}).Select("parent_id").Where(match, args...)
+			query = query.Where("("+match+") OR id IN (?)", append(args, variants)...)
+		} else {
+			query = query.Where(match, args...)
+		}
 	}
 
 	query.Count(&totalRecords)
@@ -53,32 +80,61 @@ func (ss *ProductServices) GetALL(limit, page int, orderBy, sortBy, searchTerm s
 
 func (cs *ProductServices) GetID(id string) (models.Product, error) {
 	var Product models.Product
-	if result := cs.DB.Preload("Category").Preload("ProductType").First(&Product, id); result.Error != nil {
+	if result := cs.DB.Preload("Category").Preload("ProductType").Preload("Brand").Preload("Units").Preload("Variants").First(&Product, id); result.Error != nil {
 		return models.Product{}, result.Error
 	}
 	return Product, nil
 }
 
 func (cs *ProductServices) Create(Product models.Product) (models.Product, error) {
+	if err := applyCategoryDefaults(cs.DB, &Product); err != nil {
+		return models.Product{}, err
+	}
 	if result := cs.DB.Create(&Product); result.Error != nil {
 		return models.Product{}, result.Error
 	}
 	return Product, nil
 }
 
+// Update saves the product and passes what its variants inherit on to them. A variant
+// keeps what it inherits from its parent; what's left empty comes from the category.
 func (cs *ProductServices) Update(Product models.Product) (models.Product, error) {
-	// Use Select to update all fields including zero values
-	if result := cs.DB.Model(&Product).Select(
-		"SKU", "Barcode", "NameEn", "NameAr", "Description",
-		"CategoryID", "TypeID", "UnitPrice", "CostPrice",
-		"Unit", "MinStockLevel", "IsActive",
-	).Updates(Product); result.Error != nil {
-		return models.Product{}, result.Error
+	if Product.ParentID != nil {
+		var parent models.Product
+		if err := cs.DB.First(&parent, *Product.ParentID).Error; err != nil {
+			return models.Product{}, err
+		}
+		inheritProduct(&Product, parent)
+	}
+	if err := applyCategoryDefaults(cs.DB, &Product); err != nil {
+		return models.Product{}, err
+	}
+
+	err := cs.DB.Transaction(func(tx *gorm.DB) error {
+		// Use Select to update all fields including zero values
+		if err := tx.Model(&Product).Select(
+			"SKU", "Barcode", "NameEn", "NameAr", "Description",
+			"CategoryID", "TypeID", "BrandID", "UnitPrice", "CostPrice", "TaxRate",
+			"Unit", "MinStockLevel", "InheritsTaxRate", "InheritsUnit", "InheritsMinStockLevel", "IsActive",
+		).Updates(Product).Error; err != nil {
+			return err
+		}
+		return updateVariants(tx, Product)
+	})
+	if err != nil {
+		return models.Product{}, err
 	}
 	return Product, nil
 }
 
 func (cs *ProductServices) Delete(Product models.Product) error {
+	var variants int64
+	if err := cs.DB.Model(&models.Product{}).Where("parent_id = ?", Product.ID).Count(&variants).Error; err != nil {
+		return err
+	}
+	if variants > 0 {
+		return errors.New("product has variants, delete them first")
+	}
 	if result := cs.DB.Delete(&Product); result.Error != nil {
 		return result.Error
 	}
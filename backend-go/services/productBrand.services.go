@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"math"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
@@ -25,7 +26,7 @@ func (ss *ProductBrandServices) GetALL(limit, page int, orderBy, sortBy, searchT
 	
 	query := ss.DB.Model(&models.ProductBrand{})
 	if searchTerm != "" {
-		query = query.Where("brand_name LIKE ?", "%"+searchTerm+"%")
+		query = query.Where("name_en LIKE ? OR name_ar LIKE ?", "%"+searchTerm+"%", "%"+searchTerm+"%")
 	}
 
 	query.Count(&totalRecords)
@@ -68,7 +69,15 @@ func (cs *ProductBrandServices) Update(ProductBrand models.ProductBrand) (models
 	return ProductBrand, nil
 }
 
+// Delete removes a brand no product is assigned to
 func (cs *ProductBrandServices) Delete(ProductBrand models.ProductBrand) error {
+	var products int64
+	if err := cs.DB.Model(&models.Product{}).Where("brand_id = ?", ProductBrand.ID).Count(&products).Error; err != nil {
+		return err
+	}
+	if products > 0 {
+		return errors.New("brand is assigned to products")
+	}
 	if result := cs.DB.Delete(&ProductBrand); result.Error != nil {
 		return result.Error
 	}
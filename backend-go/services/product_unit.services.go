@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// LineUnit is the unit a line was entered in: Quantity of Name, each Factor base units.
+// PackPrice is the sales price of one, when the pack has its own.
+type LineUnit struct {
+	Name      string
+	Factor    float64
+	Quantity  float64
+	PackPrice *float64
+}
+
+// BaseQuantity is Quantity in the base unit, as stock is held
+func (u LineUnit) BaseQuantity() float64 {
+	return u.Quantity * u.Factor
+}
+
+// Whole tells whether Quantity is a whole number of packs
+func (u LineUnit) Whole() bool {
+	return u.Factor == 1 || u.Quantity == math.Trunc(u.Quantity)
+}
+
+type ProductUnitInput struct {
+	Name      string   `json:"name"`
+	Factor    float64  `json:"factor"`
+	Barcode   *string  `json:"barcode"`
+	UnitPrice *float64 `json:"unit_price"`
+}
+
+type ProductUnitService struct {
+	DB *gorm.DB
+}
+
+func NewProductUnitService(db *gorm.DB) *ProductUnitService {
+	return &ProductUnitService{
+		DB: db,
+	}
+}
+
+func (s *ProductUnitService) GetByProduct(productID uint) ([]models.ProductUnit, error) {
+	var units []models.ProductUnit
+	if err := s.DB.Where("product_id = ?", productID).Order("factor ASC").Find(&units).Error; err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// Replace sets the packs of a product. Lines already entered in a removed pack keep
+// their base quantity.
+func (s *ProductUnitService) Replace(productID uint, inputs []ProductUnitInput) ([]models.ProductUnit, error) {
+	var product models.Product
+	if err := s.DB.Select("id", "unit").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("product not found")
+		}
+		return nil, err
+	}
+	names := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		name := strings.ToLower(strings.TrimSpace(input.Name))
+		if name == "" {
+			return nil, errors.New("unit name is required")
+		}
+		if name == strings.ToLower(product.Unit) {
+			return nil, fmt.Errorf("%s is the base unit of the product", input.Name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("unit %s is listed twice", input.Name)
+		}
+		names[name] = true
+		if input.Factor <= 1 {
+			return nil, fmt.Errorf("unit %s must hold more than one %s", input.Name, product.Unit)
+		}
+		if input.UnitPrice != nil && *input.UnitPrice < 0 {
+			return nil, fmt.Errorf("price of unit %s can't be negative", input.Name)
+		}
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&models.ProductUnit{}).Error; err != nil {
+			return err
+		}
+		if len(inputs) > 0 {
+			units := make([]models.ProductUnit, len(inputs))
+			for i, input := range inputs {
+				units[i] = models.ProductUnit{
+					ProductID: productID,
+					Name:      strings.TrimSpace(input.Name),
+					Factor:    input.Factor,
+					Barcode:   input.Barcode,
+					UnitPrice: input.UnitPrice,
+				}
+			}
+			if err := tx.Create(&units).Error; err != nil {
+				return err
+			}
+		}
+		// So the offline sync sends the product again
+		return tx.Model(&product).Update("updated_at", time.Now()).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByProduct(productID)
+}
+
+// Convert reads quantity of a product in unit, its base unit when unit is empty
+func (s *ProductUnitService) Convert(productID uint, unit string, quantity float64) (LineUnit, error) {
+	var product models.Product
+	if err := s.DB.Select("id", "unit").First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return LineUnit{}, fmt.Errorf("product #%d not found", productID)
+		}
+		return LineUnit{}, err
+	}
+	if unit == "" || strings.EqualFold(unit, product.Unit) {
+		return LineUnit{Name: product.Unit, Factor: 1, Quantity: quantity}, nil
+	}
+
+	var pack models.ProductUnit
+	if err := s.DB.Where("product_id = ? AND name = ?", productID, unit).Limit(1).Find(&pack).Error; err != nil {
+		return LineUnit{}, err
+	}
+	if pack.ID == 0 {
+		return LineUnit{}, fmt.Errorf("product #%d has no unit %s", productID, unit)
+	}
+	return LineUnit{Name: pack.Name, Factor: pack.Factor, Quantity: quantity, PackPrice: pack.UnitPrice}, nil
+}
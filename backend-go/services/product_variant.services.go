@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// VariantInput creates or updates a variant. NameEn defaults to the parent's name
+// followed by the attribute values.
+type VariantInput struct {
+	SKU           string            `json:"sku"`
+	Barcode       *string           `json:"barcode"`
+	NameEn        string            `json:"name_en"`
+	NameAr        *string           `json:"name_ar"`
+	Attributes    map[string]string `json:"attributes"`
+	UnitPrice     float64           `json:"unit_price"`
+	CostPrice     float64           `json:"cost_price"`
+	MinStockLevel int               `json:"min_stock_level"`
+	IsActive      *bool             `json:"is_active"`
+}
+
+type ProductVariantService struct {
+	DB *gorm.DB
+}
+
+func NewProductVariantService(db *gorm.DB) *ProductVariantService {
+	return &ProductVariantService{
+		DB: db,
+	}
+}
+
+func (s *ProductVariantService) GetByParent(parentID uint) ([]models.Product, error) {
+	if _, err := s.parent(parentID); err != nil {
+		return nil, err
+	}
+	var variants []models.Product
+	if err := s.DB.Where("parent_id = ?", parentID).Order("name_en ASC").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (s *ProductVariantService) Create(parentID uint, input VariantInput) (models.Product, error) {
+	parent, err := s.parent(parentID)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if err := s.validate(parent, 0, input); err != nil {
+		return models.Product{}, err
+	}
+	variant := models.Product{ParentID: &parent.ID, Unit: parent.Unit, InheritsUnit: parent.InheritsUnit, IsActive: true}
+	applyVariantInput(&variant, parent, input)
+	if err := s.DB.Create(&variant).Error; err != nil {
+		return models.Product{}, err
+	}
+	return variant, nil
+}
+
+func (s *ProductVariantService) Update(parentID, id uint, input VariantInput) (models.Product, error) {
+	parent, err := s.parent(parentID)
+	if err != nil {
+		return models.Product{}, err
+	}
+	var variant models.Product
+	if err := s.DB.Where("parent_id = ?", parent.ID).First(&variant, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Product{}, errors.New("variant not found")
+		}
+		return models.Product{}, err
+	}
+	if err := s.validate(parent, variant.ID, input); err != nil {
+		return models.Product{}, err
+	}
+	applyVariantInput(&variant, parent, input)
+	if err := s.DB.Save(&variant).Error; err != nil {
+		return models.Product{}, err
+	}
+	return variant, nil
+}
+
+// parent loads a product that can have variants, i.e. isn't a variant itself
+func (s *ProductVariantService) parent(id uint) (models.Product, error) {
+	var parent models.Product
+	if err := s.DB.First(&parent, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Product{}, errors.New("product not found")
+		}
+		return models.Product{}, err
+	}
+	if parent.ParentID != nil {
+		return models.Product{}, errors.New("a variant can't have variants of its own")
+	}
+	return parent, nil
+}
+
+// validate checks the variant differs from its siblings (other than id) by its attributes
+func (s *ProductVariantService) validate(parent models.Product, id uint, input VariantInput) error {
+	if input.SKU == "" {
+		return errors.New("sku is required")
+	}
+	if len(input.Attributes) == 0 {
+		return errors.New("a variant needs at least one attribute")
+	}
+	var siblings []models.Product
+	if err := s.DB.Select("id", "attributes").Where("parent_id = ? AND id <> ?", parent.ID, id).
+		Find(&siblings).Error; err != nil {
+		return err
+	}
+	key := attributesKey(input.Attributes)
+	for _, sibling := range siblings {
+		if attributesKey(sibling.Attributes) == key {
+			return fmt.Errorf("%s already has a variant %s", parent.NameEn, attributesLabel(input.Attributes))
+		}
+	}
+	return nil
+}
+
+func applyVariantInput(variant *models.Product, parent models.Product, input VariantInput) {
+	variant.SKU = input.SKU
+	variant.Barcode = input.Barcode
+	variant.NameEn = input.NameEn
+	if variant.NameEn == "" {
+		variant.NameEn = parent.NameEn + " - " + attributesLabel(input.Attributes)
+	}
+	variant.NameAr = input.NameAr
+	variant.Attributes = input.Attributes
+	variant.UnitPrice = input.UnitPrice
+	variant.CostPrice = input.CostPrice
+	variant.MinStockLevel = input.MinStockLevel
+	if input.IsActive != nil {
+		variant.IsActive = *input.IsActive
+	}
+	inheritProduct(variant, parent)
+}
+
+// inheritProduct copies what a variant takes from its parent
+func inheritProduct(variant *models.Product, parent models.Product) {
+	variant.CategoryID = parent.CategoryID
+	variant.TypeID = parent.TypeID
+	variant.BrandID = parent.BrandID
+	variant.TaxRate = parent.TaxRate
+	variant.InheritsTaxRate = parent.InheritsTaxRate
+	variant.Description = parent.Description
+}
+
+// updateVariants passes the inherited fields of a parent on to its variants
+func updateVariants(db *gorm.DB, parent models.Product) error {
+	var inherited models.Product
+	inheritProduct(&inherited, parent)
+	return db.Model(&models.Product{}).Where("parent_id = ?", parent.ID).
+		Select("CategoryID", "TypeID", "BrandID", "TaxRate", "InheritsTaxRate", "Description").Updates(inherited).Error
+}
+
+// attributesKey compares attribute sets regardless of order and case
+func attributesKey(attributes map[string]string) string {
+	pairs := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		pairs = append(pairs, strings.ToLower(strings.TrimSpace(name))+"="+strings.ToLower(strings.TrimSpace(value)))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+// attributesLabel lists the attribute values by attribute name, e.g. "500ml / mint"
+func attributesLabel(attributes map[string]string) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = attributes[name]
+	}
+	return strings.Join(values, " / ")
+}
@@ -0,0 +1,479 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+type PromotionItemInput struct {
+	ProductID uint    `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+// PromotionInput creates or replaces a promotion; which fields count depends on Type,
+// see models.Promotion
+type PromotionInput struct {
+	Name            string               `json:"name"`
+	Description     *string              `json:"description"`
+	Type            string               `json:"type"`
+	ProductID       *uint                `json:"product_id"`
+	BuyQuantity     float64              `json:"buy_quantity"`
+	FreeProductID   *uint                `json:"free_product_id"`
+	FreeQuantity    float64              `json:"free_quantity"`
+	BundlePrice     float64              `json:"bundle_price"`
+	Items           []PromotionItemInput `json:"items"`
+	CategoryID      *uint                `json:"category_id"`
+	DiscountPercent float64              `json:"discount_percent"`
+	MinSpend        float64              `json:"min_spend"`
+	StartsAt        *time.Time           `json:"starts_at"`
+	EndsAt          *time.Time           `json:"ends_at"`
+	LocationIDs     []uint               `json:"location_ids"`
+	VanIDs          []uint               `json:"van_ids"`
+	CustomerGroups  []string             `json:"customer_groups"`
+	Priority        int                  `json:"priority"`
+	IsActive        *bool                `json:"is_active"`
+}
+
+type PromotionService struct {
+	DB *gorm.DB
+}
+
+func NewPromotionService(db *gorm.DB) *PromotionService {
+	return &PromotionService{
+		DB: db,
+	}
+}
+
+// GetAll lists the promotions; running=true keeps only those active and within their
+// dates
+func (s *PromotionService) GetAll(running bool) ([]models.Promotion, error) {
+	query := s.DB.Preload("Items")
+	if running {
+		query = query.Scopes(runningPromotions(time.Now()))
+	}
+	var promotions []models.Promotion
+	if err := query.Order("priority DESC, id ASC").Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+func (s *PromotionService) GetID(id uint) (models.Promotion, error) {
+	var promotion models.Promotion
+	if err := s.DB.Preload("Items.Product").First(&promotion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Promotion{}, errors.New("promotion not found")
+		}
+		return models.Promotion{}, err
+	}
+	return promotion, nil
+}
+
+func (s *PromotionService) Create(input PromotionInput) (models.Promotion, error) {
+	if err := validatePromotion(input); err != nil {
+		return models.Promotion{}, err
+	}
+	promotion := models.Promotion{IsActive: true}
+	applyPromotionInput(&promotion, input)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(&promotion).Error; err != nil {
+			return err
+		}
+		return savePromotionItems(tx, promotion.ID, input.Items)
+	})
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	return s.GetID(promotion.ID)
+}
+
+// Update replaces the promotion, bundle items included. Lines it was already applied
+// to keep their discount.
+func (s *PromotionService) Update(id uint, input PromotionInput) (models.Promotion, error) {
+	promotion, err := s.GetID(id)
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	if err := validatePromotion(input); err != nil {
+		return models.Promotion{}, err
+	}
+	applyPromotionInput(&promotion, input)
+	promotion.Items = nil
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Save(&promotion).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("promotion_id = ?", promotion.ID).Delete(&models.PromotionItem{}).Error; err != nil {
+			return err
+		}
+		return savePromotionItems(tx, promotion.ID, input.Items)
+	})
+	if err != nil {
+		return models.Promotion{}, err
+	}
+	return s.GetID(promotion.ID)
+}
+
+// Delete removes a promotion that was never applied; one that was stays for the reports
+// and can only be deactivated
+func (s *PromotionService) Delete(id uint) error {
+	promotion, err := s.GetID(id)
+	if err != nil {
+		return err
+	}
+	var used int64
+	if err := s.DB.Model(&models.SalesInvoiceItem{}).Where("promotion_id = ?", promotion.ID).Count(&used).Error; err != nil {
+		return err
+	}
+	if used > 0 {
+		return errors.New("promotion has been applied to sales, deactivate it instead")
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("promotion_id = ?", promotion.ID).Delete(&models.PromotionItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&promotion).Error
+	})
+}
+
+func applyPromotionInput(promotion *models.Promotion, input PromotionInput) {
+	promotion.Name = input.Name
+	promotion.Description = input.Description
+	promotion.Type = input.Type
+	promotion.ProductID = input.ProductID
+	promotion.BuyQuantity = input.BuyQuantity
+	promotion.FreeProductID = input.FreeProductID
+	promotion.FreeQuantity = input.FreeQuantity
+	promotion.BundlePrice = input.BundlePrice
+	promotion.CategoryID = input.CategoryID
+	promotion.DiscountPercent = input.DiscountPercent
+	promotion.MinSpend = input.MinSpend
+	promotion.StartsAt = input.StartsAt
+	promotion.EndsAt = input.EndsAt
+	promotion.LocationIDs = input.LocationIDs
+	promotion.VanIDs = input.VanIDs
+	promotion.CustomerGroups = input.CustomerGroups
+	promotion.Priority = input.Priority
+	if input.IsActive != nil {
+		promotion.IsActive = *input.IsActive
+	}
+}
+
+func savePromotionItems(tx *gorm.DB, promotionID uint, inputs []PromotionItemInput) error {
+	if len(inputs) == 0 {
+		return nil
+	}
+	items := make([]models.PromotionItem, len(inputs))
+	for i, input := range inputs {
+		items[i] = models.PromotionItem{
+			PromotionID: promotionID,
+			ProductID:   input.ProductID,
+			Quantity:    input.Quantity,
+		}
+	}
+	return tx.Create(&items).Error
+}
+
+func validatePromotion(input PromotionInput) error {
+	if input.Name == "" {
+		return errors.New("name is required")
+	}
+	if input.StartsAt != nil && input.EndsAt != nil && input.EndsAt.Before(*input.StartsAt) {
+		return errors.New("ends_at must not be before starts_at")
+	}
+	percentOK := input.DiscountPercent > 0 && input.DiscountPercent <= 100
+
+	switch input.Type {
+	case models.PromotionBuyXGetY:
+		if input.ProductID == nil || input.BuyQuantity <= 0 || input.FreeQuantity <= 0 {
+			return errors.New("buy_x_get_y needs product_id, buy_quantity and free_quantity")
+		}
+	case models.PromotionBundle:
+		if len(input.Items) == 0 || input.BundlePrice < 0 {
+			return errors.New("bundle needs items and a bundle_price")
+		}
+		products := make(map[uint]bool, len(input.Items))
+		for _, item := range input.Items {
+			if item.Quantity <= 0 {
+				return fmt.Errorf("bundle quantity of product #%d must be positive", item.ProductID)
+			}
+			if products[item.ProductID] {
+				return fmt.Errorf("product #%d is in the bundle twice", item.ProductID)
+			}
+			products[item.ProductID] = true
+		}
+	case models.PromotionCategoryPercent:
+		if input.CategoryID == nil || !percentOK {
+			return errors.New("category_percent needs category_id and a discount_percent between 0 and 100")
+		}
+	case models.PromotionMinSpend:
+		if input.MinSpend <= 0 || !percentOK {
+			return errors.New("min_spend needs min_spend and a discount_percent between 0 and 100")
+		}
+	default:
+		return fmt.Errorf("unknown promotion type %q", input.Type)
+	}
+	return nil
+}
+
+func runningPromotions(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("is_active = ? AND (starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at >= ?)",
+			true, now, now)
+	}
+}
+
+// Apply runs the promotions on the lines of a new sales invoice at locationID, for
+// customerID (nil for walk-in sales). The lines must be priced, with Total net of their
+// own discount; the promotions they get are recorded on them and taken off Total.
+// Line promotions are tried by priority and a line takes part in one at most; basket
+// promotions (min_spend) come last, on what the others left, so the basket is counted
+// after their discounts.
+func (s *PromotionService) Apply(customerID *uint, locationID uint, items []models.SalesInvoiceItem) error {
+	for i := range items {
+		items[i].PromotionID = nil
+		items[i].PromotionDiscount = 0
+	}
+	var promotions []models.Promotion
+	if err := s.DB.Scopes(runningPromotions(time.Now())).Preload("Items").
+		Order("priority DESC, id ASC").Find(&promotions).Error; err != nil {
+		return err
+	}
+	if len(promotions) == 0 {
+		return nil
+	}
+
+	var location models.Location
+	if err := s.DB.Select("id", "van_id").Limit(1).Find(&location, locationID).Error; err != nil {
+		return err
+	}
+	var group *string
+	if customerID != nil {
+		var customer models.Customer
+		if err := s.DB.Select("id", "customer_group").Limit(1).Find(&customer, *customerID).Error; err != nil {
+			return err
+		}
+		group = customer.CustomerGroup
+	}
+
+	productIDs := make([]uint, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	var products []models.Product
+	if err := s.DB.Select("id", "category_id").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return err
+	}
+	categories := make(map[uint]*uint, len(products))
+	for _, product := range products {
+		categories[product.ID] = product.CategoryID
+	}
+
+	basket := &promotionBasket{items: items, claimed: make([]bool, len(items))}
+	var basketPromotions []models.Promotion
+	for _, promotion := range promotions {
+		if !promotionMatches(promotion, locationID, location.VanID, group) {
+			continue
+		}
+		switch promotion.Type {
+		case models.PromotionBuyXGetY:
+			basket.buyXGetY(promotion)
+		case models.PromotionBundle:
+			basket.bundle(promotion)
+		case models.PromotionCategoryPercent:
+			// The category takes in its subcategories
+			tree, err := CategoryWithDescendants(s.DB, *promotion.CategoryID)
+			if err != nil {
+				return err
+			}
+			basket.categoryPercent(promotion, categories, tree)
+		case models.PromotionMinSpend:
+			basketPromotions = append(basketPromotions, promotion)
+		}
+	}
+	for _, promotion := range basketPromotions {
+		basket.minSpend(promotion)
+	}
+
+	for i := range items {
+		items[i].Total -= items[i].PromotionDiscount
+	}
+	return nil
+}
+
+// promotionMatches tells whether a promotion is meant for the location, van and customer
+// group of a sale
+func promotionMatches(promotion models.Promotion, locationID uint, vanID *uint, group *string) bool {
+	if len(promotion.LocationIDs) > 0 && !containsUint(promotion.LocationIDs, locationID) {
+		return false
+	}
+	if len(promotion.VanIDs) > 0 && (vanID == nil || !containsUint(promotion.VanIDs, *vanID)) {
+		return false
+	}
+	if len(promotion.CustomerGroups) > 0 {
+		if group == nil {
+			return false
+		}
+		for _, allowed := range promotion.CustomerGroups {
+			if allowed == *group {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsUint(values []uint, value uint) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// promotionBasket tracks the lines of a sale already taken by a promotion
+type promotionBasket struct {
+	items   []models.SalesInvoiceItem
+	claimed []bool
+}
+
+// netPrice is the unit price of line i after its own discount
+func (b *promotionBasket) netPrice(i int) float64 {
+	return b.items[i].UnitPrice * (1 - b.items[i].DiscountPercent/100)
+}
+
+// lines returns the free lines of a product and the quantity on them
+func (b *promotionBasket) lines(productID uint) ([]int, float64) {
+	var lines []int
+	var quantity float64
+	for i, item := range b.items {
+		if !b.claimed[i] && item.ProductID == productID {
+			lines = append(lines, i)
+			quantity += item.Quantity
+		}
+	}
+	return lines, quantity
+}
+
+func (b *promotionBasket) claim(i int, promotion models.Promotion, discount float64) {
+	id := promotion.ID
+	b.claimed[i] = true
+	b.items[i].PromotionID = &id
+	b.items[i].PromotionDiscount = math.Round(discount*100) / 100
+}
+
+// buyXGetY gives FreeQuantity free for every BuyQuantity bought, as often as the lines
+// allow. The free units are taken off the lines of the free product.
+func (b *promotionBasket) buyXGetY(promotion models.Promotion) {
+	buyLines, bought := b.lines(*promotion.ProductID)
+	if len(buyLines) == 0 {
+		return
+	}
+	var free float64
+	freeLines := buyLines
+	if promotion.FreeProductID == nil || *promotion.FreeProductID == *promotion.ProductID {
+		// The free units come out of the quantity sold
+		free = math.Floor(bought/(promotion.BuyQuantity+promotion.FreeQuantity)) * promotion.FreeQuantity
+	} else {
+		var available float64
+		freeLines, available = b.lines(*promotion.FreeProductID)
+		free = math.Min(math.Floor(bought/promotion.BuyQuantity)*promotion.FreeQuantity, available)
+	}
+	if free <= 0 {
+		return
+	}
+
+	discounts := make(map[int]float64, len(freeLines))
+	for _, i := range freeLines {
+		units := math.Min(free, b.items[i].Quantity)
+		discounts[i] = units * b.netPrice(i)
+		free -= units
+		if free <= 0 {
+			break
+		}
+	}
+	for _, i := range buyLines {
+		b.claim(i, promotion, discounts[i])
+	}
+	for _, i := range freeLines {
+		if discount, ok := discounts[i]; ok {
+			b.claim(i, promotion, discount)
+		}
+	}
+}
+
+// bundle sells complete sets of the bundle products at BundlePrice. The saving is
+// spread over the lines in proportion to their value in the sets.
+func (b *promotionBasket) bundle(promotion models.Promotion) {
+	if len(promotion.Items) == 0 {
+		return
+	}
+	sets := math.Inf(1)
+	lines := make([][]int, len(promotion.Items))
+	for n, item := range promotion.Items {
+		var quantity float64
+		lines[n], quantity = b.lines(item.ProductID)
+		sets = math.Min(sets, math.Floor(quantity/item.Quantity))
+	}
+	if sets <= 0 {
+		return
+	}
+
+	values := make(map[int]float64)
+	var regular float64
+	for n, item := range promotion.Items {
+		needed := sets * item.Quantity
+		for _, i := range lines[n] {
+			units := math.Min(needed, b.items[i].Quantity)
+			values[i] = units * b.netPrice(i)
+			regular += values[i]
+			needed -= units
+			if needed <= 0 {
+				break
+			}
+		}
+	}
+	saving := regular - sets*promotion.BundlePrice
+	if saving <= 0 {
+		return
+	}
+	for i, value := range values {
+		b.claim(i, promotion, saving*value/regular)
+	}
+}
+
+func (b *promotionBasket) categoryPercent(promotion models.Promotion, categories map[uint]*uint, tree []uint) {
+	inTree := make(map[uint]bool, len(tree))
+	for _, id := range tree {
+		inTree[id] = true
+	}
+	for i, item := range b.items {
+		category := categories[item.ProductID]
+		if b.claimed[i] || category == nil || !inTree[*category] {
+			continue
+		}
+		b.claim(i, promotion, item.Total*promotion.DiscountPercent/100)
+	}
+}
+
+func (b *promotionBasket) minSpend(promotion models.Promotion) {
+	var spend float64
+	for _, item := range b.items {
+		spend += item.Total - item.PromotionDiscount
+	}
+	if spend < promotion.MinSpend {
+		return
+	}
+	for i, item := range b.items {
+		if !b.claimed[i] {
+			b.claim(i, promotion, item.Total*promotion.DiscountPercent/100)
+		}
+	}
+}
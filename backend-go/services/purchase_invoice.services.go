@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
@@ -22,11 +23,13 @@ func NewPurchaseInvoiceService(model models.PurchaseInvoice, db *gorm.DB) *Purch
 	}
 }
 
-func (s *PurchaseInvoiceService) GetALL(filters map[string]string, limit, offset int) ([]models.PurchaseInvoice, int64, error) {
+// GetALL lists invoices within the caller's location scope
+func (s *PurchaseInvoiceService) GetALL(scope AccessScope, filters map[string]string, limit, offset int) ([]models.PurchaseInvoice, int64, error) {
 	var invoices []models.PurchaseInvoice
 	var total int64
 
 	query := s.db.Model(&models.PurchaseInvoice{}).
+		Scopes(scope.Filter("location_id")).
 		Preload("Vendor").
 		Preload("Location").
 		Preload("CreatedByUser").
@@ -62,9 +65,10 @@ func (s *PurchaseInvoiceService) GetALL(filters map[string]string, limit, offset
 	return invoices, total, nil
 }
 
-func (s *PurchaseInvoiceService) GetID(id string) (models.PurchaseInvoice, error) {
+// GetID returns an invoice if it belongs to the caller's location scope
+func (s *PurchaseInvoiceService) GetID(scope AccessScope, id string) (models.PurchaseInvoice, error) {
 	var invoice models.PurchaseInvoice
-	if err := s.db.Preload("Vendor").
+	if err := s.db.Scopes(scope.Filter("location_id")).Preload("Vendor").
 		Preload("Location").
 		Preload("CreatedByUser").
 		Preload("Items").
@@ -85,9 +89,9 @@ func (s *PurchaseInvoiceService) GetID(id string) (models.PurchaseInvoice, error
 	return invoice, nil
 }
 
-func (s *PurchaseInvoiceService) GetCount() (int64, error) {
+func (s *PurchaseInvoiceService) GetCount(scope AccessScope) (int64, error) {
 	var count int64
-	err := s.db.Model(&models.PurchaseInvoice{}).Count(&count).Error
+	err := s.db.Model(&models.PurchaseInvoice{}).Scopes(scope.Filter("location_id")).Count(&count).Error
 	return count, err
 }
 
@@ -100,20 +104,37 @@ func (s *PurchaseInvoiceService) Create(invoice models.PurchaseInvoice) (models.
 		invoice.InvoiceDate = time.Now()
 	}
 
-	if err := s.db.Create(&invoice).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invoice).Error; err != nil {
+			return err
+		}
+		if err := postPurchaseInvoiceJournal(tx, invoice.ID); err != nil {
+			return err
+		}
+		return PublishEvent(tx, WebhookInvoiceCreated, map[string]interface{}{"invoice_type": "purchase", "invoice": invoice})
+	})
+	if err != nil {
 		return invoice, err
 	}
-	return s.GetID(fmt.Sprintf("%d", invoice.ID))
+	return s.GetID(AccessScope{}, fmt.Sprintf("%d", invoice.ID))
 }
 
 func (s *PurchaseInvoiceService) Update(invoice models.PurchaseInvoice) (models.PurchaseInvoice, error) {
-	if err := s.db.Save(&invoice).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&invoice).Error; err != nil {
+			return err
+		}
+		// The invoice date may have moved
+		return postPurchaseInvoiceJournal(tx, invoice.ID)
+	})
+	if err != nil {
 		return invoice, err
 	}
-	return s.GetID(fmt.Sprintf("%d", invoice.ID))
+	return s.GetID(AccessScope{}, fmt.Sprintf("%d", invoice.ID))
 }
 
-func (s *PurchaseInvoiceService) UpdateItem(itemID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error {
+func (s *PurchaseInvoiceService) UpdateItem(itemID uint, productID uint, unit LineUnit, unitPrice, discountPercent float64) error {
+	quantity := unit.BaseQuantity()
 	var item models.PurchaseInvoiceItem
 	if err := s.db.First(&item, itemID).Error; err != nil {
 		return err
@@ -130,11 +151,14 @@ func (s *PurchaseInvoiceService) UpdateItem(itemID uint, productID uint, quantit
 	item.UnitPrice = unitPrice
 	item.DiscountPercent = discountPercent
 	item.Total = newTotal
+	item.Unit = unit.Name
+	item.UnitQuantity = unit.Quantity
 
 	return s.db.Save(&item).Error
 }
 
-func (s *PurchaseInvoiceService) AddItem(invoiceID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error {
+func (s *PurchaseInvoiceService) AddItem(invoiceID uint, productID uint, unit LineUnit, unitPrice, discountPercent float64) error {
+	quantity := unit.BaseQuantity()
 	// Calculate total for the new item
 	subtotal := quantity * unitPrice
 	discountAmount := subtotal * discountPercent / 100
@@ -148,6 +172,8 @@ func (s *PurchaseInvoiceService) AddItem(invoiceID uint, productID uint, quantit
 		UnitPrice:       unitPrice,
 		DiscountPercent: discountPercent,
 		Total:           newTotal,
+		Unit:            unit.Name,
+		UnitQuantity:    unit.Quantity,
 	}
 
 	return s.db.Create(&newItem).Error
@@ -175,7 +201,12 @@ func (s *PurchaseInvoiceService) RecalculateTotals(invoiceID uint) error {
 		invoice.PaymentStatus = "unpaid"
 	}
 
-	return s.db.Save(&invoice).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&invoice).Error; err != nil {
+			return err
+		}
+		return postPurchaseInvoiceJournal(tx, invoice.ID)
+	})
 }
 
 func (s *PurchaseInvoiceService) UpdatePaymentStatus(id uint, paidAmount float64) error {
@@ -203,12 +234,12 @@ func (s *PurchaseInvoiceService) generateInvoiceNumber() string {
 	return fmt.Sprintf("PI-%s-%05d", time.Now().Format("200601"), count+1)
 }
 
-func (s *PurchaseInvoiceService) GetPaginated(limit, page int, orderBy, sortBy string, filters map[string]string) (PaginationResponse, error) {
+func (s *PurchaseInvoiceService) GetPaginated(scope AccessScope, limit, page int, orderBy, sortBy string, filters map[string]string) (PaginationResponse, error) {
 	var invoices []models.PurchaseInvoice
 	var total int64
 
 	offset := (page - 1) * limit
-	invoices, total, err := s.GetALL(filters, limit, offset)
+	invoices, total, err := s.GetALL(scope, filters, limit, offset)
 	if err != nil {
 		return PaginationResponse{}, err
 	}
@@ -225,9 +256,15 @@ func (s *PurchaseInvoiceService) GetPaginated(limit, page int, orderBy, sortBy s
 }
 
 func (s *PurchaseInvoiceService) Delete(id string) error {
-	// Soft delete the invoice
-	if err := s.db.Delete(&models.PurchaseInvoice{}, id).Error; err != nil {
+	invoiceID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
 		return err
 	}
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := removeJournal(tx, "purchase_invoice", uint(invoiceID)); err != nil {
+			return err
+		}
+		// Soft delete the invoice
+		return tx.Delete(&models.PurchaseInvoice{}, id).Error
+	})
 }
@@ -2,7 +2,9 @@ package services
 
 import (
 	"errors"
+	"sync"
 
+	"github.com/gonext-tech/invoicing-system/backend/database"
 	"github.com/gonext-tech/invoicing-system/backend/models"
 	"gorm.io/gorm"
 )
@@ -12,6 +14,53 @@ type RoleService struct {
 	DB   *gorm.DB
 }
 
+// userAccess is what the permission middleware needs to know about a user
+type userAccess struct {
+	permissions map[string]bool // "resource:action"
+	scope       AccessScope
+}
+
+// permissionCache keeps each user's permissions and location scope in memory so the
+// middleware doesn't hit the database on every request. Entries are dropped whenever
+// role, permission or location assignments change.
+type permissionCache struct {
+	mu    sync.RWMutex
+	users map[uint]*userAccess
+}
+
+var userPermissionCache = &permissionCache{users: make(map[uint]*userAccess)}
+
+func (pc *permissionCache) get(userID uint) (*userAccess, bool) {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	access, ok := pc.users[userID]
+	return access, ok
+}
+
+func (pc *permissionCache) set(userID uint, access *userAccess) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.users[userID] = access
+}
+
+func (pc *permissionCache) invalidate(userID uint) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.users, userID)
+}
+
+func (pc *permissionCache) invalidateAll() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.users = make(map[uint]*userAccess)
+}
+
+// InvalidateUserAccess drops the cached permissions and scope of a user, e.g. after
+// their location changed
+func InvalidateUserAccess(userID uint) {
+	userPermissionCache.invalidate(userID)
+}
+
 func NewRoleService(r models.Role, db *gorm.DB) *RoleService {
 	return &RoleService{
 		Role: r,
@@ -58,7 +107,13 @@ func (rs *RoleService) UpdateRole(roleID, companyID uint, updates map[string]int
 		return errors.New("cannot update system roles")
 	}
 
-	return rs.DB.Model(&role).Updates(updates).Error
+	if err := rs.DB.Model(&role).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// location_bound may have changed for every holder of the role
+	userPermissionCache.invalidateAll()
+	return nil
 }
 
 // DeleteRole deletes a role
@@ -90,14 +145,35 @@ func (rs *RoleService) AssignPermissionsToRole(roleID uint, permissionIDs []uint
 		return err
 	}
 
-	// Clear existing permissions
-	rs.DB.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID)
+	var previousIDs []uint
+	if err := rs.DB.Table("role_permissions").Where("role_id = ?", roleID).
+		Order("permission_id").Pluck("permission_id", &previousIDs).Error; err != nil {
+		return err
+	}
+
+	err := rs.DB.Transaction(func(tx *gorm.DB) error {
+		// Clear existing permissions
+		if err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID).Error; err != nil {
+			return err
+		}
 
-	// Assign new permissions
-	for _, permID := range permissionIDs {
-		rs.DB.Exec("INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?)", roleID, permID)
+		// Assign new permissions
+		for _, permID := range permissionIDs {
+			if err := tx.Exec("INSERT INTO role_permissions (role_id, permission_id) VALUES (?, ?)", roleID, permID).Error; err != nil {
+				return err
+			}
+		}
+		// The raw statements above bypass the audit callbacks
+		return database.RecordChange(tx, "roles", roleID, "update", map[string]models.AuditChange{
+			"permission_ids": {Old: previousIDs, New: permissionIDs},
+		})
+	})
+	if err != nil {
+		return err
 	}
 
+	// Every user holding the role is affected
+	userPermissionCache.invalidateAll()
 	return nil
 }
 
@@ -112,6 +188,13 @@ func (rs *RoleService) GetAllPermissions() ([]models.Permission, error) {
 
 // AssignRoleToUser assigns a role to a user
 func (rs *RoleService) AssignRoleToUser(userID, roleID uint) error {
+	if err := rs.checkUser(userID); err != nil {
+		return err
+	}
+	if err := rs.checkExists(&models.Role{}, []uint{roleID}, "role not found"); err != nil {
+		return err
+	}
+
 	// Check if assignment already exists
 	var existing models.UserRole
 	if err := rs.DB.Where("user_id = ? AND role_id = ?", userID, roleID).
@@ -123,13 +206,26 @@ func (rs *RoleService) AssignRoleToUser(userID, roleID uint) error {
 		UserID: userID,
 		RoleID: roleID,
 	}
-	return rs.DB.Create(&userRole).Error
+	if err := rs.DB.Create(&userRole).Error; err != nil {
+		return err
+	}
+
+	userPermissionCache.invalidate(userID)
+	return nil
 }
 
 // RemoveRoleFromUser removes a role from a user
 func (rs *RoleService) RemoveRoleFromUser(userID, roleID uint) error {
-	return rs.DB.Where("user_id = ? AND role_id = ?", userID, roleID).
-		Delete(&models.UserRole{}).Error
+	if err := rs.checkUser(userID); err != nil {
+		return err
+	}
+	if err := rs.DB.Where("user_id = ? AND role_id = ?", userID, roleID).
+		Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+
+	userPermissionCache.invalidate(userID)
+	return nil
 }
 
 // GetUserRoles retrieves all roles for a user
@@ -162,6 +258,160 @@ func (rs *RoleService) CheckUserPermission(userID uint, resource, action string)
 	return count > 0, nil
 }
 
+// HasPermission reports whether a user holds resource:action through any of their roles.
+// Results are served from the in-memory cache when possible.
+func (rs *RoleService) HasPermission(userID uint, resource, action string) (bool, error) {
+	access, err := rs.userAccess(userID)
+	if err != nil {
+		return false, err
+	}
+	return access.permissions[resource+":"+action], nil
+}
+
+// GetAccessScope returns the locations a user is limited to. Admins and users without
+// a location-bound role are unrestricted.
+func (rs *RoleService) GetAccessScope(userID uint) (AccessScope, error) {
+	access, err := rs.userAccess(userID)
+	if err != nil {
+		return AccessScope{}, err
+	}
+	return access.scope, nil
+}
+
+func (rs *RoleService) userAccess(userID uint) (*userAccess, error) {
+	if access, ok := userPermissionCache.get(userID); ok {
+		return access, nil
+	}
+
+	var user models.User
+	if err := rs.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []models.Permission
+	err := rs.DB.Table("permissions").
+		Select("DISTINCT permissions.resource, permissions.action").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN user_roles ON user_roles.role_id = role_permissions.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	access := &userAccess{permissions: make(map[string]bool, len(rows))}
+	for _, row := range rows {
+		access.permissions[row.Resource+":"+row.Action] = true
+	}
+
+	if !IsAdminRole(user.Role) {
+		var boundRoles int64
+		if err := rs.DB.Model(&models.Role{}).
+			Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+			Where("user_roles.user_id = ? AND roles.location_bound = ?", userID, true).
+			Count(&boundRoles).Error; err != nil {
+			return nil, err
+		}
+		if boundRoles > 0 {
+			locationIDs, err := rs.GetUserLocations(userID)
+			if err != nil {
+				return nil, err
+			}
+			if user.LocationID != nil {
+				locationIDs = append(locationIDs, *user.LocationID)
+			}
+			access.scope = AccessScope{Restricted: true, LocationIDs: locationIDs}
+		}
+	}
+
+	userPermissionCache.set(userID, access)
+	return access, nil
+}
+
+// GetUserLocations returns the extra locations assigned to a user
+func (rs *RoleService) GetUserLocations(userID uint) ([]uint, error) {
+	if err := rs.checkUser(userID); err != nil {
+		return nil, err
+	}
+	var locationIDs []uint
+	if err := rs.DB.Model(&models.UserLocation{}).Where("user_id = ?", userID).
+		Pluck("location_id", &locationIDs).Error; err != nil {
+		return nil, err
+	}
+	return locationIDs, nil
+}
+
+// SetUserLocations replaces the extra locations assigned to a user
+func (rs *RoleService) SetUserLocations(userID uint, locationIDs []uint) error {
+	if err := rs.checkUser(userID); err != nil {
+		return err
+	}
+	if err := rs.checkExists(&models.Location{}, locationIDs, "location not found"); err != nil {
+		return err
+	}
+	err := rs.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserLocation{}).Error; err != nil {
+			return err
+		}
+		for _, locationID := range locationIDs {
+			if err := tx.Create(&models.UserLocation{UserID: userID, LocationID: locationID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	userPermissionCache.invalidate(userID)
+	return nil
+}
+
+func (rs *RoleService) checkUser(userID uint) error {
+	return rs.checkExists(&models.User{}, []uint{userID}, "user not found")
+}
+
+// checkExists fails unless every id is a record of model visible through rs.DB, which
+// for a request only holds the caller's company
+func (rs *RoleService) checkExists(model interface{}, ids []uint, notFound string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	unique := map[uint]bool{}
+	for _, id := range ids {
+		unique[id] = true
+	}
+	var count int64
+	if err := rs.DB.Model(model).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(unique) {
+		return errors.New(notFound)
+	}
+	return nil
+}
+
+// EnsurePermissions creates any of the given resource/action pairs that don't exist yet,
+// so every permission the API checks can be granted to a role
+func (rs *RoleService) EnsurePermissions(permissions []models.Permission) error {
+	for _, permission := range permissions {
+		var existing models.Permission
+		err := rs.DB.Where("resource = ? AND action = ?", permission.Resource, permission.Action).
+			First(&existing).Error
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := rs.DB.Create(&permission).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetUsersWithRoles retrieves all users with their assigned roles
 func (rs *RoleService) GetUsersWithRoles(companyID uint) ([]map[string]interface{}, error) {
 	var users []models.User
@@ -3,11 +3,14 @@ package services
 import (
 	"errors"
 	"fmt"
+	"log"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/gonext-tech/invoicing-system/backend/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SalesInvoiceService struct {
@@ -22,11 +25,13 @@ func NewSalesInvoiceService(model models.SalesInvoice, db *gorm.DB) *SalesInvoic
 	}
 }
 
-func (s *SalesInvoiceService) GetALL(filters map[string]string, limit, offset int) ([]models.SalesInvoice, int64, error) {
+// GetALL lists invoices within the caller's location scope
+func (s *SalesInvoiceService) GetALL(scope AccessScope, filters map[string]string, limit, offset int) ([]models.SalesInvoice, int64, error) {
 	var invoices []models.SalesInvoice
 	var total int64
 
 	query := s.db.Model(&models.SalesInvoice{}).
+		Scopes(scope.Filter("location_id")).
 		Preload("Customer").
 		Preload("Location").
 		Preload("CreatedByUser").
@@ -66,9 +71,10 @@ func (s *SalesInvoiceService) GetALL(filters map[string]string, limit, offset in
 	return invoices, total, nil
 }
 
-func (s *SalesInvoiceService) GetID(id string) (models.SalesInvoice, error) {
+// GetID returns an invoice if it belongs to the caller's location scope
+func (s *SalesInvoiceService) GetID(scope AccessScope, id string) (models.SalesInvoice, error) {
 	var invoice models.SalesInvoice
-	if err := s.db.Preload("Customer").
+	if err := s.db.Scopes(scope.Filter("location_id")).Preload("Customer").
 		Preload("Location").
 		Preload("CreatedByUser").
 		Preload("Items").
@@ -82,9 +88,18 @@ func (s *SalesInvoiceService) GetID(id string) (models.SalesInvoice, error) {
 	return invoice, nil
 }
 
-func (s *SalesInvoiceService) GetCount() (int64, error) {
+// CheckSalesInvoiceEditable rejects changes to invoices locked by a closed van settlement,
+// whether they come in online or from an offline device
+func CheckSalesInvoiceEditable(invoice models.SalesInvoice) error {
+	if invoice.SettlementID != nil {
+		return fmt.Errorf("invoice %s is locked by closed van settlement #%d", invoice.InvoiceNumber, *invoice.SettlementID)
+	}
+	return nil
+}
+
+func (s *SalesInvoiceService) GetCount(scope AccessScope) (int64, error) {
 	var count int64
-	err := s.db.Model(&models.SalesInvoice{}).Count(&count).Error
+	err := s.db.Model(&models.SalesInvoice{}).Scopes(scope.Filter("location_id")).Count(&count).Error
 	return count, err
 }
 
@@ -92,20 +107,148 @@ func (s *SalesInvoiceService) Create(invoice models.SalesInvoice) (models.SalesI
 	// Generate invoice number
 	invoice.InvoiceNumber = s.generateInvoiceNumber()
 
-	if err := s.db.Create(&invoice).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invoice).Error; err != nil {
+			return err
+		}
+		if err := postSalesInvoiceJournal(tx, invoice.ID); err != nil {
+			return err
+		}
+		return PublishEvent(tx, WebhookInvoiceCreated, map[string]interface{}{"invoice_type": "sales", "invoice": invoice})
+	})
+	if err != nil {
 		return invoice, err
 	}
-	return s.GetID(fmt.Sprintf("%d", invoice.ID))
+	publishLive(s.db, LiveEvent{
+		Type:        LiveSaleCreated,
+		CompanyID:   invoice.CompanyID,
+		LocationIDs: []uint{invoice.LocationID},
+		Data: map[string]interface{}{
+			"id":             invoice.ID,
+			"invoice_number": invoice.InvoiceNumber,
+			"customer_id":    invoice.CustomerID,
+			"location_id":    invoice.LocationID,
+			"total_amount":   invoice.TotalAmount,
+			"paid_amount":    invoice.PaidAmount,
+			"created_at":     invoice.CreatedAt,
+		},
+	})
+	s.notifyCreditLimit(invoice)
+	return s.GetID(AccessScope{}, fmt.Sprintf("%d", invoice.ID))
+}
+
+// CreateSale creates a sales invoice together with its stock deductions and movements
+// and, when payment isn't nil, the payment taken at the sale, all in one transaction.
+// The invoice's location must have every line in stock.
+func (s *SalesInvoiceService) CreateSale(invoice models.SalesInvoice, payment *models.Payment) (models.SalesInvoice, error) {
+	var created models.SalesInvoice
+	err := Transaction(s.db, func(tx *gorm.DB) error {
+		stocks := NewStockService(models.Stock{}, tx)
+		locationType, locationID := stocks.GetLocationTypeAndID(invoice.LocationID)
+
+		required := map[uint]float64{}
+		for _, item := range invoice.Items {
+			required[item.ProductID] += item.Quantity
+		}
+		for _, item := range invoice.Items {
+			quantity, ok := required[item.ProductID]
+			if !ok {
+				continue
+			}
+			delete(required, item.ProductID)
+			// Locked until the sale commits, so concurrent sales can't both pass the check
+			var stock models.Stock
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("product_id = ? AND location_type = ? AND location_id = ?", item.ProductID, locationType, locationID).
+				Limit(1).Find(&stock).Error; err != nil {
+				return err
+			}
+			if stock.ID == 0 {
+				return fmt.Errorf("insufficient stock for product ID %d: no stock available", item.ProductID)
+			}
+			if stock.Quantity < quantity {
+				return fmt.Errorf("insufficient stock for product ID %d: available %.2f, required %.2f", item.ProductID, stock.Quantity, quantity)
+			}
+		}
+
+		var err error
+		created, err = NewSalesInvoiceService(s.model, tx).Create(invoice)
+		if err != nil {
+			return err
+		}
+
+		notes := fmt.Sprintf("Sales Invoice #%d", created.ID)
+		for _, item := range invoice.Items {
+			if err := stocks.UpdateStock(item.ProductID, locationType, locationID, -item.Quantity); err != nil {
+				return err
+			}
+			if err := stocks.CreateMovement(item.ProductID, "sale", item.Quantity, locationType, locationID,
+				"", 0, notes, invoice.CreatedBy); err != nil {
+				return err
+			}
+		}
+
+		if payment == nil {
+			return nil
+		}
+		payment.InvoiceID = created.ID
+		_, err = NewPaymentService(models.Payment{}, tx).Create(*payment)
+		return err
+	})
+	if err != nil {
+		return models.SalesInvoice{}, err
+	}
+	return created, nil
+}
+
+// notifyCreditLimit tells whoever manages customers when a new invoice takes the
+// customer's unpaid balance over their credit limit
+func (s *SalesInvoiceService) notifyCreditLimit(invoice models.SalesInvoice) {
+	if invoice.CustomerID == nil {
+		return
+	}
+	var customer models.Customer
+	if err := s.db.First(&customer, *invoice.CustomerID).Error; err != nil || customer.CreditLimit <= 0 {
+		return
+	}
+	var balance float64
+	if err := s.db.Model(&models.SalesInvoice{}).
+		Where("customer_id = ? AND deleted_at IS NULL", customer.ID).
+		Select("COALESCE(SUM(total_amount - paid_amount), 0)").Scan(&balance).Error; err != nil {
+		log.Printf("[SALES INVOICE] Could not check credit limit of customer %d: %v", customer.ID, err)
+		return
+	}
+	previous := balance - (invoice.TotalAmount - invoice.PaidAmount)
+	if balance <= customer.CreditLimit || previous > customer.CreditLimit {
+		return
+	}
+
+	notifyHolders(s.db, "customers", "update", models.Notification{
+		Event: EventCreditLimitExceeded,
+		Title: fmt.Sprintf("%s is over their credit limit", customer.Name),
+		Body: fmt.Sprintf("Invoice %s took the unpaid balance of %s to %.2f; the credit limit is %.2f.",
+			invoice.InvoiceNumber, customer.Name, balance, customer.CreditLimit),
+		Link: fmt.Sprintf("/customers/%d", customer.ID),
+		Data: map[string]interface{}{
+			"customer_id":  customer.ID,
+			"invoice_id":   invoice.ID,
+			"balance":      balance,
+			"credit_limit": customer.CreditLimit,
+		},
+	})
 }
 
 func (s *SalesInvoiceService) Update(invoice models.SalesInvoice) (models.SalesInvoice, error) {
 	if err := s.db.Save(&invoice).Error; err != nil {
 		return invoice, err
 	}
-	return s.GetID(fmt.Sprintf("%d", invoice.ID))
+	return s.GetID(AccessScope{}, fmt.Sprintf("%d", invoice.ID))
 }
 
-func (s *SalesInvoiceService) UpdateItem(itemID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error {
+// UpdateItem reprices a line. Promotions are only run when the invoice is created, so the
+// line loses the one it had.
+func (s *SalesInvoiceService) UpdateItem(itemID uint, productID uint, unit LineUnit, unitPrice, discountPercent float64, pricing LinePrice) error {
+	quantity := unit.BaseQuantity()
 	var item models.SalesInvoiceItem
 	if err := s.db.First(&item, itemID).Error; err != nil {
 		return err
@@ -122,11 +265,19 @@ func (s *SalesInvoiceService) UpdateItem(itemID uint, productID uint, quantity f
 	item.UnitPrice = unitPrice
 	item.DiscountPercent = discountPercent
 	item.Total = newTotal
+	item.Unit = unit.Name
+	item.UnitQuantity = unit.Quantity
+	item.ListPrice = pricing.ListPrice
+	item.PriceFlag = pricing.Flag
+	item.PromotionID = nil
+	item.Promotion = nil
+	item.PromotionDiscount = 0
 
 	return s.db.Save(&item).Error
 }
 
-func (s *SalesInvoiceService) AddItem(invoiceID uint, productID uint, quantity float64, unitPrice, discountPercent float64) error {
+func (s *SalesInvoiceService) AddItem(invoiceID uint, productID uint, unit LineUnit, unitPrice, discountPercent float64, pricing LinePrice) error {
+	quantity := unit.BaseQuantity()
 	// Calculate total for the new item
 	subtotal := quantity * unitPrice
 	discountAmount := subtotal * discountPercent / 100
@@ -140,6 +291,10 @@ func (s *SalesInvoiceService) AddItem(invoiceID uint, productID uint, quantity f
 		UnitPrice:       unitPrice,
 		DiscountPercent: discountPercent,
 		Total:           newTotal,
+		Unit:            unit.Name,
+		UnitQuantity:    unit.Quantity,
+		ListPrice:       pricing.ListPrice,
+		PriceFlag:       pricing.Flag,
 	}
 
 	return s.db.Create(&newItem).Error
@@ -167,7 +322,12 @@ func (s *SalesInvoiceService) RecalculateTotals(invoiceID uint) error {
 		invoice.PaymentStatus = "unpaid"
 	}
 
-	return s.db.Save(&invoice).Error
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&invoice).Error; err != nil {
+			return err
+		}
+		return postSalesInvoiceJournal(tx, invoice.ID)
+	})
 }
 
 func (s *SalesInvoiceService) UpdatePaymentStatus(id uint, paidAmount float64) error {
@@ -195,12 +355,12 @@ func (s *SalesInvoiceService) generateInvoiceNumber() string {
 	return fmt.Sprintf("SI-%s-%05d", time.Now().Format("200601"), count+1)
 }
 
-func (s *SalesInvoiceService) GetPaginated(limit, page int, orderBy, sortBy string, filters map[string]string) (PaginationResponse, error) {
+func (s *SalesInvoiceService) GetPaginated(scope AccessScope, limit, page int, orderBy, sortBy string, filters map[string]string) (PaginationResponse, error) {
 	var invoices []models.SalesInvoice
 	var total int64
 
 	offset := (page - 1) * limit
-	invoices, total, err := s.GetALL(filters, limit, offset)
+	invoices, total, err := s.GetALL(scope, filters, limit, offset)
 	if err != nil {
 		return PaginationResponse{}, err
 	}
@@ -217,9 +377,15 @@ func (s *SalesInvoiceService) GetPaginated(limit, page int, orderBy, sortBy stri
 }
 
 func (s *SalesInvoiceService) Delete(id string) error {
-	// Soft delete the invoice
-	if err := s.db.Delete(&models.SalesInvoice{}, id).Error; err != nil {
+	invoiceID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
 		return err
 	}
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := removeJournal(tx, "sales_invoice", uint(invoiceID)); err != nil {
+			return err
+		}
+		// Soft delete the invoice
+		return tx.Delete(&models.SalesInvoice{}, id).Error
+	})
 }
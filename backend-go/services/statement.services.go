@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+type StatementService struct {
+	db *gorm.DB
+}
+
+func NewStatementService(db *gorm.DB) *StatementService {
+	return &StatementService{
+		db: db,
+	}
+}
+
+// GetCustomerStatement builds a running-balance ledger of what the customer owes us.
+// Invoices and refunds are debits, payments are credits; allocations are listed for reference only.
+func (s *StatementService) GetCustomerStatement(customerID uint, fromDate, toDate time.Time) (models.Statement, error) {
+	var customer models.Customer
+	if err := s.db.First(&customer, customerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Statement{}, errors.New("customer not found")
+		}
+		return models.Statement{}, err
+	}
+
+	end := endOfDay(toDate)
+	var lines []models.StatementLine
+
+	var invoices []models.SalesInvoice
+	if err := s.db.Where("customer_id = ? AND created_at <= ?", customerID, end).
+		Find(&invoices).Error; err != nil {
+		return models.Statement{}, err
+	}
+	invoiceNumbers := make(map[uint]string)
+	for _, invoice := range invoices {
+		invoiceNumbers[invoice.ID] = invoice.InvoiceNumber
+		lines = append(lines, models.StatementLine{
+			Date:        invoice.CreatedAt,
+			EntryType:   "invoice",
+			Reference:   invoice.InvoiceNumber,
+			Description: "Sales invoice",
+			ReferenceID: invoice.ID,
+			Debit:       invoice.TotalAmount,
+		})
+	}
+
+	var payments []models.Payment
+	if err := s.db.Where("created_at <= ?", end).
+		Where(s.db.Where("customer_id = ?", customerID).
+			Or("invoice_type = ? AND invoice_id IN (?)", "sales",
+				s.db.Model(&models.SalesInvoice{}).Select("id").Where("customer_id = ?", customerID))).
+		Find(&payments).Error; err != nil {
+		return models.Statement{}, err
+	}
+	for _, payment := range payments {
+		lines = append(lines, paymentLine(payment, false))
+	}
+
+	allocationLines, err := s.allocationLines(payments, "sales", invoiceNumbers, end)
+	if err != nil {
+		return models.Statement{}, err
+	}
+	lines = append(lines, allocationLines...)
+
+	statement := buildStatement(lines, fromDate, end, false)
+	statement.PartyType = "customer"
+	statement.PartyID = customer.ID
+	statement.PartyName = customer.Name
+	return statement, nil
+}
+
+// GetVendorStatement builds a running-balance ledger of what we owe the vendor.
+// Purchase invoices and vendor refunds are credits, payments and approved credit notes are debits.
+func (s *StatementService) GetVendorStatement(vendorID uint, fromDate, toDate time.Time) (models.Statement, error) {
+	var vendor models.Vendor
+	if err := s.db.First(&vendor, vendorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Statement{}, errors.New("vendor not found")
+		}
+		return models.Statement{}, err
+	}
+
+	end := endOfDay(toDate)
+	var lines []models.StatementLine
+
+	var invoices []models.PurchaseInvoice
+	if err := s.db.Where("vendor_id = ? AND invoice_date <= ?", vendorID, end).
+		Find(&invoices).Error; err != nil {
+		return models.Statement{}, err
+	}
+	invoiceNumbers := make(map[uint]string)
+	for _, invoice := range invoices {
+		invoiceNumbers[invoice.ID] = invoice.InvoiceNumber
+		lines = append(lines, models.StatementLine{
+			Date:        invoice.InvoiceDate,
+			EntryType:   "invoice",
+			Reference:   invoice.InvoiceNumber,
+			Description: "Purchase invoice",
+			ReferenceID: invoice.ID,
+			Credit:      invoice.TotalAmount,
+		})
+	}
+
+	var payments []models.Payment
+	if err := s.db.Where("created_at <= ?", end).
+		Where(s.db.Where("vendor_id = ?", vendorID).
+			Or("invoice_type = ? AND invoice_id IN (?)", "purchase",
+				s.db.Model(&models.PurchaseInvoice{}).Select("id").Where("vendor_id = ?", vendorID))).
+		Find(&payments).Error; err != nil {
+		return models.Statement{}, err
+	}
+	for _, payment := range payments {
+		lines = append(lines, paymentLine(payment, true))
+	}
+
+	allocationLines, err := s.allocationLines(payments, "purchase", invoiceNumbers, end)
+	if err != nil {
+		return models.Statement{}, err
+	}
+	lines = append(lines, allocationLines...)
+
+	var creditNotes []models.CreditNote
+	if err := s.db.Where("vendor_id = ? AND status = ? AND deleted_at IS NULL AND credit_note_date <= ?", vendorID, "approved", end).
+		Find(&creditNotes).Error; err != nil {
+		return models.Statement{}, err
+	}
+	for _, creditNote := range creditNotes {
+		description := "Credit note"
+		if creditNote.PurchaseInvoiceID != nil {
+			if number, ok := invoiceNumbers[*creditNote.PurchaseInvoiceID]; ok {
+				description = "Credit note against " + number
+			}
+		}
+		lines = append(lines, models.StatementLine{
+			Date:        creditNote.CreditNoteDate,
+			EntryType:   "credit_note",
+			Reference:   creditNote.CreditNoteNumber,
+			Description: description,
+			ReferenceID: creditNote.ID,
+			Debit:       creditNote.TotalAmount,
+		})
+	}
+
+	statement := buildStatement(lines, fromDate, end, true)
+	statement.PartyType = "vendor"
+	statement.PartyID = vendor.ID
+	statement.PartyName = vendor.Name
+	return statement, nil
+}
+
+// allocationLines lists how each multi-invoice payment was spread over invoices.
+// They carry no amount so the running balance is not counted twice.
+func (s *StatementService) allocationLines(payments []models.Payment, invoiceType string, invoiceNumbers map[uint]string, end time.Time) ([]models.StatementLine, error) {
+	if len(payments) == 0 {
+		return nil, nil
+	}
+	paymentIDs := make([]uint, 0, len(payments))
+	for _, payment := range payments {
+		paymentIDs = append(paymentIDs, payment.ID)
+	}
+
+	var allocations []models.PaymentAllocation
+	if err := s.db.Where("payment_id IN ? AND invoice_type = ? AND allocation_date <= ?", paymentIDs, invoiceType, end).
+		Find(&allocations).Error; err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.StatementLine, 0, len(allocations))
+	for _, allocation := range allocations {
+		invoiceNumber := invoiceNumbers[allocation.InvoiceID]
+		if invoiceNumber == "" {
+			invoiceNumber = fmt.Sprintf("#%d", allocation.InvoiceID)
+		}
+		lines = append(lines, models.StatementLine{
+			Date:        allocation.AllocationDate,
+			EntryType:   "allocation",
+			Reference:   fmt.Sprintf("PAY-%d", allocation.PaymentID),
+			Description: fmt.Sprintf("Allocated %.2f to %s", allocation.AllocatedAmount, invoiceNumber),
+			ReferenceID: allocation.ID,
+		})
+	}
+	return lines, nil
+}
+
+// paymentLine converts a payment into a statement line. Negative amounts are refunds.
+func paymentLine(payment models.Payment, vendor bool) models.StatementLine {
+	line := models.StatementLine{
+		Date:        payment.CreatedAt,
+		EntryType:   "payment",
+		Reference:   fmt.Sprintf("PAY-%d", payment.ID),
+		Description: "Payment (" + payment.PaymentMethod + ")",
+		ReferenceID: payment.ID,
+	}
+	if payment.ReferenceNumber != nil && *payment.ReferenceNumber != "" {
+		line.Description += " ref " + *payment.ReferenceNumber
+	}
+
+	amount := payment.Amount
+	if amount < 0 {
+		line.EntryType = "refund"
+		line.Description = "Refund (" + payment.PaymentMethod + ")"
+		amount = -amount
+		if vendor {
+			line.Credit = amount
+		} else {
+			line.Debit = amount
+		}
+		return line
+	}
+
+	if vendor {
+		line.Debit = amount
+	} else {
+		line.Credit = amount
+	}
+	return line
+}
+
+// buildStatement sorts the lines, folds everything before fromDate into the opening
+// balance and computes the running balance for the remaining lines.
+func buildStatement(lines []models.StatementLine, fromDate, toDate time.Time, creditNormal bool) models.Statement {
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Date.Before(lines[j].Date)
+	})
+
+	statement := models.Statement{
+		FromDate: fromDate,
+		ToDate:   toDate,
+		Lines:    []models.StatementLine{},
+	}
+
+	balance := 0.0
+	for _, line := range lines {
+		effect := line.Debit - line.Credit
+		if creditNormal {
+			effect = -effect
+		}
+		if line.Date.Before(fromDate) {
+			balance += effect
+			statement.OpeningBalance = roundAmount(balance)
+			continue
+		}
+		balance += effect
+		line.Balance = roundAmount(balance)
+		statement.TotalDebit += line.Debit
+		statement.TotalCredit += line.Credit
+		statement.Lines = append(statement.Lines, line)
+	}
+
+	statement.TotalDebit = roundAmount(statement.TotalDebit)
+	statement.TotalCredit = roundAmount(statement.TotalCredit)
+	statement.ClosingBalance = roundAmount(balance)
+	return statement
+}
+
+// roundAmount rounds to 2 decimals to avoid floating-point noise in balances
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// endOfDay returns the last instant of the given day
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+}
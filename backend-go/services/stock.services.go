@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
 
+	"github.com/gonext-tech/invoicing-system/backend/database"
 	"github.com/gonext-tech/invoicing-system/backend/models"
 	"gorm.io/gorm"
 )
@@ -22,7 +24,7 @@ func NewStockService(model models.Stock, db *gorm.DB) *StockService {
 }
 
 // GetWarehouseStock returns stock for warehouse location
-func (s *StockService) GetWarehouseStock() ([]map[string]interface{}, error) {
+func (s *StockService) GetWarehouseStock(scope AccessScope) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
 	// Query all stock records where location_type = 'warehouse'
@@ -32,6 +34,7 @@ func (s *StockService) GetWarehouseStock() ([]map[string]interface{}, error) {
 		Joins("LEFT JOIN products ON stocks.product_id = products.id").
 		Joins("LEFT JOIN categories ON products.category_id = categories.id").
 		Where("stocks.location_type = ?", "warehouse").
+		Scopes(scope.Filter("stocks.location_id")).
 		Scan(&results).Error
 
 	if err != nil {
@@ -43,7 +46,7 @@ func (s *StockService) GetWarehouseStock() ([]map[string]interface{}, error) {
 }
 
 // GetVanStock returns stock for a specific van
-func (s *StockService) GetVanStock(vanID string) ([]map[string]interface{}, error) {
+func (s *StockService) GetVanStock(scope AccessScope, vanID string) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
 	err := s.db.Table("stocks").
@@ -52,6 +55,7 @@ func (s *StockService) GetVanStock(vanID string) ([]map[string]interface{}, erro
 		Joins("LEFT JOIN categories ON products.category_id = categories.id").
 		Where("stocks.location_type = ?", "van").
 		Where("stocks.location_id = ?", vanID).
+		Scopes(scope.Filter("stocks.location_id")).
 		Scan(&results).Error
 
 	if err != nil {
@@ -61,9 +65,13 @@ func (s *StockService) GetVanStock(vanID string) ([]map[string]interface{}, erro
 }
 
 // GetLocationStock returns stock for a specific location
-func (s *StockService) GetLocationStock(locationID string) ([]map[string]interface{}, error) {
+func (s *StockService) GetLocationStock(scope AccessScope, locationID string) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
+	if id, err := strconv.ParseUint(locationID, 10, 64); err != nil || !scope.Allows(uint(id)) {
+		return nil, ErrOutsideScope
+	}
+
 	log.Printf("[STOCK SERVICE] GetLocationStock called for location ID: %s", locationID)
 
 	// Get the location type from the database
@@ -101,7 +109,7 @@ func (s *StockService) GetLocationStock(locationID string) ([]map[string]interfa
 }
 
 // GetAllStockByLocation returns all stock grouped by location
-func (s *StockService) GetAllStockByLocation() ([]map[string]interface{}, error) {
+func (s *StockService) GetAllStockByLocation(scope AccessScope) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
 	err := s.db.Table("stocks").
@@ -109,6 +117,7 @@ func (s *StockService) GetAllStockByLocation() ([]map[string]interface{}, error)
 		Joins("LEFT JOIN products ON stocks.product_id = products.id").
 		Joins("LEFT JOIN categories ON products.category_id = categories.id").
 		Joins("LEFT JOIN locations ON stocks.location_id = locations.id").
+		Scopes(scope.Filter("stocks.location_id")).
 		Scan(&results).Error
 
 	if err != nil {
@@ -119,8 +128,17 @@ func (s *StockService) GetAllStockByLocation() ([]map[string]interface{}, error)
 	return results, nil
 }
 
-// GetInventorySummary returns stock grouped by product with location details
-func (s *StockService) GetInventorySummary() ([]map[string]interface{}, error) {
+// GetInventorySummary returns stock grouped by product with location details, for a
+// category and its subcategories when categoryID isn't 0
+func (s *StockService) GetInventorySummary(scope AccessScope, categoryID uint) ([]map[string]interface{}, error) {
+	// Restricted users only see quantities held at their own locations
+	stockJoin := "LEFT JOIN stocks s ON p.id = s.product_id"
+	var args []interface{}
+	if scope.Restricted {
+		stockJoin += " AND s.location_id IN (?)"
+		args = append(args, append([]uint{0}, scope.LocationIDs...))
+	}
+
 	query := `
 		SELECT 
 			p.id as product_id,
@@ -139,16 +157,33 @@ func (s *StockService) GetInventorySummary() ([]map[string]interface{}, error) {
 				) SEPARATOR '|'
 			) as location_quantities
 		FROM products p
-		LEFT JOIN stocks s ON p.id = s.product_id
+		` + stockJoin + `
 		LEFT JOIN categories c ON p.category_id = c.id
 		LEFT JOIN locations l ON s.location_id = l.id
-		WHERE p.is_active = 1
+		WHERE p.company_id = ?
+		AND p.is_active = 1
+	`
+
+	// Raw SQL isn't scoped by the tenant callbacks
+	companyID, _ := database.CompanyFromContext(s.db.Statement.Context)
+	args = append(args, companyID)
+
+	if categoryID != 0 {
+		categories, err := CategoryWithDescendants(s.db, categoryID)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND p.category_id IN (?)"
+		args = append(args, categories)
+	}
+
+	query += `
 		GROUP BY p.id, p.sku, p.name_en, p.name_ar, p.unit, p.min_stock_level, c.name_en, c.name_ar
 		ORDER BY p.name_en
 	`
 
 	var results []map[string]interface{}
-	err := s.db.Raw(query).Scan(&results).Error
+	err := s.db.Raw(query, args...).Scan(&results).Error
 	if err != nil {
 		return nil, err
 	}
@@ -171,17 +206,20 @@ func (s *StockService) CreateMovement(productID uint, movementType string, quant
 		CreatedBy:        &createdBy,
 	}
 
-	log.Printf("[STOCK SERVICE] Creating movement - Product: %d, Type: %s, Qty: %d, From: %s(%d), To: %s(%d)",
+	log.Printf("[STOCK SERVICE] Creating movement - Product: %d, Type: %s, Qty: %.2f, From: %s(%d), To: %s(%d)",
 		productID, movementType, quantity, fromLocationType, fromLocationID, toLocationType, toLocationID)
 
 	return s.db.Create(&movement).Error
 }
 
 // GetMovements returns stock movements with filters
-func (s *StockService) GetMovements(productID, movementType, fromDate, toDate string, limit int) ([]models.StockMovement, error) {
+func (s *StockService) GetMovements(scope AccessScope, productID, movementType, fromDate, toDate string, limit int) ([]models.StockMovement, error) {
 	var movements []models.StockMovement
 
-	query := s.db.Model(&models.StockMovement{}).Preload("Product").Preload("CreatedByUser")
+	query := s.db.Model(&models.StockMovement{}).
+		Scopes(scope.Filter("from_location_id", "to_location_id")).
+		Preload("Product").
+		Preload("CreatedByUser")
 
 	if productID != "" {
 		query = query.Where("product_id = ?", productID)
@@ -225,40 +263,130 @@ func (s *StockService) UpdateStock(productID uint, locationType string, location
 				LocationID:   locationID,
 				Quantity:     quantity,
 			}
-			return s.db.Create(&stock).Error
+			if err := s.db.Create(&stock).Error; err != nil {
+				return err
+			}
+			publishStockChange(s.db, stock, quantity)
+			return nil
 		}
 		return err
 	}
 
 	// Update existing stock
+	previous := stock.Quantity
 	stock.Quantity += quantity
-	return s.db.Save(&stock).Error
+	var low *models.Product
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&stock).Error; err != nil {
+			return err
+		}
+		var err error
+		low, err = publishLowStock(tx, stock, previous)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	publishStockChange(s.db, stock, quantity)
+	s.notifyLowStock(low, stock)
+	return nil
 }
 
-// SetStock sets stock to exact quantity
+// SetStock sets stock to exact quantity and posts the difference to the general ledger
 func (s *StockService) SetStock(productID uint, locationType string, locationID uint, quantity float64) error {
 	var stock models.Stock
-
-	err := s.db.Where("product_id = ? AND location_type = ? AND location_id = ?",
-		productID, locationType, locationID).First(&stock).Error
-
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// Create new stock record
-			stock = models.Stock{
-				ProductID:    productID,
-				LocationType: locationType,
-				LocationID:   locationID,
-				Quantity:     quantity,
+	var change float64
+	var low *models.Product
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("product_id = ? AND location_type = ? AND location_id = ?",
+			productID, locationType, locationID).First(&stock).Error
+
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				// Create new stock record
+				stock = models.Stock{
+					ProductID:    productID,
+					LocationType: locationType,
+					LocationID:   locationID,
+					Quantity:     quantity,
+				}
+				if err := tx.Create(&stock).Error; err != nil {
+					return err
+				}
+				change = quantity
+				return postStockAdjustmentJournal(tx, stock, quantity)
 			}
-			return s.db.Create(&stock).Error
+			return err
 		}
+
+		// Set exact quantity
+		previous := stock.Quantity
+		change = quantity - stock.Quantity
+		stock.Quantity = quantity
+		if err := tx.Save(&stock).Error; err != nil {
+			return err
+		}
+		if err := postStockAdjustmentJournal(tx, stock, change); err != nil {
+			return err
+		}
+		low, err = publishLowStock(tx, stock, previous)
+		return err
+	})
+	if err != nil {
 		return err
 	}
+	publishStockChange(s.db, stock, change)
+	s.notifyLowStock(low, stock)
+	return nil
+}
+
+// publishLowStock publishes stock.low when a change takes a product down to its minimum
+// level at a location and returns the product. Only the change that crosses the level
+// counts; otherwise the product is nil.
+func publishLowStock(tx *gorm.DB, stock models.Stock, previous float64) (*models.Product, error) {
+	var product models.Product
+	if err := tx.Select("id", "sku", "name_en", "min_stock_level").First(&product, stock.ProductID).Error; err != nil {
+		return nil, err
+	}
+	minimum := float64(product.MinStockLevel)
+	if minimum <= 0 || previous <= minimum || stock.Quantity > minimum {
+		return nil, nil
+	}
+	event := map[string]interface{}{
+		"product_id":    product.ID,
+		"sku":           product.SKU,
+		"location_type": stock.LocationType,
+		"location_id":   stock.LocationID,
+		"quantity":      stock.Quantity,
+		"minimum":       product.MinStockLevel,
+	}
+	if err := PublishEvent(tx, WebhookStockLow, event); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// notifyLowStock tells whoever manages stock that product went low, as found by
+// publishLowStock
+func (s *StockService) notifyLowStock(product *models.Product, stock models.Stock) {
+	if product == nil {
+		return
+	}
 
-	// Set exact quantity
-	stock.Quantity = quantity
-	return s.db.Save(&stock).Error
+	notifyHolders(s.db, "stock", "update", models.Notification{
+		Event: EventStockLow,
+		Title: fmt.Sprintf("Low stock: %s", product.NameEn),
+		Body: fmt.Sprintf("%s (%s) is down to %.2f at %s #%d; the minimum is %d.",
+			product.NameEn, product.SKU, stock.Quantity, stock.LocationType, stock.LocationID, product.MinStockLevel),
+		Link: fmt.Sprintf("/stock/location/%d", stock.LocationID),
+		Data: map[string]interface{}{
+			"product_id":    product.ID,
+			"location_type": stock.LocationType,
+			"location_id":   stock.LocationID,
+			"quantity":      stock.Quantity,
+			"minimum":       product.MinStockLevel,
+		},
+	})
 }
 
 // GetLocationTypeAndID determines the correct location_type for stock operations
@@ -290,7 +418,7 @@ func (s *StockService) GetProductStock(productID uint, locationType string, loca
 	s.db.Where("product_id = ?", productID).Find(&allStocks)
 	log.Printf("[STOCK SERVICE] Found %d stock record(s) for Product ID %d:", len(allStocks), productID)
 	for _, s := range allStocks {
-		log.Printf("  - Location Type: %s, Location ID: %d, Quantity: %d", s.LocationType, s.LocationID, s.Quantity)
+		log.Printf("  - Location Type: %s, Location ID: %d, Quantity: %.2f", s.LocationType, s.LocationID, s.Quantity)
 	}
 
 	var stock models.Stock
@@ -307,7 +435,7 @@ func (s *StockService) GetProductStock(productID uint, locationType string, loca
 		return nil, err
 	}
 
-	log.Printf("[STOCK SERVICE] Stock found at requested location - Quantity: %d", stock.Quantity)
+	log.Printf("[STOCK SERVICE] Stock found at requested location - Quantity: %.2f", stock.Quantity)
 	return &stock, nil
 }
 
@@ -0,0 +1,619 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// syncCursorOverlap makes the next pull start a little before this one did, so changes
+// still committing while it ran aren't skipped. Clients upsert, so repeats are harmless.
+const syncCursorOverlap = 5 * time.Second
+
+// SyncPullRequest asks for what changed since Cursor (everything when it is empty). The
+// Products, Customers and Stock flags say which sections the caller may receive; stock
+// is that of LocationID, usually the van.
+type SyncPullRequest struct {
+	DeviceID   string
+	Cursor     string
+	LocationID *uint
+	Products   bool
+	Customers  bool
+	Stock      bool
+	IPAddress  string
+}
+
+// SyncPullResponse carries the changed records with their prices and stock levels. A
+// changed price list comes with all of its items. Cursor is passed back on the next
+// pull; Deleted lists the ids removed since the last one.
+type SyncPullResponse struct {
+	Cursor     string             `json:"cursor"`
+	Full       bool               `json:"full"`
+	Products   []models.Product   `json:"products"`
+	PriceLists []models.PriceList `json:"price_lists"`
+	Customers  []models.Customer  `json:"customers"`
+	Stock      []models.Stock     `json:"stock"`
+	Deleted    map[string][]uint  `json:"deleted"`
+}
+
+// SyncInvoiceItem is a line of a sale made offline; Quantity and UnitPrice are in Unit,
+// a pack of the product or its base unit when empty
+type SyncInvoiceItem struct {
+	ProductID       uint    `json:"product_id"`
+	Unit            string  `json:"unit"`
+	Quantity        float64 `json:"quantity"`
+	UnitPrice       float64 `json:"unit_price"`
+	DiscountPercent float64 `json:"discount_percent"`
+}
+
+// SyncInvoice is a sale made offline. UUID is generated on the device and makes the push
+// safe to repeat.
+type SyncInvoice struct {
+	UUID          string            `json:"uuid"`
+	CustomerID    *uint             `json:"customer_id"`
+	PaymentMethod *string           `json:"payment_method"`
+	PaidAmount    float64           `json:"paid_amount"`
+	Notes         *string           `json:"notes"`
+	CreatedAt     *time.Time        `json:"created_at"`
+	Items         []SyncInvoiceItem `json:"items"`
+}
+
+// SyncPayment is a payment collected offline, against a synced invoice (InvoiceID) or one
+// pushed from the device (InvoiceUUID)
+type SyncPayment struct {
+	UUID            string     `json:"uuid"`
+	InvoiceID       *uint      `json:"invoice_id"`
+	InvoiceUUID     string     `json:"invoice_uuid"`
+	Amount          float64    `json:"amount"`
+	PaymentMethod   string     `json:"payment_method"`
+	ReferenceNumber *string    `json:"reference_number"`
+	Notes           *string    `json:"notes"`
+	CreatedAt       *time.Time `json:"created_at"`
+}
+
+// SyncPushRequest is a batch recorded offline at LocationID. Invoices are applied before
+// payments so a payment can refer to an invoice of the same batch.
+type SyncPushRequest struct {
+	DeviceID   string        `json:"device_id"`
+	LocationID uint          `json:"location_id"`
+	Invoices   []SyncInvoice `json:"invoices"`
+	Payments   []SyncPayment `json:"payments"`
+}
+
+// SyncResult is the outcome of one pushed record. Status is applied, conflict (applied,
+// and flagged for review) or rejected; rejected records aren't remembered and may be
+// pushed again once fixed. Duplicate is set when the UUID had been pushed before.
+type SyncResult struct {
+	UUID      string   `json:"uuid"`
+	Kind      string   `json:"kind"`
+	Status    string   `json:"status"`
+	RecordID  uint     `json:"record_id,omitempty"`
+	Duplicate bool     `json:"duplicate"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+const syncRejected = "rejected"
+
+type SyncPushResponse struct {
+	Results    []SyncResult `json:"results"`
+	Applied    int          `json:"applied"`
+	Duplicates int          `json:"duplicates"`
+	Conflicts  int          `json:"conflicts"`
+	Rejected   int          `json:"rejected"`
+}
+
+// SyncService is the offline sync API of the mobile van app: delta pulls of what the
+// app needs to sell offline, and idempotent pushes of what it sold
+type SyncService struct {
+	DB *gorm.DB
+}
+
+func NewSyncService(db *gorm.DB) *SyncService {
+	return &SyncService{
+		DB: db,
+	}
+}
+
+// Pull returns the records changed since the request's cursor
+func (s *SyncService) Pull(user models.User, request SyncPullRequest) (SyncPullResponse, error) {
+	if request.DeviceID == "" {
+		return SyncPullResponse{}, errors.New("device_id is required")
+	}
+	started := time.Now()
+	response := SyncPullResponse{
+		Cursor:     strconv.FormatInt(started.Add(-syncCursorOverlap).UnixMilli(), 10),
+		Full:       request.Cursor == "",
+		Products:   []models.Product{},
+		PriceLists: []models.PriceList{},
+		Customers:  []models.Customer{},
+		Stock:      []models.Stock{},
+		Deleted:    map[string][]uint{},
+	}
+	var since time.Time
+	if !response.Full {
+		millis, err := strconv.ParseInt(request.Cursor, 10, 64)
+		if err != nil {
+			return SyncPullResponse{}, errors.New("invalid cursor")
+		}
+		since = time.UnixMilli(millis)
+	}
+	changed := func(db *gorm.DB) *gorm.DB {
+		if response.Full {
+			return db
+		}
+		return db.Where("updated_at >= ?", since)
+	}
+
+	if request.Products {
+		if err := s.DB.Scopes(changed).Preload("Units").Order("id ASC").Find(&response.Products).Error; err != nil {
+			return SyncPullResponse{}, err
+		}
+		if err := s.DB.Scopes(changed).Preload("Items").Order("id ASC").Find(&response.PriceLists).Error; err != nil {
+			return SyncPullResponse{}, err
+		}
+	}
+	if request.Customers {
+		if err := s.DB.Scopes(changed).Order("id ASC").Find(&response.Customers).Error; err != nil {
+			return SyncPullResponse{}, err
+		}
+	}
+	if request.Stock && request.LocationID != nil {
+		if err := s.DB.Scopes(changed).Where("location_id = ?", *request.LocationID).
+			Order("id ASC").Find(&response.Stock).Error; err != nil {
+			return SyncPullResponse{}, err
+		}
+	}
+
+	// Records are deleted for good; the audit log is what still knows about them
+	if !response.Full {
+		sections := map[string]bool{
+			"products":    request.Products,
+			"price_lists": request.Products,
+			"customers":   request.Customers,
+		}
+		for entity, wanted := range sections {
+			if !wanted {
+				continue
+			}
+			var ids []uint
+			if err := s.DB.Model(&models.AuditLog{}).
+				Where("entity = ? AND action = ? AND created_at >= ?", entity, "delete", since).
+				Pluck("entity_id", &ids).Error; err != nil {
+				return SyncPullResponse{}, err
+			}
+			if len(ids) > 0 {
+				response.Deleted[entity] = ids
+			}
+		}
+	}
+
+	s.log(models.SyncLog{
+		DeviceID:   request.DeviceID,
+		UserID:     user.ID,
+		LocationID: request.LocationID,
+		Direction:  "pull",
+		Cursor:     request.Cursor,
+		NextCursor: response.Cursor,
+		Sent:       len(response.Products) + len(response.PriceLists) + len(response.Customers) + len(response.Stock),
+		IPAddress:  request.IPAddress,
+	})
+	return response, nil
+}
+
+// Push applies a batch recorded offline. Every record stands on its own: one that is
+// rejected doesn't hold back the others. Sales are never refused for lack of stock or
+// price, the goods have already left the van; overselling, selling under the resolved
+// price or cost, overpaying and discounts that would have needed approval are applied
+// and flagged as conflicts instead. Payments on invoices a closed van settlement has
+// locked are rejected, as they are online.
+func (s *SyncService) Push(user models.User, scope AccessScope, request SyncPushRequest, canPay bool, ipAddress string) (SyncPushResponse, error) {
+	if request.DeviceID == "" {
+		return SyncPushResponse{}, errors.New("device_id is required")
+	}
+	if err := scope.Check(request.LocationID); err != nil {
+		return SyncPushResponse{}, err
+	}
+
+	response := SyncPushResponse{Results: []SyncResult{}}
+	for _, invoice := range request.Invoices {
+		response.Results = append(response.Results, s.pushInvoice(user, request, invoice))
+	}
+	for _, payment := range request.Payments {
+		result := SyncResult{UUID: payment.UUID, Kind: "payment", Status: syncRejected,
+			Error: "missing permission payments:create"}
+		if canPay {
+			result = s.pushPayment(user, scope, request.DeviceID, payment)
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	for _, result := range response.Results {
+		switch {
+		case result.Duplicate:
+			response.Duplicates++
+		case result.Status == syncRejected:
+			response.Rejected++
+		case result.Status == models.SyncConflict:
+			response.Conflicts++
+		default:
+			response.Applied++
+		}
+	}
+	s.log(models.SyncLog{
+		DeviceID:   request.DeviceID,
+		UserID:     user.ID,
+		LocationID: &request.LocationID,
+		Direction:  "push",
+		Received:   len(response.Results),
+		Applied:    response.Applied,
+		Duplicates: response.Duplicates,
+		Conflicts:  response.Conflicts,
+		Rejected:   response.Rejected,
+		IPAddress:  ipAddress,
+	})
+	return response, nil
+}
+
+// replay returns the outcome of a UUID pushed before
+func (s *SyncService) replay(uuid, kind string) (SyncResult, bool) {
+	var record models.SyncRecord
+	if err := s.DB.Where("client_uuid = ?", uuid).Limit(1).Find(&record).Error; err != nil || record.ID == 0 {
+		return SyncResult{}, false
+	}
+	result := SyncResult{UUID: uuid, Kind: record.Kind, Status: record.Status, RecordID: record.RecordID,
+		Duplicate: true, Conflicts: record.Conflicts}
+	if record.Kind != kind {
+		result.Status = syncRejected
+		result.Error = fmt.Sprintf("uuid was already used for a %s", record.Kind)
+	}
+	return result, true
+}
+
+// finish turns the outcome of applying a record into its result. A failure may be a
+// concurrent push of the same UUID, which then counts as a replay.
+func (s *SyncService) finish(uuid, kind string, record models.SyncRecord, err error) SyncResult {
+	if err != nil {
+		if replayed, ok := s.replay(uuid, kind); ok {
+			return replayed
+		}
+		return SyncResult{UUID: uuid, Kind: kind, Status: syncRejected, Error: err.Error()}
+	}
+	return SyncResult{UUID: uuid, Kind: kind, Status: record.Status, RecordID: record.RecordID,
+		Conflicts: record.Conflicts}
+}
+
+func validateSyncUUID(uuid string) error {
+	if uuid == "" || len(uuid) > 36 {
+		return errors.New("uuid is required and at most 36 characters")
+	}
+	return nil
+}
+
+func (s *SyncService) pushInvoice(user models.User, request SyncPushRequest, in SyncInvoice) SyncResult {
+	if err := validateSyncUUID(in.UUID); err != nil {
+		return SyncResult{UUID: in.UUID, Kind: "invoice", Status: syncRejected, Error: err.Error()}
+	}
+	if replayed, ok := s.replay(in.UUID, "invoice"); ok {
+		return replayed
+	}
+
+	record := models.SyncRecord{
+		ClientUUID:      in.UUID,
+		DeviceID:        request.DeviceID,
+		UserID:          user.ID,
+		Kind:            "invoice",
+		ClientCreatedAt: in.CreatedAt,
+		Conflicts:       []string{},
+	}
+	err := Transaction(s.DB, func(tx *gorm.DB) error {
+		if len(in.Items) == 0 {
+			return errors.New("invoice must have at least one item")
+		}
+
+		var highestDiscount float64
+		var items []models.SalesInvoiceItem
+		prices := NewPriceListService(tx)
+		units := NewProductUnitService(tx)
+		for _, item := range in.Items {
+			if item.Quantity <= 0 || item.UnitPrice < 0 || item.DiscountPercent < 0 || item.DiscountPercent > 100 {
+				return fmt.Errorf("invalid line for product #%d", item.ProductID)
+			}
+			unit, err := units.Convert(item.ProductID, item.Unit, item.Quantity)
+			if err != nil {
+				return err
+			}
+			resolved, err := prices.Resolve(in.CustomerID, item.ProductID, unit.BaseQuantity())
+			if err != nil {
+				return err
+			}
+			resolved = resolved.InUnit(unit)
+			unitPrice := item.UnitPrice / unit.Factor
+			flag := resolved.Flag(unitPrice, item.DiscountPercent)
+			switch flag {
+			case PriceBelowCost:
+				record.Conflicts = append(record.Conflicts, fmt.Sprintf(
+					"product #%d sold below cost", item.ProductID))
+			case PriceBelowList:
+				record.Conflicts = append(record.Conflicts, fmt.Sprintf(
+					"product #%d sold at %.2f a %s, below its price of %.2f",
+					item.ProductID, item.UnitPrice, unit.Name, resolved.UnitPrice*unit.Factor))
+			}
+			subtotal := item.Quantity * item.UnitPrice
+			total := subtotal - subtotal*item.DiscountPercent/100
+			highestDiscount = math.Max(highestDiscount, item.DiscountPercent)
+			items = append(items, models.SalesInvoiceItem{
+				ProductID:       item.ProductID,
+				Quantity:        unit.BaseQuantity(),
+				UnitPrice:       unitPrice,
+				DiscountPercent: item.DiscountPercent,
+				Total:           total,
+				ListPrice:       resolved.UnitPrice,
+				PriceFlag:       flag,
+				Unit:            unit.Name,
+				UnitQuantity:    item.Quantity,
+			})
+		}
+		// Promotions apply as they do to invoices created online
+		if err := NewPromotionService(tx).Apply(in.CustomerID, request.LocationID, items); err != nil {
+			return err
+		}
+		var totalAmount float64
+		for _, item := range items {
+			totalAmount += item.Total
+		}
+
+		if in.PaidAmount > totalAmount {
+			record.Conflicts = append(record.Conflicts, fmt.Sprintf(
+				"paid %.2f on an invoice of %.2f", in.PaidAmount, totalAmount))
+		}
+		paymentStatus := "unpaid"
+		if in.PaidAmount >= totalAmount {
+			paymentStatus = "paid"
+		} else if in.PaidAmount > 0 {
+			paymentStatus = "partial"
+		}
+		invoice, err := NewSalesInvoiceService(models.SalesInvoice{}, tx).Create(models.SalesInvoice{
+			CustomerID:    in.CustomerID,
+			LocationID:    request.LocationID,
+			TotalAmount:   totalAmount,
+			PaidAmount:    in.PaidAmount,
+			PaymentStatus: paymentStatus,
+			PaymentMethod: in.PaymentMethod,
+			Notes:         in.Notes,
+			CreatedBy:     user.ID,
+			Items:         items,
+		})
+		if err != nil {
+			return err
+		}
+		record.RecordID = invoice.ID
+
+		stocks := NewStockService(models.Stock{}, tx)
+		locationType, locationID := stocks.GetLocationTypeAndID(request.LocationID)
+		for _, item := range items {
+			var stock models.Stock
+			if err := tx.Where("product_id = ? AND location_type = ? AND location_id = ?",
+				item.ProductID, locationType, locationID).Limit(1).Find(&stock).Error; err != nil {
+				return err
+			}
+			if stock.Quantity < item.Quantity {
+				record.Conflicts = append(record.Conflicts, fmt.Sprintf(
+					"oversold product #%d: %.2f in stock, %.2f sold", item.ProductID, stock.Quantity, item.Quantity))
+			}
+			if err := stocks.UpdateStock(item.ProductID, locationType, locationID, -item.Quantity); err != nil {
+				return err
+			}
+			notes := fmt.Sprintf("Sales Invoice #%d (offline)", invoice.ID)
+			if err := stocks.CreateMovement(item.ProductID, "sale", item.Quantity, locationType, locationID,
+				"", 0, notes, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if highestDiscount > 0 {
+			rule, err := NewApprovalService(tx).Match(ApprovalFacts{
+				Operation:  ApprovalSalesDiscount,
+				Amount:     highestDiscount,
+				LocationID: &request.LocationID,
+			})
+			if err != nil {
+				return err
+			}
+			if rule != nil {
+				record.Conflicts = append(record.Conflicts, fmt.Sprintf(
+					"%.2f%% discount given without the approval rule %q requires", highestDiscount, rule.Name))
+			}
+		}
+
+		if in.PaidAmount > 0 && in.PaymentMethod != nil {
+			_, err := NewPaymentService(models.Payment{}, tx).Create(models.Payment{
+				InvoiceID:     invoice.ID,
+				InvoiceType:   "sales",
+				CustomerID:    in.CustomerID,
+				Amount:        in.PaidAmount,
+				PaymentMethod: *in.PaymentMethod,
+				CreatedBy:     user.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		record.Status = models.SyncApplied
+		if len(record.Conflicts) > 0 {
+			record.Status = models.SyncConflict
+		}
+		return tx.Create(&record).Error
+	})
+	return s.finish(in.UUID, "invoice", record, err)
+}
+
+func (s *SyncService) pushPayment(user models.User, scope AccessScope, deviceID string, in SyncPayment) SyncResult {
+	if err := validateSyncUUID(in.UUID); err != nil {
+		return SyncResult{UUID: in.UUID, Kind: "payment", Status: syncRejected, Error: err.Error()}
+	}
+	if replayed, ok := s.replay(in.UUID, "payment"); ok {
+		return replayed
+	}
+
+	record := models.SyncRecord{
+		ClientUUID:      in.UUID,
+		DeviceID:        deviceID,
+		UserID:          user.ID,
+		Kind:            "payment",
+		ClientCreatedAt: in.CreatedAt,
+		Conflicts:       []string{},
+	}
+	err := Transaction(s.DB, func(tx *gorm.DB) error {
+		if in.Amount <= 0 {
+			return errors.New("payment amount must be greater than zero")
+		}
+		if in.PaymentMethod == "" {
+			return errors.New("payment_method is required")
+		}
+
+		var invoiceID uint
+		switch {
+		case in.InvoiceUUID != "":
+			var synced models.SyncRecord
+			if err := tx.Where("client_uuid = ? AND kind = ?", in.InvoiceUUID, "invoice").
+				Limit(1).Find(&synced).Error; err != nil {
+				return err
+			}
+			if synced.ID == 0 {
+				return fmt.Errorf("invoice %s has not been synced", in.InvoiceUUID)
+			}
+			invoiceID = synced.RecordID
+		case in.InvoiceID != nil:
+			invoiceID = *in.InvoiceID
+		default:
+			return errors.New("invoice_id or invoice_uuid is required")
+		}
+
+		invoices := NewSalesInvoiceService(models.SalesInvoice{}, tx)
+		invoice, err := invoices.GetID(scope, strconv.Itoa(int(invoiceID)))
+		if err != nil {
+			return err
+		}
+		if err := CheckSalesInvoiceEditable(invoice); err != nil {
+			return err
+		}
+		if remaining := invoice.TotalAmount - invoice.PaidAmount; in.Amount > remaining {
+			record.Conflicts = append(record.Conflicts, fmt.Sprintf(
+				"payment of %.2f exceeds the balance of invoice %s (%.2f)", in.Amount, invoice.InvoiceNumber, remaining))
+		}
+
+		payment, err := NewPaymentService(models.Payment{}, tx).Create(models.Payment{
+			InvoiceID:       invoice.ID,
+			InvoiceType:     "sales",
+			CustomerID:      invoice.CustomerID,
+			Amount:          in.Amount,
+			PaymentMethod:   in.PaymentMethod,
+			ReferenceNumber: in.ReferenceNumber,
+			Notes:           in.Notes,
+			AllocationType:  "single",
+			CreatedBy:       user.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := invoices.UpdatePaymentStatus(invoice.ID, invoice.PaidAmount+in.Amount); err != nil {
+			return err
+		}
+		record.RecordID = payment.ID
+
+		record.Status = models.SyncApplied
+		if len(record.Conflicts) > 0 {
+			record.Status = models.SyncConflict
+		}
+		return tx.Create(&record).Error
+	})
+	return s.finish(in.UUID, "payment", record, err)
+}
+
+// GetConflicts lists flagged records, unresolved ones unless all is set
+func (s *SyncService) GetConflicts(all bool, limit, page int) (PaginationResponse, error) {
+	var records []models.SyncRecord
+	var total int64
+
+	query := s.DB.Model(&models.SyncRecord{}).Where("status = ?", models.SyncConflict)
+	if !all {
+		query = query.Where("resolved_at IS NULL")
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+	offset := (page - 1) * limit
+	if err := query.Preload("User").Order("created_at DESC").Limit(limit).Offset(offset).Find(&records).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	return PaginationResponse{
+		Data:        records,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
+
+// ResolveConflict marks a flagged record as dealt with
+func (s *SyncService) ResolveConflict(userID, id uint) (models.SyncRecord, error) {
+	var record models.SyncRecord
+	if err := s.DB.Where("status = ?", models.SyncConflict).First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.SyncRecord{}, errors.New("sync conflict not found")
+		}
+		return models.SyncRecord{}, err
+	}
+	if record.ResolvedAt != nil {
+		return models.SyncRecord{}, errors.New("sync conflict is already resolved")
+	}
+	now := time.Now()
+	record.ResolvedBy = &userID
+	record.ResolvedAt = &now
+	if err := s.DB.Save(&record).Error; err != nil {
+		return models.SyncRecord{}, err
+	}
+	return record, nil
+}
+
+// GetLogs lists the pulls and pushes newest first, of one device when deviceID is set
+func (s *SyncService) GetLogs(deviceID string, limit, page int) (PaginationResponse, error) {
+	var logs []models.SyncLog
+	var total int64
+
+	query := s.DB.Model(&models.SyncLog{})
+	if deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+	offset := (page - 1) * limit
+	if err := query.Preload("User").Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	return PaginationResponse{
+		Data:        logs,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
+
+// log records a pull or push; failing to do so doesn't fail the sync
+func (s *SyncService) log(entry models.SyncLog) {
+	if err := s.DB.Create(&entry).Error; err != nil {
+		log.Printf("sync: could not log %s of device %s: %v", entry.Direction, entry.DeviceID, err)
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/gonext-tech/invoicing-system/backend/models"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+	twoFactorChallengeTTL  = 5 * time.Minute
+	minSigningKeyLength    = 32
+
+	twoFactorChallengePurpose = "two_factor"
+)
+
+var (
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrSessionRevoked      = errors.New("session has been revoked")
+)
+
+// jwtKeySet holds the HMAC keys by kid. Tokens are signed with the active key; the
+// others still verify tokens issued before a rotation until they expire.
+type jwtKeySet struct {
+	activeKID string
+	keys      map[string][]byte
+}
+
+var signingKeys *jwtKeySet
+
+// LoadJWTKeys reads the token signing keys from the environment. JWT_KEYS lists them as
+// "kid:secret,kid:secret" and JWT_ACTIVE_KID picks the one used for new tokens (default:
+// the first listed). A single JWT_SECRET is accepted as the key "default".
+func LoadJWTKeys() error {
+	set := &jwtKeySet{keys: map[string][]byte{}}
+
+	if configured := strings.TrimSpace(os.Getenv("JWT_KEYS")); configured != "" {
+		for _, entry := range strings.Split(configured, ",") {
+			kid, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || kid == "" {
+				return fmt.Errorf("JWT_KEYS entry %q must be kid:secret", entry)
+			}
+			if err := set.add(kid, secret); err != nil {
+				return err
+			}
+			if set.activeKID == "" {
+				set.activeKID = kid
+			}
+		}
+	} else if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if err := set.add("default", secret); err != nil {
+			return err
+		}
+		set.activeKID = "default"
+	} else {
+		return errors.New("no JWT signing key configured, set JWT_SECRET or JWT_KEYS")
+	}
+
+	if kid := os.Getenv("JWT_ACTIVE_KID"); kid != "" {
+		if _, ok := set.keys[kid]; !ok {
+			return fmt.Errorf("JWT_ACTIVE_KID %q is not one of JWT_KEYS", kid)
+		}
+		set.activeKID = kid
+	}
+
+	signingKeys = set
+	return nil
+}
+
+func (ks *jwtKeySet) add(kid, secret string) error {
+	if len(secret) < minSigningKeyLength {
+		return fmt.Errorf("JWT key %q must be at least %d characters", kid, minSigningKeyLength)
+	}
+	if _, exists := ks.keys[kid]; exists {
+		return fmt.Errorf("JWT key %q is listed twice", kid)
+	}
+	ks.keys[kid] = []byte(secret)
+	return nil
+}
+
+// AccessTokenTTL is how long an access token is valid (JWT_ACCESS_TTL, e.g. "15m")
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("JWT_ACCESS_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL is how long a session stays alive without a refresh (JWT_REFRESH_TTL, e.g. "720h")
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("JWT_REFRESH_TTL", defaultRefreshTokenTTL)
+}
+
+// signAccessToken issues a short-lived token for a session, signed with the active key
+func signAccessToken(user models.User, sessionID uint, expiresAt time.Time) (string, error) {
+	return signToken(&models.Claims{
+		Email:     user.Email,
+		UserID:    user.ID,
+		SessionID: sessionID,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	})
+}
+
+// signChallengeToken issues the token a user holds between entering their password and
+// their second factor. It has no session, so it can't be used as an access token.
+func signChallengeToken(user models.User, expiresAt time.Time) (string, error) {
+	return signToken(&models.Claims{
+		Email:   user.Email,
+		UserID:  user.ID,
+		Purpose: twoFactorChallengePurpose,
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	})
+}
+
+func signToken(claims *models.Claims) (string, error) {
+	if signingKeys == nil {
+		return "", errors.New("JWT signing keys are not loaded")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = signingKeys.activeKID
+	return token.SignedString(signingKeys.keys[signingKeys.activeKID])
+}
+
+func parseAccessToken(tokenString string) (*models.Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" || claims.SessionID == 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func parseChallengeToken(tokenString string) (*models.Claims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != twoFactorChallengePurpose {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// parseToken verifies a token against the key named by its kid header
+func parseToken(tokenString string) (*models.Claims, error) {
+	if signingKeys == nil {
+		return nil, errors.New("JWT signing keys are not loaded")
+	}
+	token, err := jwt.ParseWithClaims(tokenString, &models.Claims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := signingKeys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*models.Claims)
+	if !ok || !token.Valid || claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// newOpaqueToken returns a random token (refresh or password reset) and the hash stored for it
+func newOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, hashOpaqueToken(token), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
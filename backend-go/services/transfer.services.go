@@ -22,10 +22,12 @@ func NewTransferService(model models.Transfer, db *gorm.DB) *TransferService {
 	}
 }
 
-func (s *TransferService) GetALL(status, fromDate, toDate string) ([]models.Transfer, error) {
+// GetALL lists transfers leaving or entering a location in the caller's scope
+func (s *TransferService) GetALL(scope AccessScope, status, fromDate, toDate string) ([]models.Transfer, error) {
 	var transfers []models.Transfer
 	
 	query := s.db.Model(&models.Transfer{}).
+		Scopes(scope.Filter("from_location_id", "to_location_id")).
 		Preload("CreatedByUser").
 		Preload("Items").
 		Preload("Items.Product")
@@ -46,9 +48,9 @@ func (s *TransferService) GetALL(status, fromDate, toDate string) ([]models.Tran
 	return transfers, nil
 }
 
-func (s *TransferService) GetID(id string) (models.Transfer, error) {
+func (s *TransferService) GetID(scope AccessScope, id string) (models.Transfer, error) {
 	var transfer models.Transfer
-	if err := s.db.Preload("CreatedByUser").
+	if err := s.db.Scopes(scope.Filter("from_location_id", "to_location_id")).Preload("CreatedByUser").
 		Preload("Items").
 		Preload("Items.Product").
 		First(&transfer, id).Error; err != nil {
@@ -61,7 +63,16 @@ func (s *TransferService) GetID(id string) (models.Transfer, error) {
 }
 
 func (s *TransferService) Create(transfer models.Transfer) (models.Transfer, error) {
-	if err := s.db.Create(&transfer).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		if transfer.Status != "completed" {
+			return nil
+		}
+		return PublishEvent(tx, WebhookTransferCompleted, map[string]interface{}{"transfer": transfer})
+	})
+	if err != nil {
 		log.Printf("[TRANSFER SERVICE] Error creating transfer: %v", err)
 		return transfer, err
 	}
@@ -72,15 +83,22 @@ func (s *TransferService) Create(transfer models.Transfer) (models.Transfer, err
 		log.Printf("[TRANSFER SERVICE] Warning: Transfer ID is 0 after creation")
 		return transfer, errors.New("transfer created but ID is 0")
 	}
+
+	publishLive(s.db, LiveEvent{
+		Type:        LiveTransferCreated,
+		CompanyID:   transfer.CompanyID,
+		LocationIDs: []uint{transfer.FromLocationID, transfer.ToLocationID},
+		Data:        transfer,
+	})
 	
-	return s.GetID(strconv.Itoa(int(transfer.ID)))
+	return s.GetID(AccessScope{}, strconv.Itoa(int(transfer.ID)))
 }
 
 func (s *TransferService) Update(transfer models.Transfer) (models.Transfer, error) {
 	if err := s.db.Save(&transfer).Error; err != nil {
 		return transfer, err
 	}
-	return s.GetID(strconv.Itoa(int(transfer.ID)))
+	return s.GetID(AccessScope{}, strconv.Itoa(int(transfer.ID)))
 }
 
 func (s *TransferService) Delete(transfer models.Transfer) error {
@@ -90,11 +108,12 @@ func (s *TransferService) Delete(transfer models.Transfer) error {
 	return nil
 }
 
-func (s *TransferService) GetPaginated(limit, page int, orderBy, sortBy, status string) (PaginationResponse, error) {
+func (s *TransferService) GetPaginated(scope AccessScope, limit, page int, orderBy, sortBy, status string) (PaginationResponse, error) {
 	var transfers []models.Transfer
 	var total int64
 
 	query := s.db.Model(&models.Transfer{}).
+		Scopes(scope.Filter("from_location_id", "to_location_id")).
 		Preload("CreatedByUser").
 		Preload("Items").
 		Preload("Items.Product")
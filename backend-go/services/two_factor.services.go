@@ -0,0 +1,289 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+const backupCodeCount = 10
+
+var (
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorRequired    = errors.New("two-factor authentication is required for your role")
+)
+
+// TwoFactorStatus is what a user sees about their own two-factor setup
+type TwoFactorStatus struct {
+	Enabled         bool       `json:"enabled"`
+	EnabledAt       *time.Time `json:"enabled_at"`
+	Required        bool       `json:"required"`
+	BackupCodesLeft int64      `json:"backup_codes_left"`
+}
+
+// TwoFactorSetup is handed out once when enrolment starts. The URI goes into a QR code.
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorService struct {
+	DB *gorm.DB
+}
+
+func NewTwoFactorService(db *gorm.DB) *TwoFactorService {
+	return &TwoFactorService{
+		DB: db,
+	}
+}
+
+// IsRequired reports whether the user must use two-factor authentication: admins (unless
+// TWO_FACTOR_REQUIRE_ADMINS=false, they hold every permission) and holders of a role with
+// require_two_factor
+func (ts *TwoFactorService) IsRequired(user models.User) (bool, error) {
+	if IsAdminRole(user.Role) && boolFromEnv("TWO_FACTOR_REQUIRE_ADMINS", true) {
+		return true, nil
+	}
+	var roles int64
+	if err := ts.DB.Model(&models.Role{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.require_two_factor = ?", user.ID, true).
+		Count(&roles).Error; err != nil {
+		return false, err
+	}
+	return roles > 0, nil
+}
+
+// IsEnabled reports whether the user has a confirmed authenticator
+func (ts *TwoFactorService) IsEnabled(userID uint) (bool, error) {
+	var count int64
+	if err := ts.DB.Model(&models.UserTwoFactor{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL", userID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (ts *TwoFactorService) GetStatus(user models.User) (TwoFactorStatus, error) {
+	var status TwoFactorStatus
+	required, err := ts.IsRequired(user)
+	if err != nil {
+		return status, err
+	}
+	status.Required = required
+
+	var twoFactor models.UserTwoFactor
+	if err := ts.DB.Where("user_id = ? AND enabled_at IS NOT NULL", user.ID).Limit(1).Find(&twoFactor).Error; err != nil {
+		return status, err
+	}
+	if twoFactor.ID == 0 {
+		return status, nil
+	}
+	status.Enabled = true
+	status.EnabledAt = twoFactor.EnabledAt
+	err = ts.DB.Model(&models.UserBackupCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&status.BackupCodesLeft).Error
+	return status, err
+}
+
+// Setup starts enrolment with a new secret. Nothing changes for the user until Enable
+// confirms it with a code.
+func (ts *TwoFactorService) Setup(user models.User) (TwoFactorSetup, error) {
+	enabled, err := ts.IsEnabled(user.ID)
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	if enabled {
+		return TwoFactorSetup{}, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	var twoFactor models.UserTwoFactor
+	if err := ts.DB.Where("user_id = ?", user.ID).Limit(1).Find(&twoFactor).Error; err != nil {
+		return TwoFactorSetup{}, err
+	}
+	twoFactor.UserID = user.ID
+	twoFactor.Secret = secret
+	twoFactor.LastUsedStep = 0
+	if err := ts.DB.Save(&twoFactor).Error; err != nil {
+		return TwoFactorSetup{}, err
+	}
+
+	return TwoFactorSetup{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(twoFactorIssuer(), user.Email, secret),
+	}, nil
+}
+
+// Enable confirms the setup with a code from the app and returns the backup codes, the
+// only time they are shown. Sessions waiting for enrolment are released.
+func (ts *TwoFactorService) Enable(userID uint, code string) ([]string, error) {
+	var twoFactor models.UserTwoFactor
+	if err := ts.DB.Where("user_id = ? AND enabled_at IS NULL", userID).First(&twoFactor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("start the two-factor setup first")
+		}
+		return nil, err
+	}
+	step, ok := matchTOTP(twoFactor.Secret, normalizeTwoFactorCode(code), time.Now(), twoFactor.LastUsedStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err := ts.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&twoFactor).Updates(map[string]interface{}{
+			"enabled_at":     now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		if codes, err = replaceBackupCodes(tx, userID); err != nil {
+			return err
+		}
+		return tx.Model(&models.UserSession{}).Where("user_id = ? AND two_factor_pending = ?", userID, true).
+			Update("two_factor_pending", false).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a code from the app or an unused backup code. Both work only once.
+func (ts *TwoFactorService) Verify(userID uint, code string) error {
+	var twoFactor models.UserTwoFactor
+	if err := ts.DB.Where("user_id = ? AND enabled_at IS NOT NULL", userID).First(&twoFactor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTwoFactorNotEnabled
+		}
+		return err
+	}
+
+	code = normalizeTwoFactorCode(code)
+	if step, ok := matchTOTP(twoFactor.Secret, code, time.Now(), twoFactor.LastUsedStep); ok {
+		// The condition on the old step stops the same code being accepted by two requests at once
+		result := ts.DB.Model(&models.UserTwoFactor{}).
+			Where("id = ? AND last_used_step < ?", twoFactor.ID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	result := ts.DB.Model(&models.UserBackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashOpaqueToken(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// RegenerateBackupCodes replaces all backup codes after checking a current code
+func (ts *TwoFactorService) RegenerateBackupCodes(userID uint, code string) ([]string, error) {
+	if err := ts.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	return replaceBackupCodes(ts.DB, userID)
+}
+
+// Disable turns two-factor authentication off after checking a current code. Users whose
+// role requires it can't.
+func (ts *TwoFactorService) Disable(user models.User, code string) error {
+	required, err := ts.IsRequired(user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+	if err := ts.Verify(user.ID, code); err != nil {
+		return err
+	}
+	return removeTwoFactor(ts.DB, user.ID)
+}
+
+// GetCompanyUser returns the user userID of the company companyID
+func (ts *TwoFactorService) GetCompanyUser(companyID, userID uint) (models.User, error) {
+	var user models.User
+	if err := ts.DB.Where("id = ? AND company_id = ?", userID, companyID).First(&user).Error; err != nil {
+		return models.User{}, errors.New("user not found")
+	}
+	return user, nil
+}
+
+// ResetForUser removes another user's two-factor setup, e.g. after they lost their phone.
+// They have to enrol again on their next login if their role requires it.
+func (ts *TwoFactorService) ResetForUser(companyID, userID uint) error {
+	if _, err := ts.GetCompanyUser(companyID, userID); err != nil {
+		return err
+	}
+	return ts.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeTwoFactor(tx, userID); err != nil {
+			return err
+		}
+		return RevokeUserSessions(tx, userID, "two_factor_reset")
+	})
+}
+
+func removeTwoFactor(db *gorm.DB, userID uint) error {
+	if err := db.Where("user_id = ?", userID).Delete(&models.UserBackupCode{}).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userID).Delete(&models.UserTwoFactor{}).Error
+}
+
+// replaceBackupCodes stores a fresh set of backup codes and returns them in clear
+func replaceBackupCodes(db *gorm.DB, userID uint) ([]string, error) {
+	if err := db.Where("user_id = ?", userID).Delete(&models.UserBackupCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, backupCodeCount)
+	records := make([]models.UserBackupCode, backupCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := totpEncoding.EncodeToString(buf) // 8 characters
+		codes[i] = code[:4] + "-" + code[4:]
+		records[i] = models.UserBackupCode{UserID: userID, CodeHash: hashOpaqueToken(code)}
+	}
+	if err := db.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeTwoFactorCode accepts codes typed with spaces or dashes, in any case
+func normalizeTwoFactorCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// twoFactorIssuer is the account name shown in authenticator apps (TWO_FACTOR_ISSUER)
+func twoFactorIssuer() string {
+	if issuer := os.Getenv("TWO_FACTOR_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Invoicing System"
+}
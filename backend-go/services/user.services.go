@@ -99,15 +99,44 @@ func (us *UserService) Create(user models.User) (models.User, error) {
 }
 
 func (us *UserService) Update(user models.User) (models.User, error) {
+	var previous models.User
+	if err := us.DB.Select("id", "password", "status").First(&previous, user.ID).Error; err != nil {
+		return models.User{}, err
+	}
 	if result := us.DB.Model(&user).Updates(user); result.Error != nil {
 		return models.User{}, result.Error
 	}
+	// Role or location may have changed
+	InvalidateUserAccess(user.ID)
+	if err := us.revokeSessionsOnChange(previous, user); err != nil {
+		return models.User{}, err
+	}
 	return user, nil
 }
 
 func (us *UserService) UpdateToDelete(user models.User) (models.User, error) {
+	var previous models.User
+	if err := us.DB.Select("id", "password", "status").First(&previous, user.ID).Error; err != nil {
+		return models.User{}, err
+	}
 	if result := us.DB.Save(&user); result.Error != nil {
 		return models.User{}, result.Error
 	}
+	InvalidateUserAccess(user.ID)
+	if err := us.revokeSessionsOnChange(previous, user); err != nil {
+		return models.User{}, err
+	}
 	return user, nil
 }
+
+// revokeSessionsOnChange signs the user out everywhere when their password changed or
+// they were deactivated
+func (us *UserService) revokeSessionsOnChange(previous, user models.User) error {
+	switch {
+	case user.Password != "" && user.Password != previous.Password:
+		return RevokeUserSessions(us.DB, user.ID, "password_changed")
+	case user.Status != "" && user.Status != "ACTIVE" && previous.Status != user.Status:
+		return RevokeUserSessions(us.DB, user.ID, "deactivated")
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+type VanSettlementService struct {
+	db *gorm.DB
+}
+
+func NewVanSettlementService(db *gorm.DB) *VanSettlementService {
+	return &VanSettlementService{
+		db: db,
+	}
+}
+
+func (s *VanSettlementService) GetALL(limit, page int, vanID, status, fromDate, toDate string) (PaginationResponse, error) {
+	var settlements []models.VanSettlement
+	var total int64
+
+	query := s.db.Model(&models.VanSettlement{}).
+		Preload("Van").
+		Preload("Location").
+		Preload("Driver")
+
+	if vanID != "" {
+		query = query.Where("van_id = ?", vanID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if fromDate != "" && toDate != "" {
+		query = query.Where("settlement_date BETWEEN ? AND ?", fromDate, toDate)
+	}
+
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	if err := query.Order("settlement_date DESC, id DESC").Limit(limit).Offset(offset).Find(&settlements).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	return PaginationResponse{
+		Data:        settlements,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  totalPages,
+	}, nil
+}
+
+func (s *VanSettlementService) GetID(id string) (models.VanSettlement, error) {
+	var settlement models.VanSettlement
+	if err := s.db.Preload("Van").
+		Preload("Location").
+		Preload("Driver").
+		Preload("MethodTotals").
+		Preload("Denominations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("denomination DESC")
+		}).
+		First(&settlement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return settlement, errors.New("settlement not found")
+		}
+		return settlement, err
+	}
+	return settlement, nil
+}
+
+// GetInvoices lists the sales invoices a settlement covers
+func (s *VanSettlementService) GetInvoices(id string) ([]models.SalesInvoice, error) {
+	settlement, err := s.GetID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var invoices []models.SalesInvoice
+	if err := s.settlementInvoices(s.db, settlement).
+		Preload("Customer").
+		Preload("CreatedByUser").
+		Order("created_at ASC").
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// Create opens the settlement of a van for a day and computes what the driver should hand in
+func (s *VanSettlementService) Create(vanID uint, date time.Time, driverID *uint, createdBy uint) (models.VanSettlement, error) {
+	var location models.Location
+	if err := s.db.Where("van_id = ? AND deleted_at IS NULL", vanID).First(&location).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.VanSettlement{}, errors.New("van has no location assigned")
+		}
+		return models.VanSettlement{}, err
+	}
+
+	day := startOfDay(date)
+	var count int64
+	s.db.Model(&models.VanSettlement{}).Where("van_id = ? AND settlement_date = ?", vanID, day.Format("2006-01-02")).Count(&count)
+	if count > 0 {
+		return models.VanSettlement{}, fmt.Errorf("a settlement for this van on %s already exists", day.Format("2006-01-02"))
+	}
+
+	// Default the driver to the user assigned to the van's location
+	if driverID == nil {
+		var driver models.User
+		if err := s.db.Where("location_id = ?", location.ID).Order("id ASC").First(&driver).Error; err == nil {
+			driverID = &driver.ID
+		}
+	}
+
+	settlement := models.VanSettlement{
+		VanID:          vanID,
+		LocationID:     location.ID,
+		DriverID:       driverID,
+		SettlementDate: day,
+		Status:         "open",
+		CreatedBy:      createdBy,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&settlement).Error; err != nil {
+			return err
+		}
+		return s.computeExpected(tx, &settlement)
+	})
+	if err != nil {
+		return settlement, err
+	}
+	return s.GetID(strconv.Itoa(int(settlement.ID)))
+}
+
+// Refresh recomputes the expected amounts of an open settlement
+func (s *VanSettlementService) Refresh(id string) (models.VanSettlement, error) {
+	settlement, err := s.GetID(id)
+	if err != nil {
+		return settlement, err
+	}
+	if settlement.Status == "closed" {
+		return settlement, errors.New("settlement is already closed")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.computeExpected(tx, &settlement)
+	})
+	if err != nil {
+		return settlement, err
+	}
+	return s.GetID(id)
+}
+
+// RecordCount stores the counted cash per denomination, replacing any earlier count
+func (s *VanSettlementService) RecordCount(id string, denominations []models.VanSettlementDenomination, notes *string) (models.VanSettlement, error) {
+	settlement, err := s.GetID(id)
+	if err != nil {
+		return settlement, err
+	}
+	if settlement.Status == "closed" {
+		return settlement, errors.New("settlement is already closed")
+	}
+
+	counted := 0.0
+	for i := range denominations {
+		if denominations[i].Denomination <= 0 || denominations[i].Quantity < 0 {
+			return settlement, errors.New("denominations must be positive and quantities cannot be negative")
+		}
+		denominations[i].ID = 0
+		denominations[i].SettlementID = settlement.ID
+		denominations[i].Total = roundAmount(denominations[i].Denomination * float64(denominations[i].Quantity))
+		counted += denominations[i].Total
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("settlement_id = ?", settlement.ID).Delete(&models.VanSettlementDenomination{}).Error; err != nil {
+			return err
+		}
+		if len(denominations) > 0 {
+			if err := tx.Create(&denominations).Error; err != nil {
+				return err
+			}
+		}
+
+		settlement.CountedCash = roundAmount(counted)
+		applyDifference(&settlement)
+		updates := map[string]interface{}{
+			"counted_cash":    settlement.CountedCash,
+			"difference":      settlement.Difference,
+			"difference_type": settlement.DifferenceType,
+		}
+		if notes != nil {
+			updates["notes"] = notes
+		}
+		return tx.Model(&models.VanSettlement{}).Where("id = ?", settlement.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return settlement, err
+	}
+	return s.GetID(id)
+}
+
+// Close finalises the settlement: the expected amounts are recomputed, the difference is posted
+// against the driver and the van cash box, and the day's invoices are locked from further edits.
+func (s *VanSettlementService) Close(id string, closedBy uint) (models.VanSettlement, error) {
+	settlement, err := s.GetID(id)
+	if err != nil {
+		return settlement, err
+	}
+	if settlement.Status == "closed" {
+		return settlement, errors.New("settlement is already closed")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the settlement first: a concurrent close blocks on the row and then finds
+		// it closed, so variances and cash adjustments are posted once
+		now := time.Now()
+		claimed := tx.Model(&models.VanSettlement{}).Where("id = ? AND status <> ?", settlement.ID, "closed").Updates(map[string]interface{}{
+			"status":    "closed",
+			"closed_by": closedBy,
+			"closed_at": &now,
+		})
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			return errors.New("settlement is already closed")
+		}
+		// Re-read under the lock for the latest count
+		if err := tx.First(&settlement, settlement.ID).Error; err != nil {
+			return err
+		}
+
+		if err := s.computeExpected(tx, &settlement); err != nil {
+			return err
+		}
+
+		if settlement.Difference != 0 {
+			if settlement.DriverID == nil {
+				return errors.New("assign a driver before closing a settlement with a difference")
+			}
+			variance := models.DriverCashVariance{
+				UserID:       *settlement.DriverID,
+				SettlementID: settlement.ID,
+				Type:         settlement.DifferenceType,
+				Amount:       settlement.Difference,
+				VarianceDate: settlement.SettlementDate,
+			}
+			if err := tx.Create(&variance).Error; err != nil {
+				return err
+			}
+
+			// Bring the cash box in line with the cash actually handed in
+			cashBox, err := ensureCashBox(tx, settlement.LocationID)
+			if err != nil {
+				return err
+			}
+			if _, err := postAccountTransaction(tx, models.MoneyAccountTransaction{
+				AccountID:       cashBox.ID,
+				Type:            "adjustment",
+				Amount:          settlement.Difference,
+				TransactionDate: time.Now(),
+				Description:     fmt.Sprintf("Settlement #%d %s (%s)", settlement.ID, settlement.DifferenceType, settlement.SettlementDate.Format("2006-01-02")),
+				CreatedBy:       closedBy,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return s.settlementInvoices(tx, settlement).
+			Update("settlement_id", settlement.ID).Error
+	})
+	if err != nil {
+		return settlement, err
+	}
+	return s.GetID(id)
+}
+
+// Delete removes a settlement that has not been closed
+func (s *VanSettlementService) Delete(settlement models.VanSettlement) error {
+	if settlement.Status == "closed" {
+		return errors.New("closed settlements cannot be deleted")
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("settlement_id = ?", settlement.ID).Delete(&models.VanSettlementMethodTotal{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("settlement_id = ?", settlement.ID).Delete(&models.VanSettlementDenomination{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.VanSettlement{}, settlement.ID).Error
+	})
+}
+
+// GetDriverVariances lists the shortages and overages posted against a driver
+func (s *VanSettlementService) GetDriverVariances(userID string) ([]models.DriverCashVariance, error) {
+	var variances []models.DriverCashVariance
+	if err := s.db.Preload("Settlement").Preload("Settlement.Van").
+		Where("user_id = ?", userID).
+		Order("variance_date DESC").
+		Find(&variances).Error; err != nil {
+		return nil, err
+	}
+	return variances, nil
+}
+
+// computeExpected totals the invoices and payments created by the van's users on the settlement day
+func (s *VanSettlementService) computeExpected(tx *gorm.DB, settlement *models.VanSettlement) error {
+	from, to := dayRange(settlement.SettlementDate)
+	users := s.locationUsers(tx, settlement.LocationID)
+
+	var invoiceTotals struct {
+		Count int
+		Total float64
+	}
+	if err := s.settlementInvoices(tx, *settlement).
+		Select("COUNT(*) AS count, COALESCE(SUM(total_amount), 0) AS total").
+		Scan(&invoiceTotals).Error; err != nil {
+		return err
+	}
+
+	var methodTotals []models.VanSettlementMethodTotal
+	if err := tx.Model(&models.Payment{}).
+		Select("payment_method, COUNT(*) AS payment_count, COALESCE(SUM(amount), 0) AS amount").
+		Where("created_by IN (?) AND created_at >= ? AND created_at < ?", users, from, to).
+		Where("invoice_type <> ?", "purchase").
+		Group("payment_method").
+		Scan(&methodTotals).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("settlement_id = ?", settlement.ID).Delete(&models.VanSettlementMethodTotal{}).Error; err != nil {
+		return err
+	}
+
+	settlement.ExpectedTotal = 0
+	settlement.ExpectedCash = 0
+	for i := range methodTotals {
+		methodTotals[i].SettlementID = settlement.ID
+		methodTotals[i].Amount = roundAmount(methodTotals[i].Amount)
+		settlement.ExpectedTotal += methodTotals[i].Amount
+		if methodTotals[i].PaymentMethod == "cash" {
+			settlement.ExpectedCash += methodTotals[i].Amount
+		}
+	}
+	if len(methodTotals) > 0 {
+		if err := tx.Create(&methodTotals).Error; err != nil {
+			return err
+		}
+	}
+
+	settlement.InvoiceCount = invoiceTotals.Count
+	settlement.SalesTotal = roundAmount(invoiceTotals.Total)
+	settlement.ExpectedTotal = roundAmount(settlement.ExpectedTotal)
+	settlement.ExpectedCash = roundAmount(settlement.ExpectedCash)
+	applyDifference(settlement)
+
+	return tx.Model(&models.VanSettlement{}).Where("id = ?", settlement.ID).Updates(map[string]interface{}{
+		"invoice_count":   settlement.InvoiceCount,
+		"sales_total":     settlement.SalesTotal,
+		"expected_total":  settlement.ExpectedTotal,
+		"expected_cash":   settlement.ExpectedCash,
+		"difference":      settlement.Difference,
+		"difference_type": settlement.DifferenceType,
+	}).Error
+}
+
+// settlementInvoices scopes sales invoices to those created by the van's users on the settlement day
+func (s *VanSettlementService) settlementInvoices(tx *gorm.DB, settlement models.VanSettlement) *gorm.DB {
+	from, to := dayRange(settlement.SettlementDate)
+	return tx.Model(&models.SalesInvoice{}).
+		Where("created_by IN (?) AND created_at >= ? AND created_at < ?", s.locationUsers(tx, settlement.LocationID), from, to).
+		Where("deleted_at IS NULL")
+}
+
+func (s *VanSettlementService) locationUsers(tx *gorm.DB, locationID uint) *gorm.DB {
+	return tx.Model(&models.User{}).Select("id").Where("location_id = ?", locationID)
+}
+
+// applyDifference compares counted against expected cash
+func applyDifference(settlement *models.VanSettlement) {
+	settlement.Difference = roundAmount(settlement.CountedCash - settlement.ExpectedCash)
+	switch {
+	case settlement.Difference < 0:
+		settlement.DifferenceType = "shortage"
+	case settlement.Difference > 0:
+		settlement.DifferenceType = "overage"
+	default:
+		settlement.DifferenceType = "balanced"
+	}
+}
+
+// startOfDay returns midnight of the given day
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dayRange returns [midnight, next midnight) for the given day
+func dayRange(t time.Time) (time.Time, time.Time) {
+	from := startOfDay(t)
+	return from, from.AddDate(0, 0, 1)
+}
@@ -0,0 +1,496 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gonext-tech/invoicing-system/backend/models"
+	"gorm.io/gorm"
+)
+
+// Webhook events
+const (
+	WebhookInvoiceCreated     = "invoice.created"
+	WebhookPaymentReceived    = "payment.received"
+	WebhookStockLow           = "stock.low"
+	WebhookTransferCompleted  = "transfer.completed"
+	WebhookCreditNoteApproved = "credit_note.approved"
+)
+
+// WebhookEvents describes the events subscriptions can listen to
+var WebhookEvents = map[string]string{
+	WebhookInvoiceCreated:     "A sales or purchase invoice was created",
+	WebhookPaymentReceived:    "A customer payment was recorded",
+	WebhookStockLow:           "A product's stock at a location fell to its minimum level",
+	WebhookTransferCompleted:  "A stock transfer was completed",
+	WebhookCreditNoteApproved: "A credit note was approved",
+}
+
+const (
+	webhookSecretPrefix = "whsec_"
+	// maxWebhookResponse caps the part of a response kept in the delivery log
+	maxWebhookResponse = 4 * 1024
+)
+
+// PublishEvent writes a business event to the outbox. Call it with the transaction of
+// the change, so the event exists exactly when the change does. Nothing is written when
+// no active subscription listens to the event.
+func PublishEvent(tx *gorm.DB, event string, data interface{}) error {
+	var subscribers int64
+	if err := tx.Model(&models.WebhookSubscription{}).
+		Where("is_active = ? AND events LIKE ?", true, `%"`+event+`"%`).
+		Count(&subscribers).Error; err != nil {
+		return err
+	}
+	if subscribers == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{Event: event, Payload: string(payload)}).Error
+}
+
+// SignWebhook is the X-Webhook-Signature of a request: "sha256=" and the hex HMAC-SHA256
+// of "<timestamp>.<body>" keyed with the subscription secret. Receivers should recompute
+// it and reject old timestamps.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookInput creates or changes a subscription. RotateSecret issues a new secret.
+type WebhookInput struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url"`
+	Events       []string `json:"events"`
+	IsActive     *bool    `json:"is_active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// WebhookService manages the company's subscriptions and delivery log. Sending is done
+// by WebhookDispatcher.
+type WebhookService struct {
+	DB *gorm.DB
+}
+
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{
+		DB: db,
+	}
+}
+
+func (s *WebhookService) GetAll() ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := s.DB.Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (s *WebhookService) GetID(id uint) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := s.DB.First(&subscription, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.WebhookSubscription{}, errors.New("webhook not found")
+		}
+		return models.WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+// Create adds a subscription. Its signing secret is returned only here and on rotation.
+func (s *WebhookService) Create(input WebhookInput) (models.WebhookSubscription, string, error) {
+	if err := validateWebhook(input); err != nil {
+		return models.WebhookSubscription{}, "", err
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return models.WebhookSubscription{}, "", err
+	}
+	subscription := models.WebhookSubscription{
+		Name:     input.Name,
+		URL:      input.URL,
+		Secret:   secret,
+		Events:   input.Events,
+		IsActive: input.IsActive == nil || *input.IsActive,
+	}
+	if err := s.DB.Create(&subscription).Error; err != nil {
+		return models.WebhookSubscription{}, "", err
+	}
+	return subscription, secret, nil
+}
+
+// Update changes a subscription; the new secret is returned when it was rotated
+func (s *WebhookService) Update(id uint, input WebhookInput) (models.WebhookSubscription, string, error) {
+	subscription, err := s.GetID(id)
+	if err != nil {
+		return models.WebhookSubscription{}, "", err
+	}
+	if err := validateWebhook(input); err != nil {
+		return models.WebhookSubscription{}, "", err
+	}
+
+	subscription.Name = input.Name
+	subscription.URL = input.URL
+	subscription.Events = input.Events
+	if input.IsActive != nil {
+		subscription.IsActive = *input.IsActive
+	}
+	var secret string
+	if input.RotateSecret {
+		if secret, err = newWebhookSecret(); err != nil {
+			return models.WebhookSubscription{}, "", err
+		}
+		subscription.Secret = secret
+	}
+	if err := s.DB.Save(&subscription).Error; err != nil {
+		return models.WebhookSubscription{}, "", err
+	}
+	return subscription, secret, nil
+}
+
+// Delete removes a subscription and gives up its pending deliveries. The delivery log
+// is kept.
+func (s *WebhookService) Delete(id uint) error {
+	subscription, err := s.GetID(id)
+	if err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.WebhookDelivery{}).
+			Where("subscription_id = ? AND status = ?", subscription.ID, models.WebhookDeliveryPending).
+			Updates(map[string]interface{}{"status": models.WebhookDeliveryFailed, "error": "webhook deleted", "next_attempt_at": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&subscription).Error
+	})
+}
+
+// GetDeliveries lists the delivery log newest first. Filters: subscription_id, event
+// and status.
+func (s *WebhookService) GetDeliveries(limit, page int, filters map[string]string) (PaginationResponse, error) {
+	var deliveries []models.WebhookDelivery
+	var total int64
+
+	query := s.DB.Model(&models.WebhookDelivery{})
+	for _, column := range []string{"subscription_id", "event", "status"} {
+		if value := filters[column]; value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		return PaginationResponse{}, err
+	}
+
+	return PaginationResponse{
+		Data:        deliveries,
+		Total:       int(total),
+		CurrentPage: page,
+		PerPage:     limit,
+		TotalPages:  int(math.Ceil(float64(total) / float64(limit))),
+	}, nil
+}
+
+func (s *WebhookService) GetDelivery(id uint) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := s.DB.Preload("Subscription").Preload("OutboxEvent").First(&delivery, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.WebhookDelivery{}, errors.New("webhook delivery not found")
+		}
+		return models.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// Redeliver queues the delivery's event for its subscription again, as a new delivery
+// that is sent straight away
+func (s *WebhookService) Redeliver(id uint) (models.WebhookDelivery, error) {
+	delivery, err := s.GetDelivery(id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		return models.WebhookDelivery{}, errors.New("delivery is still pending")
+	}
+	if delivery.Subscription == nil {
+		return models.WebhookDelivery{}, errors.New("webhook no longer exists")
+	}
+
+	now := time.Now()
+	redelivery := models.WebhookDelivery{
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		Event:          delivery.Event,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  &now,
+		RedeliveryOf:   &delivery.ID,
+	}
+	if err := s.DB.Create(&redelivery).Error; err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return redelivery, nil
+}
+
+func validateWebhook(input WebhookInput) error {
+	if input.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := validateOutboundURL(input.URL); err != nil {
+		return err
+	}
+	if len(input.Events) == 0 {
+		return errors.New("at least one event is required")
+	}
+	for _, event := range input.Events {
+		if _, ok := WebhookEvents[event]; !ok {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	token, _, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + token, nil
+}
+
+// WebhookDispatcher turns outbox events into deliveries and sends them. Failed attempts
+// are retried after WEBHOOK_RETRY_BASE, doubling each time up to WEBHOOK_RETRY_MAX,
+// until WEBHOOK_MAX_ATTEMPTS. Endpoints must be public addresses and redirects aren't
+// followed (a 3xx counts as a failure). Rows are claimed with conditional updates, so
+// several instances can run side by side.
+type WebhookDispatcher struct {
+	DB           *gorm.DB
+	Client       *http.Client
+	PollInterval time.Duration
+	MaxAttempts  int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+}
+
+// NewWebhookDispatcher works on db across companies; it must not be a company-scoped handle
+func NewWebhookDispatcher(db *gorm.DB) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		DB:           db,
+		Client:       newOutboundClient(durationFromEnv("WEBHOOK_TIMEOUT", 10*time.Second)),
+		PollInterval: durationFromEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		MaxAttempts:  intFromEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+		RetryBase:    durationFromEnv("WEBHOOK_RETRY_BASE", 30*time.Second),
+		RetryMax:     durationFromEnv("WEBHOOK_RETRY_MAX", 6*time.Hour),
+	}
+}
+
+// Start runs the dispatcher in the background for the life of the process
+func (d *WebhookDispatcher) Start() {
+	go func() {
+		ticker := time.NewTicker(d.PollInterval)
+		defer ticker.Stop()
+		for {
+			d.RunOnce()
+			<-ticker.C
+		}
+	}()
+}
+
+// RunOnce fans out new outbox events and sends the deliveries that are due
+func (d *WebhookDispatcher) RunOnce() {
+	if err := d.fanOut(); err != nil {
+		log.Printf("webhooks: could not dispatch outbox events: %v", err)
+	}
+	if err := d.deliverDue(); err != nil {
+		log.Printf("webhooks: could not send deliveries: %v", err)
+	}
+}
+
+// fanOut creates a delivery per subscription listening to each new outbox event
+func (d *WebhookDispatcher) fanOut() error {
+	var events []models.OutboxEvent
+	if err := d.DB.Where("dispatched_at IS NULL").Order("id ASC").Limit(100).Find(&events).Error; err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		err := d.DB.Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+			claimed := tx.Model(&models.OutboxEvent{}).
+				Where("id = ? AND dispatched_at IS NULL", event.ID).
+				Update("dispatched_at", now)
+			if claimed.Error != nil || claimed.RowsAffected == 0 {
+				return claimed.Error
+			}
+
+			var subscriptions []models.WebhookSubscription
+			if err := tx.Where("company_id = ? AND is_active = ?", event.CompanyID, true).
+				Find(&subscriptions).Error; err != nil {
+				return err
+			}
+			for _, subscription := range subscriptions {
+				if !subscribesTo(subscription, event.Event) {
+					continue
+				}
+				delivery := models.WebhookDelivery{
+					CompanyID:      event.CompanyID,
+					SubscriptionID: subscription.ID,
+					EventID:        event.ID,
+					Event:          event.Event,
+					Status:         models.WebhookDeliveryPending,
+					NextAttemptAt:  &now,
+				}
+				if err := tx.Create(&delivery).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func subscribesTo(subscription models.WebhookSubscription, event string) bool {
+	for _, name := range subscription.Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *WebhookDispatcher) deliverDue() error {
+	var due []models.WebhookDelivery
+	if err := d.DB.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+		Order("next_attempt_at ASC").Limit(50).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, delivery := range due {
+		// Push the next attempt out while this one runs so no other instance takes it
+		lease := time.Now().Add(d.Client.Timeout + time.Minute)
+		claimed := d.DB.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, models.WebhookDeliveryPending, delivery.NextAttemptAt).
+			Update("next_attempt_at", lease)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected == 0 {
+			continue
+		}
+		if err := d.attempt(delivery); err != nil {
+			log.Printf("webhooks: could not record attempt of delivery #%d: %v", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+// attempt sends the delivery once and records the outcome
+func (d *WebhookDispatcher) attempt(delivery models.WebhookDelivery) error {
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_attempt_at": now,
+	}
+
+	statusCode, responseBody, err := d.send(delivery)
+	updates["response_status"] = statusCode
+	updates["response_body"] = responseBody
+	switch {
+	case err == nil:
+		updates["status"] = models.WebhookDeliverySucceeded
+		updates["error"] = ""
+		updates["next_attempt_at"] = nil
+	case attempts >= d.MaxAttempts || errors.Is(err, errWebhookGone):
+		updates["status"] = models.WebhookDeliveryFailed
+		updates["error"] = err.Error()
+		updates["next_attempt_at"] = nil
+	default:
+		updates["error"] = err.Error()
+		updates["next_attempt_at"] = now.Add(d.backoff(attempts))
+	}
+	return d.DB.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error
+}
+
+// backoff is the wait after the given number of failed attempts
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	wait := float64(d.RetryBase) * math.Pow(2, float64(attempts-1))
+	if wait > float64(d.RetryMax) {
+		return d.RetryMax
+	}
+	return time.Duration(wait)
+}
+
+var errWebhookGone = errors.New("webhook was deleted or disabled")
+
+func (d *WebhookDispatcher) send(delivery models.WebhookDelivery) (int, string, error) {
+	var subscription models.WebhookSubscription
+	if err := d.DB.Where("id = ? AND is_active = ?", delivery.SubscriptionID, true).
+		Limit(1).Find(&subscription).Error; err != nil {
+		return 0, "", err
+	}
+	if subscription.ID == 0 {
+		return 0, "", errWebhookGone
+	}
+	var event models.OutboxEvent
+	if err := d.DB.First(&event, delivery.EventID).Error; err != nil {
+		return 0, "", err
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         event.ID,
+		"event":      event.Event,
+		"company_id": event.CompanyID,
+		"created_at": event.CreatedAt,
+		"data":       json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	request, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "invoicing-system-webhooks")
+	request.Header.Set("X-Webhook-Id", strconv.FormatUint(uint64(event.ID), 10))
+	request.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	request.Header.Set("X-Webhook-Event", event.Event)
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", SignWebhook(subscription.Secret, timestamp, body))
+
+	response, err := d.Client.Do(request)
+	if err != nil {
+		return 0, "", err
+	}
+	defer response.Body.Close()
+	responseBody, _ := io.ReadAll(io.LimitReader(response.Body, maxWebhookResponse))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, string(responseBody), fmt.Errorf("endpoint answered %s", response.Status)
+	}
+	return response.StatusCode, string(responseBody), nil
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxXLSXPartSize limits how much of a single part of the workbook is unpacked
+const maxXLSXPartSize = 50 << 20
+
+// maxXLSXColumns is the number of columns of a worksheet, up to XFD
+const maxXLSXColumns = 16384
+
+// ReadXLSX returns the cells of the first worksheet of an XLSX workbook as text, one
+// slice per row. Empty rows are kept so that row i is spreadsheet row i+1. It reads
+// values only: shared and inline strings, numbers and booleans, no formatting. A file
+// with rows past maxRows, or cells past column XFD, is refused.
+func ReadXLSX(data []byte, maxRows int) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("not a valid XLSX file")
+	}
+	parts := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+
+	var shared []string
+	if file, ok := parts["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := readXLSXPart(file, &sst); err != nil {
+			return nil, err
+		}
+		for _, item := range sst.Items {
+			shared = append(shared, item.String())
+		}
+	}
+
+	sheetName, err := firstWorksheet(parts)
+	if err != nil {
+		return nil, err
+	}
+	var sheet struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string    `xml:"r,attr"`
+				Type   string    `xml:"t,attr"`
+				Value  string    `xml:"v"`
+				Inline *xlsxText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := readXLSXPart(parts[sheetName], &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		number := row.Number
+		if number <= 0 {
+			number = len(rows) + 1
+		}
+		if number > maxRows {
+			return nil, errors.New("not a valid XLSX file")
+		}
+		for len(rows) < number {
+			rows = append(rows, nil)
+		}
+		var cells []string
+		for _, cell := range row.Cells {
+			column := xlsxColumn(cell.Ref)
+			if column < 0 {
+				column = len(cells)
+			}
+			if column >= maxXLSXColumns {
+				return nil, errors.New("not a valid XLSX file")
+			}
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+			switch cell.Type {
+			case "s":
+				if index, err := strconv.Atoi(cell.Value); err == nil && index >= 0 && index < len(shared) {
+					cells[column] = shared[index]
+				}
+			case "inlineStr":
+				if cell.Inline != nil {
+					cells[column] = cell.Inline.String()
+				}
+			case "b":
+				cells[column] = map[string]string{"1": "true", "0": "false"}[cell.Value]
+			default:
+				cells[column] = cell.Value
+			}
+		}
+		rows[number-1] = cells
+	}
+	return rows, nil
+}
+
+// xlsxText is a string item: plain text, or runs of rich text
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	text := t.Text
+	for _, run := range t.Runs {
+		text += run.Text
+	}
+	return text
+}
+
+// firstWorksheet finds the part holding the first sheet of the workbook
+func firstWorksheet(parts map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if file, ok := parts["xl/workbook.xml"]; ok && readXLSXPart(file, &workbook) == nil && len(workbook.Sheets) > 0 {
+		if file, ok := parts["xl/_rels/workbook.xml.rels"]; ok && readXLSXPart(file, &rels) == nil {
+			for _, rel := range rels.Relationships {
+				if rel.ID != workbook.Sheets[0].ID {
+					continue
+				}
+				name := strings.TrimPrefix(rel.Target, "/")
+				if !strings.HasPrefix(name, "xl/") {
+					name = path.Join("xl", name)
+				}
+				if _, ok := parts[name]; ok {
+					return name, nil
+				}
+			}
+		}
+	}
+	if _, ok := parts["xl/worksheets/sheet1.xml"]; ok {
+		return "xl/worksheets/sheet1.xml", nil
+	}
+	return "", errors.New("XLSX file has no worksheet")
+}
+
+func readXLSXPart(file *zip.File, v interface{}) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(io.LimitReader(reader, maxXLSXPartSize)).Decode(v); err != nil {
+		return errors.New("could not read XLSX part " + file.Name)
+	}
+	return nil
+}
+
+// xlsxColumn turns the letters of a cell reference (e.g. "AB12") into a column index,
+// -1 without any. Column references have at most three letters; longer ones give
+// maxXLSXColumns, past the last column.
+func xlsxColumn(ref string) int {
+	column := 0
+	for i, char := range ref {
+		if char < 'A' || char > 'Z' {
+			break
+		}
+		if i == 3 {
+			return maxXLSXColumns
+		}
+		column = column*26 + int(char-'A'+1)
+	}
+	return column - 1
+}